@@ -0,0 +1,244 @@
+/*
+	Copyright (c) 2026 Stratux contributors
+	Distributable under the terms of The "BSD New" License
+	that can be found in the LICENSE file, herein included
+	as part of this header.
+
+	asterix.go: EUROCONTROL ASTERIX encoder and decoder for CAT021 (ADS-B target reports, edition 2.x) and CAT023
+		(CNS/ATM ground station and service status, edition 1.2). Only the data items Stratux has data for are
+		encoded. The decoder reads everything the encoder writes and skips the other fixed and extended length items.
+		See EUROCONTROL-SPEC-0149-12 (CAT021) and EUROCONTROL-SPEC-0149-15 (CAT023).
+*/
+
+package asterix
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"time"
+)
+
+const (
+	CAT021 = 21
+	CAT023 = 23
+
+	TIME_RES    = 1.0 / 128.0             // seconds, "time of day" items
+	LAT_LNG_RES = 180.0 / (1 << 23)       // I021/130
+	HIRES_RES   = 180.0 / (1 << 30)       // I021/131
+	SPEED_RES   = 3600.0 / (1 << 14)      // knots, I021/160 (2^-14 NM/s)
+	ANGLE_RES   = 360.0 / (1 << 16)       // degrees
+	VVEL_RES    = 6.25                    // ft/min, I021/155 and I021/157
+	HEIGHT_RES  = 6.25                    // ft, I021/140
+	FL_RES      = 25.0                    // ft, I021/145 (1/4 FL)
+	MIDNIGHT    = 24 * 60 * 60 / TIME_RES // time of day wraps here
+)
+
+var (
+	ErrShort           = errors.New("asterix: data block too short")
+	ErrLength          = errors.New("asterix: invalid data block length")
+	ErrUnknownCategory = errors.New("asterix: unsupported category")
+	ErrUnsupportedItem = errors.New("asterix: unsupported data item")
+)
+
+// Item formats of a User Application Profile, indexed by FRN-1
+const (
+	itemFixed       = iota // len bytes
+	itemExtended           // len bytes, then one more octet as long as the FX bit of the last octet is set
+	itemRepetitive         // REP octet, then REP times len bytes
+	itemExplicit           // first octet is the length including itself (RE and SP fields)
+	itemUnsupported        // compound items we don't decode
+)
+
+type itemFormat struct {
+	format int
+	len    int
+}
+
+// Data block: CAT, LEN (including CAT and LEN) and the records, which must all be of the same category.
+func Block(cat uint8, records ...[]byte) []byte {
+	block := []byte{cat, 0, 0}
+	for _, r := range records {
+		block = append(block, r...)
+	}
+	binary.BigEndian.PutUint16(block[1:], uint16(len(block)))
+	return block
+}
+
+// Splits a datagram or stream buffer into data blocks. rest is an incomplete block at the end of buf.
+func Split(buf []byte) (blocks [][]byte, rest []byte, err error) {
+	for len(buf) >= 3 {
+		l := int(binary.BigEndian.Uint16(buf[1:]))
+		if l < 3 {
+			return blocks, nil, ErrLength
+		}
+		if l > len(buf) {
+			break
+		}
+		blocks = append(blocks, buf[:l])
+		buf = buf[l:]
+	}
+	return blocks, buf, nil
+}
+
+// Decodes a data block into its records: *Cat021Report or *Cat023Report.
+func Decode(block []byte) (cat uint8, records []interface{}, err error) {
+	if len(block) < 3 {
+		return 0, nil, ErrShort
+	}
+	cat = block[0]
+	l := int(binary.BigEndian.Uint16(block[1:]))
+	if l < 3 || l > len(block) {
+		return cat, nil, ErrLength
+	}
+	data := block[3:l]
+	for len(data) > 0 {
+		var rec interface{}
+		var n int
+		switch cat {
+		case CAT021:
+			rec, n, err = decodeCat021(data)
+		case CAT023:
+			rec, n, err = decodeCat023(data)
+		default:
+			return cat, nil, ErrUnknownCategory
+		}
+		if err != nil {
+			return cat, records, err
+		}
+		records = append(records, rec)
+		data = data[n:]
+	}
+	return cat, records, nil
+}
+
+// Builds a record. Items must be added in FRN order.
+type recordWriter struct {
+	fspec []byte
+	data  []byte
+}
+
+func (w *recordWriter) add(frn int, item ...byte) {
+	idx := (frn - 1) / 7
+	for len(w.fspec) <= idx {
+		if len(w.fspec) > 0 {
+			w.fspec[len(w.fspec)-1] |= 1 // FX
+		}
+		w.fspec = append(w.fspec, 0)
+	}
+	w.fspec[idx] |= 0x80 >> uint((frn-1)%7)
+	w.data = append(w.data, item...)
+}
+
+func (w *recordWriter) bytes() []byte {
+	return append(w.fspec, w.data...)
+}
+
+// Reads the FSPEC at the start of a record and returns the FRNs present, in order.
+func readFSPEC(buf []byte) (frns []int, n int, err error) {
+	for {
+		if n >= len(buf) {
+			return nil, 0, ErrShort
+		}
+		b := buf[n]
+		for bit := 0; bit < 7; bit++ {
+			if b&(0x80>>uint(bit)) != 0 {
+				frns = append(frns, n*7+bit+1)
+			}
+		}
+		n++
+		if b&1 == 0 {
+			return frns, n, nil
+		}
+	}
+}
+
+// Length of the item at the start of buf
+func itemLength(f itemFormat, buf []byte) (int, error) {
+	switch f.format {
+	case itemFixed:
+		if len(buf) < f.len {
+			return 0, ErrShort
+		}
+		return f.len, nil
+	case itemExtended:
+		n := f.len
+		for {
+			if len(buf) < n {
+				return 0, ErrShort
+			}
+			if buf[n-1]&1 == 0 {
+				return n, nil
+			}
+			n++
+		}
+	case itemRepetitive:
+		if len(buf) < 1 || len(buf) < 1+int(buf[0])*f.len {
+			return 0, ErrShort
+		}
+		return 1 + int(buf[0])*f.len, nil
+	case itemExplicit:
+		if len(buf) < 1 || buf[0] < 1 || len(buf) < int(buf[0]) {
+			return 0, ErrShort
+		}
+		return int(buf[0]), nil
+	}
+	return 0, ErrUnsupportedItem
+}
+
+// Splits a record into its items, keyed by FRN. n is the record length.
+func readRecord(uap []itemFormat, buf []byte) (items map[int][]byte, n int, err error) {
+	frns, n, err := readFSPEC(buf)
+	if err != nil {
+		return nil, 0, err
+	}
+	items = make(map[int][]byte)
+	for _, frn := range frns {
+		if frn > len(uap) {
+			return nil, 0, ErrUnsupportedItem
+		}
+		l, err := itemLength(uap[frn-1], buf[n:])
+		if err != nil {
+			return nil, 0, err
+		}
+		items[frn] = buf[n : n+l]
+		n += l
+	}
+	return items, n, nil
+}
+
+// Time of day, seconds since midnight UTC in 1/128 s
+func TimeOfDay(t time.Time) float64 {
+	t = t.UTC()
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	return t.Sub(midnight).Seconds()
+}
+
+func encodeTimeOfDay(secs float64) []byte {
+	t := math.Mod(math.Round(secs/TIME_RES), MIDNIGHT)
+	if t < 0 {
+		t += MIDNIGHT // e.g. time of position just before midnight
+	}
+	v := uint32(t)
+	return []byte{byte(v >> 16), byte(v >> 8), byte(v)}
+}
+
+func decodeTimeOfDay(b []byte) float64 {
+	return float64(uint32(b[0])<<16|uint32(b[1])<<8|uint32(b[2])) * TIME_RES
+}
+
+func clamp(v, min, max int) (int, bool) {
+	if v < min {
+		return min, true
+	}
+	if v > max {
+		return max, true
+	}
+	return v, false
+}
+
+// Sign extends the lowest bits of v
+func signExtend(v uint32, bits uint) int32 {
+	shift := 32 - bits
+	return int32(v<<shift) >> shift
+}
@@ -0,0 +1,304 @@
+package asterix
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+// Encodes the report in a data block and decodes it again.
+func roundTrip021(t *testing.T, r *Cat021Report) *Cat021Report {
+	blocks, rest, err := Split(Block(CAT021, EncodeCat021(r)))
+	if err != nil || len(blocks) != 1 || len(rest) != 0 {
+		t.Fatalf("split: %d blocks, %d bytes left, %v", len(blocks), len(rest), err)
+	}
+	cat, records, err := Decode(blocks[0])
+	if err != nil || cat != CAT021 || len(records) != 1 {
+		t.Fatalf("decode: category %d, %d records, %v", cat, len(records), err)
+	}
+	return records[0].(*Cat021Report)
+}
+
+// Raw bytes of one item of an encoded CAT021 record.
+func item021(t *testing.T, r *Cat021Report, frn int) []byte {
+	items, _, err := readRecord(uap021, EncodeCat021(r))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return items[frn]
+}
+
+func TestCat021RoundTrip(t *testing.T) {
+	r := &Cat021Report{
+		SAC: 25, SIC: 1,
+		AddrType: ATP_ICAO, AltitudeCapability: ARC_25FT,
+		TrackNumber: 0xABC, TrackNumberValid: true,
+		Address:        0xA12345,
+		TimeOfPosition: 43200.5, TimeOfPositionValid: true,
+		Lat: 43.99, Lng: -88.56, PositionValid: true,
+		TimeOfReception: 43200.75, TimeOfReceptionValid: true,
+		GeoAltitude: 5125, GeoAltitudeValid: true,
+		NACv: 2, NIC: 8, NACp: 9, SIL: 3, NICbaro: true, QualityValid: true,
+		Squawk: 1200, SquawkValid: true,
+		PressureAltitude: 5000, PressureAltitudeValid: true,
+		PriorityStatus: 5, StatusValid: true,
+		BaroVvel: -500, BaroVvelValid: true,
+		GeoVvel: 512.5, GeoVvelValid: true,
+		Speed: 120, Track: 275.5, GroundVectorValid: true,
+		TimeOfTransmission: 43201, TimeOfTransmissionValid: true,
+		Callsign: "N123AB",
+		Emitter:  EmitterFromGDL90(1), EmitterValid: true,
+	}
+	d := roundTrip021(t, r)
+
+	exact := *r
+	exact.Lat, exact.Lng, exact.Speed, exact.Track = d.Lat, d.Lng, d.Speed, d.Track
+	if *d != exact {
+		t.Errorf("decoded %+v\nwant %+v", *d, exact)
+	}
+	if math.Abs(d.Lat-r.Lat) > HIRES_RES || math.Abs(d.Lng-r.Lng) > HIRES_RES {
+		t.Errorf("position %.8f,%.8f", d.Lat, d.Lng)
+	}
+	if math.Abs(d.Speed-r.Speed) > SPEED_RES || math.Abs(d.Track-r.Track) > ANGLE_RES {
+		t.Errorf("ground vector %.3f kts %.3f°", d.Speed, d.Track)
+	}
+
+	// Only the mandatory items
+	d = roundTrip021(t, &Cat021Report{SAC: 25, SIC: 1, Address: 0xFFFFFF})
+	if *d != (Cat021Report{SAC: 25, SIC: 1, Address: 0xFFFFFF}) {
+		t.Errorf("decoded %+v", *d)
+	}
+}
+
+// I021/040 target report descriptor: ATP, ARC and the first extension with GBS and SIM.
+func TestCat021TargetReportDescriptor(t *testing.T) {
+	tests := []struct {
+		addrType, arc uint8
+		onGround, sim bool
+		want          []byte
+	}{
+		{ATP_ICAO, ARC_25FT, false, false, []byte{0x01, 0x00}},
+		{ATP_ICAO, ARC_100FT, true, false, []byte{0x09, 0x40}},
+		{ATP_ANONYMOUS, ARC_25FT, false, true, []byte{0x61, 0x20}},
+		{ATP_SURFACE, ARC_UNKNOWN, true, true, []byte{0x51, 0x60}},
+	}
+	for _, tt := range tests {
+		r := &Cat021Report{Address: 1, AddrType: tt.addrType, AltitudeCapability: tt.arc, OnGround: tt.onGround, Simulated: tt.sim}
+		if b := item021(t, r, i021_040); !bytes.Equal(b, tt.want) {
+			t.Errorf("%+v: I021/040 % X, want % X", tt, b, tt.want)
+		}
+		d := roundTrip021(t, r)
+		if d.AddrType != tt.addrType || d.AltitudeCapability != tt.arc || d.OnGround != tt.onGround || d.Simulated != tt.sim {
+			t.Errorf("%+v: decoded ATP %d ARC %d GBS %t SIM %t", tt, d.AddrType, d.AltitudeCapability, d.OnGround, d.Simulated)
+		}
+	}
+}
+
+// I021/070 Mode 3/A code: four octal digits of 3 bits.
+func TestCat021Squawk(t *testing.T) {
+	tests := []struct {
+		squawk uint16
+		want   []byte
+	}{
+		{0, []byte{0x00, 0x00}},
+		{1200, []byte{0x02, 0x80}},
+		{7000, []byte{0x0E, 0x00}},
+		{7500, []byte{0x0F, 0x40}},
+		{7600, []byte{0x0F, 0x80}},
+		{7700, []byte{0x0F, 0xC0}},
+		{7777, []byte{0x0F, 0xFF}},
+		{1234, []byte{0x02, 0x9C}},
+	}
+	for _, tt := range tests {
+		r := &Cat021Report{Address: 1, Squawk: tt.squawk, SquawkValid: true}
+		if b := item021(t, r, i021_070); !bytes.Equal(b, tt.want) {
+			t.Errorf("%04d: I021/070 % X, want % X", tt.squawk, b, tt.want)
+		}
+		if d := roundTrip021(t, r); !d.SquawkValid || d.Squawk != tt.squawk {
+			t.Errorf("%04d: decoded %04d", tt.squawk, d.Squawk)
+		}
+	}
+}
+
+// I021/145 flight level in 1/4 FL and I021/140 geometric height in 6.25 ft, both 16 bit two's complement.
+func TestCat021Altitude(t *testing.T) {
+	tests := []struct {
+		alt                      float64
+		fl, height               []byte
+		decodedFL, decodedHeight float64
+	}{
+		{0, []byte{0x00, 0x00}, []byte{0x00, 0x00}, 0, 0},
+		{1000, []byte{0x00, 0x28}, []byte{0x00, 0xA0}, 1000, 1000},
+		{35000, []byte{0x05, 0x78}, []byte{0x15, 0xE0}, 35000, 35000},
+		{-1000, []byte{0xFF, 0xD8}, []byte{0xFF, 0x60}, -1000, -1000},
+		{1010, []byte{0x00, 0x28}, []byte{0x00, 0xA2}, 1000, 1012.5},                 // rounded to the resolution
+		{250000, []byte{0x27, 0x10}, []byte{0x7F, 0xFF}, 250000, 32767 * HEIGHT_RES}, // height clamped
+		{-900000, []byte{0x80, 0x00}, []byte{0x80, 0x00}, -32768 * FL_RES, -32768 * HEIGHT_RES},
+	}
+	for _, tt := range tests {
+		r := &Cat021Report{Address: 1, PressureAltitude: tt.alt, PressureAltitudeValid: true, GeoAltitude: tt.alt, GeoAltitudeValid: true}
+		if b := item021(t, r, i021_145); !bytes.Equal(b, tt.fl) {
+			t.Errorf("%.0f ft: I021/145 % X, want % X", tt.alt, b, tt.fl)
+		}
+		if b := item021(t, r, i021_140); !bytes.Equal(b, tt.height) {
+			t.Errorf("%.0f ft: I021/140 % X, want % X", tt.alt, b, tt.height)
+		}
+		d := roundTrip021(t, r)
+		if d.PressureAltitude != tt.decodedFL || d.GeoAltitude != tt.decodedHeight {
+			t.Errorf("%.0f ft: decoded %.2f ft FL, %.2f ft height", tt.alt, d.PressureAltitude, d.GeoAltitude)
+		}
+	}
+}
+
+// I021/160 airborne ground vector: RE bit, ground speed in 2^-14 NM/s and track angle in 360/2^16 degrees.
+func TestCat021GroundVector(t *testing.T) {
+	tests := []struct {
+		speed, track float64
+		want         []byte
+		decodedSpeed float64
+		decodedTrack float64
+	}{
+		{0, 0, []byte{0x00, 0x00, 0x00, 0x00}, 0, 0},
+		{225, 90, []byte{0x04, 0x00, 0x40, 0x00}, 225, 90},
+		{450, 180, []byte{0x08, 0x00, 0x80, 0x00}, 450, 180},
+		{112.5, 270, []byte{0x02, 0x00, 0xC0, 0x00}, 112.5, 270},
+		{100, 360, []byte{0x01, 0xC7, 0x00, 0x00}, 455 * SPEED_RES, 0},     // 360° wraps to 0°
+		{8000, 45, []byte{0xFF, 0xFF, 0x20, 0x00}, 0x7FFF * SPEED_RES, 45}, // range exceeded
+	}
+	for _, tt := range tests {
+		r := &Cat021Report{Address: 1, Speed: tt.speed, Track: tt.track, GroundVectorValid: true}
+		if b := item021(t, r, i021_160); !bytes.Equal(b, tt.want) {
+			t.Errorf("%.1f kts %.0f°: I021/160 % X, want % X", tt.speed, tt.track, b, tt.want)
+		}
+		d := roundTrip021(t, r)
+		if math.Abs(d.Speed-tt.decodedSpeed) > 1e-9 || math.Abs(d.Track-tt.decodedTrack) > 1e-9 {
+			t.Errorf("%.1f kts %.0f°: decoded %.4f kts %.4f°", tt.speed, tt.track, d.Speed, d.Track)
+		}
+	}
+}
+
+// I021/020 emitter category
+func TestEmitterCategory(t *testing.T) {
+	tests := []struct {
+		gdl90, asterix uint8
+	}{
+		{0, 0},
+		{1, 1}, // light
+		{3, 3}, // medium
+		{5, 5}, // heavy
+		{6, 6}, // highly manoeuvrable
+		{7, 10},
+		{9, 11},
+		{10, 12},
+		{11, 16},
+		{12, 15},
+		{14, 13},
+		{15, 14},
+		{17, 20},
+		{18, 21},
+		{19, 22},
+		{21, 24},
+	}
+	for _, tt := range tests {
+		if c := EmitterFromGDL90(tt.gdl90); c != tt.asterix {
+			t.Errorf("GDL90 %d: ASTERIX %d, want %d", tt.gdl90, c, tt.asterix)
+		}
+		if c := EmitterToGDL90(tt.asterix); c != tt.gdl90 {
+			t.Errorf("ASTERIX %d: GDL90 %d, want %d", tt.asterix, c, tt.gdl90)
+		}
+		r := &Cat021Report{Address: 1, Emitter: EmitterFromGDL90(tt.gdl90), EmitterValid: true}
+		if b := item021(t, r, i021_020); !bytes.Equal(b, []byte{tt.asterix}) {
+			t.Errorf("GDL90 %d: I021/020 % X", tt.gdl90, b)
+		}
+		if d := roundTrip021(t, r); !d.EmitterValid || EmitterToGDL90(d.Emitter) != tt.gdl90 {
+			t.Errorf("GDL90 %d: decoded %d", tt.gdl90, d.Emitter)
+		}
+	}
+	// No ASTERIX equivalent
+	for _, c := range []uint8{8, 13, 16, 22, 39} {
+		if e := EmitterFromGDL90(c); e != 0 {
+			t.Errorf("GDL90 %d: ASTERIX %d", c, e)
+		}
+	}
+}
+
+func TestCat021Callsign(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"N123AB", "N123AB"},
+		{"dlh4ab", "DLH4AB"},
+		{"ABCDEFGHIJ", "ABCDEFGH"},
+		{"D-EFGH", "D EFGH"},
+	}
+	for _, tt := range tests {
+		if d := roundTrip021(t, &Cat021Report{Address: 1, Callsign: tt.in}); d.Callsign != tt.want {
+			t.Errorf("%s: decoded %q, want %q", tt.in, d.Callsign, tt.want)
+		}
+	}
+}
+
+func TestCat023RoundTrip(t *testing.T) {
+	tests := []Cat023Report{
+		{SAC: 25, SIC: 1, ReportType: REPORT_GROUND_STATION_STATUS, TimeOfDay: 3600, TimeOfDayValid: true,
+			MonitoringConnected: true, StatusPeriod: 10},
+		{SAC: 25, SIC: 1, ReportType: REPORT_GROUND_STATION_STATUS, NoGo: true, Overload: true, TimeSourceInvalid: true},
+		{SAC: 25, SIC: 1, ReportType: REPORT_SERVICE_STATUS, ServiceID: 2, ServiceType: SERVICE_ADSB_ES,
+			TimeOfDay: 86399.5, TimeOfDayValid: true, ReportPeriod: 1.5, ServiceClass: 1, Status: STATUS_NORMAL,
+			StatusValid: true, OperationalRange: 100},
+		{SAC: 25, SIC: 1, ReportType: REPORT_SERVICE_STATUS, ServiceID: 3, ServiceType: SERVICE_ADSB_UAT,
+			Status: STATUS_DISABLED, StatusValid: true},
+	}
+	var records [][]byte
+	for i := range tests {
+		records = append(records, EncodeCat023(&tests[i]))
+	}
+	cat, decoded, err := Decode(Block(CAT023, records...))
+	if err != nil || cat != CAT023 || len(decoded) != len(tests) {
+		t.Fatalf("category %d, %d records, %v", cat, len(decoded), err)
+	}
+	for i, rec := range decoded {
+		if d := rec.(*Cat023Report); *d != tests[i] {
+			t.Errorf("decoded %+v\nwant %+v", *d, tests[i])
+		}
+	}
+}
+
+// Truncated and corrupt blocks return an error instead of panicking.
+func TestDecodeTruncated(t *testing.T) {
+	r := &Cat021Report{
+		SAC: 25, SIC: 1, Address: 0xA12345, Lat: 43.99, Lng: -88.56, PositionValid: true,
+		Squawk: 7700, SquawkValid: true, Callsign: "N123AB", QualityValid: true, NACp: 9,
+	}
+	block := Block(CAT021, EncodeCat021(r))
+	for l := 0; l < len(block); l++ {
+		if _, _, err := Decode(block[:l]); err == nil {
+			t.Errorf("%d of %d bytes: no error", l, len(block))
+		}
+		// Length field still claiming the full block
+		truncated := append([]byte{}, block[:l]...)
+		if l >= 3 {
+			if _, _, err := Decode(append(truncated[:3:3], block[3:l]...)); err == nil {
+				t.Errorf("%d of %d bytes: no error", l, len(block))
+			}
+		}
+	}
+	// Record cut off inside the block
+	short := Block(CAT021, EncodeCat021(r)[:10])
+	if _, _, err := Decode(short); err == nil {
+		t.Error("truncated record: no error")
+	}
+	if _, _, err := Decode([]byte{CAT021, 0x00, 0x02}); err != ErrLength {
+		t.Errorf("length 2: %v", err)
+	}
+	if _, _, err := Decode(Block(48, []byte{0x80, 0x01, 0x02})); err != ErrUnknownCategory {
+		t.Errorf("CAT048: %v", err)
+	}
+
+	// Split keeps an incomplete block for the next read
+	stream := append(Block(CAT021, EncodeCat021(r)), block[:5]...)
+	blocks, rest, err := Split(stream)
+	if err != nil || len(blocks) != 1 || !bytes.Equal(rest, block[:5]) {
+		t.Errorf("split: %d blocks, rest % X, %v", len(blocks), rest, err)
+	}
+}
@@ -0,0 +1,440 @@
+/*
+	Copyright (c) 2026 Stratux contributors
+	Distributable under the terms of The "BSD New" License
+	that can be found in the LICENSE file, herein included
+	as part of this header.
+
+	cat021.go: ASTERIX CAT021 ADS-B target reports, edition 2.x UAP.
+*/
+
+package asterix
+
+import (
+	"encoding/binary"
+	"math"
+	"strings"
+)
+
+const (
+	// I021/040 ATP, address type
+	ATP_ICAO      = 0
+	ATP_DUPLICATE = 1
+	ATP_SURFACE   = 2
+	ATP_ANONYMOUS = 3
+	// I021/040 ARC, altitude reporting capability
+	ARC_25FT    = 0
+	ARC_100FT   = 1
+	ARC_UNKNOWN = 2
+	// I021/200 PS, priority status. Same values as the GDL90 emergency/priority code
+	PS_NO_EMERGENCY = 0
+)
+
+// FRN of the CAT021 items
+const (
+	i021_010 = 1
+	i021_040 = 2
+	i021_161 = 3
+	i021_071 = 5
+	i021_130 = 6
+	i021_131 = 7
+	i021_080 = 11
+	i021_073 = 12
+	i021_140 = 16
+	i021_090 = 17
+	i021_070 = 19
+	i021_145 = 21
+	i021_200 = 23
+	i021_155 = 24
+	i021_157 = 25
+	i021_160 = 26
+	i021_077 = 28
+	i021_170 = 29
+	i021_020 = 30
+)
+
+var uap021 = []itemFormat{
+	{itemFixed, 2},       // 1 I021/010 Data Source Identification
+	{itemExtended, 1},    // 2 I021/040 Target Report Descriptor
+	{itemFixed, 2},       // 3 I021/161 Track Number
+	{itemFixed, 1},       // 4 I021/015 Service Identification
+	{itemFixed, 3},       // 5 I021/071 Time of Applicability for Position
+	{itemFixed, 6},       // 6 I021/130 Position in WGS-84 Co-ordinates
+	{itemFixed, 8},       // 7 I021/131 Position in WGS-84 Co-ordinates, high res.
+	{itemFixed, 3},       // 8 I021/072 Time of Applicability for Velocity
+	{itemFixed, 2},       // 9 I021/150 Air Speed
+	{itemFixed, 2},       // 10 I021/151 True Air Speed
+	{itemFixed, 3},       // 11 I021/080 Target Address
+	{itemFixed, 3},       // 12 I021/073 Time of Message Reception for Position
+	{itemFixed, 4},       // 13 I021/074 Time of Message Reception of Position, high precision
+	{itemFixed, 3},       // 14 I021/075 Time of Message Reception for Velocity
+	{itemFixed, 4},       // 15 I021/076 Time of Message Reception of Velocity, high precision
+	{itemFixed, 2},       // 16 I021/140 Geometric Height
+	{itemExtended, 1},    // 17 I021/090 Quality Indicators
+	{itemFixed, 1},       // 18 I021/210 MOPS Version
+	{itemFixed, 2},       // 19 I021/070 Mode 3/A Code
+	{itemFixed, 2},       // 20 I021/230 Roll Angle
+	{itemFixed, 2},       // 21 I021/145 Flight Level
+	{itemFixed, 2},       // 22 I021/152 Magnetic Heading
+	{itemFixed, 1},       // 23 I021/200 Target Status
+	{itemFixed, 2},       // 24 I021/155 Barometric Vertical Rate
+	{itemFixed, 2},       // 25 I021/157 Geometric Vertical Rate
+	{itemFixed, 4},       // 26 I021/160 Airborne Ground Vector
+	{itemFixed, 2},       // 27 I021/165 Track Angle Rate
+	{itemFixed, 3},       // 28 I021/077 Time of Report Transmission
+	{itemFixed, 6},       // 29 I021/170 Target Identification
+	{itemFixed, 1},       // 30 I021/020 Emitter Category
+	{itemUnsupported, 0}, // 31 I021/220 Met Information
+	{itemFixed, 2},       // 32 I021/146 Selected Altitude
+	{itemFixed, 2},       // 33 I021/148 Final State Selected Altitude
+	{itemUnsupported, 0}, // 34 I021/110 Trajectory Intent
+	{itemFixed, 1},       // 35 I021/016 Service Management
+	{itemExtended, 1},    // 36 I021/008 Aircraft Operational Status
+	{itemExtended, 1},    // 37 I021/271 Surface Capabilities and Characteristics
+	{itemFixed, 1},       // 38 I021/132 Message Amplitude
+	{itemRepetitive, 8},  // 39 I021/250 Mode S MB Data
+	{itemFixed, 7},       // 40 I021/260 ACAS Resolution Advisory Report
+	{itemFixed, 1},       // 41 I021/400 Receiver ID
+	{itemUnsupported, 0}, // 42 I021/295 Data Ages
+	{itemFixed, 0},       // 43 spare
+	{itemFixed, 0},       // 44 spare
+	{itemFixed, 0},       // 45 spare
+	{itemFixed, 0},       // 46 spare
+	{itemFixed, 0},       // 47 spare
+	{itemExplicit, 0},    // 48 RE Reserved Expansion Field
+	{itemExplicit, 0},    // 49 SP Special Purpose Field
+}
+
+// A CAT021 target report. Items are only encoded if their ...Valid flag is set. Address and the data source are
+// mandatory.
+type Cat021Report struct {
+	SAC uint8 // System Area Code
+	SIC uint8 // System Identification Code
+
+	AddrType           uint8 // ATP_*
+	AltitudeCapability uint8 // ARC_*
+	OnGround           bool  // GBS, ground bit set
+	Simulated          bool
+
+	TrackNumber      uint16 // 12 bits
+	TrackNumberValid bool
+
+	Address uint32 // 24 bit target address
+
+	TimeOfPosition      float64 // time of applicability for position, seconds since midnight UTC
+	TimeOfPositionValid bool
+
+	Lat, Lng      float64
+	PositionValid bool
+
+	TimeOfReception      float64 // time of message reception for position, seconds since midnight UTC
+	TimeOfReceptionValid bool
+
+	GeoAltitude      float64 // geometric height above WGS-84, feet
+	GeoAltitudeValid bool
+
+	NACv, NIC, NACp, SIL uint8
+	NICbaro              bool
+	QualityValid         bool
+
+	Squawk      uint16 // as shown by the transponder, e.g. 7700
+	SquawkValid bool
+
+	PressureAltitude      float64 // flight level in feet, 25 ft resolution
+	PressureAltitudeValid bool
+
+	PriorityStatus uint8 // PS_*, GDL90 emergency/priority code
+	StatusValid    bool
+
+	BaroVvel      float64 // ft/min
+	BaroVvelValid bool
+
+	GeoVvel      float64 // ft/min
+	GeoVvelValid bool
+
+	Speed             float64 // ground speed, knots
+	Track             float64 // degrees true
+	GroundVectorValid bool
+
+	TimeOfTransmission      float64 // time of report transmission, seconds since midnight UTC
+	TimeOfTransmissionValid bool
+
+	Callsign string // up to 8 characters
+
+	Emitter      uint8 // I021/020 value, see EmitterFromGDL90()
+	EmitterValid bool
+}
+
+// ASTERIX emitter category for a GDL90/DO-260 emitter category
+func EmitterFromGDL90(cat uint8) uint8 {
+	switch cat {
+	case 1, 2, 3, 4, 5, 6:
+		return cat // light, small, medium, high vortex large, heavy, highly manoeuvrable
+	case 7:
+		return 10 // rotocraft
+	case 9:
+		return 11 // glider
+	case 10:
+		return 12 // lighter than air
+	case 11:
+		return 16 // parachutist
+	case 12:
+		return 15 // ultralight
+	case 14:
+		return 13 // UAV
+	case 15:
+		return 14 // space vehicle
+	case 17:
+		return 20 // surface emergency vehicle
+	case 18:
+		return 21 // surface service vehicle
+	case 19, 20, 21:
+		return cat + 3 // fixed, cluster and line obstacle
+	}
+	return 0 // no information
+}
+
+// Inverse of EmitterFromGDL90()
+func EmitterToGDL90(cat uint8) uint8 {
+	for i := uint8(1); i <= 21; i++ {
+		if cat != 0 && EmitterFromGDL90(i) == cat {
+			return i
+		}
+	}
+	return 0
+}
+
+// Encodes a CAT021 record. Use Block(CAT021, ...) to send it.
+func EncodeCat021(r *Cat021Report) []byte {
+	w := &recordWriter{}
+	w.add(i021_010, r.SAC, r.SIC)
+
+	trd2 := byte(0)
+	if r.OnGround {
+		trd2 |= 0x40
+	}
+	if r.Simulated {
+		trd2 |= 0x20
+	}
+	w.add(i021_040, (r.AddrType&7)<<5|(r.AltitudeCapability&3)<<3|1, trd2)
+
+	if r.TrackNumberValid {
+		w.add(i021_161, byte(r.TrackNumber>>8)&0x0F, byte(r.TrackNumber))
+	}
+	if r.TimeOfPositionValid {
+		w.add(i021_071, encodeTimeOfDay(r.TimeOfPosition)...)
+	}
+	if r.PositionValid {
+		item := make([]byte, 8)
+		binary.BigEndian.PutUint32(item[0:], uint32(int32(math.Round(r.Lat/HIRES_RES))))
+		binary.BigEndian.PutUint32(item[4:], uint32(int32(math.Round(r.Lng/HIRES_RES))))
+		w.add(i021_131, item...)
+	}
+	w.add(i021_080, byte(r.Address>>16), byte(r.Address>>8), byte(r.Address))
+	if r.TimeOfReceptionValid {
+		w.add(i021_073, encodeTimeOfDay(r.TimeOfReception)...)
+	}
+	if r.GeoAltitudeValid {
+		v, _ := clamp(int(math.Round(r.GeoAltitude/HEIGHT_RES)), math.MinInt16, math.MaxInt16)
+		w.add(i021_140, byte(v>>8), byte(v))
+	}
+	if r.QualityValid {
+		q2 := (r.SIL&3)<<5 | (r.NACp&0x0F)<<1
+		if r.NICbaro {
+			q2 |= 0x80
+		}
+		w.add(i021_090, (r.NACv&7)<<5|(r.NIC&0x0F)<<1|1, q2)
+	}
+	if r.SquawkValid {
+		code := uint16(0)
+		for i, v := uint(0), r.Squawk; i < 4; i, v = i+1, v/10 {
+			code |= (v % 10 & 7) << (3 * i)
+		}
+		w.add(i021_070, byte(code>>8), byte(code))
+	}
+	if r.PressureAltitudeValid {
+		v, _ := clamp(int(math.Round(r.PressureAltitude/FL_RES)), math.MinInt16, math.MaxInt16)
+		w.add(i021_145, byte(v>>8), byte(v))
+	}
+	if r.StatusValid {
+		w.add(i021_200, (r.PriorityStatus&7)<<2)
+	}
+	if r.BaroVvelValid {
+		w.add(i021_155, encodeVvel(r.BaroVvel)...)
+	}
+	if r.GeoVvelValid {
+		w.add(i021_157, encodeVvel(r.GeoVvel)...)
+	}
+	if r.GroundVectorValid {
+		gs, re := clamp(int(math.Round(r.Speed/SPEED_RES)), 0, 0x7FFF)
+		if re {
+			gs |= 0x8000
+		}
+		track := uint16(int(math.Round(r.Track/ANGLE_RES)) & 0xFFFF)
+		w.add(i021_160, byte(gs>>8), byte(gs), byte(track>>8), byte(track))
+	}
+	if r.TimeOfTransmissionValid {
+		w.add(i021_077, encodeTimeOfDay(r.TimeOfTransmission)...)
+	}
+	if len(r.Callsign) > 0 {
+		w.add(i021_170, encodeCallsign(r.Callsign)...)
+	}
+	if r.EmitterValid {
+		w.add(i021_020, r.Emitter)
+	}
+	return w.bytes()
+}
+
+func decodeCat021(buf []byte) (*Cat021Report, int, error) {
+	items, n, err := readRecord(uap021, buf)
+	if err != nil {
+		return nil, 0, err
+	}
+	r := &Cat021Report{}
+	if b, ok := items[i021_010]; ok {
+		r.SAC, r.SIC = b[0], b[1]
+	}
+	if b, ok := items[i021_040]; ok {
+		r.AddrType = b[0] >> 5
+		r.AltitudeCapability = (b[0] >> 3) & 3
+		if len(b) > 1 {
+			r.OnGround = b[1]&0x40 != 0
+			r.Simulated = b[1]&0x20 != 0
+		}
+	}
+	if b, ok := items[i021_161]; ok {
+		r.TrackNumber = binary.BigEndian.Uint16(b) & 0x0FFF
+		r.TrackNumberValid = true
+	}
+	if b, ok := items[i021_071]; ok {
+		r.TimeOfPosition = decodeTimeOfDay(b)
+		r.TimeOfPositionValid = true
+	}
+	if b, ok := items[i021_130]; ok {
+		r.Lat = float64(signExtend(uint32(b[0])<<16|uint32(b[1])<<8|uint32(b[2]), 24)) * LAT_LNG_RES
+		r.Lng = float64(signExtend(uint32(b[3])<<16|uint32(b[4])<<8|uint32(b[5]), 24)) * LAT_LNG_RES
+		r.PositionValid = true
+	}
+	if b, ok := items[i021_131]; ok {
+		r.Lat = float64(int32(binary.BigEndian.Uint32(b[0:]))) * HIRES_RES
+		r.Lng = float64(int32(binary.BigEndian.Uint32(b[4:]))) * HIRES_RES
+		r.PositionValid = true
+	}
+	if b, ok := items[i021_080]; ok {
+		r.Address = uint32(b[0])<<16 | uint32(b[1])<<8 | uint32(b[2])
+	}
+	if b, ok := items[i021_073]; ok {
+		r.TimeOfReception = decodeTimeOfDay(b)
+		r.TimeOfReceptionValid = true
+	}
+	if b, ok := items[i021_140]; ok {
+		r.GeoAltitude = float64(int16(binary.BigEndian.Uint16(b))) * HEIGHT_RES
+		r.GeoAltitudeValid = true
+	}
+	if b, ok := items[i021_090]; ok {
+		r.NACv = b[0] >> 5
+		r.NIC = (b[0] >> 1) & 0x0F
+		if len(b) > 1 {
+			r.NICbaro = b[1]&0x80 != 0
+			r.SIL = (b[1] >> 5) & 3
+			r.NACp = (b[1] >> 1) & 0x0F
+		}
+		r.QualityValid = true
+	}
+	if b, ok := items[i021_070]; ok {
+		code := binary.BigEndian.Uint16(b) & 0x0FFF
+		for i, mul := uint(0), uint16(1); i < 4; i, mul = i+1, mul*10 {
+			r.Squawk += (code >> (3 * i) & 7) * mul
+		}
+		r.SquawkValid = true
+	}
+	if b, ok := items[i021_145]; ok {
+		r.PressureAltitude = float64(int16(binary.BigEndian.Uint16(b))) * FL_RES
+		r.PressureAltitudeValid = true
+	}
+	if b, ok := items[i021_200]; ok {
+		r.PriorityStatus = (b[0] >> 2) & 7
+		r.StatusValid = true
+	}
+	if b, ok := items[i021_155]; ok {
+		r.BaroVvel = decodeVvel(b)
+		r.BaroVvelValid = true
+	}
+	if b, ok := items[i021_157]; ok {
+		r.GeoVvel = decodeVvel(b)
+		r.GeoVvelValid = true
+	}
+	if b, ok := items[i021_160]; ok {
+		r.Speed = float64(binary.BigEndian.Uint16(b[0:])&0x7FFF) * SPEED_RES
+		r.Track = float64(binary.BigEndian.Uint16(b[2:])) * ANGLE_RES
+		r.GroundVectorValid = true
+	}
+	if b, ok := items[i021_077]; ok {
+		r.TimeOfTransmission = decodeTimeOfDay(b)
+		r.TimeOfTransmissionValid = true
+	}
+	if b, ok := items[i021_170]; ok {
+		r.Callsign = decodeCallsign(b)
+	}
+	if b, ok := items[i021_020]; ok {
+		r.Emitter = b[0]
+		r.EmitterValid = true
+	}
+	return r, n, nil
+}
+
+// I021/155 and I021/157: RE bit and 15 bit two's complement
+func encodeVvel(fpm float64) []byte {
+	v, re := clamp(int(math.Round(fpm/VVEL_RES)), -(1 << 14), (1<<14)-1)
+	item := uint16(v) & 0x7FFF
+	if re {
+		item |= 0x8000
+	}
+	return []byte{byte(item >> 8), byte(item)}
+}
+
+func decodeVvel(b []byte) float64 {
+	return float64(signExtend(uint32(binary.BigEndian.Uint16(b)), 15)) * VVEL_RES
+}
+
+// 8 characters of 6 bits, ICAO Annex 10 character set
+func encodeCallsign(s string) []byte {
+	s = strings.ToUpper(s)
+	var v uint64
+	for i := 0; i < 8; i++ {
+		c := byte(' ')
+		if i < len(s) {
+			c = s[i]
+		}
+		var code byte
+		switch {
+		case c >= 'A' && c <= 'Z':
+			code = c - 'A' + 1
+		case c >= '0' && c <= '9':
+			code = c // 48..57
+		default:
+			code = 32 // space
+		}
+		v = v<<6 | uint64(code)
+	}
+	item := make([]byte, 8)
+	binary.BigEndian.PutUint64(item, v)
+	return item[2:]
+}
+
+func decodeCallsign(b []byte) string {
+	v := uint64(b[0])<<40 | uint64(b[1])<<32 | uint64(b[2])<<24 | uint64(b[3])<<16 | uint64(b[4])<<8 | uint64(b[5])
+	s := make([]byte, 8)
+	for i := 7; i >= 0; i-- {
+		code := byte(v & 0x3F)
+		v >>= 6
+		switch {
+		case code >= 1 && code <= 26:
+			s[i] = 'A' + code - 1
+		case code >= 48 && code <= 57:
+			s[i] = code
+		default:
+			s[i] = ' '
+		}
+	}
+	return strings.TrimRight(string(s), " ")
+}
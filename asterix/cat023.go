@@ -0,0 +1,186 @@
+/*
+	Copyright (c) 2026 Stratux contributors
+	Distributable under the terms of The "BSD New" License
+	that can be found in the LICENSE file, herein included
+	as part of this header.
+
+	cat023.go: ASTERIX CAT023 CNS/ATM ground station and service status reports, edition 1.2 UAP.
+*/
+
+package asterix
+
+import (
+	"math"
+)
+
+const (
+	// I023/000 report type
+	REPORT_GROUND_STATION_STATUS = 1
+	REPORT_SERVICE_STATUS        = 2
+	REPORT_SERVICE_STATISTICS    = 3
+
+	// I023/015 STYP, service type
+	SERVICE_ADSB_VDL4 = 1
+	SERVICE_ADSB_ES   = 2
+	SERVICE_ADSB_UAT  = 3
+	SERVICE_TISB_VDL4 = 4
+	SERVICE_TISB_ES   = 5
+	SERVICE_TISB_UAT  = 6
+	SERVICE_FISB_VDL4 = 7
+	SERVICE_GRAS_VDL4 = 8
+	SERVICE_MLT       = 9
+
+	// I023/110 STAT, service status
+	STATUS_UNKNOWN        = 0
+	STATUS_FAILED         = 1
+	STATUS_DISABLED       = 2
+	STATUS_DEGRADED       = 3
+	STATUS_NORMAL         = 4
+	STATUS_INITIALISATION = 5
+)
+
+// FRN of the CAT023 items
+const (
+	i023_010 = 1
+	i023_000 = 2
+	i023_015 = 3
+	i023_070 = 4
+	i023_100 = 5
+	i023_101 = 6
+	i023_200 = 7
+	i023_110 = 8
+)
+
+var uap023 = []itemFormat{
+	{itemFixed, 2},      // 1 I023/010 Data Source Identifier
+	{itemFixed, 1},      // 2 I023/000 Report Type
+	{itemFixed, 1},      // 3 I023/015 Service Type and Identification
+	{itemFixed, 3},      // 4 I023/070 Time of Day
+	{itemExtended, 1},   // 5 I023/100 Ground Station Status
+	{itemExtended, 2},   // 6 I023/101 Service Configuration
+	{itemFixed, 1},      // 7 I023/200 Operational Range
+	{itemExtended, 1},   // 8 I023/110 Service Status
+	{itemRepetitive, 6}, // 9 I023/120 Service Statistics
+	{itemFixed, 0},      // 10 spare
+	{itemFixed, 0},      // 11 spare
+	{itemFixed, 0},      // 12 spare
+	{itemExplicit, 0},   // 13 RE Reserved Expansion Field
+	{itemExplicit, 0},   // 14 SP Special Purpose Field
+}
+
+// A CAT023 ground station status (REPORT_GROUND_STATION_STATUS) or service status (REPORT_SERVICE_STATUS) report.
+type Cat023Report struct {
+	SAC        uint8
+	SIC        uint8
+	ReportType uint8 // REPORT_*
+
+	ServiceID   uint8 // SID, 4 bits
+	ServiceType uint8 // SERVICE_*, only for service status reports
+
+	TimeOfDay      float64 // seconds since midnight UTC
+	TimeOfDayValid bool
+
+	// Ground station status
+	NoGo                bool  // operational release status of the data
+	Overload            bool  // ODP, data processor overload
+	MonitoringConnected bool  // MSC
+	TimeSourceInvalid   bool  // TSV
+	StatusPeriod        uint8 // GSSP, seconds between ground station status reports. 0 if not sent
+
+	// Service status
+	ReportPeriod float64 // RP, seconds between target reports, 0.5 s resolution. 0 if not sent
+	ServiceClass uint8   // SC
+	Status       uint8   // STATUS_*
+	StatusValid  bool
+
+	OperationalRange uint8 // NM. 0 if not sent
+}
+
+// Encodes a CAT023 record. Use Block(CAT023, ...) to send it.
+func EncodeCat023(r *Cat023Report) []byte {
+	w := &recordWriter{}
+	w.add(i023_010, r.SAC, r.SIC)
+	w.add(i023_000, r.ReportType)
+	if r.ReportType == REPORT_SERVICE_STATUS || r.ReportType == REPORT_SERVICE_STATISTICS {
+		w.add(i023_015, (r.ServiceID&0x0F)<<4|r.ServiceType&0x0F)
+	}
+	if r.TimeOfDayValid {
+		w.add(i023_070, encodeTimeOfDay(r.TimeOfDay)...)
+	}
+	if r.ReportType == REPORT_GROUND_STATION_STATUS {
+		gs := byte(0)
+		if r.NoGo {
+			gs |= 0x80
+		}
+		if r.Overload {
+			gs |= 0x40
+		}
+		if r.MonitoringConnected {
+			gs |= 0x10
+		}
+		if r.TimeSourceInvalid {
+			gs |= 0x08
+		}
+		if r.StatusPeriod > 0 {
+			w.add(i023_100, gs|1, (r.StatusPeriod&0x7F)<<1)
+		} else {
+			w.add(i023_100, gs)
+		}
+	}
+	if r.ReportType == REPORT_SERVICE_STATUS {
+		if r.ReportPeriod > 0 {
+			rp, _ := clamp(int(math.Round(r.ReportPeriod*2)), 0, math.MaxUint8)
+			w.add(i023_101, byte(rp), (r.ServiceClass&7)<<5)
+		}
+		if r.OperationalRange > 0 {
+			w.add(i023_200, r.OperationalRange)
+		}
+		if r.StatusValid {
+			w.add(i023_110, (r.Status&7)<<1)
+		}
+	}
+	return w.bytes()
+}
+
+func decodeCat023(buf []byte) (*Cat023Report, int, error) {
+	items, n, err := readRecord(uap023, buf)
+	if err != nil {
+		return nil, 0, err
+	}
+	r := &Cat023Report{}
+	if b, ok := items[i023_010]; ok {
+		r.SAC, r.SIC = b[0], b[1]
+	}
+	if b, ok := items[i023_000]; ok {
+		r.ReportType = b[0]
+	}
+	if b, ok := items[i023_015]; ok {
+		r.ServiceID = b[0] >> 4
+		r.ServiceType = b[0] & 0x0F
+	}
+	if b, ok := items[i023_070]; ok {
+		r.TimeOfDay = decodeTimeOfDay(b)
+		r.TimeOfDayValid = true
+	}
+	if b, ok := items[i023_100]; ok {
+		r.NoGo = b[0]&0x80 != 0
+		r.Overload = b[0]&0x40 != 0
+		r.MonitoringConnected = b[0]&0x10 != 0
+		r.TimeSourceInvalid = b[0]&0x08 != 0
+		if len(b) > 1 {
+			r.StatusPeriod = b[1] >> 1
+		}
+	}
+	if b, ok := items[i023_101]; ok {
+		r.ReportPeriod = float64(b[0]) / 2
+		r.ServiceClass = b[1] >> 5
+	}
+	if b, ok := items[i023_200]; ok {
+		r.OperationalRange = b[0]
+	}
+	if b, ok := items[i023_110]; ok {
+		r.Status = (b[0] >> 1) & 7
+		r.StatusValid = true
+	}
+	return r, n, nil
+}
@@ -0,0 +1,408 @@
+/*
+	Copyright (c) 2026 Stratux contributors
+	Distributable under the terms of The "BSD New" License
+	that can be found in the LICENSE file, herein included
+	as part of this header.
+
+	gdl90.go: GDL90 decoder. Frames received from another GDL90 device (SkyEcho, another Stratux) are unescaped, CRC
+		checked and decoded into Go structs. Also decodes everything Stratux encodes itself, so it can be used to check
+		the encoder. See GDL 90 Data Interface Specification 560-1058-00 Rev A and the ForeFlight GDL90 extension.
+*/
+
+package gdl90
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	MSG_HEARTBEAT            = 0x00
+	MSG_INITIALIZATION       = 0x02
+	MSG_UPLINK               = 0x07
+	MSG_HEIGHT_ABOVE_TERRAIN = 0x09
+	MSG_OWNSHIP              = 0x0A
+	MSG_OWNSHIP_GEO_ALT      = 0x0B
+	MSG_TRAFFIC              = 0x14
+	MSG_BASIC_REPORT         = 0x1E
+	MSG_LONG_REPORT          = 0x1F
+	MSG_FOREFLIGHT           = 0x65
+	MSG_STRATUX_HEARTBEAT    = 0xCC
+
+	FLAG_BYTE    = 0x7E
+	CONTROL_ESC  = 0x7D
+	LAT_LNG_RES  = float32(180.0 / 8388608.0)
+	TRACK_RES    = float32(360.0 / 256.0)
+	UPLINK_BYTES = 432
+	BASIC_BYTES  = 18
+	LONG_BYTES   = 34
+
+	TIME_OF_RECEPTION_INVALID = 0xFFFFFF
+	VFOM_NOT_AVAILABLE        = 0x7FFF
+	VFOM_TOO_LARGE            = 0x7EEE // > 32766 m
+
+	// Report "m" field, track type
+	TRACK_INVALID      = 0
+	TRACK_TRUE_TRACK   = 1
+	TRACK_MAG_HEADING  = 2
+	TRACK_TRUE_HEADING = 3
+)
+
+var (
+	ErrFrame          = errors.New("gdl90: invalid frame")
+	ErrCRC            = errors.New("gdl90: CRC mismatch")
+	ErrShort          = errors.New("gdl90: message too short")
+	ErrUnknownMessage = errors.New("gdl90: unsupported message ID")
+)
+
+var crc16Table [256]uint16
+
+func init() {
+	for i := 0; i < 256; i++ {
+		crc := uint16(i) << 8
+		for bit := 0; bit < 8; bit++ {
+			z := uint16(0)
+			if (crc & 0x8000) != 0 {
+				z = 0x1021
+			}
+			crc = (crc << 1) ^ z
+		}
+		crc16Table[i] = crc
+	}
+}
+
+// CRC-CCITT as used by GDL90, computed over the unescaped message without flags.
+func CRC(data []byte) uint16 {
+	crc := uint16(0)
+	for _, b := range data {
+		crc = crc16Table[crc>>8] ^ (crc << 8) ^ uint16(b)
+	}
+	return crc
+}
+
+// Frame appends the CRC, escapes flag and control bytes and adds the flags. Same as prepareMessage() in main.
+func Frame(msg []byte) []byte {
+	crc := CRC(msg)
+	data := append(append([]byte(nil), msg...), byte(crc&0xFF), byte(crc>>8))
+	frame := []byte{FLAG_BYTE}
+	for _, b := range data {
+		if b == FLAG_BYTE || b == CONTROL_ESC {
+			frame = append(frame, CONTROL_ESC)
+			b ^= 0x20
+		}
+		frame = append(frame, b)
+	}
+	return append(frame, FLAG_BYTE)
+}
+
+// Unframe reverses Frame(): returns the message (ID byte first) without CRC. Flags are optional.
+func Unframe(frame []byte) ([]byte, error) {
+	if len(frame) > 0 && frame[0] == FLAG_BYTE {
+		frame = frame[1:]
+	}
+	if len(frame) > 0 && frame[len(frame)-1] == FLAG_BYTE {
+		frame = frame[:len(frame)-1]
+	}
+	data := make([]byte, 0, len(frame))
+	for i := 0; i < len(frame); i++ {
+		b := frame[i]
+		if b == FLAG_BYTE {
+			return nil, ErrFrame
+		}
+		if b == CONTROL_ESC {
+			i++
+			if i >= len(frame) {
+				return nil, ErrFrame
+			}
+			b = frame[i] ^ 0x20
+		}
+		data = append(data, b)
+	}
+	if len(data) < 3 {
+		return nil, ErrShort
+	}
+	msg := data[:len(data)-2]
+	crc := uint16(data[len(data)-2]) | uint16(data[len(data)-1])<<8
+	if CRC(msg) != crc {
+		return nil, ErrCRC
+	}
+	return msg, nil
+}
+
+// Split returns all complete frames (including flags) in buf, and the remaining bytes of an incomplete frame.
+// UDP datagrams usually contain one or more complete frames, for streams the rest is prepended to the next read.
+func Split(buf []byte) (frames [][]byte, rest []byte) {
+	start := -1
+	for i, b := range buf {
+		if b != FLAG_BYTE {
+			continue
+		}
+		if start >= 0 && i > start+1 {
+			frames = append(frames, buf[start:i+1])
+		}
+		start = i // a closing flag may also open the next frame
+	}
+	if start >= 0 && start < len(buf)-1 {
+		rest = buf[start:]
+	}
+	return
+}
+
+type Heartbeat struct {
+	GPSPosValid     bool
+	MaintReq        bool
+	Ident           bool
+	AddrTalkback    bool
+	GPSBattLow      bool
+	RATCS           bool
+	UATInitialized  bool
+	CSARequested    bool
+	CSANotAvailable bool
+	UTCOK           bool
+	Timestamp       uint32 // seconds since 0000Z
+	UplinkCount     uint8  // uplink messages received in the previous second
+	BasicLongCount  uint16 // basic and long reports received in the previous second
+}
+
+type Report struct {
+	Alert         bool
+	AddrType      uint8 // 0: ADS-B ICAO, 1: ADS-B self-assigned, 2: TIS-B ICAO, 3: TIS-B track file, 4: surface vehicle, 5: ground station beacon
+	Address       uint32
+	Lat           float32
+	Lng           float32
+	PositionValid bool // false for lat=lng=0 with NIC=0, the spec's "no position" value
+	AltitudeValid bool
+	Altitude      int32 // feet, pressure altitude
+	TrackType     uint8 // TRACK_*
+	Extrapolated  bool
+	Airborne      bool
+	NIC           uint8
+	NACp          uint8
+	SpeedValid    bool
+	Speed         uint16 // knots
+	VvelValid     bool
+	Vvel          int16   // feet per minute
+	Track         float32 // degrees, see TrackType
+	Emitter       uint8
+	Callsign      string
+	Emergency     uint8 // emergency/priority code, as in DO-260B / DO-282B
+}
+
+type TrafficReport struct {
+	Report
+}
+
+type OwnshipReport struct {
+	Report
+}
+
+type OwnshipGeoAltitude struct {
+	Altitude        int32 // feet, 5 ft resolution. HAE, or MSL if the ForeFlight ID message says so
+	VerticalWarning bool
+	VFOMValid       bool
+	VFOM            uint16 // meters
+}
+
+type HeightAboveTerrain struct {
+	Valid  bool
+	Height int16 // feet
+}
+
+// Uplink, basic and long report messages wrap raw UAT frames.
+type UATMessage struct {
+	ID              uint8
+	TimeOfReception uint32 // 80 ns units since the last UTC second, TIME_OF_RECEPTION_INVALID if unknown
+	Payload         []byte
+}
+
+type ForeFlightID struct {
+	Version      uint8
+	SerialNumber uint64 // 0xFFFFFFFFFFFFFFFF if invalid
+	Name         string
+	LongName     string
+	MSLGeoAlt    bool // capability mask bit 0: ownship geometric altitude is MSL instead of HAE
+}
+
+type StratuxHeartbeat struct {
+	GPSValid  bool
+	AHRSValid bool
+	Protocol  uint8
+}
+
+// Decode a message as returned by Unframe(). Returns one of *Heartbeat, *TrafficReport, *OwnshipReport,
+// *OwnshipGeoAltitude, *HeightAboveTerrain, *UATMessage, *ForeFlightID or *StratuxHeartbeat.
+// ErrUnknownMessage is returned for other (valid) message IDs, e.g. ForeFlight AHRS.
+func Decode(msg []byte) (interface{}, error) {
+	if len(msg) == 0 {
+		return nil, ErrShort
+	}
+	switch msg[0] {
+	case MSG_HEARTBEAT:
+		return decodeHeartbeat(msg)
+	case MSG_TRAFFIC:
+		r, err := decodeReport(msg)
+		if err != nil {
+			return nil, err
+		}
+		return &TrafficReport{r}, nil
+	case MSG_OWNSHIP:
+		r, err := decodeReport(msg)
+		if err != nil {
+			return nil, err
+		}
+		return &OwnshipReport{r}, nil
+	case MSG_OWNSHIP_GEO_ALT:
+		return decodeGeoAltitude(msg)
+	case MSG_HEIGHT_ABOVE_TERRAIN:
+		if len(msg) < 3 {
+			return nil, ErrShort
+		}
+		hat := int16(uint16(msg[1])<<8 | uint16(msg[2]))
+		return &HeightAboveTerrain{Valid: uint16(hat) != 0x8000, Height: hat}, nil
+	case MSG_UPLINK, MSG_BASIC_REPORT, MSG_LONG_REPORT:
+		return decodeUATMessage(msg)
+	case MSG_FOREFLIGHT:
+		if len(msg) < 2 || msg[1] != 0 {
+			return nil, ErrUnknownMessage
+		}
+		return decodeForeFlightID(msg)
+	case MSG_STRATUX_HEARTBEAT:
+		if len(msg) < 2 {
+			return nil, ErrShort
+		}
+		return &StratuxHeartbeat{
+			GPSValid:  msg[1]&0x02 != 0,
+			AHRSValid: msg[1]&0x01 != 0,
+			Protocol:  msg[1] >> 2,
+		}, nil
+	}
+	return nil, ErrUnknownMessage
+}
+
+func decodeHeartbeat(msg []byte) (*Heartbeat, error) {
+	if len(msg) < 7 {
+		return nil, ErrShort
+	}
+	return &Heartbeat{
+		GPSPosValid:     msg[1]&0x80 != 0,
+		MaintReq:        msg[1]&0x40 != 0,
+		Ident:           msg[1]&0x20 != 0,
+		AddrTalkback:    msg[1]&0x10 != 0,
+		GPSBattLow:      msg[1]&0x08 != 0,
+		RATCS:           msg[1]&0x04 != 0,
+		UATInitialized:  msg[1]&0x01 != 0,
+		CSARequested:    msg[2]&0x40 != 0,
+		CSANotAvailable: msg[2]&0x20 != 0,
+		UTCOK:           msg[2]&0x01 != 0,
+		Timestamp:       uint32(msg[2]>>7)<<16 | uint32(msg[4])<<8 | uint32(msg[3]),
+		UplinkCount:     msg[5] >> 3,
+		BasicLongCount:  uint16(msg[5]&0x03)<<8 | uint16(msg[6]),
+	}, nil
+}
+
+// 24 bit signed fraction of 180 degrees
+func decodeLatLng(b []byte) float32 {
+	v := int32(uint32(b[0])<<16 | uint32(b[1])<<8 | uint32(b[2]))
+	if v&0x800000 != 0 {
+		v -= 0x1000000
+	}
+	return float32(v) * LAT_LNG_RES
+}
+
+func decodeReport(msg []byte) (Report, error) {
+	var r Report
+	if len(msg) < 28 {
+		return r, ErrShort
+	}
+	r.Alert = msg[1]>>4 != 0
+	r.AddrType = msg[1] & 0x0F
+	r.Address = uint32(msg[2])<<16 | uint32(msg[3])<<8 | uint32(msg[4])
+	r.Lat = decodeLatLng(msg[5:8])
+	r.Lng = decodeLatLng(msg[8:11])
+
+	alt := uint16(msg[11])<<4 | uint16(msg[12]>>4)
+	r.AltitudeValid = alt != 0xFFF
+	if r.AltitudeValid {
+		r.Altitude = int32(alt)*25 - 1000
+	}
+	misc := msg[12] & 0x0F
+	r.TrackType = misc & 0x03
+	r.Extrapolated = misc&0x04 != 0
+	r.Airborne = misc&0x08 != 0
+
+	r.NIC = msg[13] >> 4
+	r.NACp = msg[13] & 0x0F
+	r.PositionValid = !(r.Lat == 0 && r.Lng == 0 && r.NIC == 0)
+
+	speed := uint16(msg[14])<<4 | uint16(msg[15]>>4)
+	r.SpeedValid = speed != 0xFFF
+	if r.SpeedValid {
+		r.Speed = speed
+	}
+	vvel := uint16(msg[15]&0x0F)<<8 | uint16(msg[16])
+	r.VvelValid = vvel != 0x800
+	if r.VvelValid {
+		v := int16(vvel)
+		if v&0x800 != 0 {
+			v -= 0x1000
+		}
+		r.Vvel = v * 64
+	}
+	r.Track = float32(msg[17]) * TRACK_RES
+	r.Emitter = msg[18]
+	r.Callsign = strings.TrimRight(string(msg[19:27]), " \x00")
+	r.Emergency = msg[27] >> 4
+	return r, nil
+}
+
+func decodeGeoAltitude(msg []byte) (*OwnshipGeoAltitude, error) {
+	if len(msg) < 5 {
+		return nil, ErrShort
+	}
+	vfom := uint16(msg[3]&0x7F)<<8 | uint16(msg[4])
+	return &OwnshipGeoAltitude{
+		Altitude:        int32(int16(uint16(msg[1])<<8|uint16(msg[2]))) * 5,
+		VerticalWarning: msg[3]&0x80 != 0,
+		VFOMValid:       vfom != VFOM_NOT_AVAILABLE,
+		VFOM:            vfom,
+	}, nil
+}
+
+func decodeUATMessage(msg []byte) (*UATMessage, error) {
+	payloadLen := UPLINK_BYTES
+	switch msg[0] {
+	case MSG_BASIC_REPORT:
+		payloadLen = BASIC_BYTES
+	case MSG_LONG_REPORT:
+		payloadLen = LONG_BYTES
+	}
+	if len(msg) < 4+payloadLen {
+		return nil, ErrShort
+	}
+	return &UATMessage{
+		ID:              msg[0],
+		TimeOfReception: uint32(msg[3])<<16 | uint32(msg[2])<<8 | uint32(msg[1]),
+		Payload:         append([]byte(nil), msg[4:4+payloadLen]...),
+	}, nil
+}
+
+func decodeForeFlightID(msg []byte) (*ForeFlightID, error) {
+	if len(msg) < 39 {
+		return nil, ErrShort
+	}
+	id := &ForeFlightID{
+		Version:   msg[2],
+		Name:      strings.TrimRight(string(msg[11:19]), " \x00"),
+		LongName:  strings.TrimRight(string(msg[19:35]), " \x00"),
+		MSLGeoAlt: msg[38]&0x01 != 0,
+	}
+	for i := 3; i < 11; i++ {
+		id.SerialNumber = id.SerialNumber<<8 | uint64(msg[i])
+	}
+	return id, nil
+}
+
+func (r Report) String() string {
+	return fmt.Sprintf("%06X (type %d) %s %.5f,%.5f %d ft %d kt %.0f deg", r.Address, r.AddrType, r.Callsign, r.Lat, r.Lng, r.Altitude, r.Speed, r.Track)
+}
@@ -0,0 +1,323 @@
+package gdl90
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+// Heartbeat example of the GDL90 spec, section 2.2.3, including flags and CRC.
+var specHeartbeatFrame = []byte{0x7E, 0x00, 0x81, 0x41, 0xDB, 0xD0, 0x08, 0x02, 0xB3, 0x8B, 0x7E}
+
+// Traffic report example of the GDL90 spec, section 3.5.4.
+var specTrafficReport = []byte{0x14, 0x00, 0xAB, 0x45, 0x49, 0x1F, 0xEF, 0x15, 0xA8, 0x89, 0x78, 0x0F, 0x09, 0xA9, 0x07,
+	0xB0, 0x01, 0x20, 0x01, 0x4E, 0x38, 0x32, 0x35, 0x56, 0x20, 0x20, 0x20, 0x00}
+
+// Encodes a traffic or ownship report like makeTrafficReportMsg() / makeOwnshipReport() in main.
+func encodeReport(id byte, r Report) []byte {
+	msg := make([]byte, 28)
+	msg[0] = id
+	msg[1] = r.AddrType & 0x0F
+	if r.Alert {
+		msg[1] |= 0x10
+	}
+	msg[2] = byte(r.Address >> 16)
+	msg[3] = byte(r.Address >> 8)
+	msg[4] = byte(r.Address)
+	encodeLatLng(msg[5:8], r.Lat)
+	encodeLatLng(msg[8:11], r.Lng)
+	alt := uint16(0xFFF)
+	if r.AltitudeValid {
+		alt = uint16((r.Altitude + 1000) / 25)
+	}
+	misc := r.TrackType & 0x03
+	if r.Extrapolated {
+		misc |= 0x04
+	}
+	if r.Airborne {
+		misc |= 0x08
+	}
+	msg[11] = byte(alt >> 4)
+	msg[12] = byte(alt&0x0F)<<4 | misc
+	msg[13] = r.NIC<<4 | r.NACp&0x0F
+	speed := uint16(0xFFF)
+	if r.SpeedValid {
+		speed = r.Speed
+	}
+	vvel := uint16(0x800)
+	if r.VvelValid {
+		vvel = uint16(r.Vvel/64) & 0xFFF
+	}
+	msg[14] = byte(speed >> 4)
+	msg[15] = byte(speed&0x0F)<<4 | byte(vvel>>8)
+	msg[16] = byte(vvel)
+	msg[17] = byte(r.Track / TRACK_RES)
+	msg[18] = r.Emitter
+	copy(msg[19:27], []byte(r.Callsign+"        "))
+	msg[27] = r.Emergency << 4
+	return msg
+}
+
+func encodeLatLng(b []byte, v float32) {
+	x := int32(math.Round(float64(v / LAT_LNG_RES)))
+	b[0] = byte(x >> 16)
+	b[1] = byte(x >> 8)
+	b[2] = byte(x)
+}
+
+func encodeHeartbeat(hb Heartbeat) []byte {
+	msg := make([]byte, 7)
+	msg[0] = MSG_HEARTBEAT
+	flags := []struct {
+		set  bool
+		idx  int
+		mask byte
+	}{
+		{hb.GPSPosValid, 1, 0x80}, {hb.MaintReq, 1, 0x40}, {hb.Ident, 1, 0x20}, {hb.AddrTalkback, 1, 0x10},
+		{hb.GPSBattLow, 1, 0x08}, {hb.RATCS, 1, 0x04}, {hb.UATInitialized, 1, 0x01},
+		{hb.CSARequested, 2, 0x40}, {hb.CSANotAvailable, 2, 0x20}, {hb.UTCOK, 2, 0x01},
+	}
+	for _, f := range flags {
+		if f.set {
+			msg[f.idx] |= f.mask
+		}
+	}
+	msg[2] |= byte(hb.Timestamp>>16) << 7
+	msg[3] = byte(hb.Timestamp)
+	msg[4] = byte(hb.Timestamp >> 8)
+	msg[5] = hb.UplinkCount<<3 | byte(hb.BasicLongCount>>8)&0x03
+	msg[6] = byte(hb.BasicLongCount)
+	return msg
+}
+
+func decodeFrame(t *testing.T, frame []byte) interface{} {
+	t.Helper()
+	msg, err := Unframe(frame)
+	if err != nil {
+		t.Fatalf("Unframe(% X): %v", frame, err)
+	}
+	d, err := Decode(msg)
+	if err != nil {
+		t.Fatalf("Decode(% X): %v", msg, err)
+	}
+	return d
+}
+
+func TestSpecHeartbeat(t *testing.T) {
+	msg, err := Unframe(specHeartbeatFrame)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(Frame(msg), specHeartbeatFrame) {
+		t.Errorf("Frame() = % X, want % X", Frame(msg), specHeartbeatFrame)
+	}
+	hb := decodeFrame(t, specHeartbeatFrame).(*Heartbeat)
+	want := Heartbeat{GPSPosValid: true, UATInitialized: true, CSARequested: true, UTCOK: true, Timestamp: 53467, UplinkCount: 1, BasicLongCount: 2}
+	if *hb != want {
+		t.Errorf("got %+v, want %+v", *hb, want)
+	}
+}
+
+func TestSpecTrafficReport(t *testing.T) {
+	tr := decodeFrame(t, Frame(specTrafficReport)).(*TrafficReport)
+	if tr.Address != 0xAB4549 || tr.AddrType != 0 || tr.Callsign != "N825V" || tr.Emitter != 1 {
+		t.Errorf("identification: %+v", tr.Report)
+	}
+	if math.Abs(float64(tr.Lat)-44.90708) > 0.0001 || math.Abs(float64(tr.Lng)+122.99488) > 0.0001 || !tr.PositionValid {
+		t.Errorf("position %f,%f", tr.Lat, tr.Lng)
+	}
+	if !tr.AltitudeValid || tr.Altitude != 5000 || !tr.Airborne || tr.TrackType != TRACK_TRUE_TRACK || tr.Extrapolated {
+		t.Errorf("altitude / misc: %+v", tr.Report)
+	}
+	if tr.NIC != 10 || tr.NACp != 9 {
+		t.Errorf("NIC %d NACp %d, want 10 and 9", tr.NIC, tr.NACp)
+	}
+	if !tr.SpeedValid || tr.Speed != 123 || !tr.VvelValid || tr.Vvel != 64 || tr.Track != 45 {
+		t.Errorf("velocity: %d kt, %d fpm, %.1f deg", tr.Speed, tr.Vvel, tr.Track)
+	}
+	if !bytes.Equal(encodeReport(MSG_TRAFFIC, tr.Report), specTrafficReport) {
+		t.Errorf("re-encoded % X, want % X", encodeReport(MSG_TRAFFIC, tr.Report), specTrafficReport)
+	}
+}
+
+func TestHeartbeatRoundTrip(t *testing.T) {
+	for _, hb := range []Heartbeat{
+		{},
+		{GPSPosValid: true, UATInitialized: true, UTCOK: true, Timestamp: 86399, UplinkCount: 31, BasicLongCount: 1023},
+		{MaintReq: true, Ident: true, AddrTalkback: true, GPSBattLow: true, RATCS: true, CSARequested: true, CSANotAvailable: true, Timestamp: 65536},
+	} {
+		got := decodeFrame(t, Frame(encodeHeartbeat(hb))).(*Heartbeat)
+		if *got != hb {
+			t.Errorf("got %+v, want %+v", *got, hb)
+		}
+	}
+}
+
+func TestReportRoundTrip(t *testing.T) {
+	reports := []Report{
+		{AddrType: 0, Address: 0x3C6589, Lat: 48.35343, Lng: 11.78611, PositionValid: true, AltitudeValid: true, Altitude: 3500,
+			TrackType: TRACK_TRUE_TRACK, Airborne: true, NIC: 8, NACp: 9, SpeedValid: true, Speed: 110, VvelValid: true, Vvel: -640,
+			Track: 271.40625, Emitter: 1, Callsign: "DEABC"},
+		{AddrType: 1, Address: 0xF00D7E, Lat: -33.94611, Lng: -151.17722, PositionValid: true, AltitudeValid: true, Altitude: -1000,
+			TrackType: TRACK_TRUE_HEADING, Extrapolated: true, NIC: 0, NACp: 0, SpeedValid: true, Speed: 4094, VvelValid: true,
+			Vvel: 32640, Track: 0, Emitter: 14, Callsign: "12345678", Emergency: 7, Alert: true},
+		{AddrType: 2, Address: 0x7D7E7D, PositionValid: false, AltitudeValid: false, SpeedValid: false, VvelValid: false},
+	}
+	for _, id := range []byte{MSG_TRAFFIC, MSG_OWNSHIP} {
+		for _, want := range reports {
+			d := decodeFrame(t, Frame(encodeReport(id, want)))
+			var got Report
+			switch r := d.(type) {
+			case *TrafficReport:
+				if id != MSG_TRAFFIC {
+					t.Fatalf("message %02X decoded as traffic", id)
+				}
+				got = r.Report
+			case *OwnshipReport:
+				if id != MSG_OWNSHIP {
+					t.Fatalf("message %02X decoded as ownship", id)
+				}
+				got = r.Report
+			default:
+				t.Fatalf("unexpected %T", d)
+			}
+			if math.Abs(float64(got.Lat-want.Lat)) > float64(LAT_LNG_RES) || math.Abs(float64(got.Lng-want.Lng)) > float64(LAT_LNG_RES) {
+				t.Errorf("position %f,%f, want %f,%f", got.Lat, got.Lng, want.Lat, want.Lng)
+			}
+			got.Lat, got.Lng = want.Lat, want.Lng
+			if got != want {
+				t.Errorf("got %+v, want %+v", got, want)
+			}
+		}
+	}
+}
+
+func TestCRC(t *testing.T) {
+	if crc := CRC(specHeartbeatFrame[1:8]); crc != 0x8BB3 {
+		t.Errorf("CRC = %04X, want 8BB3", crc)
+	}
+	if crc := CRC(nil); crc != 0 {
+		t.Errorf("CRC(nil) = %04X, want 0", crc)
+	}
+	frame := Frame(specTrafficReport)
+	for i := 1; i < len(frame)-1; i++ {
+		corrupt := append([]byte(nil), frame...)
+		corrupt[i] ^= 0x01
+		if corrupt[i] == FLAG_BYTE || corrupt[i] == CONTROL_ESC || frame[i] == CONTROL_ESC {
+			continue
+		}
+		if _, err := Unframe(corrupt); err != ErrCRC {
+			t.Errorf("bit flip in byte %d: got %v, want ErrCRC", i, err)
+		}
+	}
+}
+
+func TestByteStuffing(t *testing.T) {
+	// Flag and control bytes in the message are escaped
+	msg := []byte{MSG_UPLINK, FLAG_BYTE, CONTROL_ESC, 0x5E, 0x5D, FLAG_BYTE ^ 0x20, CONTROL_ESC, CONTROL_ESC}
+	frame := Frame(msg)
+	if bytes.Count(frame, []byte{FLAG_BYTE}) != 2 || frame[0] != FLAG_BYTE || frame[len(frame)-1] != FLAG_BYTE {
+		t.Errorf("unescaped flag in % X", frame)
+	}
+	if !bytes.HasPrefix(frame, []byte{FLAG_BYTE, MSG_UPLINK, CONTROL_ESC, 0x5E, CONTROL_ESC, 0x5D, 0x5E, 0x5D, 0x5E, CONTROL_ESC, 0x5D, CONTROL_ESC, 0x5D}) {
+		t.Errorf("Frame() = % X", frame)
+	}
+	got, err := Unframe(frame)
+	if err != nil || !bytes.Equal(got, msg) {
+		t.Errorf("Unframe() = % X, %v, want % X", got, err, msg)
+	}
+
+	// The CRC is escaped as well. Find messages whose CRC contains a flag or control byte.
+	for _, special := range []byte{FLAG_BYTE, CONTROL_ESC} {
+		found := false
+		for i := 0; i < 0x10000 && !found; i++ {
+			msg := []byte{MSG_HEARTBEAT, byte(i >> 8), byte(i)}
+			crc := CRC(msg)
+			if byte(crc) != special && byte(crc>>8) != special {
+				continue
+			}
+			found = true
+			frame := Frame(msg)
+			if bytes.Count(frame, []byte{FLAG_BYTE}) != 2 {
+				t.Errorf("CRC %04X not escaped: % X", crc, frame)
+			}
+			if got, err := Unframe(frame); err != nil || !bytes.Equal(got, msg) {
+				t.Errorf("Unframe(% X) = % X, %v", frame, got, err)
+			}
+		}
+		if !found {
+			t.Errorf("no CRC with byte %02X", special)
+		}
+	}
+
+	// Frames without flags are accepted
+	if got, err := Unframe(frame[1 : len(frame)-1]); err != nil || !bytes.Equal(got, msg) {
+		t.Errorf("Unframe() without flags = % X, %v", got, err)
+	}
+}
+
+func TestUnframeErrors(t *testing.T) {
+	frame := Frame(specTrafficReport)
+	tests := []struct {
+		name  string
+		frame []byte
+		err   error
+	}{
+		{"empty", []byte{}, ErrShort},
+		{"flags only", []byte{FLAG_BYTE, FLAG_BYTE}, ErrShort},
+		{"CRC only", []byte{FLAG_BYTE, 0x00, 0x00, FLAG_BYTE}, ErrShort},
+		{"escape at end", []byte{FLAG_BYTE, 0x00, 0x01, 0x02, CONTROL_ESC, FLAG_BYTE}, ErrFrame},
+		{"flag inside", append(append(append([]byte(nil), frame[:5]...), FLAG_BYTE), frame[5:]...), ErrFrame},
+		{"truncated", frame[:len(frame)-3], ErrCRC},
+	}
+	for _, tc := range tests {
+		if _, err := Unframe(tc.frame); err != tc.err {
+			t.Errorf("%s: got %v, want %v", tc.name, err, tc.err)
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	hb := specHeartbeatFrame
+	tr := Frame(specTrafficReport)
+	var stream []byte
+	stream = append(stream, 0x01, 0x02) // tail of a frame we missed the start of
+	stream = append(stream, hb...)
+	stream = append(stream, tr...)
+	stream = append(stream, tr[:10]...)
+
+	frames, rest := Split(stream)
+	if len(frames) != 2 || !bytes.Equal(frames[0], hb) || !bytes.Equal(frames[1], tr) {
+		t.Fatalf("frames % X", frames)
+	}
+	if !bytes.Equal(rest, tr[:10]) {
+		t.Errorf("rest % X, want % X", rest, tr[:10])
+	}
+	// The rest is completed by the next read
+	frames, rest = Split(append(rest, tr[10:]...))
+	if len(frames) != 1 || !bytes.Equal(frames[0], tr) || len(rest) != 0 {
+		t.Errorf("frames % X, rest % X", frames, rest)
+	}
+
+	// Frames sharing a flag and empty frames between double flags
+	shared := append(append([]byte(nil), hb...), tr[1:]...)
+	shared = append(shared, FLAG_BYTE)
+	frames, rest = Split(shared)
+	if len(frames) != 2 || !bytes.Equal(frames[0], hb) || !bytes.Equal(frames[1], tr) || len(rest) != 0 {
+		t.Errorf("shared flags: frames % X, rest % X", frames, rest)
+	}
+	for _, f := range frames {
+		decodeFrame(t, f)
+	}
+}
+
+func TestDecodeShort(t *testing.T) {
+	for _, msg := range [][]byte{{}, {MSG_HEARTBEAT, 0}, specTrafficReport[:27], {MSG_OWNSHIP_GEO_ALT, 0}, {MSG_UPLINK, 0, 0, 0}} {
+		if _, err := Decode(msg); err != ErrShort {
+			t.Errorf("Decode(% X): got %v, want ErrShort", msg, err)
+		}
+	}
+	if _, err := Decode([]byte{0x4C, 0x45}); err != ErrUnknownMessage {
+		t.Errorf("got %v, want ErrUnknownMessage", err)
+	}
+}
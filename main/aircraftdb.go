@@ -0,0 +1,405 @@
+/*
+	Copyright (c) 2026 Stratux contributors
+	Distributable under the terms of The "BSD New" License
+	that can be found in the LICENSE file, herein included
+	as part of this header.
+
+	aircraftdb.go: Local aircraft database (ICAO address -> registration, type designator, operator, category).
+		Imported from a CSV dump (e.g. the OpenSky aircraft database) or an SQLite file and used to fill in
+		TrafficInfo fields for all traffic sources.
+*/
+
+package main
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const (
+	aircraftDBFile          = STRATUX_HOME + "aircraftdb.sqlite"
+	aircraftDBMaxCacheSize  = 20000
+	aircraftDBSqliteMagic   = "SQLite format 3\x00"
+)
+
+type aircraftRecord struct {
+	Registration string
+	TypeCode     string // ICAO type designator, e.g. C172
+	Operator     string
+	Category     uint8  // GDL90 emitter category, 0 = unknown
+}
+
+var aircraftDB *sql.DB
+var aircraftDBMutex sync.Mutex
+var aircraftDBCache map[uint32]*aircraftRecord = make(map[uint32]*aircraftRecord) // nil entries cache misses
+
+// Header names used by common aircraft database dumps, lower case
+var aircraftDBColumnNames = map[string][]string{
+	"icao":         {"icao24", "icao", "hex", "modes", "icao24bit", "icao_hex"},
+	"registration": {"registration", "reg", "regid", "tail"},
+	"typecode":     {"typecode", "icaotype", "type", "type_designator"},
+	"operator":     {"operator", "owner", "operator_name"},
+	"category":     {"category", "categorydescription", "emitter_category", "cat"},
+}
+
+func initAircraftDB() {
+	aircraftDBMutex.Lock()
+	defer aircraftDBMutex.Unlock()
+	openAircraftDB()
+}
+
+// Must be called with aircraftDBMutex held
+func openAircraftDB() {
+	if _, err := os.Stat(aircraftDBFile); err != nil {
+		setAircraftDB(nil, 0)
+		return
+	}
+	db, count, err := loadAircraftDB(aircraftDBFile)
+	if err != nil {
+		log.Printf("Invalid aircraft database %s: %s\n", aircraftDBFile, err.Error())
+	}
+	setAircraftDB(db, count)
+}
+
+// Opens and validates the aircraft database at path and returns it with its number of records.
+func loadAircraftDB(path string) (*sql.DB, int, error) {
+	db, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return nil, 0, err
+	}
+	count, err := validateAircraftDB(db)
+	if err != nil {
+		db.Close()
+		return nil, 0, err
+	}
+	return db, count, nil
+}
+
+// Replaces the current aircraft database. Must be called with aircraftDBMutex held
+func setAircraftDB(db *sql.DB, count int) {
+	if aircraftDB != nil {
+		aircraftDB.Close()
+	}
+	aircraftDB = db
+	aircraftDBCache = make(map[uint32]*aircraftRecord)
+	globalStatus.AircraftDB_records = count
+	if db != nil {
+		log.Printf("Aircraft database loaded with %d records\n", count)
+	}
+}
+
+// Checks that the aircraft table has all expected columns and returns the number of records
+func validateAircraftDB(db *sql.DB) (int, error) {
+	rows, err := db.Query("SELECT icao, registration, typecode, operator, category FROM aircraft LIMIT 1")
+	if err != nil {
+		return 0, err
+	}
+	rows.Close()
+	var count int
+	err = db.QueryRow("SELECT COUNT(*) FROM aircraft").Scan(&count)
+	return count, err
+}
+
+func lookupAircraft(icao uint32) *aircraftRecord {
+	aircraftDBMutex.Lock()
+	defer aircraftDBMutex.Unlock()
+	if aircraftDB == nil {
+		return nil
+	}
+	if rec, ok := aircraftDBCache[icao]; ok {
+		return rec
+	}
+	if len(aircraftDBCache) > aircraftDBMaxCacheSize {
+		aircraftDBCache = make(map[uint32]*aircraftRecord)
+	}
+	var rec aircraftRecord
+	err := aircraftDB.QueryRow("SELECT registration, typecode, operator, category FROM aircraft WHERE icao=?", icao).Scan(&rec.Registration, &rec.TypeCode, &rec.Operator, &rec.Category)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("Aircraft database lookup failed: %s\n", err.Error())
+		}
+		aircraftDBCache[icao] = nil
+		return nil
+	}
+	aircraftDBCache[icao] = &rec
+	return &rec
+}
+
+/*
+	fillTrafficFromAircraftDB() fills in registration, type, operator and emitter category from the aircraft database.
+		Only used for ICAO addresses - FLARM IDs, TIS-B track files and AIS MMSIs live in a different address space.
+		Data received from the target itself takes precedence.
+*/
+func fillTrafficFromAircraftDB(ti *TrafficInfo) {
+	if ti.Last_source == TRAFFIC_SOURCE_AIS || (ti.Addr_type != 0 && ti.Addr_type != 2) {
+		return
+	}
+	rec := lookupAircraft(ti.Icao_addr)
+	if rec == nil {
+		return
+	}
+	if len(ti.Reg) == 0 {
+		ti.Reg = rec.Registration
+	}
+	ti.AircraftType = rec.TypeCode
+	ti.Operator = rec.Operator
+	if ti.Emitter_category == 0 {
+		ti.Emitter_category = rec.Category
+	}
+}
+
+/*
+	parseAircraftCategory() understands GDL90 emitter categories (numeric), ADS-B emitter categories (A0-C7) and
+		the category descriptions used by the OpenSky database.
+*/
+func parseAircraftCategory(s string) uint8 {
+	s = strings.TrimSpace(s)
+	if len(s) == 0 {
+		return 0
+	}
+	if n, err := strconv.Atoi(s); err == nil {
+		if n >= 0 && n <= 39 {
+			return uint8(n)
+		}
+		return 0
+	}
+	upper := strings.ToUpper(s)
+	if len(upper) == 2 && upper[0] >= 'A' && upper[0] <= 'C' && upper[1] >= '0' && upper[1] <= '7' {
+		// A7 becomes 0x07, B0 becomes 0x08, etc.
+		return (upper[0]-'A')*8 + (upper[1] - '0')
+	}
+	lower := strings.ToLower(s)
+	switch {
+	case strings.Contains(lower, "glider") && strings.Contains(lower, "hang"),
+		strings.Contains(lower, "ultralight"), strings.Contains(lower, "paraglider"):
+		return 12
+	case strings.Contains(lower, "glider"), strings.Contains(lower, "sailplane"):
+		return 9
+	case strings.Contains(lower, "rotorcraft"), strings.Contains(lower, "helicopter"):
+		return 7
+	case strings.Contains(lower, "lighter"), strings.Contains(lower, "balloon"):
+		return 10
+	case strings.Contains(lower, "parachutist"), strings.Contains(lower, "skydiver"):
+		return 11
+	case strings.Contains(lower, "unmanned"), strings.Contains(lower, "uav"):
+		return 14
+	case strings.Contains(lower, "space"):
+		return 15
+	case strings.Contains(lower, "high performance"):
+		return 6
+	case strings.Contains(lower, "high vortex"):
+		return 4
+	case strings.Contains(lower, "heavy"):
+		return 5
+	case strings.Contains(lower, "large"):
+		return 3
+	case strings.Contains(lower, "small"):
+		return 2
+	case strings.Contains(lower, "light"):
+		return 1
+	}
+	return 0
+}
+
+func createAircraftDBSchema(db *sql.DB) error {
+	_, err := db.Exec("CREATE TABLE IF NOT EXISTS aircraft (icao INTEGER PRIMARY KEY, registration TEXT NOT NULL DEFAULT '', typecode TEXT NOT NULL DEFAULT '', operator TEXT NOT NULL DEFAULT '', category INTEGER NOT NULL DEFAULT 0)")
+	return err
+}
+
+/*
+	importAircraftCSV() converts a CSV dump into an SQLite aircraft database at dbPath. The columns are identified by
+		their header names (see aircraftDBColumnNames), the delimiter (, or ;) is detected from the header line.
+		Returns the number of imported records.
+*/
+func importAircraftCSV(r io.Reader, dbPath string) (int, error) {
+	br := bufio.NewReader(r)
+	header, err := br.Peek(4096)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return 0, err
+	}
+	firstLine := string(header)
+	if idx := strings.IndexByte(firstLine, '\n'); idx >= 0 {
+		firstLine = firstLine[:idx]
+	}
+	reader := csv.NewReader(br)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	if strings.Count(firstLine, ";") > strings.Count(firstLine, ",") {
+		reader.Comma = ';'
+	}
+
+	headerFields, err := reader.Read()
+	if err != nil {
+		return 0, err
+	}
+	columns := make(map[string]int)
+	for i, name := range headerFields {
+		name = strings.ToLower(strings.Trim(strings.TrimSpace(name), "'\"\ufeff"))
+		for field, aliases := range aircraftDBColumnNames {
+			if _, ok := columns[field]; ok {
+				continue
+			}
+			for _, alias := range aliases {
+				if name == alias {
+					columns[field] = i
+				}
+			}
+		}
+	}
+	if _, ok := columns["icao"]; !ok {
+		return 0, errors.New("no ICAO address column found in CSV header")
+	}
+
+	os.Remove(dbPath)
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+	if err = createAircraftDBSchema(db); err != nil {
+		return 0, err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	stmt, err := tx.Prepare("INSERT OR REPLACE INTO aircraft (icao, registration, typecode, operator, category) VALUES (?, ?, ?, ?, ?)")
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	defer stmt.Close()
+
+	field := func(record []string, name string) string {
+		if idx, ok := columns[name]; ok && idx < len(record) {
+			return strings.TrimSpace(record[idx])
+		}
+		return ""
+	}
+	count := 0
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			continue // skip broken lines
+		}
+		icao, err := strconv.ParseUint(field(record, "icao"), 16, 32)
+		if err != nil || icao > 0xFFFFFF {
+			continue
+		}
+		_, err = stmt.Exec(icao, field(record, "registration"), strings.ToUpper(field(record, "typecode")), field(record, "operator"), parseAircraftCategory(field(record, "category")))
+		if err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+		count++
+	}
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+	if count == 0 {
+		return 0, errors.New("no aircraft records found in CSV")
+	}
+	return count, nil
+}
+
+func isSqliteFile(fname string) bool {
+	f, err := os.Open(fname)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	magic := make([]byte, len(aircraftDBSqliteMagic))
+	if _, err := io.ReadFull(f, magic); err != nil {
+		return false
+	}
+	return string(magic) == aircraftDBSqliteMagic
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+/*
+	installAircraftDB() validates (and if necessary converts) an uploaded CSV or SQLite file and replaces the current
+		aircraft database with it. Also writes it to the read-only base of the overlay file system (if present), so
+		the update survives a reboot. Lookups continue on the old database until the new one is swapped in.
+*/
+func installAircraftDB(uploaded string) (int, error) {
+	dbPath := uploaded
+	if !isSqliteFile(uploaded) {
+		f, err := os.Open(uploaded)
+		if err != nil {
+			return 0, err
+		}
+		dbPath = uploaded + ".sqlite"
+		_, err = importAircraftCSV(f, dbPath)
+		f.Close()
+		if err != nil {
+			return 0, fmt.Errorf("CSV import failed: %s", err.Error())
+		}
+		defer os.Remove(dbPath)
+	}
+	db, _, err := loadAircraftDB(dbPath)
+	if err != nil {
+		return 0, fmt.Errorf("not a valid aircraft database: %s", err.Error())
+	}
+	db.Close()
+
+	// Copied next to the current database and renamed over it. The open database keeps reading the old file.
+	tmpPath := aircraftDBFile + ".new"
+	if err := copyFile(dbPath, tmpPath); err != nil {
+		os.Remove(tmpPath)
+		return 0, err
+	}
+	if err := os.Rename(tmpPath, aircraftDBFile); err != nil {
+		os.Remove(tmpPath)
+		return 0, err
+	}
+	if _, err := os.Stat("/overlay/robase"); err == nil {
+		persistAircraftDB(dbPath)
+	}
+	db, count, err := loadAircraftDB(aircraftDBFile)
+	if err != nil {
+		return 0, err
+	}
+
+	aircraftDBMutex.Lock()
+	defer aircraftDBMutex.Unlock()
+	setAircraftDB(db, count)
+	return count, nil
+}
+
+func persistAircraftDB(dbPath string) {
+	overlayctl("unlock")
+	defer overlayctl("lock")
+	if err := copyFile(dbPath, "/overlay/robase"+aircraftDBFile); err != nil {
+		log.Printf("Failed to persist aircraft database: %s\n", err.Error())
+	}
+}
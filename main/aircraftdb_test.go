@@ -0,0 +1,181 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Imports the CSV into an SQLite database in a temporary directory and makes it the current aircraft database.
+func loadTestAircraftDB(t *testing.T, csvData string) int {
+	dbPath := filepath.Join(t.TempDir(), "aircraftdb.sqlite")
+	imported, err := importAircraftCSV(strings.NewReader(csvData), dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, count, err := loadAircraftDB(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != imported {
+		t.Errorf("imported %d records, database has %d", imported, count)
+	}
+	aircraftDBMutex.Lock()
+	setAircraftDB(db, count)
+	aircraftDBMutex.Unlock()
+	t.Cleanup(func() {
+		aircraftDBMutex.Lock()
+		setAircraftDB(nil, 0)
+		aircraftDBMutex.Unlock()
+	})
+	return count
+}
+
+func TestImportAircraftCSV(t *testing.T) {
+	data, err := os.ReadFile("../test-data/aircraftdb-opensky.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The addresses zzzzzz and 1234567 are skipped
+	if count := loadTestAircraftDB(t, string(data)); count != 7 {
+		t.Errorf("%d records, want 7", count)
+	}
+
+	tests := []struct {
+		icao     uint32
+		reg      string
+		typeCode string
+		operator string
+		category uint8
+	}{
+		{0xA12345, "N123AB", "C172", "", 1},
+		{0x4CA7B3, "EI-DCL", "B738", "Ryanair", 3},
+		{0x3E0E2B, "D-HDEC", "EC35", "ADAC Luftrettung", 7},
+		{0x4B2A5F, "HB-1234", "DIS2", "", 9},
+		{0xAC82EC, "N9072T", "", "", 0},
+		{0x40621D, "G-EZWD", "A320", "easyJet", 3},
+	}
+	for _, tt := range tests {
+		rec := lookupAircraft(tt.icao)
+		if rec == nil {
+			t.Errorf("%X: not found", tt.icao)
+			continue
+		}
+		if rec.Registration != tt.reg || rec.TypeCode != tt.typeCode || rec.Operator != tt.operator || rec.Category != tt.category {
+			t.Errorf("%X: %+v", tt.icao, *rec)
+		}
+	}
+	if rec := lookupAircraft(0xABCDEF); rec != nil {
+		t.Errorf("ABCDEF: found %+v", *rec)
+	}
+}
+
+func TestImportAircraftCSVSemicolon(t *testing.T) {
+	csvData := "hex;reg;icaotype;owner;cat\n" +
+		"A12345;N123AB;C172;;A1\n" +
+		"a1b2c3;N55HX;R44;Heli Tours;A7\n" +
+		"broken line\n" +
+		"AB0001;N1KG;ASK21;;B1\n"
+	if count := loadTestAircraftDB(t, csvData); count != 3 {
+		t.Errorf("%d records, want 3", count)
+	}
+	if rec := lookupAircraft(0xA1B2C3); rec == nil || rec.Registration != "N55HX" || rec.Operator != "Heli Tours" || rec.Category != 7 {
+		t.Errorf("A1B2C3: %+v", rec)
+	}
+	if rec := lookupAircraft(0xAB0001); rec == nil || rec.TypeCode != "ASK21" || rec.Category != 9 {
+		t.Errorf("AB0001: %+v", rec)
+	}
+}
+
+func TestImportAircraftCSVNoAddress(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "aircraftdb.sqlite")
+	if _, err := importAircraftCSV(strings.NewReader("registration,typecode\nN123AB,C172\n"), dbPath); err == nil {
+		t.Error("imported a CSV without addresses")
+	}
+	if _, err := importAircraftCSV(strings.NewReader("icao24,registration\n"), dbPath); err == nil {
+		t.Error("imported a CSV without records")
+	}
+}
+
+func TestFillTrafficFromAircraftDB(t *testing.T) {
+	loadTestAircraftDB(t, "icao24,registration,typecode,operator,category\n"+
+		"a12345,N123AB,C172,Flying Club,Light (< 15500 lbs)\n"+
+		"3e0e2b,D-HDEC,EC35,ADAC Luftrettung,Rotorcraft\n")
+
+	tests := []struct {
+		name string
+		ti   TrafficInfo
+		want TrafficInfo
+	}{
+		{"ES",
+			TrafficInfo{Icao_addr: 0xA12345, Last_source: TRAFFIC_SOURCE_1090ES},
+			TrafficInfo{Reg: "N123AB", AircraftType: "C172", Operator: "Flying Club", Emitter_category: 1}},
+		{"received data wins",
+			TrafficInfo{Icao_addr: 0x3E0E2B, Last_source: TRAFFIC_SOURCE_1090ES, Reg: "DHDEC", Emitter_category: 1},
+			TrafficInfo{Reg: "DHDEC", AircraftType: "EC35", Operator: "ADAC Luftrettung", Emitter_category: 1}},
+		{"OGN with ICAO address",
+			TrafficInfo{Icao_addr: 0x3E0E2B, Last_source: TRAFFIC_SOURCE_OGN, Addr_type: 2},
+			TrafficInfo{Reg: "D-HDEC", AircraftType: "EC35", Operator: "ADAC Luftrettung", Emitter_category: 7}},
+		{"self-assigned address",
+			TrafficInfo{Icao_addr: 0xA12345, Last_source: TRAFFIC_SOURCE_UAT, Addr_type: 1},
+			TrafficInfo{}},
+		{"AIS",
+			TrafficInfo{Icao_addr: 0xA12345, Last_source: TRAFFIC_SOURCE_AIS},
+			TrafficInfo{}},
+		{"unknown",
+			TrafficInfo{Icao_addr: 0x000001, Last_source: TRAFFIC_SOURCE_1090ES},
+			TrafficInfo{}},
+	}
+	for _, tt := range tests {
+		ti := tt.ti
+		fillTrafficFromAircraftDB(&ti)
+		if ti.Reg != tt.want.Reg || ti.AircraftType != tt.want.AircraftType || ti.Operator != tt.want.Operator ||
+			ti.Emitter_category != tt.want.Emitter_category {
+			t.Errorf("%s: reg %s type %s operator %s category %d", tt.name, ti.Reg, ti.AircraftType, ti.Operator,
+				ti.Emitter_category)
+		}
+	}
+}
+
+func TestParseAircraftCategory(t *testing.T) {
+	tests := []struct {
+		in   string
+		want uint8
+	}{
+		{"", 0},
+		{"0", 0},
+		{"7", 7},
+		{"39", 39},
+		{"40", 0},
+		{"-1", 0},
+		{"A0", 0},
+		{"a1", 1},
+		{"A7", 7},
+		{"B0", 8},
+		{"B1", 9},
+		{"B6", 14},
+		{"C7", 23},
+		{"D1", 0},
+		{"No ADS-B Emitter Category Information", 0},
+		{"Light (< 15500 lbs)", 1},
+		{"Small (15500 to 75000 lbs)", 2},
+		{"Large (75000 to 300000 lbs)", 3},
+		{"High Vortex Large (aircraft such as B-757)", 4},
+		{"Heavy (> 300000 lbs)", 5},
+		{"High Performance (> 5g acceleration and 400 kts)", 6},
+		{"Rotorcraft", 7},
+		{"Glider / sailplane", 9},
+		{"Lighter-than-air", 10},
+		{"Parachutist / Skydiver", 11},
+		{"Ultralight / hang-glider / paraglider", 12},
+		{"Unmanned Aerial Vehicle", 14},
+		{"Space / Trans-atmospheric vehicle", 15},
+		{"Surface Vehicle – Emergency Vehicle", 0},
+	}
+	for _, tt := range tests {
+		if got := parseAircraftCategory(tt.in); got != tt.want {
+			t.Errorf("%q: %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
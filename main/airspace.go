@@ -0,0 +1,225 @@
+/*
+	Copyright (c) 2026 Stratux contributors
+	Distributable under the terms of The "BSD New" License
+	that can be found in the LICENSE file, herein included
+	as part of this header.
+
+	airspace.go: Airspace restrictions (TFRs, NOTAM-D/FDC with graphics) built from the FIS-B NOTAM products kept by
+		weatheroverlays.go, see uatparse/notam.go. New and changed restrictions are sent to the /weather websocket,
+		all of them are served as GeoJSON on /weather/restrictions. Ownship is checked against the active areas and
+		a system error is raised while inside one.
+*/
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/b3nn0/stratux/uatparse"
+)
+
+const (
+	AIRSPACE_RESTRICTION_TIMEOUT = WEATHER_OVERLAY_TIMEOUT
+	AIRSPACE_CHECK_INTERVAL      = 2 * time.Second
+)
+
+type airspaceRestrictionEntry struct {
+	Restriction *uatparse.AirspaceRestriction
+	LastSeen    time.Time // stratuxClock
+	Incursion   bool      // ownship is inside
+}
+
+// Keyed like weatherOverlays
+var airspaceRestrictions = make(map[string]*airspaceRestrictionEntry)
+var airspaceMutex sync.Mutex
+
+// Sent to the /weather websocket. The embedded WeatherMessage lets the weather page list it like the text reports.
+type AirspaceRestrictionMessage struct {
+	WeatherMessage
+	Restriction *uatparse.AirspaceRestriction
+}
+
+func isRestrictionProduct(productID uint32) bool {
+	return productID == uatparse.PRODUCT_NOTAM || productID == uatparse.PRODUCT_NOTAM_TRA || productID == uatparse.PRODUCT_NOTAM_TFR
+}
+
+func airspaceSystemErrorIdent(key string) string {
+	return "airspace-" + key
+}
+
+// Called from registerWeatherOverlayFrame() whenever a NOTAM report was received. weatherOverlaysMutex is held.
+func updateAirspaceRestriction(key string, o *weatherOverlay) {
+	recordKeys := make([]string, 0, len(o.Records))
+	for rk := range o.Records {
+		recordKeys = append(recordKeys, rk)
+	}
+	sort.Strings(recordKeys)
+	records := make([]uatparse.OverlayRecord, 0, len(recordKeys))
+	for _, rk := range recordKeys {
+		records = append(records, o.Records[rk])
+	}
+	a := uatparse.NewAirspaceRestriction(o.ProductID, o.Location, o.ReportNumber, o.ReportYear, o.Text, records, time.Now().UTC())
+	if len(a.Shapes) == 0 && a.Type != "NOTAM-TFR" {
+		return // text only NOTAM, no restriction (yet)
+	}
+
+	airspaceMutex.Lock()
+	entry, ok := airspaceRestrictions[key]
+	changed := !ok || entry.Restriction.Text != a.Text || len(entry.Restriction.Shapes) != len(a.Shapes)
+	if !ok {
+		entry = &airspaceRestrictionEntry{}
+		airspaceRestrictions[key] = entry
+	}
+	entry.Restriction = a
+	entry.LastSeen = stratuxClock.Time
+	airspaceMutex.Unlock()
+
+	if changed {
+		sendAirspaceRestriction(a)
+	}
+}
+
+// Called from registerWeatherOverlayFrame() for cancelled reports.
+func deleteAirspaceRestriction(key string) {
+	airspaceMutex.Lock()
+	defer airspaceMutex.Unlock()
+	if entry, ok := airspaceRestrictions[key]; ok {
+		if entry.Incursion {
+			removeSingleSystemError(airspaceSystemErrorIdent(key))
+		}
+		delete(airspaceRestrictions, key)
+	}
+}
+
+func sendAirspaceRestriction(a *uatparse.AirspaceRestriction) {
+	var msg AirspaceRestrictionMessage
+	msg.Type = a.Type
+	msg.Location = a.Location
+	if !a.Start.IsZero() {
+		msg.Time = a.Start.Format("021504Z")
+	}
+	msg.Data = a.Text
+	if len(msg.Data) == 0 {
+		msg.Data = a.ID
+	}
+	msg.LocaltimeReceived = stratuxClock.Time
+	msg.Restriction = a
+	weatherUpdate.SendJSON(msg)
+	mqttPublishJSON("weather/"+strings.ToLower(a.Type), msg, false)
+}
+
+func airspaceLimits(bottom, top int32) string {
+	limits := "SFC"
+	if bottom > 0 {
+		limits = fmt.Sprintf("%d ft", bottom)
+	}
+	if top > 0 {
+		return fmt.Sprintf("%s-%d ft", limits, top)
+	}
+	return limits + " and above"
+}
+
+// Checks ownship against the active restrictions and removes expired ones.
+func airspaceRestrictionWatcher() {
+	ticker := time.NewTicker(AIRSPACE_CHECK_INTERVAL)
+	for {
+		<-ticker.C
+		now := time.Now().UTC()
+		gpsValid := isGPSValid()
+		lat, lng, alt := float64(mySituation.GPSLatitude), float64(mySituation.GPSLongitude), int32(mySituation.GPSAltitudeMSL)
+
+		airspaceMutex.Lock()
+		for key, entry := range airspaceRestrictions {
+			a := entry.Restriction
+			if stratuxClock.Since(entry.LastSeen) > AIRSPACE_RESTRICTION_TIMEOUT || (!a.End.IsZero() && !now.Before(a.End)) {
+				if entry.Incursion {
+					removeSingleSystemError(airspaceSystemErrorIdent(key))
+				}
+				delete(airspaceRestrictions, key)
+				continue
+			}
+			inside := gpsValid && a.IsArea() && a.Contains(lat, lng, alt, now)
+			if inside && !entry.Incursion {
+				log.Printf("Airspace: entered %s %s (%s)\n", a.Type, a.ID, airspaceLimits(a.AltBottom, a.AltTop))
+				addSingleSystemErrorf(airspaceSystemErrorIdent(key), "Inside %s %s, %s.", a.Type, a.ID, airspaceLimits(a.AltBottom, a.AltTop))
+			} else if !inside && entry.Incursion {
+				log.Printf("Airspace: left %s %s\n", a.Type, a.ID)
+				removeSingleSystemError(airspaceSystemErrorIdent(key))
+			}
+			entry.Incursion = inside
+		}
+		airspaceMutex.Unlock()
+	}
+}
+
+func airspaceRestrictionFeature(entry *airspaceRestrictionEntry, now time.Time) (geoJSONFeature, bool) {
+	a := entry.Restriction
+	props := map[string]interface{}{
+		"id":              a.ID,
+		"type":            a.Type,
+		"product_id":      a.ProductID,
+		"location":        a.Location,
+		"report":          fmt.Sprintf("%d-%02d", a.ReportNumber, a.ReportYear),
+		"altitude_bottom": a.AltBottom,
+		"altitude_top":    a.AltTop,
+		"active":          a.Active(now),
+		"incursion":       entry.Incursion,
+		"text":            a.Text,
+		"age":             stratuxClock.Since(entry.LastSeen).Seconds(),
+	}
+	if !a.Start.IsZero() {
+		props["start"] = a.Start.Format(time.RFC3339)
+	}
+	if !a.End.IsZero() {
+		props["end"] = a.End.Format(time.RFC3339)
+	}
+
+	var polygons [][][][2]float64
+	var points [][3]float64
+	for _, s := range a.Shapes {
+		if s.IsArea() {
+			polygons = append(polygons, [][][2]float64{geoJSONRing(s.Ring(WEATHER_PRISM_SEGMENTS))})
+		} else if len(s.Points) > 0 {
+			points = append(points, [3]float64{s.Points[0].Lon, s.Points[0].Lat, float64(s.Points[0].Alt)})
+		}
+	}
+	switch {
+	case len(polygons) > 0:
+		return geoJSONFeature{Type: "Feature", Geometry: geoJSONGeometry{Type: "MultiPolygon", Coordinates: polygons}, Properties: props}, true
+	case len(points) > 0:
+		return geoJSONFeature{Type: "Feature", Geometry: geoJSONGeometry{Type: "MultiPoint", Coordinates: points}, Properties: props}, true
+	}
+	return geoJSONFeature{}, false
+}
+
+// Restrictions as GeoJSON. activeOnly: only those in effect now, areaOnly: only those with polygons or circles.
+func getAirspaceRestrictions(activeOnly, areaOnly bool) geoJSONFeatureCollection {
+	now := time.Now().UTC()
+	airspaceMutex.Lock()
+	defer airspaceMutex.Unlock()
+
+	keys := make([]string, 0, len(airspaceRestrictions))
+	for key, entry := range airspaceRestrictions {
+		if activeOnly && !entry.Restriction.Active(now) {
+			continue
+		}
+		if areaOnly && !entry.Restriction.IsArea() {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	fc := geoJSONFeatureCollection{Type: "FeatureCollection", Features: make([]geoJSONFeature, 0)}
+	for _, key := range keys {
+		if feature, ok := airspaceRestrictionFeature(airspaceRestrictions[key], now); ok {
+			fc.Features = append(fc.Features, feature)
+		}
+	}
+	return fc
+}
@@ -89,6 +89,13 @@ func parseAisMessage(data string) {
 	logMsg(thisMsg) // writes to replay logs
 
 	msg, err := aisNmeaParser.ParseSentence(data)
+	if err == nil {
+		mmsi := uint32(0)
+		if msg != nil && msg.Packet != nil {
+			mmsi = msg.Packet.GetHeader().UserID
+		}
+		forwardAISSentence(data, mmsi)
+	}
 	if err == nil && msg != nil && msg.Packet != nil {
 		importAISTrafficMessage(msg)
 	} else if err != nil {
@@ -223,7 +230,7 @@ func importAISTrafficMessage(msg *aisnmea.VdmPacket) {
 
 	traffic[key] = ti
 	postProcessTraffic(&ti)   // This will not estimate distance for non ES sources, pffff
-	registerTrafficUpdate(ti) // Sends this one to the web interface
+	registerTrafficUpdate(key, ti) // Sends this one to the web interface
 	seenTraffic[key] = true
 
 	if globalSettings.DEBUG {
@@ -0,0 +1,260 @@
+/*
+	Copyright (c) 2026 Stratux contributors
+	Distributable under the terms of The "BSD New" License
+	that can be found in the LICENSE file, herein included
+	as part of this header.
+
+	aisout.go: AIS NMEA output for marine apps (OpenCPN, Navionics). The !AIVDM sentences received from rtl-ais are
+		forwarded as they are. Vessels in the traffic map that we didn't receive ourselves are encoded as type 1
+		position and type 24 static reports, and optionally aircraft as type 9 SAR aircraft reports.
+		See https://gpsd.gitlab.io/gpsd/AIVDM.html
+*/
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	AIS_RAW_TIMEOUT     = 10 * time.Second // vessels forwarded raw this recently are not re-encoded
+	AIS_STATIC_PERIOD   = 30 * time.Second // type 24 name reports
+	AIS_OUT_PRIORITY    = 5
+	AIS_SAR_MMSI_PREFIX = 111000000 // SAR aircraft MMSI 111MIDxxx. We don't know the MID, so the ICAO address fills the rest
+)
+
+// MMSI => stratuxClock, guarded by aisOutMutex
+var aisRawForwarded = make(map[uint32]time.Time)
+var aisStaticSent = make(map[uint32]time.Time)
+var aisOutMutex sync.Mutex
+
+// 6 bit payload builder
+type aisBits struct {
+	bits []byte // one bit per byte, MSB first
+}
+
+func (b *aisBits) put(v int64, n int) {
+	for i := n - 1; i >= 0; i-- {
+		b.bits = append(b.bits, byte(v>>uint(i))&1)
+	}
+}
+
+func (b *aisBits) putString(s string, chars int) {
+	s = strings.ToUpper(s)
+	for i := 0; i < chars; i++ {
+		c := byte('@') // padding
+		if i < len(s) {
+			c = s[i]
+		}
+		switch {
+		case c >= '@' && c <= '_':
+			b.put(int64(c-'@'), 6)
+		case c >= ' ' && c <= '?':
+			b.put(int64(c), 6)
+		default:
+			b.put(int64(' '), 6)
+		}
+	}
+}
+
+// Armors the payload and returns a single fragment !AIVDM sentence
+func (b *aisBits) sentence() string {
+	fill := (6 - len(b.bits)%6) % 6
+	bits := append(b.bits, make([]byte, fill)...)
+	payload := make([]byte, 0, len(bits)/6)
+	for i := 0; i < len(bits); i += 6 {
+		v := byte(0)
+		for j := 0; j < 6; j++ {
+			v = v<<1 | bits[i+j]
+		}
+		v += 48
+		if v > 87 {
+			v += 8
+		}
+		payload = append(payload, v)
+	}
+	return appendNmeaChecksum("!AIVDM,1,1,,A,"+string(payload)+","+string('0'+byte(fill))) + "\r\n"
+}
+
+func aisLatLng(b *aisBits, lat, lng float32, valid bool) {
+	if !valid {
+		b.put(181*600000, 28)
+		b.put(91*600000, 27)
+		return
+	}
+	b.put(int64(float64(lng)*600000), 28)
+	b.put(int64(float64(lat)*600000), 27)
+}
+
+func aisTimestamp(ti TrafficInfo) int64 {
+	if ti.Timestamp.IsZero() {
+		return 60 // not available
+	}
+	return int64(ti.Timestamp.UTC().Second())
+}
+
+// Type 1 position report, class A
+func makeAISPositionReport(ti TrafficInfo) string {
+	b := &aisBits{}
+	b.put(1, 6)
+	b.put(0, 2) // repeat indicator
+	b.put(int64(ti.Icao_addr), 30)
+	b.put(15, 4)   // navigation status not defined
+	b.put(-128, 8) // rate of turn not available
+	sog, cog := int64(1023), int64(3600)
+	if ti.Speed_valid {
+		sog = int64(ti.Speed) * 10
+		if sog > 1022 {
+			sog = 1022
+		}
+		cog = int64(ti.Track*10) % 3600
+	}
+	b.put(sog, 10)
+	b.put(0, 1) // position accuracy > 10 m
+	aisLatLng(b, ti.Lat, ti.Lng, ti.Position_valid)
+	b.put(cog, 12)
+	b.put(511, 9) // true heading not available
+	b.put(aisTimestamp(ti), 6)
+	b.put(0, 2)  // maneuver indicator
+	b.put(0, 3)  // spare
+	b.put(0, 1)  // RAIM
+	b.put(0, 19) // radio status
+	return b.sentence()
+}
+
+// Type 24 part A, vessel name
+func makeAISStaticReport(ti TrafficInfo) string {
+	b := &aisBits{}
+	b.put(24, 6)
+	b.put(0, 2)
+	b.put(int64(ti.Icao_addr), 30)
+	b.put(0, 2) // part A
+	b.putString(ti.Tail, 20)
+	return b.sentence()
+}
+
+// Type 9 standard SAR aircraft position report
+func makeAISSARAircraftReport(ti TrafficInfo) string {
+	b := &aisBits{}
+	b.put(9, 6)
+	b.put(0, 2)
+	b.put(AIS_SAR_MMSI_PREFIX+int64(ti.Icao_addr%1000000), 30)
+	alt := int64(4095) // not available
+	if ti.Alt != 0 || ti.OnGround {
+		alt = int64(float64(ti.Alt) / 3.28084)
+		if alt < 0 {
+			alt = 0
+		} else if alt > 4094 {
+			alt = 4094
+		}
+	}
+	b.put(alt, 12)
+	sog, cog := int64(1023), int64(3600)
+	if ti.Speed_valid {
+		sog = int64(ti.Speed)
+		if sog > 1022 {
+			sog = 1022
+		}
+		cog = int64(ti.Track*10) % 3600
+	}
+	b.put(sog, 10)
+	b.put(0, 1)
+	aisLatLng(b, ti.Lat, ti.Lng, ti.Position_valid)
+	b.put(cog, 12)
+	b.put(aisTimestamp(ti), 6)
+	b.put(0, 8)  // regional
+	b.put(1, 1)  // DTE not ready
+	b.put(0, 3)  // spare
+	b.put(0, 1)  // assigned mode
+	b.put(0, 1)  // RAIM
+	b.put(0, 20) // radio status
+	return b.sentence()
+}
+
+// Called from parseAisMessage() for every sentence received from rtl-ais.
+func forwardAISSentence(data string, mmsi uint32) {
+	if !globalSettings.AISOut_Enabled {
+		return
+	}
+	if mmsi != 0 {
+		aisOutMutex.Lock()
+		aisRawForwarded[mmsi] = stratuxClock.Time
+		aisOutMutex.Unlock()
+	}
+	sendAISNmea(strings.TrimSpace(data)+"\r\n", 5*time.Second, AIS_OUT_PRIORITY)
+}
+
+// Called from sendTrafficUpdates() for every current target with position.
+func sendAISTrafficUpdate(ti TrafficInfo) {
+	if !globalSettings.AISOut_Enabled {
+		return
+	}
+	if ti.TargetType == TARGET_TYPE_AIS {
+		aisOutMutex.Lock()
+		defer aisOutMutex.Unlock()
+		if t, ok := aisRawForwarded[ti.Icao_addr]; ok && stratuxClock.Since(t) < AIS_RAW_TIMEOUT {
+			return // already sent as received
+		}
+		sendAISNmea(makeAISPositionReport(ti), time.Second, AIS_OUT_PRIORITY)
+		if t, ok := aisStaticSent[ti.Icao_addr]; len(ti.Tail) > 0 && (!ok || stratuxClock.Since(t) > AIS_STATIC_PERIOD) {
+			sendAISNmea(makeAISStaticReport(ti), AIS_STATIC_PERIOD, AIS_OUT_PRIORITY)
+			aisStaticSent[ti.Icao_addr] = stratuxClock.Time
+		}
+	} else if globalSettings.AISOutAircraft && !ti.OnGround {
+		sendAISNmea(makeAISSARAircraftReport(ti), time.Second, AIS_OUT_PRIORITY)
+	}
+}
+
+// Forgets vessels that timed out. Called from sendTrafficUpdates()
+func cleanupAISOut() {
+	aisOutMutex.Lock()
+	defer aisOutMutex.Unlock()
+	for mmsi, t := range aisRawForwarded {
+		if stratuxClock.Since(t) > AIS_RAW_TIMEOUT {
+			delete(aisRawForwarded, mmsi)
+		}
+	}
+	for mmsi, t := range aisStaticSent {
+		if stratuxClock.Since(t) > 2*AIS_STATIC_PERIOD {
+			delete(aisStaticSent, mmsi)
+		}
+	}
+}
+
+// TCP server for marine apps, same port as the UDP output
+func tcpAISOutListener() {
+	ln, err := net.Listen("tcp", fmt.Sprintf(":%d", globalSettings.AISOutPort))
+	if err != nil {
+		log.Printf("AIS output: %s\n", err.Error())
+		return
+	}
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			log.Printf("AIS output: %s\n", err.Error())
+			continue
+		}
+		key := "TCP:" + conn.RemoteAddr().String()
+
+		tcpConn := &tcpConnection{
+			conn.(*net.TCPConn),
+			NewMessageQueue(1024),
+			NETWORK_AIS_NMEA,
+			key,
+		}
+		netMutex.Lock()
+		clientConnections[tcpConn.GetConnectionKey()] = tcpConn
+		netMutex.Unlock()
+		go connectionWriter(tcpConn)
+	}
+}
+
+func sendAISNmea(msg string, maxAge time.Duration, priority int32) {
+	sendMsg([]byte(msg), NETWORK_AIS_NMEA, maxAge, priority)
+}
@@ -0,0 +1,178 @@
+/*
+	Copyright (c) 2026 Stratux contributors
+	Distributable under the terms of The "BSD New" License
+	that can be found in the LICENSE file, herein included
+	as part of this header.
+
+	asterix.go: ASTERIX output for surveillance tools. ADS-B targets are sent as CAT021 target reports, the receiver
+		state as CAT023 ground station and service status reports. Sent to connections with the NETWORK_ASTERIX
+		capability: UDP clients on globalSettings.ASTERIXPort, NetworkOutputs entries and /dev/serialout_asterix*.
+*/
+
+package main
+
+import (
+	"time"
+
+	"github.com/b3nn0/stratux/asterix"
+)
+
+const (
+	ASTERIX_STATUS_PERIOD = 10 // seconds between CAT023 status reports
+	ASTERIX_SERVICE_SID   = 1  // service identification of the ADS-B services in CAT023
+)
+
+var asterixStatusCounter uint
+
+// CAT021 is for ADS-B only, TIS-B, FLARM/OGN and AIS targets are not sent.
+func isAsterixTarget(ti TrafficInfo) bool {
+	if ti.TargetType != TARGET_TYPE_ADSB && ti.TargetType != TARGET_TYPE_ADSR {
+		return false
+	}
+	return ti.Last_source == TRAFFIC_SOURCE_1090ES || ti.Last_source == TRAFFIC_SOURCE_UAT || ti.Last_source == TRAFFIC_SOURCE_GDL90
+}
+
+func makeAsterixCat021(ti TrafficInfo) []byte {
+	now := time.Now().UTC()
+	lastMsg := asterix.TimeOfDay(now.Add(-time.Duration(ti.Age * float64(time.Second))))
+	r := &asterix.Cat021Report{
+		SAC:                     uint8(globalSettings.ASTERIXSAC),
+		SIC:                     uint8(globalSettings.ASTERIXSIC),
+		AddrType:                asterix.ATP_ICAO,
+		AltitudeCapability:      asterix.ARC_UNKNOWN,
+		OnGround:                ti.OnGround,
+		Address:                 ti.Icao_addr & 0xFFFFFF,
+		TimeOfPosition:          lastMsg,
+		TimeOfPositionValid:     true,
+		Lat:                     float64(ti.Lat),
+		Lng:                     float64(ti.Lng),
+		PositionValid:           ti.Position_valid,
+		TimeOfReception:         lastMsg,
+		TimeOfReceptionValid:    true,
+		PriorityStatus:          ti.PriorityStatus,
+		StatusValid:             true,
+		TimeOfTransmission:      asterix.TimeOfDay(now),
+		TimeOfTransmissionValid: true,
+		Callsign:                ti.Tail,
+	}
+	switch ti.Addr_type {
+	case 1, 3: // self assigned / TIS-B track file
+		r.AddrType = asterix.ATP_ANONYMOUS
+	case 4:
+		r.AddrType = asterix.ATP_SURFACE
+	}
+	if ti.Last_source == TRAFFIC_SOURCE_UAT {
+		r.AltitudeCapability = asterix.ARC_25FT
+	}
+	if ti.AltIsGNSS {
+		r.GeoAltitude = float64(ti.Alt)
+		r.GeoAltitudeValid = true
+	} else {
+		r.PressureAltitude = float64(ti.Alt)
+		r.PressureAltitudeValid = true
+		if ti.GnssDiffFromBaroAlt != 0 {
+			r.GeoAltitude = float64(ti.Alt + ti.GnssDiffFromBaroAlt)
+			r.GeoAltitudeValid = true
+		}
+	}
+	if ti.NIC > 0 || ti.NACp > 0 {
+		r.NIC = uint8(ti.NIC)
+		r.NACp = uint8(ti.NACp)
+		r.QualityValid = true
+	}
+	if ti.Squawk != 0 {
+		r.Squawk = uint16(ti.Squawk)
+		r.SquawkValid = true
+	}
+	if ti.Speed_valid {
+		r.Speed = float64(ti.Speed)
+		r.Track = float64(ti.Track)
+		r.GroundVectorValid = true
+		if ti.AltIsGNSS {
+			r.GeoVvel = float64(ti.Vvel)
+			r.GeoVvelValid = true
+		} else {
+			r.BaroVvel = float64(ti.Vvel)
+			r.BaroVvelValid = true
+		}
+	}
+	if emitter := asterix.EmitterFromGDL90(ti.Emitter_category); emitter != 0 {
+		r.Emitter = emitter
+		r.EmitterValid = true
+	}
+	return asterix.Block(asterix.CAT021, asterix.EncodeCat021(r))
+}
+
+func asterixServiceStatus(enabled bool, messagesLastMinute uint) uint8 {
+	if !enabled {
+		return asterix.STATUS_DISABLED
+	}
+	if messagesLastMinute > 0 {
+		return asterix.STATUS_NORMAL
+	}
+	return asterix.STATUS_UNKNOWN // no traffic or no SDR
+}
+
+// Ground station status and one service status report per ADS-B band, in one CAT023 data block.
+func makeAsterixCat023() []byte {
+	tod := asterix.TimeOfDay(time.Now())
+	station := &asterix.Cat023Report{
+		SAC:               uint8(globalSettings.ASTERIXSAC),
+		SIC:               uint8(globalSettings.ASTERIXSIC),
+		ReportType:        asterix.REPORT_GROUND_STATION_STATUS,
+		TimeOfDay:         tod,
+		TimeOfDayValid:    true,
+		TimeSourceInvalid: !isGPSClockValid(),
+		StatusPeriod:      ASTERIX_STATUS_PERIOD,
+	}
+	records := [][]byte{asterix.EncodeCat023(station)}
+
+	services := []struct {
+		styp     uint8
+		enabled  bool
+		messages uint
+	}{
+		{asterix.SERVICE_ADSB_ES, globalSettings.ES_Enabled, globalStatus.ES_messages_last_minute},
+		{asterix.SERVICE_ADSB_UAT, globalSettings.UAT_Enabled, globalStatus.UAT_messages_last_minute},
+	}
+	for _, s := range services {
+		service := &asterix.Cat023Report{
+			SAC:            station.SAC,
+			SIC:            station.SIC,
+			ReportType:     asterix.REPORT_SERVICE_STATUS,
+			ServiceID:      ASTERIX_SERVICE_SID,
+			ServiceType:    s.styp,
+			TimeOfDay:      tod,
+			TimeOfDayValid: true,
+			ReportPeriod:   1, // sendTrafficUpdates() runs once per second
+			Status:         asterixServiceStatus(s.enabled, s.messages),
+			StatusValid:    true,
+		}
+		records = append(records, asterix.EncodeCat023(service))
+	}
+	return asterix.Block(asterix.CAT023, records...)
+}
+
+// Called from sendTrafficUpdates() for every current target with position.
+func sendAsterixTrafficUpdate(ti TrafficInfo, priority int32) {
+	if !isAsterixTarget(ti) {
+		return
+	}
+	sendAsterix(makeAsterixCat021(ti), time.Second, priority)
+}
+
+// Called once per second from heartBeatSender()
+func sendAsterixStatus() {
+	asterixStatusCounter++
+	if asterixStatusCounter%ASTERIX_STATUS_PERIOD != 1 {
+		return
+	}
+	sendAsterix(makeAsterixCat023(), ASTERIX_STATUS_PERIOD*time.Second, -1)
+}
+
+func sendAsterix(msg []byte, maxAge time.Duration, priority int32) {
+	if !globalSettings.ASTERIX_Enabled {
+		return // also not to /dev/serialout_asterix*
+	}
+	sendMsg(msg, NETWORK_ASTERIX, maxAge, priority)
+}
@@ -27,7 +27,7 @@ type connection interface {
 	Writer()       io.Writer
 	IsThrottled()  bool
 	IsSleeping()   bool
-	Capabilities() uint8
+	Capabilities() uint16
 	GetDesiredPacketSize() int
 	OnError(error)
 	Close()
@@ -37,7 +37,7 @@ type networkConnection struct {
 	Conn            *net.UDPConn
 	Ip              string
 	Port            uint32
-	Capability      uint8
+	Capability      uint16
 	Queue           *MessageQueue `json:"-"` // don't store in settings
 
 	LastPingResponse time.Time // last time the client responded
@@ -88,7 +88,7 @@ func (conn *networkConnection) IsSleeping() bool {
 	return conn.SleepFlag
 }
 
-func (conn *networkConnection) Capabilities() uint8 {
+func (conn *networkConnection) Capabilities() uint16 {
 	return conn.Capability
 }
 
@@ -118,7 +118,7 @@ func (conn *networkConnection) GetConnectionKey() string {
 type serialConnection struct {
 	DeviceString string
 	Baud         int
-	Capability   uint8
+	Capability   uint16
 	serialPort   *serial.Port
 	Queue        *MessageQueue `json:"-"` // don't store in settings
 }
@@ -140,7 +140,7 @@ func (conn *serialConnection) IsSleeping() bool {
 	return conn.serialPort == nil
 }
 
-func (conn *serialConnection) Capabilities() uint8 {
+func (conn *serialConnection) Capabilities() uint16 {
 	return conn.Capability
 }
 
@@ -170,7 +170,7 @@ func (conn *serialConnection) GetConnectionKey() string {
 type tcpConnection struct {
 	Conn         *net.TCPConn
 	Queue        *MessageQueue `json:"-"`
-	Capability   uint8
+	Capability   uint16
 	Key          string
 }
 
@@ -190,7 +190,7 @@ func (conn *tcpConnection) IsThrottled() bool {
 func (conn *tcpConnection) IsSleeping() bool {
 	return conn.Conn == nil
 }
-func (conn *tcpConnection) Capabilities() uint8 {
+func (conn *tcpConnection) Capabilities() uint16 {
 	return conn.Capability
 }
 func (conn *tcpConnection) GetDesiredPacketSize() int {
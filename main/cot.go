@@ -0,0 +1,417 @@
+/*
+	Copyright (c) 2026 Stratux contributors
+	Distributable under the terms of The "BSD New" License
+	that can be found in the LICENSE file, herein included
+	as part of this header.
+
+	cot.go: Cursor-on-Target output for ATAK/WinTAK/iTAK. Every current target and the ownship position are sent as
+		CoT events to the endpoints configured in globalSettings.CoTOutputs: UDP (unicast or multicast, e.g. the
+		SA multicast group 239.2.3.1:6969) or TCP (e.g. a TAK server streaming input on port 8087).
+*/
+
+package main
+
+import (
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net"
+	"strings"
+	"time"
+
+	"golang.org/x/net/ipv4"
+)
+
+const (
+	COT_STALE_AFTER      = 20 * time.Second // targets go stale this long after their last message
+	COT_TIME_FORMAT      = "2006-01-02T15:04:05.000Z"
+	COT_UNKNOWN_ACCURACY = 9999999.0 // CoT value for unknown ce/le
+	COT_MULTICAST_TTL    = 4
+)
+
+type CoTOutput struct {
+	Protocol  string // "udp" or "tcp"
+	Address   string // host:port, e.g. "239.2.3.1:6969"
+	Interface string // multicast only: outgoing interface, e.g. "wlan0". Empty for the default route
+}
+
+type cotPoint struct {
+	Lat float64 `xml:"lat,attr"`
+	Lon float64 `xml:"lon,attr"`
+	Hae float64 `xml:"hae,attr"`
+	Ce  float64 `xml:"ce,attr"`
+	Le  float64 `xml:"le,attr"`
+}
+
+type cotContact struct {
+	Callsign string `xml:"callsign,attr"`
+}
+
+type cotTrack struct {
+	Course float64 `xml:"course,attr"`
+	Speed  float64 `xml:"speed,attr"` // m/s
+}
+
+// Aircraft details, as used by ADS-B plugins for ATAK
+type cotAircraft struct {
+	Icao     string `xml:"icao,attr"`
+	Reg      string `xml:"reg,attr,omitempty"`
+	Type     string `xml:"type,attr,omitempty"`
+	Flight   string `xml:"flight,attr,omitempty"`
+	Squawk   string `xml:"squawk,attr,omitempty"`
+	Category string `xml:"cat,attr,omitempty"`
+	Rssi     string `xml:"rssi,attr,omitempty"`
+}
+
+type cotDetail struct {
+	Contact  cotContact   `xml:"contact"`
+	Track    *cotTrack    `xml:"track,omitempty"`
+	Aircraft *cotAircraft `xml:"_aircot_,omitempty"`
+	Remarks  string       `xml:"remarks,omitempty"`
+}
+
+type cotEvent struct {
+	XMLName xml.Name  `xml:"event"`
+	Version string    `xml:"version,attr"`
+	Uid     string    `xml:"uid,attr"`
+	Type    string    `xml:"type,attr"`
+	How     string    `xml:"how,attr"`
+	Time    string    `xml:"time,attr"`
+	Start   string    `xml:"start,attr"`
+	Stale   string    `xml:"stale,attr"`
+	Point   cotPoint  `xml:"point"`
+	Detail  cotDetail `xml:"detail"`
+}
+
+// CoT (MIL-STD-2525 based) type for a target. Affiliation is always neutral ("n"), as we know nothing about it.
+// Air: a-n-A-C-F civil fixed wing, a-n-A-C-H civil rotary wing, a-n-A-C-L lighter than air, a-n-A-C-F-q drone.
+// Ground: a-n-G-E-V vehicles, a-n-G obstacles. Sea surface: a-n-S for AIS.
+func cotTypeForTraffic(ti TrafficInfo) string {
+	if ti.TargetType == TARGET_TYPE_AIS {
+		return "a-n-S"
+	}
+	switch ti.Emitter_category {
+	case 1, 2, 3, 4, 5, 6, 9, 12:
+		return "a-n-A-C-F"
+	case 7:
+		return "a-n-A-C-H"
+	case 10:
+		return "a-n-A-C-L"
+	case 14:
+		return "a-n-A-C-F-q"
+	case 15:
+		return "a-n-P"
+	case 17, 18:
+		return "a-n-G-E-V"
+	case 19, 20, 21:
+		return "a-n-G"
+	}
+	return "a-n-A-C" // civil aircraft, unknown kind
+}
+
+// Height above ellipsoid in meters. Traffic altitudes are barometric, so they are corrected with our own
+// GNSS/baro difference if we know both.
+func cotHae(alt int32, altIsGNSS bool) float64 {
+	msl := float64(alt)
+	if !altIsGNSS && isGPSValid() && isTempPressValid() {
+		msl += float64(mySituation.GPSAltitudeMSL - mySituation.BaroPressureAltitude)
+	}
+	return (msl + float64(mySituation.GPSGeoidSep)) / 3.28084
+}
+
+// Rounds v to 1/scale
+func cotRound(v float64, scale float64) float64 {
+	return math.Round(v*scale) / scale
+}
+
+// Unknown accuracies are reported as 999999 by the GPS code.
+func cotAccuracy(accuracy float32) float64 {
+	if accuracy >= 999999 {
+		return COT_UNKNOWN_ACCURACY
+	}
+	return float64(accuracy)
+}
+
+func makeCoTEvent(ev cotEvent) []byte {
+	// Round off the float32 noise and that of the feet/meter conversions, e.g. hae 1066.799965862401.
+	// Coordinates to 1e-5 degrees (~1 m), about the precision of float32 positions
+	ev.Point.Lat = cotRound(ev.Point.Lat, 1e5)
+	ev.Point.Lon = cotRound(ev.Point.Lon, 1e5)
+	ev.Point.Hae = cotRound(ev.Point.Hae, 100)
+	ev.Point.Ce = cotRound(ev.Point.Ce, 100)
+	ev.Point.Le = cotRound(ev.Point.Le, 100)
+	if ev.Detail.Track != nil {
+		ev.Detail.Track.Course = cotRound(ev.Detail.Track.Course, 100)
+		ev.Detail.Track.Speed = cotRound(ev.Detail.Track.Speed, 100)
+	}
+	data, err := xml.Marshal(ev)
+	if err != nil {
+		log.Printf("CoT output: %s\n", err.Error())
+		return nil
+	}
+	return append([]byte(xml.Header), data...)
+}
+
+// now is the current UTC time.
+func makeCoTTrafficEvent(ti TrafficInfo, now time.Time) []byte {
+	lastMsg := now.Add(-time.Duration(ti.Age * float64(time.Second)))
+	callsign := strings.TrimSpace(ti.Tail)
+	if len(callsign) == 0 {
+		callsign = fmt.Sprintf("%06X", ti.Icao_addr&0xFFFFFF)
+	}
+	how := "m-g" // GNSS derived
+	if ti.ExtrapolatedPosition {
+		how = "m-p" // predicted
+	}
+	ce := float64(nacpToAccuracy(uint8(ti.NACp)))
+	if ti.NACp == 0 {
+		ce = COT_UNKNOWN_ACCURACY
+	}
+
+	ev := cotEvent{
+		Version: "2.0",
+		Uid:     fmt.Sprintf("ICAO-%06X", ti.Icao_addr&0xFFFFFF),
+		Type:    cotTypeForTraffic(ti),
+		How:     how,
+		Time:    now.Format(COT_TIME_FORMAT),
+		Start:   lastMsg.Format(COT_TIME_FORMAT),
+		Stale:   lastMsg.Add(COT_STALE_AFTER).Format(COT_TIME_FORMAT),
+		Point: cotPoint{
+			Lat: float64(ti.Lat),
+			Lon: float64(ti.Lng),
+			Hae: cotHae(ti.Alt, ti.AltIsGNSS),
+			Ce:  ce,
+			Le:  COT_UNKNOWN_ACCURACY,
+		},
+		Detail: cotDetail{
+			Contact: cotContact{Callsign: callsign},
+			Aircraft: &cotAircraft{
+				Icao:     fmt.Sprintf("%06X", ti.Icao_addr&0xFFFFFF),
+				Reg:      ti.Reg,
+				Type:     ti.AircraftType,
+				Flight:   strings.TrimSpace(ti.Tail),
+				Category: readsbCategory(ti.Emitter_category),
+			},
+		},
+	}
+	if ti.TargetType == TARGET_TYPE_AIS {
+		ev.Uid = fmt.Sprintf("MMSI-%d", ti.Icao_addr)
+		ev.Detail.Aircraft = nil
+	} else if ti.Addr_type != 0 {
+		ev.Uid = fmt.Sprintf("STRATUX-%d-%06X", ti.Addr_type, ti.Icao_addr&0xFFFFFF) // not an ICAO address
+	}
+	if ev.Detail.Aircraft != nil {
+		if ti.Squawk != 0 {
+			ev.Detail.Aircraft.Squawk = fmt.Sprintf("%04d", ti.Squawk)
+		}
+		if ti.SignalLevel > -999 && ti.SignalLevel != 0 {
+			ev.Detail.Aircraft.Rssi = fmt.Sprintf("%.1f", ti.SignalLevel)
+		}
+	}
+	if ti.Speed_valid {
+		ev.Detail.Track = &cotTrack{Course: float64(ti.Track), Speed: float64(ti.Speed) * 1852 / 3600}
+	}
+	if ti.Emergency != EMERGENCY_NONE {
+		ev.Detail.Remarks = "EMERGENCY: " + emergencyDescription(ti.Emergency)
+	}
+	return makeCoTEvent(ev)
+}
+
+// now is the current UTC time.
+func makeCoTOwnshipEvent(now time.Time) []byte {
+	callsign := "Stratux"
+	uid := "STRATUX-OWNSHIP"
+	if code, err := hex.DecodeString(globalSettings.OwnshipModeS); err == nil && len(code) == 3 && code[0] != 0xF0 {
+		icao := uint32(code[0])<<16 | uint32(code[1])<<8 | uint32(code[2])
+		uid = fmt.Sprintf("ICAO-%06X", icao)
+		if reg, ok := icao2reg(icao); ok {
+			callsign = reg
+		}
+	}
+	ev := cotEvent{
+		Version: "2.0",
+		Uid:     uid,
+		Type:    "a-f-A-C-F", // friendly civil fixed wing
+		How:     "m-g",
+		Time:    now.Format(COT_TIME_FORMAT),
+		Start:   now.Format(COT_TIME_FORMAT),
+		Stale:   now.Add(COT_STALE_AFTER).Format(COT_TIME_FORMAT),
+		Point: cotPoint{
+			Lat: float64(mySituation.GPSLatitude),
+			Lon: float64(mySituation.GPSLongitude),
+			Hae: float64(mySituation.GPSHeightAboveEllipsoid) / 3.28084,
+			Ce:  cotAccuracy(mySituation.GPSHorizontalAccuracy),
+			Le:  cotAccuracy(mySituation.GPSVerticalAccuracy),
+		},
+		Detail: cotDetail{
+			Contact: cotContact{Callsign: callsign},
+		},
+	}
+	if isGPSGroundTrackValid() {
+		ev.Detail.Track = &cotTrack{Course: float64(mySituation.GPSTrueCourse), Speed: mySituation.GPSGroundSpeed * 1852 / 3600}
+	}
+	return makeCoTEvent(ev)
+}
+
+// Called from sendTrafficUpdates() for every current target with position.
+func sendCoTTrafficUpdate(ti TrafficInfo, priority int32) {
+	if !globalSettings.CoT_Enabled {
+		return
+	}
+	if msg := makeCoTTrafficEvent(ti, time.Now().UTC()); msg != nil {
+		sendCoT(msg, time.Second, priority)
+	}
+}
+
+// Called once per second from heartBeatSender()
+func sendCoTOwnship() {
+	if !globalSettings.CoT_Enabled || !isGPSValid() {
+		return
+	}
+	if msg := makeCoTOwnshipEvent(time.Now().UTC()); msg != nil {
+		sendCoT(msg, time.Second, -1)
+	}
+}
+
+func sendCoT(msg []byte, maxAge time.Duration, priority int32) {
+	sendMsg(msg, NETWORK_COT, maxAge, priority)
+}
+
+// Writes every message as a datagram to the configured address
+type cotPacketWriter struct {
+	conn net.PacketConn
+	dst  net.Addr
+}
+
+func (w *cotPacketWriter) Write(p []byte) (int, error) {
+	return w.conn.WriteTo(p, w.dst)
+}
+
+type cotConnection struct {
+	Output CoTOutput
+	conn   io.Closer
+	writer io.Writer
+	Queue  *MessageQueue
+}
+
+func (conn *cotConnection) MessageQueue() *MessageQueue {
+	return conn.Queue
+}
+func (conn *cotConnection) Writer() io.Writer {
+	return conn.writer
+}
+func (conn *cotConnection) IsThrottled() bool {
+	return false
+}
+func (conn *cotConnection) IsSleeping() bool {
+	return conn.conn == nil
+}
+func (conn *cotConnection) Capabilities() uint16 {
+	return NETWORK_COT
+}
+func (conn *cotConnection) GetDesiredPacketSize() int {
+	if conn.Output.Protocol == "udp" {
+		return 1 // one event per datagram, TAK clients don't parse more
+	}
+	return 4096
+}
+func (conn *cotConnection) OnError(err error) {
+	if conn.Output.Protocol == "udp" {
+		return // keep the socket open, like for UDP clients
+	}
+	log.Printf("CoT output %s closed: %s\n", conn.Output.Address, err.Error())
+	conn.Close()
+}
+func (conn *cotConnection) Close() {
+	if conn.conn != nil {
+		conn.conn.Close()
+		conn.conn = nil
+		conn.Queue.Close()
+		onConnectionClosed(conn)
+	}
+}
+func (conn *cotConnection) GetConnectionKey() string {
+	return "COT:" + conn.Output.Protocol + ":" + conn.Output.Address
+}
+
+func dialCoTOutput(output CoTOutput) (*cotConnection, error) {
+	conn := &cotConnection{Output: output, Queue: NewMessageQueue(1024)}
+	switch output.Protocol {
+	case "tcp":
+		c, err := net.DialTimeout("tcp", output.Address, 5*time.Second)
+		if err != nil {
+			return nil, err
+		}
+		conn.conn = c
+		conn.writer = c
+	case "udp":
+		dst, err := net.ResolveUDPAddr("udp4", output.Address)
+		if err != nil {
+			return nil, err
+		}
+		c, err := net.ListenPacket("udp4", ":0")
+		if err != nil {
+			return nil, err
+		}
+		if dst.IP.IsMulticast() {
+			p := ipv4.NewPacketConn(c)
+			p.SetMulticastTTL(COT_MULTICAST_TTL)
+			if len(output.Interface) > 0 {
+				ifi, err := net.InterfaceByName(output.Interface)
+				if err == nil {
+					err = p.SetMulticastInterface(ifi)
+				}
+				if err != nil {
+					c.Close()
+					return nil, err
+				}
+			}
+		}
+		conn.conn = c
+		conn.writer = &cotPacketWriter{c, dst}
+	default:
+		return nil, fmt.Errorf("unknown protocol '%s'", output.Protocol)
+	}
+	return conn, nil
+}
+
+// Opens the configured CoT outputs, reconnects TCP outputs and closes outputs that were removed or disabled.
+func cotOutputWatcher() {
+	ticker := time.NewTicker(10 * time.Second)
+	for {
+		valid := make(map[string]bool)
+		if globalSettings.CoT_Enabled {
+			for _, output := range globalSettings.CoTOutputs {
+				key := (&cotConnection{Output: output}).GetConnectionKey()
+				valid[key] = true
+				netMutex.Lock()
+				_, connected := clientConnections[key]
+				netMutex.Unlock()
+				if connected {
+					continue
+				}
+				conn, err := dialCoTOutput(output)
+				if err != nil {
+					log.Printf("CoT output %s %s: %s\n", output.Protocol, output.Address, err.Error())
+					continue
+				}
+				log.Printf("CoT output: sending to %s %s\n", output.Protocol, output.Address)
+				netMutex.Lock()
+				clientConnections[key] = conn
+				netMutex.Unlock()
+				go connectionWriter(conn)
+			}
+		}
+		netMutex.Lock()
+		for key, c := range clientConnections {
+			if conn, ok := c.(*cotConnection); ok && !valid[key] {
+				go conn.Close() // takes netMutex
+			}
+		}
+		netMutex.Unlock()
+		<-ticker.C
+	}
+}
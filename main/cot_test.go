@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/xml"
+	"testing"
+	"time"
+)
+
+var testCoTNow = time.Date(2026, 3, 14, 15, 9, 26, 500e6, time.UTC)
+
+func TestCoTTrafficEvent(t *testing.T) {
+	setTestOwnship(t, 90, 100)
+	defer func() { mySituation.GPSGeoidSep = 0 }()
+	mySituation.GPSAltitudeMSL = testOwnshipAlt + 100 // 100 ft above pressure altitude
+	mySituation.BaroLastMeasurementTime = stratuxClock.Time
+	mySituation.GPSGeoidSep = -110
+
+	tests := []struct {
+		name   string
+		ti     TrafficInfo
+		golden string
+	}{
+		{"ADS-B", TrafficInfo{
+			Icao_addr:        0xA12345,
+			Tail:             "N12345  ",
+			Reg:              "N12345",
+			AircraftType:     "C172",
+			Emitter_category: 1,
+			Squawk:           1200,
+			SignalLevel:      -20.5,
+			Lat:              44.1234,
+			Lng:              -88.25,
+			Position_valid:   true,
+			Alt:              3500,
+			NACp:             8,
+			Track:            270,
+			Speed:            120,
+			Speed_valid:      true,
+			Age:              1.5,
+			TargetType:       TARGET_TYPE_ADSB,
+		}, `<event version="2.0" uid="ICAO-A12345" type="a-n-A-C-F" how="m-g" time="2026-03-14T15:09:26.500Z" ` +
+			`start="2026-03-14T15:09:25.000Z" stale="2026-03-14T15:09:45.000Z">` +
+			`<point lat="44.1234" lon="-88.25" hae="1063.75" ce="92.6" le="9.999999e+06"></point><detail>` +
+			`<contact callsign="N12345"></contact><track course="270" speed="61.73"></track>` +
+			`<_aircot_ icao="A12345" reg="N12345" type="C172" flight="N12345" squawk="1200" cat="A1" rssi="-20.5"></_aircot_>` +
+			`</detail></event>`},
+		{"FLARM helicopter emergency", TrafficInfo{
+			Icao_addr:            0xDD1234,
+			Addr_type:            1,
+			Emitter_category:     7,
+			Lat:                  44.25,
+			Lng:                  -88.5,
+			Alt:                  1000,
+			AltIsGNSS:            true,
+			Emergency:            EMERGENCY_MEDICAL,
+			ExtrapolatedPosition: true,
+			SignalLevel:          -999,
+		}, `<event version="2.0" uid="STRATUX-1-DD1234" type="a-n-A-C-H" how="m-p" time="2026-03-14T15:09:26.500Z" ` +
+			`start="2026-03-14T15:09:26.500Z" stale="2026-03-14T15:09:46.500Z">` +
+			`<point lat="44.25" lon="-88.5" hae="271.27" ce="9.999999e+06" le="9.999999e+06"></point><detail>` +
+			`<contact callsign="DD1234"></contact><_aircot_ icao="DD1234" cat="A7"></_aircot_>` +
+			`<remarks>EMERGENCY: Lifeguard / medical emergency</remarks></detail></event>`},
+		{"AIS", TrafficInfo{
+			Icao_addr:   366123456,
+			TargetType:  TARGET_TYPE_AIS,
+			Tail:        "EVER GIVEN",
+			Lat:         41.75,
+			Lng:         -87.5,
+			AltIsGNSS:   true,
+			NACp:        10,
+			Track:       90,
+			Speed:       12,
+			Speed_valid: true,
+		}, `<event version="2.0" uid="MMSI-366123456" type="a-n-S" how="m-g" time="2026-03-14T15:09:26.500Z" ` +
+			`start="2026-03-14T15:09:26.500Z" stale="2026-03-14T15:09:46.500Z">` +
+			`<point lat="41.75" lon="-87.5" hae="-33.53" ce="10" le="9.999999e+06"></point><detail>` +
+			`<contact callsign="EVER GIVEN"></contact><track course="90" speed="6.17"></track></detail></event>`},
+	}
+	for _, tt := range tests {
+		if got := string(makeCoTTrafficEvent(tt.ti, testCoTNow)); got != xml.Header+tt.golden {
+			t.Errorf("%s:\n got %s\nwant %s", tt.name, got, tt.golden)
+		}
+	}
+}
+
+func TestCoTOwnshipEvent(t *testing.T) {
+	setTestOwnship(t, 90, 100)
+	mySituation.GPSHeightAboveEllipsoid = 2900
+	mySituation.GPSHorizontalAccuracy = 5
+	mySituation.GPSVerticalAccuracy = 8.5
+	golden := `<event version="2.0" uid="STRATUX-OWNSHIP" type="a-f-A-C-F" how="m-g" time="2026-03-14T15:09:26.500Z" ` +
+		`start="2026-03-14T15:09:26.500Z" stale="2026-03-14T15:09:46.500Z">` +
+		`<point lat="44" lon="-88.5" hae="883.92" ce="5" le="8.5"></point><detail>` +
+		`<contact callsign="Stratux"></contact><track course="90" speed="51.44"></track></detail></event>`
+	if got := string(makeCoTOwnshipEvent(testCoTNow)); got != xml.Header+golden {
+		t.Errorf("\n got %s\nwant %s", got, golden)
+	}
+
+	// Unknown accuracy and no ground track without a fix
+	mySituation.GPSFixQuality = 0
+	isGPSValid()
+	golden = `<event version="2.0" uid="STRATUX-OWNSHIP" type="a-f-A-C-F" how="m-g" time="2026-03-14T15:09:26.500Z" ` +
+		`start="2026-03-14T15:09:26.500Z" stale="2026-03-14T15:09:46.500Z">` +
+		`<point lat="44" lon="-88.5" hae="883.92" ce="9.999999e+06" le="9.999999e+06"></point><detail>` +
+		`<contact callsign="Stratux"></contact></detail></event>`
+	if got := string(makeCoTOwnshipEvent(testCoTNow)); got != xml.Header+golden {
+		t.Errorf("no fix:\n got %s\nwant %s", got, golden)
+	}
+}
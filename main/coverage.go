@@ -0,0 +1,220 @@
+/*
+	Copyright (c) 2026 Stratux contributors
+	Distributable under the terms of The "BSD New" License
+	that can be found in the LICENSE file, herein included
+	as part of this header.
+
+	coverage.go: Reception coverage analytics. Position reports are binned per traffic source by bearing and altitude
+		band relative to ownship, keeping the maximum range per bin. Together with message rate statistics this allows
+		to evaluate and compare antenna installations. Served as JSON via /getCoverage.
+*/
+
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/b3nn0/stratux/common"
+)
+
+const (
+	COVERAGE_BEARING_BINS = 36 // 10 degrees per bin
+)
+
+// Upper edges (feet, target altitude relative to ownship) of all but the last altitude band
+var coverageAltitudeBands = []int32{-5000, -1000, 1000, 5000}
+
+var coverageSources = []struct {
+	Source uint8
+	Name   string
+}{
+	{TRAFFIC_SOURCE_1090ES, "ES"},
+	{TRAFFIC_SOURCE_UAT, "UAT"},
+	{TRAFFIC_SOURCE_OGN, "OGN"},
+	{TRAFFIC_SOURCE_AIS, "AIS"},
+	{TRAFFIC_SOURCE_GDL90, "GDL90"},
+}
+
+type CoverageBin struct {
+	Reports      uint64  // position reports received in this bin
+	MaxRange     float64 // meters
+	MaxRangeIcao uint32  // target that was received at MaxRange
+	MaxRangeAlt  int32   // its altitude, feet
+}
+
+type CoverageSourceStats struct {
+	Reports              uint64  // position reports with known ownship position
+	Targets              int     // distinct targets. One that timed out and came back is counted again
+	MaxRange             float64 // meters
+	Messages_last_minute uint
+	Messages_max         uint                        // highest messages per minute since Start
+	Messages_mean        float64                     // mean messages per minute since Start
+	Bins                 [][]CoverageBin             // [bearing bin][altitude band]
+	targets              map[uint32]coveragePosition // last binned position per current target, by traffic map key
+	messageSamples       uint64
+	messageSum           uint64
+}
+
+type coveragePosition struct {
+	lat, lng float32
+}
+
+type CoverageStats struct {
+	Start          time.Time // UTC
+	BearingBinSize float64   // degrees
+	AltitudeBands  []int32   // see coverageAltitudeBands
+	Sources        map[string]*CoverageSourceStats
+}
+
+var coverage CoverageStats
+var coverageMutex sync.Mutex
+
+func newCoverageSourceStats() *CoverageSourceStats {
+	stats := &CoverageSourceStats{
+		Bins:    make([][]CoverageBin, COVERAGE_BEARING_BINS),
+		targets: make(map[uint32]coveragePosition),
+	}
+	for i := range stats.Bins {
+		stats.Bins[i] = make([]CoverageBin, len(coverageAltitudeBands)+1)
+	}
+	return stats
+}
+
+// Starts a new coverage session, e.g. for a new flight or antenna position.
+func resetCoverage() {
+	coverageMutex.Lock()
+	defer coverageMutex.Unlock()
+	coverage = CoverageStats{
+		Start:          time.Now().UTC(),
+		BearingBinSize: 360.0 / COVERAGE_BEARING_BINS,
+		AltitudeBands:  coverageAltitudeBands,
+		Sources:        make(map[string]*CoverageSourceStats),
+	}
+	for _, src := range coverageSources {
+		coverage.Sources[src.Name] = newCoverageSourceStats()
+	}
+}
+
+func coverageSourceName(source uint8) string {
+	for _, src := range coverageSources {
+		if src.Source == source {
+			return src.Name
+		}
+	}
+	return ""
+}
+
+func coverageAltitudeBand(relAlt int32) int {
+	for i, edge := range coverageAltitudeBands {
+		if relAlt < edge {
+			return i
+		}
+	}
+	return len(coverageAltitudeBands)
+}
+
+// Called from registerTrafficUpdate() for every received message. Only messages that carry a new position fix are
+// binned, velocity, altitude or identification updates of a target repeat its last position.
+func recordCoverage(key uint32, ti TrafficInfo) {
+	if !ti.Position_valid || !isGPSValid() || stratuxClock.Since(ti.Last_seen) > 2*time.Second {
+		return
+	}
+	name := coverageSourceName(ti.Last_source)
+	if len(name) == 0 {
+		return
+	}
+	dist, bearing := common.Distance(float64(mySituation.GPSLatitude), float64(mySituation.GPSLongitude), float64(ti.Lat), float64(ti.Lng))
+	myAlt := mySituation.GPSAltitudeMSL
+	if isTempPressValid() {
+		myAlt = mySituation.BaroPressureAltitude
+	}
+	bearingBin := int(bearing/(360.0/COVERAGE_BEARING_BINS)) % COVERAGE_BEARING_BINS
+	if bearingBin < 0 {
+		bearingBin += COVERAGE_BEARING_BINS
+	}
+	altBand := coverageAltitudeBand(ti.Alt - int32(myAlt))
+
+	coverageMutex.Lock()
+	defer coverageMutex.Unlock()
+	stats := coverage.Sources[name]
+	if stats == nil {
+		return
+	}
+	pos := coveragePosition{ti.Lat, ti.Lng}
+	last, ok := stats.targets[key]
+	if ok && last == pos {
+		return
+	}
+	if !ok {
+		stats.Targets++
+	}
+	stats.targets[key] = pos
+	stats.Reports++
+	if dist > stats.MaxRange {
+		stats.MaxRange = dist
+	}
+	bin := &stats.Bins[bearingBin][altBand]
+	bin.Reports++
+	if dist > bin.MaxRange {
+		bin.MaxRange = dist
+		bin.MaxRangeIcao = ti.Icao_addr
+		bin.MaxRangeAlt = ti.Alt
+	}
+}
+
+// Forgets the last positions of targets that timed out. Must be called with trafficMutex held.
+func cleanupCoverage() {
+	coverageMutex.Lock()
+	defer coverageMutex.Unlock()
+	for _, stats := range coverage.Sources {
+		for key := range stats.targets {
+			if _, ok := traffic[key]; !ok {
+				delete(stats.targets, key)
+			}
+		}
+	}
+}
+
+// Called from heartBeatSender() after updateMessageStats()
+func updateCoverageMessageRates() {
+	coverageMutex.Lock()
+	defer coverageMutex.Unlock()
+	rates := map[string]uint{
+		"ES":  globalStatus.ES_messages_last_minute,
+		"UAT": globalStatus.UAT_messages_last_minute,
+		"OGN": globalStatus.OGN_messages_last_minute,
+		"AIS": globalStatus.AIS_messages_last_minute,
+	}
+	for name, rate := range rates {
+		stats := coverage.Sources[name]
+		if stats == nil {
+			continue
+		}
+		stats.Messages_last_minute = rate
+		if rate > stats.Messages_max {
+			stats.Messages_max = rate
+		}
+		stats.messageSamples++
+		stats.messageSum += uint64(rate)
+		stats.Messages_mean = float64(stats.messageSum) / float64(stats.messageSamples)
+	}
+}
+
+// Returns a deep copy of the current statistics for serialization.
+func getCoverage() CoverageStats {
+	coverageMutex.Lock()
+	defer coverageMutex.Unlock()
+	result := coverage
+	result.Sources = make(map[string]*CoverageSourceStats)
+	for name, stats := range coverage.Sources {
+		s := *stats
+		s.targets = nil
+		s.Bins = make([][]CoverageBin, len(stats.Bins))
+		for i := range stats.Bins {
+			s.Bins[i] = append([]CoverageBin(nil), stats.Bins[i]...)
+		}
+		result.Sources[name] = &s
+	}
+	return result
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestRecordCoverage(t *testing.T) {
+	setTestOwnship(t, 0, testSpeedKts)
+	resetCoverage()
+	ti := testConflictTarget(5000, 0, 180, testSpeedKts, 2000)
+	ti.Icao_addr = 0xA12345
+	ti.Last_source = TRAFFIC_SOURCE_1090ES
+	ti.Last_seen = stratuxClock.Time
+	addTestTraffic(0xA12345, ti)
+
+	recordCoverage(0xA12345, ti)
+	recordCoverage(0xA12345, ti) // e.g. a velocity message, no new position
+	moved := testConflictTarget(4800, 0, 180, testSpeedKts, 2000)
+	ti.Lat, ti.Lng = moved.Lat, moved.Lng
+	recordCoverage(0xA12345, ti)
+	es := getCoverage().Sources["ES"]
+	if es.Reports != 2 || es.Targets != 1 || math.Abs(es.MaxRange-5000) > 5 {
+		t.Errorf("reports %d targets %d max range %.0f m", es.Reports, es.Targets, es.MaxRange)
+	}
+	if bin := es.Bins[0][3]; bin.Reports != 2 || bin.MaxRangeIcao != 0xA12345 || bin.MaxRangeAlt != ti.Alt {
+		t.Errorf("north, 1000 to 5000 ft above: %+v", bin)
+	}
+
+	// Targets are forgotten when they time out, so the map doesn't grow with every target of a long session
+	for i := uint32(1); i <= 1000; i++ {
+		recordCoverage(0xB00000+i, ti)
+	}
+	trafficMutex.Lock()
+	cleanupCoverage()
+	trafficMutex.Unlock()
+	coverageMutex.Lock()
+	remaining := len(coverage.Sources["ES"].targets)
+	coverageMutex.Unlock()
+	if remaining != 1 {
+		t.Errorf("%d targets remembered, want 1", remaining)
+	}
+	if es := getCoverage().Sources["ES"]; es.Targets != 1001 || es.Reports != 1002 {
+		t.Errorf("after cleanup: reports %d targets %d", es.Reports, es.Targets)
+	}
+
+	// A target that came back is counted again
+	recordCoverage(0xB00001, ti)
+	if es := getCoverage().Sources["ES"]; es.Targets != 1002 {
+		t.Errorf("target came back: targets %d", es.Targets)
+	}
+}
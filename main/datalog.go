@@ -183,10 +183,17 @@ var sqlTypeMap = map[reflect.Kind]string{
 	reflect.UnsafePointer: "notsupported",
 }
 
-func makeTable(i interface{}, tbl string, db *sql.DB) {
+// Checks if table tbl exists in db.
+func tableExists(tbl string, db *sql.DB) bool {
+	var name string
+	err := db.QueryRow("SELECT name FROM sqlite_master WHERE type='table' AND name=?", tbl).Scan(&name)
+	return err == nil
+}
+
+// Column names and definitions ("name TYPE") of the table for struct i, without the primary key.
+func tableColumns(i interface{}, tbl string) (names []string, fields []string) {
 	val := reflect.ValueOf(i)
 
-	fields := make([]string, 0)
 	for i := 0; i < val.NumField(); i++ {
 		kind := val.Field(i).Kind()
 		fieldName := val.Type().Field(i).Name
@@ -196,19 +203,24 @@ func makeTable(i interface{}, tbl string, db *sql.DB) {
 		if sqlTypeAlias == "struct" && !structCanBeMarshalled(val.Field(i)) {
 			continue
 		}
-		if sqlTypeAlias == "notsupported" || fieldName == "id" {
+		if sqlTypeAlias == "notsupported" || strings.EqualFold(fieldName, "id") { // id is the primary key added below
 			continue
 		}
 		sqlType := sqliteMarshalFunctions[sqlTypeAlias].FieldType
-		s := fieldName + " " + sqlType
-		fields = append(fields, s)
+		names = append(names, fieldName)
+		fields = append(fields, fieldName+" "+sqlType)
 	}
 
 	// Add the timestamp_id field to link up with the timestamp table.
 	if tbl != "timestamp" && tbl != "startup" {
+		names = append(names, "timestamp_id")
 		fields = append(fields, "timestamp_id INTEGER")
 	}
+	return
+}
 
+func makeTable(i interface{}, tbl string, db *sql.DB) {
+	_, fields := tableColumns(i, tbl)
 	tblCreate := fmt.Sprintf("CREATE TABLE %s (id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT, %s)", tbl, strings.Join(fields, ", "))
 
 	_, err := db.Exec(tblCreate)
@@ -217,6 +229,42 @@ func makeTable(i interface{}, tbl string, db *sql.DB) {
 	}
 }
 
+/*
+	addMissingColumns().
+		Adds the columns of fields that were added to the struct since the table was created by an older version.
+		 Rows logged before have NULL in these columns.
+*/
+
+func addMissingColumns(i interface{}, tbl string, db *sql.DB) {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", tbl))
+	if err != nil {
+		log.Printf("PRAGMA table_info(%s) err: %s\n", tbl, err.Error())
+		return
+	}
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var cid, notnull, pk int
+		var name, colType string
+		var dflt sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notnull, &dflt, &pk); err == nil {
+			existing[strings.ToLower(name)] = true
+		}
+	}
+	rows.Close()
+
+	names, fields := tableColumns(i, tbl)
+	for j, name := range names {
+		if existing[strings.ToLower(name)] {
+			continue
+		}
+		if _, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", tbl, fields[j])); err != nil {
+			log.Printf("ALTER TABLE %s ADD COLUMN %s err: %s\n", tbl, fields[j], err.Error())
+		} else {
+			log.Printf("datalog.go: added column %s to table %s\n", name, tbl)
+		}
+	}
+}
+
 /*
 	bulkInsert().
 		Reads insertBatch and insertBatchIfs. This is called after a group of insertData() calls.
@@ -292,7 +340,7 @@ func insertData(i interface{}, tbl string, db *sql.DB, ts_num int64) int64 {
 		fieldName := val.Type().Field(i).Name
 		sqlTypeAlias := sqlTypeMap[kind]
 
-		if sqlTypeAlias == "notsupported" || fieldName == "id" {
+		if sqlTypeAlias == "notsupported" || strings.EqualFold(fieldName, "id") { // id is the primary key, assigned by SQLite
 			continue
 		}
 
@@ -465,18 +513,31 @@ func dataLog() {
 	//log.Printf("Starting dataLogWriter\n") // REMOVE -- DEBUG
 	go dataLogWriter(db)
 
-	// Do we need to create the database?
-	if createDatabase {
-		makeTable(StratuxTimestamp{}, "timestamp", db)
-		makeTable(mySituation, "mySituation", db)
-		makeTable(globalStatus, "status", db)
-		makeTable(globalSettings, "settings", db)
-		makeTable(TrafficInfo{}, "traffic", db)
-		makeTable(msg{}, "messages", db)
-		makeTable(esmsg{}, "es_messages", db)
-		makeTable(Dump1090TermMessage{}, "dump1090_terminal", db)
-		makeTable(gpsPerfStats{}, "gps_attitude", db)
-		makeTable(StratuxStartup{}, "startup", db)
+	// Do we need to create the database? Databases of older versions are missing the tables and columns that were
+	// added since.
+	tables := []struct {
+		i   interface{}
+		tbl string
+	}{
+		{StratuxTimestamp{}, "timestamp"},
+		{mySituation, "mySituation"},
+		{globalStatus, "status"},
+		{globalSettings, "settings"},
+		{TrafficInfo{}, "traffic"},
+		{TrafficEvent{}, "traffic_events"},
+		{EmergencyEpisode{}, "emergencies"},
+		{msg{}, "messages"},
+		{esmsg{}, "es_messages"},
+		{Dump1090TermMessage{}, "dump1090_terminal"},
+		{gpsPerfStats{}, "gps_attitude"},
+		{StratuxStartup{}, "startup"},
+	}
+	for _, t := range tables {
+		if createDatabase || !tableExists(t.tbl, db) {
+			makeTable(t.i, t.tbl, db)
+		} else {
+			addMissingColumns(t.i, t.tbl, db)
+		}
 	}
 
 	// The first entry to be created is the "startup" entry.
@@ -557,6 +618,18 @@ func logTraffic(ti TrafficInfo) {
 	}
 }
 
+func logTrafficEvent(ev TrafficEvent) {
+	if globalSettings.ReplayLog && isDataLogReady() {
+		dataLogChan <- DataLogRow{tbl: "traffic_events", data: ev}
+	}
+}
+
+func logEmergency(episode EmergencyEpisode) {
+	if globalSettings.ReplayLog && isDataLogReady() {
+		dataLogChan <- DataLogRow{tbl: "emergencies", data: episode}
+	}
+}
+
 func logMsg(m msg) {
 	if globalSettings.ReplayLog && isDataLogReady() {
 		dataLogChan <- DataLogRow{tbl: "messages", data: m}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func tableColumnNames(t *testing.T, db *sql.DB, tbl string) map[string]bool {
+	rows, err := db.Query("PRAGMA table_info(" + tbl + ")")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	names := make(map[string]bool)
+	for rows.Next() {
+		var cid, notnull, pk int
+		var name, colType string
+		var dflt sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notnull, &dflt, &pk); err != nil {
+			t.Fatal(err)
+		}
+		names[strings.ToLower(name)] = true
+	}
+	return names
+}
+
+// Tables of an older version get the columns of fields that were added since, the logged rows are kept.
+func TestAddMissingColumns(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "stratux.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	tables := []struct {
+		i   interface{}
+		tbl string
+	}{
+		{TrafficInfo{}, "traffic"},
+		{mySituation, "mySituation"},
+		{globalStatus, "status"},
+		{globalSettings, "settings"},
+	}
+	for _, tt := range tables {
+		names, _ := tableColumns(tt.i, tt.tbl)
+		if _, err := db.Exec("CREATE TABLE " + tt.tbl + " (id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT, " + names[0] + " INTEGER, timestamp_id INTEGER)"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Exec("INSERT INTO " + tt.tbl + " (" + names[0] + ", timestamp_id) VALUES (1, 1)"); err != nil {
+			t.Fatal(err)
+		}
+
+		addMissingColumns(tt.i, tt.tbl, db)
+		columns := tableColumnNames(t, db, tt.tbl)
+		for _, name := range names {
+			if !columns[strings.ToLower(name)] {
+				t.Errorf("%s: column %s missing", tt.tbl, name)
+			}
+		}
+		if len(columns) != len(names)+1 {
+			t.Errorf("%s: %d columns, want %d", tt.tbl, len(columns), len(names)+1)
+		}
+		var count int
+		if err := db.QueryRow("SELECT COUNT(*) FROM " + tt.tbl).Scan(&count); err != nil || count != 1 {
+			t.Errorf("%s: %d rows, %v", tt.tbl, count, err)
+		}
+
+		// Nothing to add the second time
+		addMissingColumns(tt.i, tt.tbl, db)
+		if n := len(tableColumnNames(t, db, tt.tbl)); n != len(columns) {
+			t.Errorf("%s: %d columns after the second run, want %d", tt.tbl, n, len(columns))
+		}
+	}
+}
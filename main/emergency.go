@@ -0,0 +1,170 @@
+/*
+	Copyright (c) 2026 Stratux contributors
+	Distributable under the terms of The "BSD New" License
+	that can be found in the LICENSE file, herein included
+	as part of this header.
+
+	emergency.go: Detects targets that declare an emergency, either by squawking 7500/7600/7700 or through the
+		emergency/priority status of DO-282B (UAT) and relayed GDL90 traffic. dump1090 doesn't pass the DO-260B
+		status on, so 1090ES targets are detected by their squawk only. Emergency episodes are pushed to the
+		/trafficEvents websocket and recorded in the datalog.
+*/
+
+package main
+
+import (
+	"log"
+	"time"
+)
+
+// Emergency / priority status codes as defined in the GDL90 spec (same as DO-260B and DO-282B)
+const (
+	EMERGENCY_NONE                  = 0
+	EMERGENCY_GENERAL               = 1
+	EMERGENCY_MEDICAL               = 2
+	EMERGENCY_MINIMUM_FUEL          = 3
+	EMERGENCY_NO_COMMUNICATION      = 4
+	EMERGENCY_UNLAWFUL_INTERFERENCE = 5
+	EMERGENCY_DOWNED_AIRCRAFT       = 6
+
+	EMERGENCY_TRAFFIC_PRIORITY = -1 // MessageQueue priority of emergency traffic. Same as ownship, so it's never pruned
+)
+
+type EmergencyEpisode struct {
+	Icao_addr   uint32
+	Addr_type   uint8
+	Tail        string
+	Reg         string
+	Squawk      int
+	Status      uint8 // EMERGENCY_*
+	Description string
+	Start       time.Time // UTC
+	End         time.Time // UTC, zero while the episode is active
+	Lat         float32   // last known position
+	Lng         float32
+	Alt         int32
+	Distance    float64 // meters, 0 if unknown
+}
+
+// Keyed like the traffic map. Protected by trafficMutex.
+var emergencyEpisodes map[uint32]*EmergencyEpisode = make(map[uint32]*EmergencyEpisode)
+
+func emergencyStatusFromSquawk(squawk int) uint8 {
+	switch squawk {
+	case 7500:
+		return EMERGENCY_UNLAWFUL_INTERFERENCE
+	case 7600:
+		return EMERGENCY_NO_COMMUNICATION
+	case 7700:
+		return EMERGENCY_GENERAL
+	}
+	return EMERGENCY_NONE
+}
+
+func emergencyDescription(status uint8) string {
+	switch status {
+	case EMERGENCY_GENERAL:
+		return "General emergency"
+	case EMERGENCY_MEDICAL:
+		return "Lifeguard / medical emergency"
+	case EMERGENCY_MINIMUM_FUEL:
+		return "Minimum fuel"
+	case EMERGENCY_NO_COMMUNICATION:
+		return "No communication"
+	case EMERGENCY_UNLAWFUL_INTERFERENCE:
+		return "Unlawful interference"
+	case EMERGENCY_DOWNED_AIRCRAFT:
+		return "Downed aircraft"
+	}
+	return "No emergency"
+}
+
+// The reported emergency status takes precedence over the one derived from the squawk code.
+func trafficEmergencyStatus(ti TrafficInfo) uint8 {
+	if ti.PriorityStatus >= EMERGENCY_GENERAL && ti.PriorityStatus <= EMERGENCY_DOWNED_AIRCRAFT {
+		return ti.PriorityStatus
+	}
+	return emergencyStatusFromSquawk(ti.Squawk)
+}
+
+/*
+	detectEmergency() sets ti.Emergency and starts, updates or ends the emergency episode of the target.
+		Called from sendTrafficUpdates() with trafficMutex held.
+*/
+func detectEmergency(key uint32, ti *TrafficInfo) {
+	if ti.Fused {
+		// Reported by the target it was fused into
+		ti.Emergency = EMERGENCY_NONE
+	} else {
+		ti.Emergency = trafficEmergencyStatus(*ti)
+	}
+
+	episode, active := emergencyEpisodes[key]
+	if ti.Emergency == EMERGENCY_NONE {
+		if active {
+			endEmergencyEpisode(key, episode)
+		}
+		return
+	}
+
+	if !active {
+		episode = &EmergencyEpisode{Start: time.Now().UTC()}
+		emergencyEpisodes[key] = episode
+	}
+	statusChanged := !active || episode.Status != ti.Emergency
+	episode.Icao_addr = ti.Icao_addr
+	episode.Addr_type = ti.Addr_type
+	episode.Tail = ti.Tail
+	episode.Reg = ti.Reg
+	episode.Squawk = ti.Squawk
+	episode.Status = ti.Emergency
+	episode.Description = emergencyDescription(ti.Emergency)
+	if ti.Position_valid {
+		episode.Lat = ti.Lat
+		episode.Lng = ti.Lng
+	}
+	episode.Alt = ti.Alt
+	if ti.BearingDist_valid {
+		episode.Distance = ti.Distance
+	}
+
+	if statusChanged {
+		log.Printf("Emergency: %X (%s) squawk %04d: %s\n", ti.Icao_addr, ti.Tail, ti.Squawk, episode.Description)
+		logEmergency(*episode)
+		addTrafficEvent(emergencyTrafficEvent(TRAFFIC_EVENT_EMERGENCY, *episode, ti.OnGround))
+	}
+}
+
+func endEmergencyEpisode(key uint32, episode *EmergencyEpisode) {
+	episode.End = time.Now().UTC()
+	log.Printf("Emergency ended: %X (%s): %s\n", episode.Icao_addr, episode.Tail, episode.Description)
+	logEmergency(*episode)
+	addTrafficEvent(emergencyTrafficEvent(TRAFFIC_EVENT_EMERGENCY_END, *episode, false))
+	delete(emergencyEpisodes, key)
+}
+
+// Ends episodes of targets that are no longer tracked. Must be called with trafficMutex held.
+func cleanupEmergencies() {
+	for key, episode := range emergencyEpisodes {
+		if _, ok := traffic[key]; !ok {
+			endEmergencyEpisode(key, episode)
+		}
+	}
+}
+
+func emergencyTrafficEvent(eventType string, episode EmergencyEpisode, onGround bool) TrafficEvent {
+	return TrafficEvent{
+		Type:      eventType,
+		Icao_addr: episode.Icao_addr,
+		Addr_type: episode.Addr_type,
+		Tail:      episode.Tail,
+		Reg:       episode.Reg,
+		Squawk:    episode.Squawk,
+		Emergency: episode.Status,
+		Lat:       episode.Lat,
+		Lng:       episode.Lng,
+		Alt:       episode.Alt,
+		Distance:  episode.Distance,
+		OnGround:  onGround,
+	}
+}
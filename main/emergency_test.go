@@ -0,0 +1,73 @@
+package main
+
+import (
+	"testing"
+)
+
+// Types of the traffic events added since the event log had n entries.
+func trafficEventTypesSince(n int) []string {
+	trafficWatchMutex.Lock()
+	defer trafficWatchMutex.Unlock()
+	var types []string
+	for _, ev := range trafficEventLog[n:] {
+		types = append(types, ev.Type)
+	}
+	return types
+}
+
+func trafficEventCount() int {
+	trafficWatchMutex.Lock()
+	defer trafficWatchMutex.Unlock()
+	return len(trafficEventLog)
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestDetectEmergency(t *testing.T) {
+	tests := []struct {
+		name     string
+		squawk   int
+		priority uint8
+		fused    bool
+		want     uint8
+		events   []string
+	}{
+		{"no emergency", 1200, EMERGENCY_NONE, false, EMERGENCY_NONE, nil},
+		{"hijack", 7500, EMERGENCY_NONE, false, EMERGENCY_UNLAWFUL_INTERFERENCE, []string{TRAFFIC_EVENT_EMERGENCY}},
+		{"same status", 7500, EMERGENCY_NONE, false, EMERGENCY_UNLAWFUL_INTERFERENCE, nil},
+		{"reported status wins", 7500, EMERGENCY_MEDICAL, false, EMERGENCY_MEDICAL, []string{TRAFFIC_EVENT_EMERGENCY}},
+		{"radio failure", 7600, EMERGENCY_NONE, false, EMERGENCY_NO_COMMUNICATION, []string{TRAFFIC_EVENT_EMERGENCY}},
+		{"ended", 7000, EMERGENCY_NONE, false, EMERGENCY_NONE, []string{TRAFFIC_EVENT_EMERGENCY_END}},
+		{"general", 7700, EMERGENCY_NONE, false, EMERGENCY_GENERAL, []string{TRAFFIC_EVENT_EMERGENCY}},
+		// Fused into another track, which reports the emergency from now on
+		{"fused", 7700, EMERGENCY_NONE, true, EMERGENCY_NONE, []string{TRAFFIC_EVENT_EMERGENCY_END}},
+		{"still fused", 7700, EMERGENCY_NONE, true, EMERGENCY_NONE, nil},
+	}
+
+	const key = 0xA12345
+	delete(emergencyEpisodes, key)
+	for _, tt := range tests {
+		ti := TrafficInfo{Icao_addr: key, Squawk: tt.squawk, PriorityStatus: tt.priority, Fused: tt.fused}
+		n := trafficEventCount()
+		detectEmergency(key, &ti)
+		if ti.Emergency != tt.want {
+			t.Errorf("%s: emergency %d, want %d", tt.name, ti.Emergency, tt.want)
+		}
+		if events := trafficEventTypesSince(n); !equalStrings(events, tt.events) {
+			t.Errorf("%s: events %v, want %v", tt.name, events, tt.events)
+		}
+		if _, active := emergencyEpisodes[key]; active != (tt.want != EMERGENCY_NONE) {
+			t.Errorf("%s: episode active %t", tt.name, active)
+		}
+	}
+}
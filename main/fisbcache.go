@@ -0,0 +1,266 @@
+/*
+	Copyright (c) 2026 Stratux contributors
+	Distributable under the terms of The "BSD New" License
+	that can be found in the LICENSE file, herein included
+	as part of this header.
+
+	fisbcache.go: FIS-B product cache. Every FIS-B information frame received over UAT is kept as a GDL90 uplink
+		message of its own, keyed by product ID and record identity (text report, graphical report, NEXRAD block,
+		segment), so newer frames replace older ones and frames expire like the product they carry. Frames without a
+		record identity aren't cached. Clients that
+		connect or wake up from sleep get the cache replayed into their MessageQueue at low priority instead of
+		waiting for the next broadcast cycle, and the /weather websocket starts off with the cached text reports.
+*/
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/b3nn0/stratux/uatparse"
+)
+
+const (
+	FISB_CACHE_CLEANUP_INTERVAL = time.Minute
+	FISB_CACHE_REPLAY_CHECK     = 5 * time.Second
+	FISB_CACHE_DEFAULT_EXPIRY   = 15 * time.Minute // grid products without a max age, same as the queue durability in relayMessage()
+	FISB_CACHE_PRIO_TEXT        = 6                // live uplinks are sent with priority 4
+	FISB_CACHE_PRIO_GRAPHICS    = 7
+	FISB_CACHE_PRIO_NEXRAD      = 8
+	UPLINK_HEADER_BYTES         = 8 // UAT specific header: ground station position, slot ID, application data valid
+)
+
+// Expiry of the text products (413) by report type. Reports that aren't rebroadcast within that time are stale.
+var fisbTextExpiry = map[string]time.Duration{
+	"METAR":   2 * time.Hour,
+	"SPECI":   2 * time.Hour,
+	"TAF":     6 * time.Hour,
+	"TAF.AMD": 6 * time.Hour,
+	"WINDS":   12 * time.Hour,
+	"PIREP":   90 * time.Minute,
+}
+
+type fisbCacheEntry struct {
+	ProductID uint32
+	Message   []byte // GDL90 uplink message with only this frame
+	Priority  int32
+	Received  time.Time // stratuxClock
+	Expires   time.Time // stratuxClock
+}
+
+type fisbCachedReport struct {
+	Message WeatherMessage
+	Expires time.Time // stratuxClock
+}
+
+var fisbCache = make(map[string]*fisbCacheEntry)
+var fisbCachedReports = make(map[string]*fisbCachedReport) // by type and location
+var fisbCacheMutex sync.Mutex
+var fisbCacheLastCleanup time.Time // stratuxClock
+
+func fisbTextReportExpiry(reportType string) time.Duration {
+	if d, ok := fisbTextExpiry[reportType]; ok {
+		return d
+	}
+	return WEATHER_OVERLAY_TIMEOUT // AIRMET, SIGMET, CWA and NOTAM texts
+}
+
+// Record identity of a frame and how long it is kept. Text frames are identified by the reports they carry, graphical
+// frames by location, report number and record, NEXRAD and the other grid frames by the first block (and altitude),
+// segments by file ID and segment number. Returns an empty key for frames that can't be identified.
+func fisbCacheKey(f *uatparse.UATFrame) (string, int32, time.Duration) {
+	if f.SegmentCount > 0 {
+		return fmt.Sprintf("%d/segment/%d/%d", f.Product_id, f.SegmentFileID, f.SegmentNumber), FISB_CACHE_PRIO_GRAPHICS, WEATHER_OVERLAY_TIMEOUT
+	}
+	switch {
+	case f.Product_id == 413 && len(f.Text_data) > 0:
+		reports := make([]string, 0)
+		expiry := time.Duration(0)
+		for _, t := range f.Text_data {
+			x := strings.Fields(t)
+			if len(x) < 2 {
+				continue
+			}
+			reports = append(reports, x[0]+" "+x[1])
+			if d := fisbTextReportExpiry(x[0]); d > expiry {
+				expiry = d
+			}
+		}
+		if len(reports) > 0 {
+			return fmt.Sprintf("%d/%s", f.Product_id, strings.Join(reports, ",")), FISB_CACHE_PRIO_TEXT, expiry
+		}
+	case uatparse.IsOverlayProduct(f.Product_id) && f.Product != nil:
+		// Includes the overlay records that can't be decoded, so they are replayed to the EFBs as well.
+		records := make([]string, 0, len(f.Product.RecordIDs))
+		for _, r := range f.Product.RecordIDs {
+			if r.RecordID == 0 {
+				records = append(records, fmt.Sprintf("%d-%d/text", r.ReportNumber, r.ReportYear))
+			} else {
+				records = append(records, fmt.Sprintf("%d-%d/%d", r.ReportNumber, r.ReportYear, r.RecordID))
+			}
+		}
+		if len(records) > 0 {
+			return fmt.Sprintf("%d/%s/%s", f.Product_id, f.Product.LocationIdentifier, strings.Join(records, ",")), FISB_CACHE_PRIO_GRAPHICS, WEATHER_OVERLAY_TIMEOUT
+		}
+	case (f.Product_id == NEXRAD_PRODUCT_REGIONAL || f.Product_id == NEXRAD_PRODUCT_CONUS) && len(f.NEXRAD) > 0:
+		b := f.NEXRAD[0]
+		expiry := NEXRAD_CONUS_MAX_AGE
+		if f.Product_id == NEXRAD_PRODUCT_REGIONAL {
+			expiry = NEXRAD_REGIONAL_MAX_AGE
+		}
+		return fmt.Sprintf("%d/%d/%.4f/%.4f", f.Product_id, b.Scale, b.LatNorth, b.LonWest), FISB_CACHE_PRIO_NEXRAD, expiry
+	case len(f.Blocks) > 0:
+		b := f.Blocks[0]
+		expiry, ok := weatherBlocksMaxAge[f.Product_id]
+		if !ok {
+			expiry = FISB_CACHE_DEFAULT_EXPIRY
+		}
+		return fmt.Sprintf("%d/%d/%d/%.4f/%.4f", f.Product_id, b.Altitude, b.Scale, b.LatNorth, b.LonWest), FISB_CACHE_PRIO_NEXRAD, expiry
+	}
+	return "", 0, 0
+}
+
+// GDL90 uplink message with the UAT header of the original uplink and f as the only information frame.
+func fisbSingleFrameUplink(uplink []byte, f *uatparse.UATFrame) []byte {
+	n := len(f.Raw_data)
+	if len(uplink) < UPLINK_HEADER_BYTES || UPLINK_HEADER_BYTES+2+n > UPLINK_FRAME_DATA_BYTES {
+		return nil
+	}
+	ret := make([]byte, UPLINK_FRAME_DATA_BYTES+4)
+	ret[0] = MSGTYPE_UPLINK // time of reception left out, like in relayMessage()
+	data := ret[4:]
+	copy(data, uplink[:UPLINK_HEADER_BYTES])
+	data[6] |= 0x20 // application data valid
+	data[UPLINK_HEADER_BYTES] = byte(n >> 1)
+	data[UPLINK_HEADER_BYTES+1] = byte(n&0x01)<<7 | byte(f.Frame_type&0x0f)
+	copy(data[UPLINK_HEADER_BYTES+2:], f.Raw_data)
+	return prepareMessage(ret)
+}
+
+// Called from parseInput() for every frame of an uplink message, uplink is the whole decoded message.
+func cacheFISBFrame(uplink []byte, f *uatparse.UATFrame) {
+	if f.Frame_type != 0 || len(f.Raw_data) == 0 {
+		return // not FIS-B
+	}
+	key, priority, expiry := fisbCacheKey(f)
+	if len(key) == 0 {
+		return
+	}
+	msg := fisbSingleFrameUplink(uplink, f)
+	if msg == nil {
+		return
+	}
+
+	fisbCacheMutex.Lock()
+	defer fisbCacheMutex.Unlock()
+	fisbCache[key] = &fisbCacheEntry{
+		ProductID: f.Product_id,
+		Message:   msg,
+		Priority:  priority,
+		Received:  stratuxClock.Time,
+		Expires:   stratuxClock.Time.Add(expiry),
+	}
+	if stratuxClock.Since(fisbCacheLastCleanup) > FISB_CACHE_CLEANUP_INTERVAL {
+		for key, e := range fisbCache {
+			if !e.Expires.After(stratuxClock.Time) {
+				delete(fisbCache, key)
+			}
+		}
+		for key, r := range fisbCachedReports {
+			if !r.Expires.After(stratuxClock.Time) {
+				delete(fisbCachedReports, key)
+			}
+		}
+		fisbCacheLastCleanup = stratuxClock.Time
+	}
+}
+
+// Called from registerADSBTextMessageReceived() for every text report sent to the /weather websocket.
+func cacheWeatherMessage(wm WeatherMessage) {
+	fisbCacheMutex.Lock()
+	defer fisbCacheMutex.Unlock()
+	fisbCachedReports[wm.Type+" "+wm.Location] = &fisbCachedReport{
+		Message: wm,
+		Expires: wm.LocaltimeReceived.Add(fisbTextReportExpiry(wm.Type)),
+	}
+}
+
+// Cached text reports, oldest first, so they arrive at the websocket in the order they were received.
+func getCachedWeatherMessages() []WeatherMessage {
+	fisbCacheMutex.Lock()
+	defer fisbCacheMutex.Unlock()
+	result := make([]WeatherMessage, 0)
+	for _, r := range fisbCachedReports {
+		if r.Expires.After(stratuxClock.Time) {
+			result = append(result, r.Message)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].LocaltimeReceived.Before(result[j].LocaltimeReceived) })
+	return result
+}
+
+// Queues all cached frames that haven't expired for one client. Text products first, NEXRAD last, so the queue
+// drops NEXRAD blocks first if the cache doesn't fit.
+func replayFISBCache(conn connection) {
+	fisbCacheMutex.Lock()
+	entries := make([]*fisbCacheEntry, 0, len(fisbCache))
+	for _, e := range fisbCache {
+		if e.Expires.After(stratuxClock.Time) {
+			entries = append(entries, e)
+		}
+	}
+	fisbCacheMutex.Unlock()
+	if len(entries) == 0 {
+		return
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Priority != entries[j].Priority {
+			return entries[i].Priority < entries[j].Priority
+		}
+		return entries[i].Received.Before(entries[j].Received)
+	})
+	log.Printf("replaying %d cached FIS-B frames to %s\n", len(entries), conn.GetConnectionKey())
+	queue := conn.MessageQueue()
+	for _, e := range entries {
+		queue.Put(e.Priority, e.Expires.Sub(stratuxClock.Time), e.Message)
+	}
+}
+
+// Replays the cache to GDL90 clients that are new or just woke up from sleep.
+func fisbCacheReplayer() {
+	awake := make(map[string]bool) // by connection key
+	ticker := time.NewTicker(FISB_CACHE_REPLAY_CHECK)
+	for {
+		<-ticker.C
+		woken := make([]connection, 0)
+		seen := make(map[string]bool)
+		netMutex.Lock()
+		for key, conn := range clientConnections {
+			if (conn.Capabilities() & NETWORK_GDL90_STANDARD) == 0 {
+				continue
+			}
+			seen[key] = true
+			sleeping := conn.IsSleeping()
+			if !sleeping && !awake[key] {
+				woken = append(woken, conn)
+			}
+			awake[key] = !sleeping
+		}
+		netMutex.Unlock()
+
+		for key := range awake {
+			if !seen[key] {
+				delete(awake, key)
+			}
+		}
+		for _, conn := range woken {
+			replayFISBCache(conn)
+		}
+	}
+}
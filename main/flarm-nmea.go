@@ -33,7 +33,7 @@ import (
 // Append checksum and to nmea string
 func appendNmeaChecksum(nmea string) string {
 	start := 0
-	if nmea[0] == '$' {
+	if nmea[0] == '$' || nmea[0] == '!' { // '!' for AIS
 		start = 1
 	}
 	checksum := byte(0x00)
@@ -52,7 +52,7 @@ func makeFlarmPFLAUString(ti TrafficInfo) (msg string) {
 
 	dist, bearing, _, _ := common.DistRect(float64(mySituation.GPSLatitude), float64(mySituation.GPSLongitude), float64(ti.Lat), float64(ti.Lng))
 	relativeVertical := computeRelativeVertical(ti)
-	alarmLevel := computeAlarmLevel(dist, relativeVertical)
+	alarmLevel := computeAlarmLevel(ti, dist, relativeVertical)
 
 	// make bearing relative to ground track, with +-180deg
 	bearing = bearing - float64(mySituation.GPSTrueCourse)
@@ -83,9 +83,12 @@ func makeFlarmPFLAUString(ti TrafficInfo) (msg string) {
 	return
 }
 
-// TODO: only very simplistic implementation
-func computeAlarmLevel(dist float64, relativeVertical int32) (alarmLevel uint8) {
-	if (dist < 926) && (relativeVertical < 152) && (relativeVertical > -152) { // 926 m = 0.5 NM; 152m = 500'
+// Uses the closest point of approach predicted in sendTrafficUpdates() if possible. If ownship or target motion is
+// unknown (e.g. bearingless targets), falls back to a simple check of current distance and altitude difference.
+func computeAlarmLevel(ti TrafficInfo, dist float64, relativeVertical int32) (alarmLevel uint8) {
+	if ti.CPA_valid {
+		alarmLevel = ti.AlarmLevel
+	} else if (dist < 926) && (relativeVertical < 152) && (relativeVertical > -152) { // 926 m = 0.5 NM; 152m = 500'
 		alarmLevel = 3
 	} else if (dist < 1852) && (relativeVertical < 304) && (relativeVertical > -304) { // 1852 m = 1.0 NM ; 304 m = 1000'
 		alarmLevel = 2
@@ -225,7 +228,7 @@ func makeFlarmPFLAAString(ti TrafficInfo) (msg string, valid bool, alarmLevel ui
 	//}
 
 	relativeVertical = computeRelativeVertical(ti)
-	alarmLevel = computeAlarmLevel(dist, relativeVertical)
+	alarmLevel = computeAlarmLevel(ti, dist, relativeVertical)
 
 	if ti.Speed_valid {
 		groundSpeed = int32(float32(ti.Speed) * 0.5144) // convert to m/s
@@ -591,7 +594,7 @@ func parseFlarmPFLAU(message []string) {
 	traffic[key] = ti
 
 	// notify
-	registerTrafficUpdate(ti)
+	registerTrafficUpdate(key, ti)
 
 	// mark traffic as seen
 	seenTraffic[key] = true
@@ -690,7 +693,7 @@ func parseFlarmPFLAA(message []string) {
 	traffic[key] = ti
 
 	// notify
-	registerTrafficUpdate(ti)
+	registerTrafficUpdate(key, ti)
 
 	// mark traffic as seen
 	seenTraffic[key] = true
@@ -0,0 +1,464 @@
+/*
+	Copyright (c) 2026 Stratux contributors
+	Distributable under the terms of The "BSD New" License
+	that can be found in the LICENSE file, herein included
+	as part of this header.
+
+	flightexport.go: Exports flights from the replay log (see datalog.go) as GPX, KML or GeoJSON. The mySituation rows
+		are split into flights at every startup and wherever the GPS time jumps by more than FLIGHT_SPLIT_GAP. Only
+		rows with GPS time (timestamp type 1 or 2) and a position fix are used. Traffic tracks within a radius of the
+		ownship can be added to the export.
+*/
+
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/b3nn0/stratux/common"
+)
+
+const (
+	FLIGHT_SPLIT_GAP        = 10 * time.Minute
+	FLIGHT_MIN_POINTS       = 10
+	FLIGHT_EXPORT_INTERVAL  = 1 * time.Second // at most one point per second and target
+	FLIGHT_TRAFFIC_RADIUS   = 10              // nm, default for nearby traffic
+	FLIGHT_TRAFFIC_MAX_SKEW = 5 * time.Second // traffic must be this close in time to an ownship point to be checked against the radius
+
+	FEET_PER_METER = 3.28084
+)
+
+// Layout of time.Time.String(), which is how datalog.go stores times
+const dataLogTimeLayout = "2006-01-02 15:04:05.999999999 -0700 MST"
+
+var errFlightNotFound = errors.New("flight not found")
+
+type flightPoint struct {
+	TimestampID int64
+	Time        time.Time
+	Lat         float64
+	Lng         float64
+	Alt         float64 // feet MSL
+	Speed       float64 // knots
+	Course      float64
+}
+
+// One entry of /getFlights
+type FlightInfo struct {
+	ID        int64 // Unix time of the first point, used to select the flight in /exportFlight
+	StartupID int64
+	Start     time.Time
+	End       time.Time
+	Points    int
+	MaxAlt    float64 // feet MSL
+	MaxSpeed  float64 // knots
+
+	firstTimestampID int64
+	lastTimestampID  int64
+}
+
+type trafficTrack struct {
+	Icao_addr uint32
+	Tail      string
+	Reg       string
+	Points    []flightPoint
+}
+
+func parseDataLogTime(s string) (time.Time, error) {
+	if idx := strings.Index(s, " m="); idx >= 0 {
+		s = s[:idx] // monotonic clock reading
+	}
+	return time.Parse(dataLogTimeLayout, s)
+}
+
+func openDataLogReadOnly() (*sql.DB, error) {
+	if _, err := os.Stat(dataLogFilef); err != nil {
+		return nil, err
+	}
+	return sql.Open("sqlite3", "file:"+dataLogFilef+"?mode=ro")
+}
+
+// Calls fn for every ownship position with GPS time in the replay log, in log order.
+func readOwnshipPoints(db *sql.DB, fn func(startupID int64, p flightPoint)) error {
+	rows, err := db.Query(`SELECT t.id, t.StartupID, t.PreferredTime_value, s.GPSLatitude, s.GPSLongitude, s.GPSAltitudeMSL,
+		s.GPSGroundSpeed, s.GPSTrueCourse FROM mySituation s JOIN timestamp t ON s.timestamp_id = t.id
+		WHERE t.Time_type_preference > 0 AND s.GPSFixQuality > 0 ORDER BY s.id`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	var last flightPoint
+	for rows.Next() {
+		var p flightPoint
+		var startupID int64
+		var ts string
+		if err := rows.Scan(&p.TimestampID, &startupID, &ts, &p.Lat, &p.Lng, &p.Alt, &p.Speed, &p.Course); err != nil {
+			return err
+		}
+		if p.Time, err = parseDataLogTime(ts); err != nil {
+			continue
+		}
+		if p.Lat == 0 && p.Lng == 0 {
+			continue
+		}
+		if p.Time.After(last.Time) && p.Time.Sub(last.Time) < FLIGHT_EXPORT_INTERVAL {
+			continue
+		}
+		last = p
+		fn(startupID, p)
+	}
+	return rows.Err()
+}
+
+// Splits the replay log into flights, oldest first.
+func getFlights(db *sql.DB) ([]FlightInfo, error) {
+	flights := make([]FlightInfo, 0)
+	var cur *FlightInfo
+	finish := func() {
+		if cur != nil && cur.Points >= FLIGHT_MIN_POINTS {
+			flights = append(flights, *cur)
+		}
+		cur = nil
+	}
+	err := readOwnshipPoints(db, func(startupID int64, p flightPoint) {
+		if cur != nil && (cur.StartupID != startupID || p.Time.Sub(cur.End) > FLIGHT_SPLIT_GAP || p.Time.Before(cur.End)) {
+			finish()
+		}
+		if cur == nil {
+			cur = &FlightInfo{
+				ID:               p.Time.Unix(),
+				StartupID:        startupID,
+				Start:            p.Time,
+				firstTimestampID: p.TimestampID,
+			}
+		}
+		cur.End = p.Time
+		cur.lastTimestampID = p.TimestampID
+		cur.Points++
+		cur.MaxAlt = math.Max(cur.MaxAlt, p.Alt)
+		cur.MaxSpeed = math.Max(cur.MaxSpeed, p.Speed)
+	})
+	finish()
+	return flights, err
+}
+
+// Returns the ownship track of a flight, limited to [from, to] if these are not zero.
+func getFlightTrack(db *sql.DB, id int64, from, to time.Time) (FlightInfo, []flightPoint, error) {
+	flights, err := getFlights(db)
+	if err != nil {
+		return FlightInfo{}, nil, err
+	}
+	var flight *FlightInfo
+	for i := range flights {
+		if flights[i].ID == id {
+			flight = &flights[i]
+		}
+	}
+	if flight == nil {
+		return FlightInfo{}, nil, errFlightNotFound
+	}
+	track := make([]flightPoint, 0, flight.Points)
+	err = readOwnshipPoints(db, func(startupID int64, p flightPoint) {
+		if startupID != flight.StartupID || p.TimestampID < flight.firstTimestampID || p.TimestampID > flight.lastTimestampID {
+			return
+		}
+		if (!from.IsZero() && p.Time.Before(from)) || (!to.IsZero() && p.Time.After(to)) {
+			return
+		}
+		track = append(track, p)
+	})
+	return *flight, track, err
+}
+
+// Ownship point closest in time, track must be sorted
+func nearestFlightPoint(track []flightPoint, t time.Time) (flightPoint, bool) {
+	i := sort.Search(len(track), func(i int) bool { return !track[i].Time.Before(t) })
+	best, bestDiff := flightPoint{}, time.Duration(math.MaxInt64)
+	for _, j := range []int{i - 1, i} {
+		if j < 0 || j >= len(track) {
+			continue
+		}
+		diff := track[j].Time.Sub(t)
+		if diff < 0 {
+			diff = -diff
+		}
+		if diff < bestDiff {
+			best, bestDiff = track[j], diff
+		}
+	}
+	return best, bestDiff <= FLIGHT_TRAFFIC_MAX_SKEW
+}
+
+// Traffic that came within radiusNM of the ownship during the track, ordered by address.
+func getFlightTraffic(db *sql.DB, track []flightPoint, radiusNM float64) ([]trafficTrack, error) {
+	tracks := make([]trafficTrack, 0)
+	if len(track) == 0 {
+		return tracks, nil
+	}
+	rows, err := db.Query(`SELECT t.PreferredTime_value, r.Icao_addr, r.Tail, r.Reg, r.Lat, r.Lng, r.Alt, r.AltIsGNSS,
+		r.GnssDiffFromBaroAlt, r.Speed, r.Track FROM traffic r JOIN timestamp t ON r.timestamp_id = t.id
+		WHERE r.timestamp_id BETWEEN ? AND ? AND r.Position_valid = 1 ORDER BY r.id`,
+		track[0].TimestampID, track[len(track)-1].TimestampID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	byAddr := make(map[uint32]*trafficTrack)
+	near := make(map[uint32]bool)
+	for rows.Next() {
+		var p flightPoint
+		var ts, tail, reg string
+		var addr uint32
+		var altIsGNSS bool
+		var gnssDiff float64
+		if err := rows.Scan(&ts, &addr, &tail, &reg, &p.Lat, &p.Lng, &p.Alt, &altIsGNSS, &gnssDiff, &p.Speed, &p.Course); err != nil {
+			return nil, err
+		}
+		if p.Time, err = parseDataLogTime(ts); err != nil {
+			continue
+		}
+		if !altIsGNSS {
+			p.Alt += gnssDiff // best guess for MSL, pressure altitude if we don't know the difference
+		}
+		tt, ok := byAddr[addr]
+		if !ok {
+			tt = &trafficTrack{Icao_addr: addr}
+			byAddr[addr] = tt
+		}
+		if len(tail) > 0 {
+			tt.Tail = tail
+		}
+		if len(reg) > 0 {
+			tt.Reg = reg
+		}
+		if n := len(tt.Points); n > 0 && p.Time.Sub(tt.Points[n-1].Time) < FLIGHT_EXPORT_INTERVAL {
+			continue
+		}
+		if track[0].Time.After(p.Time) || track[len(track)-1].Time.Before(p.Time) {
+			continue
+		}
+		tt.Points = append(tt.Points, p)
+		if !near[addr] {
+			if own, ok := nearestFlightPoint(track, p.Time); ok {
+				dist, _ := common.Distance(own.Lat, own.Lng, p.Lat, p.Lng)
+				near[addr] = dist/1852 <= radiusNM
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for addr, tt := range byAddr {
+		if near[addr] && len(tt.Points) > 1 {
+			tracks = append(tracks, *tt)
+		}
+	}
+	sort.Slice(tracks, func(i, j int) bool { return tracks[i].Icao_addr < tracks[j].Icao_addr })
+	return tracks, nil
+}
+
+func (tt trafficTrack) name() string {
+	if len(tt.Tail) > 0 {
+		return tt.Tail
+	}
+	if len(tt.Reg) > 0 {
+		return tt.Reg
+	}
+	return fmt.Sprintf("%06X", tt.Icao_addr&0xFFFFFF)
+}
+
+func flightName(flight FlightInfo) string {
+	return "Stratux " + flight.Start.UTC().Format("2006-01-02 15:04Z")
+}
+
+// GPX 1.1, see https://www.topografix.com/GPX/1/1/
+type gpxFile struct {
+	XMLName xml.Name   `xml:"gpx"`
+	Xmlns   string     `xml:"xmlns,attr"`
+	Version string     `xml:"version,attr"`
+	Creator string     `xml:"creator,attr"`
+	Tracks  []gpxTrack `xml:"trk"`
+}
+
+type gpxTrack struct {
+	Name     string       `xml:"name"`
+	Segments []gpxSegment `xml:"trkseg"`
+}
+
+type gpxSegment struct {
+	Points []gpxPoint `xml:"trkpt"`
+}
+
+type gpxPoint struct {
+	Lat  float64 `xml:"lat,attr"`
+	Lon  float64 `xml:"lon,attr"`
+	Ele  float64 `xml:"ele"`
+	Time string  `xml:"time"`
+}
+
+func gpxTrackFromPoints(name string, points []flightPoint) gpxTrack {
+	seg := gpxSegment{Points: make([]gpxPoint, 0, len(points))}
+	for _, p := range points {
+		seg.Points = append(seg.Points, gpxPoint{
+			Lat:  p.Lat,
+			Lon:  p.Lng,
+			Ele:  math.Round(p.Alt/FEET_PER_METER*10) / 10,
+			Time: p.Time.UTC().Format(time.RFC3339),
+		})
+	}
+	return gpxTrack{Name: name, Segments: []gpxSegment{seg}}
+}
+
+func writeFlightGPX(w io.Writer, flight FlightInfo, track []flightPoint, traffic []trafficTrack) error {
+	gpx := gpxFile{
+		Xmlns:   "http://www.topografix.com/GPX/1/1",
+		Version: "1.1",
+		Creator: "Stratux " + stratuxVersion,
+	}
+	gpx.Tracks = append(gpx.Tracks, gpxTrackFromPoints(flightName(flight), track))
+	for _, tt := range traffic {
+		gpx.Tracks = append(gpx.Tracks, gpxTrackFromPoints(tt.name(), tt.Points))
+	}
+	io.WriteString(w, xml.Header)
+	enc := xml.NewEncoder(w)
+	enc.Indent("", " ")
+	return enc.Encode(gpx)
+}
+
+// KML with gx:Track, so Google Earth shows the flight in 3D with a time slider
+type kmlFile struct {
+	XMLName  xml.Name    `xml:"kml"`
+	Xmlns    string      `xml:"xmlns,attr"`
+	XmlnsGx  string      `xml:"xmlns:gx,attr"`
+	Document kmlDocument `xml:"Document"`
+}
+
+type kmlDocument struct {
+	Name       string         `xml:"name"`
+	Styles     []kmlStyle     `xml:"Style"`
+	Placemarks []kmlPlacemark `xml:"Placemark"`
+}
+
+type kmlStyle struct {
+	ID        string `xml:"id,attr"`
+	LineColor string `xml:"LineStyle>color"`
+	LineWidth int    `xml:"LineStyle>width"`
+	PolyColor string `xml:"PolyStyle>color"`
+}
+
+type kmlPlacemark struct {
+	Name     string   `xml:"name"`
+	StyleURL string   `xml:"styleUrl"`
+	Track    kmlTrack `xml:"gx:Track"`
+}
+
+type kmlTrack struct {
+	Extrude      int      `xml:"extrude"`
+	AltitudeMode string   `xml:"altitudeMode"`
+	When         []string `xml:"when"`
+	Coords       []string `xml:"gx:coord"`
+}
+
+func kmlPlacemarkFromPoints(name, style string, extrude bool, points []flightPoint) kmlPlacemark {
+	pm := kmlPlacemark{Name: name, StyleURL: "#" + style}
+	pm.Track.AltitudeMode = "absolute"
+	if extrude {
+		pm.Track.Extrude = 1
+	}
+	for _, p := range points {
+		pm.Track.When = append(pm.Track.When, p.Time.UTC().Format(time.RFC3339))
+		pm.Track.Coords = append(pm.Track.Coords, fmt.Sprintf("%.6f %.6f %.1f", p.Lng, p.Lat, p.Alt/FEET_PER_METER))
+	}
+	return pm
+}
+
+func writeFlightKML(w io.Writer, flight FlightInfo, track []flightPoint, traffic []trafficTrack) error {
+	kml := kmlFile{
+		Xmlns:   "http://www.opengis.net/kml/2.2",
+		XmlnsGx: "http://www.google.com/kml/ext/2.2",
+		Document: kmlDocument{
+			Name: flightName(flight),
+			Styles: []kmlStyle{
+				{ID: "ownship", LineColor: "ff0000ff", LineWidth: 3, PolyColor: "400000ff"}, // aabbggrr
+				{ID: "traffic", LineColor: "ffffff00", LineWidth: 2, PolyColor: "00000000"},
+			},
+		},
+	}
+	kml.Document.Placemarks = append(kml.Document.Placemarks, kmlPlacemarkFromPoints(flightName(flight), "ownship", true, track))
+	for _, tt := range traffic {
+		kml.Document.Placemarks = append(kml.Document.Placemarks, kmlPlacemarkFromPoints(tt.name(), "traffic", false, tt.Points))
+	}
+	io.WriteString(w, xml.Header)
+	enc := xml.NewEncoder(w)
+	enc.Indent("", " ")
+	return enc.Encode(kml)
+}
+
+// GeoJSON LineStrings with [lon, lat, meters MSL] and the point times in the coordTimes property
+type geoJSONFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []geoJSONFeature `json:"features"`
+}
+
+type geoJSONFeature struct {
+	Type       string                 `json:"type"`
+	Geometry   geoJSONGeometry        `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+type geoJSONGeometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates"`
+}
+
+func geoJSONFeatureFromPoints(points []flightPoint, props map[string]interface{}) geoJSONFeature {
+	coords := make([][3]float64, 0, len(points))
+	times := make([]string, 0, len(points))
+	for _, p := range points {
+		coords = append(coords, [3]float64{p.Lng, p.Lat, math.Round(p.Alt/FEET_PER_METER*10) / 10})
+		times = append(times, p.Time.UTC().Format(time.RFC3339))
+	}
+	props["coordTimes"] = times
+	return geoJSONFeature{
+		Type:       "Feature",
+		Geometry:   geoJSONGeometry{Type: "LineString", Coordinates: coords},
+		Properties: props,
+	}
+}
+
+func writeFlightGeoJSON(w io.Writer, flight FlightInfo, track []flightPoint, traffic []trafficTrack) error {
+	fc := geoJSONFeatureCollection{Type: "FeatureCollection", Features: make([]geoJSONFeature, 0)}
+	fc.Features = append(fc.Features, geoJSONFeatureFromPoints(track, map[string]interface{}{
+		"name":    flightName(flight),
+		"ownship": true,
+	}))
+	for _, tt := range traffic {
+		fc.Features = append(fc.Features, geoJSONFeatureFromPoints(tt.Points, map[string]interface{}{
+			"name": tt.name(),
+			"icao": fmt.Sprintf("%06X", tt.Icao_addr&0xFFFFFF),
+			"tail": tt.Tail,
+			"reg":  tt.Reg,
+		}))
+	}
+	return json.NewEncoder(w).Encode(fc)
+}
+
+var flightExportFormats = map[string]struct {
+	contentType string
+	write       func(io.Writer, FlightInfo, []flightPoint, []trafficTrack) error
+}{
+	"gpx":     {"application/gpx+xml", writeFlightGPX},
+	"kml":     {"application/vnd.google-earth.kml+xml", writeFlightKML},
+	"geojson": {"application/geo+json", writeFlightGeoJSON},
+}
@@ -0,0 +1,288 @@
+/*
+	Copyright (c) 2026 Stratux contributors
+	Distributable under the terms of The "BSD New" License
+	that can be found in the LICENSE file, herein included
+	as part of this header.
+
+	gdl90in.go: GDL90 input. Listens for GDL90 broadcasts of another device (SkyEcho, another Stratux) on UDP.
+		Its traffic is added to the traffic map as TRAFFIC_SOURCE_GDL90, its UAT uplinks and reports are handled like
+		our own UAT reception, and its ownship position is used as GPS fallback if we have no GPS of our own.
+*/
+
+package main
+
+import (
+	"encoding/hex"
+	"log"
+	"net"
+	"time"
+
+	"github.com/b3nn0/stratux/common"
+	"github.com/b3nn0/stratux/gdl90"
+)
+
+const (
+	GDL90_IN_OWNSHIP_TIMEOUT = 5 * time.Second // give up the GPS fallback if no ownship report was received for this long
+	GDL90_IN_LOCAL_PRIORITY  = 5 * time.Second // relayed traffic doesn't overwrite targets we received ourselves this recently
+)
+
+// State of the GDL90 input. Only accessed from gdl90InListener().
+var gdl90InLastOwnship time.Time // stratuxClock
+var gdl90InGeoAlt *gdl90.OwnshipGeoAltitude
+var gdl90InLastGeoAlt time.Time // stratuxClock
+var gdl90InHeartbeat *gdl90.Heartbeat
+var gdl90InMSLGeoAlt bool // ForeFlight ID message: geometric altitude is MSL instead of HAE
+
+func gdl90InListener() {
+	for {
+		if !globalSettings.GDL90In_Enabled {
+			time.Sleep(5 * time.Second)
+			continue
+		}
+		port := globalSettings.GDL90InPort
+		conn, err := net.ListenUDP("udp", &net.UDPAddr{Port: port})
+		if err != nil {
+			log.Printf("GDL90 input: %s\n", err.Error())
+			time.Sleep(10 * time.Second)
+			continue
+		}
+		log.Printf("GDL90 input: listening on UDP port %d\n", port)
+
+		buf := make([]byte, 8192)
+		for globalSettings.GDL90In_Enabled && globalSettings.GDL90InPort == port {
+			conn.SetReadDeadline(time.Now().Add(time.Second))
+			n, addr, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+					checkGDL90InOwnshipTimeout()
+					continue
+				}
+				log.Printf("GDL90 input: %s\n", err.Error())
+				break
+			}
+			frames, _ := gdl90.Split(buf[:n])
+			for _, frame := range frames {
+				msg, err := gdl90.Unframe(frame)
+				if err != nil {
+					if globalSettings.DEBUG {
+						log.Printf("GDL90 input: %s from %s\n", err.Error(), addr.IP.String())
+					}
+					continue
+				}
+				handleGDL90InMessage(msg, addr.IP.String())
+			}
+			checkGDL90InOwnshipTimeout()
+		}
+		conn.Close()
+		releaseGDL90InGPS()
+		log.Printf("GDL90 input: stopped listening on UDP port %d\n", port)
+	}
+}
+
+func handleGDL90InMessage(msg []byte, remoteIp string) {
+	decoded, err := gdl90.Decode(msg)
+	if err != nil {
+		return // e.g. ForeFlight AHRS or other vendor specific messages
+	}
+	switch m := decoded.(type) {
+	case *gdl90.Heartbeat:
+		gdl90InHeartbeat = m
+	case *gdl90.ForeFlightID:
+		gdl90InMSLGeoAlt = m.MSLGeoAlt
+	case *gdl90.OwnshipGeoAltitude:
+		gdl90InGeoAlt = m
+		gdl90InLastGeoAlt = stratuxClock.Time
+	case *gdl90.OwnshipReport:
+		gdl90InLastOwnship = stratuxClock.Time
+		updateGPSFromGDL90Ownship(m, remoteIp)
+	case *gdl90.TrafficReport:
+		importGDL90Traffic(m)
+	case *gdl90.UATMessage:
+		// Handled exactly like our own UAT reception: decoded, logged and relayed to our clients
+		prefix := "-"
+		if m.ID == gdl90.MSG_UPLINK {
+			prefix = "+"
+		}
+		handleUatMessage(prefix + hex.EncodeToString(m.Payload) + ";")
+	}
+}
+
+// Horizontal accuracy (95%, meters) for a NACp value. Inverse of calculateNACp().
+func nacpToAccuracy(nacp uint8) float32 {
+	switch {
+	case nacp >= 11:
+		return 3
+	case nacp == 10:
+		return 10
+	case nacp == 9:
+		return 30
+	case nacp == 8:
+		return 92.6
+	case nacp == 7:
+		return 185.2
+	case nacp == 6:
+		return 555.6
+	}
+	return 999999
+}
+
+func isGDL90InGPS() bool {
+	return globalStatus.GPS_detected_type == GPS_TYPE_NETWORK|GPS_PROTOCOL_GDL90
+}
+
+// Uses the ownship report of the GDL90 input as position source, but only if there is no GPS of our own.
+func updateGPSFromGDL90Ownship(r *gdl90.OwnshipReport, remoteIp string) {
+	if !isGDL90InGPS() && globalStatus.GPS_connected {
+		return
+	}
+	if !r.PositionValid {
+		return
+	}
+	if gdl90InHeartbeat != nil && !gdl90InHeartbeat.GPSPosValid {
+		return
+	}
+
+	mySituation.muGPS.Lock()
+	mySituation.GPSLatitude = r.Lat
+	mySituation.GPSLongitude = r.Lng
+	mySituation.GPSFixQuality = 1
+	mySituation.GPSNACp = r.NACp
+	mySituation.GPSHorizontalAccuracy = nacpToAccuracy(r.NACp)
+	if gdl90InGeoAlt != nil && stratuxClock.Since(gdl90InLastGeoAlt) < GDL90_IN_OWNSHIP_TIMEOUT {
+		geoAlt := float32(gdl90InGeoAlt.Altitude)
+		if gdl90InMSLGeoAlt {
+			mySituation.GPSAltitudeMSL = geoAlt
+			mySituation.GPSHeightAboveEllipsoid = geoAlt + mySituation.GPSGeoidSep
+		} else {
+			mySituation.GPSHeightAboveEllipsoid = geoAlt
+			mySituation.GPSAltitudeMSL = geoAlt - mySituation.GPSGeoidSep
+		}
+		if gdl90InGeoAlt.VFOMValid {
+			mySituation.GPSVerticalAccuracy = float32(gdl90InGeoAlt.VFOM)
+		}
+	} else if r.AltitudeValid {
+		// Pressure altitude is the best we have
+		mySituation.GPSAltitudeMSL = float32(r.Altitude)
+		mySituation.GPSHeightAboveEllipsoid = float32(r.Altitude) + mySituation.GPSGeoidSep
+	}
+	if r.VvelValid {
+		mySituation.GPSVerticalSpeed = float32(r.Vvel) / 60
+	}
+	if r.SpeedValid && r.TrackType != gdl90.TRACK_INVALID {
+		mySituation.GPSGroundSpeed = float64(r.Speed)
+		mySituation.GPSTrueCourse = r.Track
+		mySituation.GPSLastGroundTrackTime = stratuxClock.Time
+	}
+	if gdl90InHeartbeat != nil && gdl90InHeartbeat.UTCOK {
+		mySituation.GPSLastFixSinceMidnightUTC = float32(gdl90InHeartbeat.Timestamp)
+	}
+	mySituation.GPSLastFixLocalTime = stratuxClock.Time
+	mySituation.GPSLastValidNMEAMessageTime = stratuxClock.Time
+	mySituation.GPSLastValidNMEAMessage = "GDL90 ownship report"
+	mySituation.muGPS.Unlock()
+
+	if !isGDL90InGPS() {
+		log.Printf("GDL90 input: using ownship of %s as GPS\n", remoteIp)
+	}
+	globalStatus.GPS_connected = true
+	globalStatus.GPS_detected_type = GPS_TYPE_NETWORK | GPS_PROTOCOL_GDL90
+	globalStatus.GPS_NetworkRemoteIp = remoteIp
+	registerSituationUpdate()
+}
+
+func checkGDL90InOwnshipTimeout() {
+	if isGDL90InGPS() && stratuxClock.Since(gdl90InLastOwnship) > GDL90_IN_OWNSHIP_TIMEOUT {
+		log.Printf("GDL90 input: no ownship report for %s, GPS fallback released\n", GDL90_IN_OWNSHIP_TIMEOUT)
+		releaseGDL90InGPS()
+	}
+}
+
+// Hands GPS back to pollGPS(), which will look for a GPS device again.
+func releaseGDL90InGPS() {
+	if !isGDL90InGPS() {
+		return
+	}
+	globalStatus.GPS_connected = false
+	globalStatus.GPS_detected_type = 0
+	globalStatus.GPS_NetworkRemoteIp = ""
+}
+
+func importGDL90Traffic(r *gdl90.TrafficReport) {
+	// Same keys as the other sources: ICAO addresses merge with our own reception, others are kept separate (see ogn.go)
+	icao := r.AddrType == 0 || r.AddrType == 2
+	key := r.Address
+	if !icao {
+		key = 1<<24 | r.Address
+	}
+
+	trafficMutex.Lock()
+	defer trafficMutex.Unlock()
+
+	ti, exists := traffic[key]
+	if exists && ti.Last_source != TRAFFIC_SOURCE_GDL90 && stratuxClock.Since(ti.Last_seen) < GDL90_IN_LOCAL_PRIORITY {
+		return // we receive it ourselves
+	}
+	if !exists {
+		ti.Icao_addr = r.Address
+		if icao {
+			if reg, ok := icao2reg(r.Address); ok {
+				ti.Reg = reg
+				ti.Tail = reg
+			}
+		}
+	}
+
+	ti.Addr_type = r.AddrType
+	ti.Last_source = TRAFFIC_SOURCE_GDL90
+	switch r.AddrType {
+	case 2:
+		ti.TargetType = TARGET_TYPE_TISB_S
+	case 3:
+		ti.TargetType = TARGET_TYPE_TISB
+	default:
+		ti.TargetType = TARGET_TYPE_ADSB
+	}
+	if len(r.Callsign) > 0 {
+		ti.Tail = r.Callsign
+	}
+	ti.Emitter_category = r.Emitter
+	ti.PriorityStatus = r.Emergency
+	ti.NIC = int(r.NIC)
+	ti.NACp = int(r.NACp)
+	ti.OnGround = !r.Airborne
+	ti.Timestamp = time.Now().UTC()
+	ti.Age = 0
+
+	if r.AltitudeValid {
+		ti.Alt = r.Altitude
+		ti.AltIsGNSS = false
+		ti.Last_alt = stratuxClock.Time
+	}
+	if r.SpeedValid {
+		ti.Speed = r.Speed
+		ti.Track = r.Track
+		ti.Speed_valid = r.TrackType != gdl90.TRACK_INVALID
+		ti.Last_speed = stratuxClock.Time
+	}
+	if r.VvelValid {
+		ti.Vvel = r.Vvel
+	}
+	if r.PositionValid {
+		ti.Lat = r.Lat
+		ti.Lng = r.Lng
+		ti.Position_valid = true
+		ti.ExtrapolatedPosition = r.Extrapolated
+		ti.Last_seen = stratuxClock.Time
+		if isGPSValid() {
+			ti.Distance, ti.Bearing = common.Distance(float64(mySituation.GPSLatitude), float64(mySituation.GPSLongitude), float64(ti.Lat), float64(ti.Lng))
+			ti.BearingDist_valid = true
+		}
+	} else if !exists {
+		ti.Last_seen = stratuxClock.Time // so it doesn't get cut before a position arrives
+	}
+
+	traffic[key] = ti
+	postProcessTraffic(&ti)
+	registerTrafficUpdate(key, ti)
+	seenTraffic[key] = true
+}
@@ -115,6 +115,7 @@ const (
 
 	// upper nibble is used for the protocol
 	GPS_PROTOCOL_NMEA = 0x10
+	GPS_PROTOCOL_GDL90 = 0x20 // ownship report of a GDL90 input (see gdl90in.go)
 	
 	
 )
@@ -162,11 +163,17 @@ var product_name_map = map[int]string{
 	62:  "NEXRAD",          //"Individual NEXRAD, Type 3 - 16 level";
 	63:  "NEXRAD Regional", //"Global Block Representation - Regional NEXRAD, Type 4 – 8 level";
 	64:  "NEXRAD CONUS",    //"Global Block Representation - CONUS NEXRAD, Type 4 - 8 level";
+	70:  "Icing Low",       //"Global Block Representation - Icing, low altitude";
+	71:  "Icing High",      //"Global Block Representation - Icing, high altitude";
 	81:  "Tops",            //"Radar echo tops graphic, scheme 1: 16-level";
 	82:  "Tops",            //"Radar echo tops graphic, scheme 2: 8-level";
 	83:  "Tops",            //"Storm tops and velocity";
+	84:  "Cloud Tops",      //"Global Block Representation - Cloud tops";
+	90:  "Turbulence Low",  //"Global Block Representation - Turbulence, low altitude";
+	91:  "Turbulence High", //"Global Block Representation - Turbulence, high altitude";
 	101: "Lightning",       //"Lightning strike type 1 (pixel level)";
 	102: "Lightning",       //"Lightning strike type 2 (grid element level)";
+	103: "Lightning",       //"Global Block Representation - Lightning";
 	151: "Lightning",       //"Point phenomena, vector format";
 	201: "Surface",         //"Surface conditions/winter precipitation graphic";
 	202: "Surface",         //"Surface weather systems";
@@ -812,6 +819,10 @@ func heartBeatSender() {
 				sendNetFLARM(makePGRMZString(), time.Second, 0)
 			}
 			sendNetFLARM("$GPGSA,A,3,,,,,,,,,,,,,1.0,1.0,1.0*33\r\n", time.Second, 1)
+			sendMAVLinkOwnship()
+			sendCoTOwnship()
+			sendAsterixStatus()
+			sendMQTTUpdates()
 
 			// --- debug code: traffic demo ---
 			// Uncomment and compile to display large number of artificial traffic targets
@@ -836,6 +847,7 @@ func heartBeatSender() {
 		case <-timerMessageStats.C:
 			// Save a bit of CPU by not pruning the message log every 1 second.
 			updateMessageStats()
+			updateCoverageMessageRates()
 		}
 	}
 }
@@ -1013,6 +1025,8 @@ func registerADSBTextMessageReceived(msg string, uatMsg *uatparse.UATMsg) {
 	if x[0] == "PIREP" {
 		globalStatus.UAT_PIREP_total++
 	}
+	registerWeatherReport(x[0], msg)
+
 	wm.Type = x[0]
 	wm.Location = x[1]
 	wm.Time = x[2]
@@ -1021,6 +1035,8 @@ func registerADSBTextMessageReceived(msg string, uatMsg *uatparse.UATMsg) {
 
 	// Send to weatherUpdate channel for any connected clients.
 	weatherUpdate.SendJSON(wm)
+	cacheWeatherMessage(wm)
+	mqttPublishJSON("weather/"+strings.ToLower(wm.Type), wm, false)
 }
 
 func UpdateUATStats(ProductID uint32) {
@@ -1038,6 +1054,16 @@ func UpdateUATStats(ProductID uint32) {
 		globalStatus.UAT_PIREP_total++
 	case 8:
 		globalStatus.UAT_NOTAM_total++
+	case 13:
+		globalStatus.UAT_SUA_total++
+	case 70, 71:
+		globalStatus.UAT_ICING_total++
+	case 84:
+		globalStatus.UAT_CLOUDTOPS_total++
+	case 90, 91:
+		globalStatus.UAT_TURBULENCE_total++
+	case 103:
+		globalStatus.UAT_LIGHTNING_total++
 	case 413:
 		// Do nothing in the case since text is recorded elsewhere
 		return
@@ -1134,6 +1160,10 @@ func parseInput(buf string) ([]byte, uint16) {
 				thisMsg.Products = append(thisMsg.Products, f.Product_id)
 				UpdateUATStats(f.Product_id)
 				weatherRawUpdate.SendJSON(f)
+				registerWeatherOverlayFrame(f, towerid)
+				registerNexradFrame(f)
+				registerWeatherBlocksFrame(f)
+				cacheFISBFrame(frame, f)
 			}
 			// Get all of the text reports.
 			textReports, _ := uatMsg.GetTextReports()
@@ -1232,6 +1262,42 @@ type settings struct {
 	GpsManualDevice	     string         // default: /dev/ttyAMA0
     GpsManualChip        string         // ublox8, ublox9, ublox
 	GpsManualTargetBaud  int            // default: 115200
+
+	TrafficWatchRules    []TrafficWatchRule
+
+	GDL90In_Enabled      bool           // traffic and ownship from another GDL90 device (see gdl90in.go)
+	GDL90InPort          int            // UDP port, default 4000
+
+	MAVLink_Enabled      bool           // MAVLink output over UDP to all clients and serial (see mavlink.go)
+	MAVLinkPort          int            // default 14550 (QGroundControl, Mission Planner)
+	MAVLinkVersion       int            // 1 or 2
+	MAVLinkOwnship       bool           // also send HEARTBEAT and GPS_RAW_INT
+
+	CoT_Enabled          bool           // Cursor-on-Target output for ATAK/WinTAK (see cot.go)
+	CoTOutputs           []CoTOutput    // UDP (multicast) or TCP endpoints
+
+	ASTERIX_Enabled      bool           // ASTERIX CAT021/CAT023 output over UDP to all clients and serial (see asterix.go)
+	ASTERIXPort          int            // default 8600
+	ASTERIXSAC           int            // System Area Code of our data source
+	ASTERIXSIC           int            // System Identification Code of our data source
+
+	MQTT_Enabled         bool           // MQTT publisher (see mqtt.go)
+	MQTTBroker           string         // host:port
+	MQTTClientID         string
+	MQTTUsername         string
+	MQTTPassword         string
+	MQTTTopicPrefix      string
+	MQTTQoS              int            // 0 or 1
+	MQTT_TLS             bool
+	MQTTTLSInsecure      bool           // don't verify the broker certificate
+	MQTTCAFile           string         // optional CA certificate (PEM) for the broker
+	MQTTTrafficExpiry    int            // seconds until the retained message of a target that disappeared is cleared
+
+	AISOut_Enabled       bool           // AIS NMEA (!AIVDM) output over UDP, TCP and serial (see aisout.go)
+	AISOutPort           int            // UDP and TCP port. Not 10110, that's used by rtl-ais locally
+	AISOutAircraft       bool           // also send aircraft as AIS SAR aircraft
+	IGC_Enabled          bool           // IGC flight recorder (see igc.go)
+	IGCInterval          int            // seconds between B records
 }
 
 type status struct {
@@ -1279,7 +1345,13 @@ type status struct {
 	UAT_SIGMET_total                           uint32
 	UAT_PIREP_total                            uint32
 	UAT_NOTAM_total                            uint32
+	UAT_SUA_total                              uint32
+	UAT_LIGHTNING_total                        uint32
+	UAT_ICING_total                            uint32
+	UAT_TURBULENCE_total                       uint32
+	UAT_CLOUDTOPS_total                        uint32
 	UAT_OTHER_total                            uint32
+	AircraftDB_records                         int
 	Errors                                     []string
 	Logfile_Size                               int64
 	AHRS_LogFiles_Size                         int64
@@ -1329,6 +1401,7 @@ func defaultSettings() {
 	globalSettings.WiFiSSID = "stratux"
 	globalSettings.WiFiSecurityEnabled = false
 	globalSettings.WiFiClientNetworks = make([]wifiClientNetwork, 0)
+	globalSettings.TrafficWatchRules = make([]TrafficWatchRule, 0)
 
 	globalSettings.RadarLimits = 2000
 	globalSettings.RadarRange = 10
@@ -1344,6 +1417,36 @@ func defaultSettings() {
 	globalSettings.GpsManualDevice = "/dev/ttyAMA0"
 	globalSettings.GpsManualTargetBaud = 115200
 	globalSettings.GpsManualChip = "ublox"
+
+	globalSettings.GDL90In_Enabled = false
+	globalSettings.GDL90InPort = 4000
+
+	globalSettings.MAVLink_Enabled = false
+	globalSettings.MAVLinkPort = 14550
+	globalSettings.MAVLinkVersion = 2
+	globalSettings.MAVLinkOwnship = true
+
+	globalSettings.CoT_Enabled = false
+	globalSettings.CoTOutputs = []CoTOutput{{Protocol: "udp", Address: "239.2.3.1:6969"}} // ATAK SA multicast
+
+	globalSettings.ASTERIX_Enabled = false
+	globalSettings.ASTERIXPort = 8600
+	globalSettings.ASTERIXSAC = 0
+	globalSettings.ASTERIXSIC = 1
+
+	globalSettings.MQTT_Enabled = false
+	globalSettings.MQTTBroker = "localhost:1883"
+	globalSettings.MQTTClientID = "stratux"
+	globalSettings.MQTTTopicPrefix = "stratux"
+	globalSettings.MQTTQoS = 0
+	globalSettings.MQTT_TLS = false
+	globalSettings.MQTTTrafficExpiry = 60
+
+	globalSettings.AISOut_Enabled = false
+	globalSettings.AISOutPort = 10111
+	globalSettings.AISOutAircraft = false
+	globalSettings.IGC_Enabled = false
+	globalSettings.IGCInterval = 1
 }
 
 func readSettings() {
@@ -1366,6 +1469,7 @@ func readSettings() {
 		log.Printf("can't read settings %s: %s\n", configLocation, err.Error())
 		return
 	}
+	migrateWatchList()
 	log.Printf("read in settings.\n")
 }
 
@@ -1671,6 +1775,7 @@ func main() {
 	traceReplaySpeed := flag.Float64("traceSpeed", 1.0, "Trace replay speed multiplier")
 	traceReplayFilter := flag.String("traceFilter", "", "Filter trace data by context. Comma separated list of: ais,nmea,aprs,ogn-rx,dump1090,godump978,lowpower_uat")
 	traceSkip := flag.Int64("traceSkip", 0, "Minutes to skip forward in recorded trace")
+	scenarioFile := flag.String("scenario", "", "Run the given traffic scenario (JSON) on startup")
 	
 
 	cpuprofile := flag.String("cpuprofile", "", "write cpu profile to file")
@@ -1722,6 +1827,8 @@ func main() {
 		pingInit()
 	}
 	initTraffic(isTraceReplayMode)
+	initAircraftDB()
+	resetCoverage()
 
 
 	// Disable replay logs when replaying - so that messages replay data isn't copied into the logs.
@@ -1758,6 +1865,21 @@ func main() {
 	// Extrapolate traffic when no signal is received.
 	go trafficInfoExtrapolator()
 
+	// Record flights to IGC files.
+	go igcRecorder()
+
+	// Check ownship against TFRs and other airspace restrictions received over FIS-B.
+	go airspaceRestrictionWatcher()
+	go fisbCacheReplayer()
+
+	if *scenarioFile != "" {
+		if scenario, err := loadScenarioFile(*scenarioFile); err == nil {
+			startScenario(scenario)
+		} else {
+			log.Printf("Can't load traffic scenario %s: %s\n", *scenarioFile, err.Error())
+		}
+	}
+
 	// Guesses barometric altitude if we don't have our own baro source by using GnssBaroDiff from other traffic at similar altitude
 	go baroAltGuesser()
 
@@ -0,0 +1,371 @@
+/*
+	Copyright (c) 2026 Stratux contributors
+	Distributable under the terms of The "BSD New" License
+	that can be found in the LICENSE file, herein included
+	as part of this header.
+
+	igc.go: IGC flight recorder. A new file is started on takeoff (ground speed above IGC_TAKEOFF_SPEED) and closed
+		after landing. B records are written from mySituation every globalSettings.IGCInterval seconds, the header is
+		filled from the OGN pilot and aircraft settings. The G record is an HMAC-SHA256 over all other records with a
+		key unique to this device, so files can be checked for modifications (see verifyIGCFile()).
+		Stratux is not an IGC approved flight recorder, so the files are signed with manufacturer code XST.
+		See "Technical Specification for IGC-approved GNSS Flight Recorders", appendix A.
+*/
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	IGC_DIR              = "igc"
+	IGC_MANUFACTURER     = "XST"            // X: not IGC approved
+	IGC_TAKEOFF_SPEED    = 30               // knots
+	IGC_LANDING_SPEED    = 10               // knots
+	IGC_LANDING_TIME     = 60 * time.Second // below IGC_LANDING_SPEED for this long ends the flight
+	IGC_GPS_LOST_TIME    = 10 * time.Minute // no valid GPS for this long ends the flight
+	IGC_PRE_TAKEOFF_TIME = 30 * time.Second // fixes before takeoff written to the file
+	IGC_G_RECORD_LEN     = 64               // hex characters per G record line
+)
+
+type igcFix struct {
+	time     time.Time // UTC
+	lat, lng float32
+	valid    bool  // 3D fix
+	pressAlt int32 // meters, 0 if not available
+	gnssAlt  int32 // meters MSL
+	accuracy int   // meters
+}
+
+type igcFlight struct {
+	file     *os.File
+	writer   *bufio.Writer
+	mac      hash.Hash
+	name     string
+	lastFix  time.Time // stratuxClock of the last B record
+	slowTime time.Time // stratuxClock since when we are below IGC_LANDING_SPEED
+	gpsTime  time.Time // stratuxClock of the last valid GPS
+}
+
+var igcPreTakeoff []igcFix // ring buffer of fixes before takeoff. Only accessed from igcRecorder()
+
+func igcDir() string {
+	return filepath.Join(logDirf, IGC_DIR)
+}
+
+func igcKeyFile() string {
+	return filepath.Join(filepath.Dir(configLocation), "stratux-igc.key")
+}
+
+// Device key for the G record. Generated on first use.
+func igcKey() ([]byte, error) {
+	if key, err := ioutil.ReadFile(igcKeyFile()); err == nil {
+		if k, err := hex.DecodeString(strings.TrimSpace(string(key))); err == nil && len(k) > 0 {
+			return k, nil
+		}
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(igcKeyFile(), []byte(hex.EncodeToString(key)+"\n"), 0600); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// Three character serial number of this recorder, from the OGN address or the ownship ICAO code
+func igcSerial() string {
+	serial := strings.ToUpper(globalSettings.OGNAddr)
+	if len(serial) == 0 {
+		serial = strings.ToUpper(globalSettings.OwnshipModeS)
+	}
+	if len(serial) < 3 {
+		return "000"
+	}
+	return serial[len(serial)-3:]
+}
+
+func currentIGCFix() igcFix {
+	fix := igcFix{
+		time:     time.Now().UTC(),
+		lat:      mySituation.GPSLatitude,
+		lng:      mySituation.GPSLongitude,
+		valid:    mySituation.GPSFixQuality > 0 && mySituation.GPSVerticalAccuracy < 999999,
+		gnssAlt:  int32(math.Round(float64(mySituation.GPSAltitudeMSL) / 3.28084)),
+		accuracy: int(mySituation.GPSHorizontalAccuracy),
+	}
+	if isTempPressValid() && mySituation.BaroSourceType != BARO_TYPE_ADSBESTIMATE {
+		fix.pressAlt = int32(math.Round(float64(mySituation.BaroPressureAltitude) / 3.28084))
+	}
+	return fix
+}
+
+// DDMMmmmN / DDDMMmmmE
+func igcLatLng(lat, lng float32) string {
+	format := func(v float64, degDigits int, pos, neg byte) string {
+		hemi := pos
+		if v < 0 {
+			hemi = neg
+			v = -v
+		}
+		deg := math.Floor(v)
+		mmm := int(math.Round((v - deg) * 60000))
+		if mmm >= 60000 {
+			deg++
+			mmm -= 60000
+		}
+		return fmt.Sprintf("%0*d%05d%c", degDigits, int(deg), mmm, hemi)
+	}
+	return format(float64(lat), 2, 'N', 'S') + format(float64(lng), 3, 'E', 'W')
+}
+
+// Altitudes are 5 characters, negative values with a leading '-'
+func igcAlt(alt int32) string {
+	if alt < 0 {
+		return fmt.Sprintf("-%04d", -alt)
+	}
+	return fmt.Sprintf("%05d", alt)
+}
+
+func makeIGCBRecord(fix igcFix) string {
+	validity := "V"
+	if fix.valid {
+		validity = "A"
+	}
+	accuracy := fix.accuracy
+	if accuracy > 999 {
+		accuracy = 999
+	}
+	return "B" + fix.time.Format("150405") + igcLatLng(fix.lat, fix.lng) + validity + igcAlt(fix.pressAlt) + igcAlt(fix.gnssAlt) + fmt.Sprintf("%03d", accuracy)
+}
+
+func (f *igcFlight) writeRecord(record string) {
+	f.mac.Write([]byte(record))
+	f.writer.WriteString(record + "\r\n")
+}
+
+// Opens a new IGC file: YYYY-MM-DD-XST-SSS-NN.igc, NN is the number of the flight of the day
+func startIGCFlight(start time.Time) (*igcFlight, error) {
+	key, err := igcKey()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(igcDir(), 0755); err != nil {
+		return nil, err
+	}
+	var name string
+	var file *os.File
+	var n int
+	serial := igcSerial()
+	for n = 1; n < 100; n++ {
+		name = fmt.Sprintf("%s-%s-%s-%02d.igc", start.Format("2006-01-02"), IGC_MANUFACTURER, serial, n)
+		file, err = os.OpenFile(filepath.Join(igcDir(), name), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil || !os.IsExist(err) {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	f := &igcFlight{file: file, writer: bufio.NewWriter(file), mac: hmac.New(sha256.New, key), name: name}
+	hardware := globalStatus.HardwareBuild
+	if len(hardware) == 0 {
+		hardware = "Stratux"
+	}
+	f.writeRecord("A" + IGC_MANUFACTURER + serial + "Stratux")
+	f.writeRecord(fmt.Sprintf("HFDTEDATE:%s,%02d", start.Format("020106"), n))
+	f.writeRecord("HFPLTPILOTINCHARGE:" + globalSettings.OGNPilot)
+	f.writeRecord("HFCM2CREW2:")
+	f.writeRecord("HFGTYGLIDERTYPE:")
+	f.writeRecord("HFGIDGLIDERID:" + globalSettings.OGNReg)
+	f.writeRecord("HFDTMGPSDATUM:WGS84")
+	f.writeRecord("HFRFWFIRMWAREVERSION:" + stratuxVersion)
+	f.writeRecord("HFRHWHARDWAREVERSION:" + hardware)
+	f.writeRecord("HFFTYFRTYPE:Stratux," + hardware)
+	f.writeRecord("HFGPSRECEIVER:" + gpsTypeName())
+	f.writeRecord("HFPRSPRESSALTSENSOR:" + baroTypeName())
+	f.writeRecord("HFALGALTGPS:GEO")
+	f.writeRecord("HFALPALTPRESSURE:ISA")
+	f.writeRecord("I013638FXA")
+	return f, nil
+}
+
+func (f *igcFlight) close() {
+	sig := hex.EncodeToString(f.mac.Sum(nil))
+	for len(sig) > 0 {
+		n := IGC_G_RECORD_LEN
+		if n > len(sig) {
+			n = len(sig)
+		}
+		f.writer.WriteString("G" + sig[:n] + "\r\n")
+		sig = sig[n:]
+	}
+	f.writer.Flush()
+	f.file.Close()
+	log.Printf("IGC: closed %s\n", f.name)
+}
+
+// Checks the G record of an IGC file against our device key.
+func verifyIGCFile(path string) bool {
+	key, err := igcKey()
+	if err != nil {
+		return false
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, key)
+	sig := ""
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		line = bytes.TrimRight(line, "\r")
+		if len(line) == 0 {
+			continue
+		}
+		if line[0] == 'G' {
+			sig += string(line[1:])
+		} else {
+			mac.Write(line)
+		}
+	}
+	expected := hex.EncodeToString(mac.Sum(nil))
+	return len(sig) > 0 && hmac.Equal([]byte(sig), []byte(expected))
+}
+
+func gpsTypeName() string {
+	switch globalStatus.GPS_detected_type & 0x0F {
+	case GPS_TYPE_NETWORK:
+		return "Network"
+	case 0:
+		return "None"
+	}
+	return fmt.Sprintf("GPS type %d", globalStatus.GPS_detected_type&0x0F)
+}
+
+func baroTypeName() string {
+	switch mySituation.BaroSourceType {
+	case BARO_TYPE_NONE, BARO_TYPE_ADSBESTIMATE:
+		return "None"
+	case BARO_TYPE_BMP280:
+		return "BMP280"
+	}
+	return fmt.Sprintf("Baro type %d", mySituation.BaroSourceType)
+}
+
+func igcRecorder() {
+	var flight *igcFlight
+	ticker := time.NewTicker(1 * time.Second)
+	for {
+		<-ticker.C
+		if flight != nil && !globalSettings.IGC_Enabled {
+			flight.close()
+			flight = nil
+		}
+		if !globalSettings.IGC_Enabled {
+			igcPreTakeoff = nil
+			continue
+		}
+
+		gpsValid := isGPSValid()
+		speed := mySituation.GPSGroundSpeed
+		if flight == nil {
+			if !gpsValid {
+				continue
+			}
+			fix := currentIGCFix()
+			igcPreTakeoff = append(igcPreTakeoff, fix)
+			for len(igcPreTakeoff) > 0 && fix.time.Sub(igcPreTakeoff[0].time) > IGC_PRE_TAKEOFF_TIME {
+				igcPreTakeoff = igcPreTakeoff[1:]
+			}
+			if speed < IGC_TAKEOFF_SPEED {
+				continue
+			}
+			var err error
+			flight, err = startIGCFlight(igcPreTakeoff[0].time)
+			if err != nil {
+				log.Printf("IGC: %s\n", err.Error())
+				continue
+			}
+			log.Printf("IGC: takeoff, recording to %s\n", flight.name)
+			interval := time.Duration(globalSettings.IGCInterval) * time.Second
+			var last time.Time
+			for _, f := range igcPreTakeoff {
+				if f.time.Sub(last) >= interval {
+					flight.writeRecord(makeIGCBRecord(f))
+					last = f.time
+				}
+			}
+			igcPreTakeoff = nil
+			flight.lastFix = stratuxClock.Time
+			flight.gpsTime = stratuxClock.Time
+			continue
+		}
+
+		if gpsValid {
+			flight.gpsTime = stratuxClock.Time
+			if stratuxClock.Since(flight.lastFix) >= time.Duration(globalSettings.IGCInterval)*time.Second {
+				flight.writeRecord(makeIGCBRecord(currentIGCFix()))
+				flight.writer.Flush()
+				flight.lastFix = stratuxClock.Time
+			}
+			if speed >= IGC_LANDING_SPEED {
+				flight.slowTime = time.Time{}
+			} else if flight.slowTime.IsZero() {
+				flight.slowTime = stratuxClock.Time
+			}
+		}
+		landed := !flight.slowTime.IsZero() && stratuxClock.Since(flight.slowTime) > IGC_LANDING_TIME
+		if landed || stratuxClock.Since(flight.gpsTime) > IGC_GPS_LOST_TIME {
+			log.Printf("IGC: landed\n")
+			flight.close()
+			flight = nil
+		}
+	}
+}
+
+type IGCFileInfo struct {
+	Name   string
+	Size   int64
+	Mtime  time.Time
+	Signed bool // G record matches our device key
+}
+
+// Recorded flights, newest first
+func getIGCFiles() []IGCFileInfo {
+	files := make([]IGCFileInfo, 0)
+	entries, err := ioutil.ReadDir(igcDir())
+	if err != nil {
+		return files
+	}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(strings.ToLower(e.Name()), ".igc") {
+			continue
+		}
+		files = append(files, IGCFileInfo{
+			Name:   e.Name(),
+			Size:   e.Size(),
+			Mtime:  e.ModTime(),
+			Signed: verifyIGCFile(filepath.Join(igcDir(), e.Name())),
+		})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name > files[j].Name })
+	return files
+}
@@ -112,6 +112,14 @@ var weatherRawUpdate *uibroadcaster
 	The /weather websocket starts off by sending the current buffer of weather messages, then sends updates as they are received.
 */
 func handleWeatherWS(conn *websocket.Conn) {
+	// Send the reports received before the client connected, see fisbcache.go.
+	for _, wm := range getCachedWeatherMessages() {
+		wmJSON, _ := json.Marshal(&wm)
+		if _, err := conn.Write(wmJSON); err != nil {
+			return
+		}
+	}
+
 	// Subscribe the socket to receive updates.
 	weatherUpdate.AddSocket(conn)
 
@@ -135,6 +143,9 @@ func handleJsonIo(conn *websocket.Conn) {
 		if !traf.Position_valid { // Don't send unless a valid position exists.
 			continue
 		}
+		if traf.Fused { // Shown as part of the target it was fused into
+			continue
+		}
 		trafficJSON, _ := json.Marshal(&traf)
 		conn.Write(trafficJSON)
 	}
@@ -168,6 +179,9 @@ func handleTrafficWS(conn *websocket.Conn) {
 		if !traf.Position_valid { // Don't send unless a valid position exists.
 			continue
 		}
+		if traf.Fused { // Shown as part of the target it was fused into
+			continue
+		}
 		trafficJSON, _ := json.Marshal(&traf)
 		conn.Write(trafficJSON)
 	}
@@ -189,6 +203,54 @@ func handleTrafficWS(conn *websocket.Conn) {
 	}
 }
 
+/*
+	The /trafficHistory websocket starts off by sending the trails of all targets (one TrafficTrail per target), then
+	sends each newly recorded point as a TrafficTrail with a single point. A point recorded just before the
+	connection may be sent twice, with the same Time.
+*/
+func handleTrafficHistoryWS(conn *websocket.Conn) {
+	trafficHistoryMutex.Lock()
+	for _, trail := range trafficTrails(0, true) {
+		trailJSON, _ := json.Marshal(&trail)
+		conn.Write(trailJSON)
+	}
+	// Subscribe the socket to receive updates. Nothing can be recorded until it is subscribed.
+	trafficHistoryUpdate.AddSocket(conn)
+	trafficHistoryMutex.Unlock()
+
+	// Connection closes when function returns. Since uibroadcast is writing and we don't need to read anything (for now), just keep it busy.
+	for {
+		buf := make([]byte, 1024)
+		_, err := conn.Read(buf)
+		if err != nil {
+			break
+		}
+		if buf[0] != 0 { // Dummy.
+			continue
+		}
+		time.Sleep(1 * time.Second)
+	}
+}
+
+// The /trafficEvents websocket sends each traffic watch event (TrafficEvent) as it happens.
+func handleTrafficEventsWS(conn *websocket.Conn) {
+	// Subscribe the socket to receive updates.
+	trafficEventUpdate.AddSocket(conn)
+
+	// Connection closes when function returns. Since uibroadcast is writing and we don't need to read anything (for now), just keep it busy.
+	for {
+		buf := make([]byte, 1024)
+		_, err := conn.Read(buf)
+		if err != nil {
+			break
+		}
+		if buf[0] != 0 { // Dummy.
+			continue
+		}
+		time.Sleep(1 * time.Second)
+	}
+}
+
 func handleRadarWS(conn *websocket.Conn) {
 	trafficMutex.Lock()
 	// Subscribe the socket to receive updates. Not necessary to send old traffic 
@@ -302,6 +364,295 @@ func handleSatellitesRequest(w http.ResponseWriter, r *http.Request) {
 	mySituation.muSatellite.Unlock()
 }
 
+// AJAX call - /getTrafficHistory?icao=ABCDEF. Responds with the recorded trails of the given target (hex address),
+// or of all targets if no address is given.
+func handleTrafficHistoryRequest(w http.ResponseWriter, r *http.Request) {
+	setNoCache(w)
+	setJSONHeaders(w)
+	icaoStr := r.URL.Query().Get("icao")
+	var trails []TrafficTrail
+	if len(icaoStr) == 0 {
+		trails = getTrafficTrails(0, true)
+	} else {
+		icao, err := strconv.ParseUint(icaoStr, 16, 32)
+		if err != nil {
+			http.Error(w, "invalid icao address", http.StatusBadRequest)
+			return
+		}
+		trails = getTrafficTrails(uint32(icao), false)
+	}
+	trailsJSON, err := json.Marshal(&trails)
+	if err != nil {
+		log.Printf("Error sending traffic history JSON data: %s\n", err.Error())
+	}
+	fmt.Fprintf(w, "%s\n", trailsJSON)
+}
+
+// AJAX call - /getTrafficEvents?since=42&type=entered_radius&icao=ABCDEF&rule=Club. Responds with the logged traffic
+// watch events. All parameters are optional.
+func handleTrafficEventsRequest(w http.ResponseWriter, r *http.Request) {
+	setNoCache(w)
+	setJSONHeaders(w)
+	query := r.URL.Query()
+	var since int64
+	var icao uint64
+	var err error
+	if sinceStr := query.Get("since"); len(sinceStr) > 0 {
+		if since, err = strconv.ParseInt(sinceStr, 10, 64); err != nil {
+			http.Error(w, "invalid since parameter", http.StatusBadRequest)
+			return
+		}
+	}
+	if icaoStr := query.Get("icao"); len(icaoStr) > 0 {
+		if icao, err = strconv.ParseUint(icaoStr, 16, 32); err != nil {
+			http.Error(w, "invalid icao address", http.StatusBadRequest)
+			return
+		}
+	}
+	events := getTrafficEvents(since, query.Get("type"), uint32(icao), query.Get("rule"))
+	eventsJSON, err := json.Marshal(&events)
+	if err != nil {
+		log.Printf("Error sending traffic events JSON data: %s\n", err.Error())
+	}
+	fmt.Fprintf(w, "%s\n", eventsJSON)
+}
+
+// AJAX call - /getCoverage. Responds with the reception coverage statistics per traffic source (see coverage.go).
+func handleCoverageRequest(w http.ResponseWriter, r *http.Request) {
+	setNoCache(w)
+	setJSONHeaders(w)
+	stats := getCoverage()
+	coverageJSON, err := json.Marshal(&stats)
+	if err != nil {
+		log.Printf("Error sending coverage JSON data: %s\n", err.Error())
+	}
+	fmt.Fprintf(w, "%s\n", coverageJSON)
+}
+
+// AJAX call - /resetCoverage. Starts a new coverage session, e.g. after moving the antenna.
+func handleCoverageResetRequest(w http.ResponseWriter, r *http.Request) {
+	setNoCache(w)
+	setJSONHeaders(w)
+	resetCoverage()
+}
+
+// AJAX call - /data/aircraft.json. Responds with the current traffic in the readsb / dump1090-fa aircraft.json format
+// (see readsbjson.go), for use with tar1090 and similar map frontends.
+func handleReadsbAircraftRequest(w http.ResponseWriter, r *http.Request) {
+	setNoCache(w)
+	setJSONHeaders(w)
+	aircraft := getReadsbAircraftList()
+	aircraftJSON, err := json.Marshal(&aircraft)
+	if err != nil {
+		log.Printf("Error sending aircraft JSON data: %s\n", err.Error())
+	}
+	fmt.Fprintf(w, "%s\n", aircraftJSON)
+}
+
+// AJAX call - /data/receiver.json. Receiver information matching /data/aircraft.json.
+func handleReadsbReceiverRequest(w http.ResponseWriter, r *http.Request) {
+	setNoCache(w)
+	setJSONHeaders(w)
+	receiver := getReadsbReceiver()
+	receiverJSON, err := json.Marshal(&receiver)
+	if err != nil {
+		log.Printf("Error sending receiver JSON data: %s\n", err.Error())
+	}
+	fmt.Fprintf(w, "%s\n", receiverJSON)
+}
+
+// AJAX call - /startScenario?name=head-on loads a traffic scenario from the scenario directory, or POST the scenario
+// JSON. Responds with the scenario status.
+func handleScenarioStartRequest(w http.ResponseWriter, r *http.Request) {
+	setNoCache(w)
+	setJSONHeaders(w)
+	var scenario *Scenario
+	var err error
+	if name := r.URL.Query().Get("name"); len(name) > 0 {
+		scenario, err = loadNamedScenario(name)
+	} else {
+		var body []byte
+		body, err = ioutil.ReadAll(r.Body)
+		if err == nil {
+			scenario, err = parseScenario(body)
+		}
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	startScenario(scenario)
+	handleScenarioStatusRequest(w, r)
+}
+
+// AJAX call - /stopScenario. Stops the running traffic scenario.
+func handleScenarioStopRequest(w http.ResponseWriter, r *http.Request) {
+	stopScenario()
+	handleScenarioStatusRequest(w, r)
+}
+
+// AJAX call - /getScenario. Responds with the status of the traffic scenario engine.
+func handleScenarioStatusRequest(w http.ResponseWriter, r *http.Request) {
+	setNoCache(w)
+	setJSONHeaders(w)
+	status := getScenarioStatus()
+	statusJSON, _ := json.Marshal(&status)
+	fmt.Fprintf(w, "%s\n", statusJSON)
+}
+
+// AJAX call - /getIGCFiles. Responds with the recorded IGC flights, newest first.
+func handleIGCFilesRequest(w http.ResponseWriter, r *http.Request) {
+	setNoCache(w)
+	setJSONHeaders(w)
+	filesJSON, _ := json.Marshal(getIGCFiles())
+	fmt.Fprintf(w, "%s\n", filesJSON)
+}
+
+// /igc/<name>. Downloads a recorded IGC file.
+func handleIGCDownloadRequest(w http.ResponseWriter, r *http.Request) {
+	name := filepath.Base(strings.TrimPrefix(r.URL.Path, "/igc/"))
+	if !strings.HasSuffix(name, ".igc") {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", "attachment; filename="+name)
+	http.ServeFile(w, r, filepath.Join(igcDir(), name))
+}
+
+// AJAX call - /getFlights. Responds with the flights found in the replay log, oldest first.
+func handleFlightsRequest(w http.ResponseWriter, r *http.Request) {
+	setNoCache(w)
+	setJSONHeaders(w)
+	db, err := openDataLogReadOnly()
+	if err != nil {
+		http.Error(w, "no replay log", http.StatusNotFound)
+		return
+	}
+	defer db.Close()
+	flights, err := getFlights(db)
+	if err != nil {
+		log.Printf("Error reading flights from the replay log: %s\n", err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	flightsJSON, _ := json.Marshal(&flights)
+	fmt.Fprintf(w, "%s\n", flightsJSON)
+}
+
+// AJAX call - /weather/overlays?product=11,12. Responds with the FIS-B graphical products as a GeoJSON
+// FeatureCollection, see weatheroverlays.go. Without product, all overlay products are returned.
+func handleWeatherOverlaysRequest(w http.ResponseWriter, r *http.Request) {
+	setNoCache(w)
+	setJSONHeaders(w)
+	products := make(map[uint32]bool)
+	for _, p := range strings.Split(r.URL.Query().Get("product"), ",") {
+		if id, err := strconv.ParseUint(strings.TrimSpace(p), 10, 32); err == nil {
+			products[uint32(id)] = true
+		}
+	}
+	overlays := getWeatherOverlays(products)
+	overlaysJSON, _ := json.Marshal(&overlays)
+	fmt.Fprintf(w, "%s\n", overlaysJSON)
+}
+
+// AJAX call - /weather/restrictions?active=1&area=1. Responds with the airspace restrictions (TFRs, NOTAMs with
+// graphics) as a GeoJSON FeatureCollection, see airspace.go. active: only those in effect now, area: only those with
+// a polygon or circle.
+func handleAirspaceRestrictionsRequest(w http.ResponseWriter, r *http.Request) {
+	setNoCache(w)
+	setJSONHeaders(w)
+	activeOnly := r.URL.Query().Get("active") == "1"
+	areaOnly := r.URL.Query().Get("area") == "1"
+	restrictions := getAirspaceRestrictions(activeOnly, areaOnly)
+	restrictionsJSON, _ := json.Marshal(&restrictions)
+	fmt.Fprintf(w, "%s\n", restrictionsJSON)
+}
+
+// AJAX call - /getMetars?station=KBOS,KATL. Responds with the latest parsed METAR and TAF and the flight category
+// of all stations, or only of the given ones. See metars.go.
+func handleMetarsRequest(w http.ResponseWriter, r *http.Request) {
+	setNoCache(w)
+	setJSONHeaders(w)
+	stations := make(map[string]bool)
+	for _, s := range strings.Split(r.URL.Query().Get("station"), ",") {
+		if s = strings.ToUpper(strings.TrimSpace(s)); len(s) > 0 {
+			stations[s] = true
+		}
+	}
+	metars := getStationWeather(stations)
+	metarsJSON, _ := json.Marshal(&metars)
+	fmt.Fprintf(w, "%s\n", metarsJSON)
+}
+
+// /exportFlight?id=1767225600&format=kml&traffic=1&radius=10&from=2026-01-01T00:00:00Z&to=2026-01-01T01:00:00Z.
+// Downloads a flight from /getFlights as gpx (default), kml or geojson. All parameters except id are optional.
+func handleFlightExportRequest(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	id, err := strconv.ParseInt(query.Get("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid id parameter", http.StatusBadRequest)
+		return
+	}
+	formatName := strings.ToLower(query.Get("format"))
+	if len(formatName) == 0 {
+		formatName = "gpx"
+	}
+	format, ok := flightExportFormats[formatName]
+	if !ok {
+		http.Error(w, "invalid format parameter", http.StatusBadRequest)
+		return
+	}
+	var from, to time.Time
+	if fromStr := query.Get("from"); len(fromStr) > 0 {
+		if from, err = time.Parse(time.RFC3339, fromStr); err != nil {
+			http.Error(w, "invalid from parameter", http.StatusBadRequest)
+			return
+		}
+	}
+	if toStr := query.Get("to"); len(toStr) > 0 {
+		if to, err = time.Parse(time.RFC3339, toStr); err != nil {
+			http.Error(w, "invalid to parameter", http.StatusBadRequest)
+			return
+		}
+	}
+	radius := float64(FLIGHT_TRAFFIC_RADIUS)
+	if radiusStr := query.Get("radius"); len(radiusStr) > 0 {
+		if radius, err = strconv.ParseFloat(radiusStr, 64); err != nil {
+			http.Error(w, "invalid radius parameter", http.StatusBadRequest)
+			return
+		}
+	}
+
+	db, err := openDataLogReadOnly()
+	if err != nil {
+		http.Error(w, "no replay log", http.StatusNotFound)
+		return
+	}
+	defer db.Close()
+	flight, track, err := getFlightTrack(db, id, from, to)
+	if err == errFlightNotFound {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var traffic []trafficTrack
+	if t := query.Get("traffic"); t == "1" || t == "true" {
+		if traffic, err = getFlightTraffic(db, track, radius); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", format.contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=stratux-%s.%s", flight.Start.UTC().Format("20060102-1504"), formatName))
+	if err := format.write(w, flight, track, traffic); err != nil {
+		log.Printf("Error exporting flight %d: %s\n", id, err.Error())
+	}
+}
+
 // AJAX call - /getSettings. Responds with all stratux.conf data.
 func handleSettingsGetRequest(w http.ResponseWriter, r *http.Request) {
 	setNoCache(w)
@@ -340,6 +691,7 @@ func handleSettingsSetRequest(w http.ResponseWriter, r *http.Request) {
 				reconfigureOgnTracker := false
 				reconfigureGXTracker := false
 				reconfigureFancontrol := false
+				refreshClients := false
 				for key, val := range msg {
 					// log.Printf("handleSettingsSetRequest:json: testing for key:%s of type %s\n", key, reflect.TypeOf(val))
 					switch key {
@@ -355,6 +707,79 @@ func handleSettingsSetRequest(w http.ResponseWriter, r *http.Request) {
 						globalSettings.AIS_Enabled = val.(bool)
 					case "APRS_Enabled":
 						globalSettings.APRS_Enabled = val.(bool)
+					case "GDL90In_Enabled":
+						globalSettings.GDL90In_Enabled = val.(bool)
+					case "GDL90InPort":
+						globalSettings.GDL90InPort = int(val.(float64))
+					case "MAVLink_Enabled":
+						globalSettings.MAVLink_Enabled = val.(bool)
+						refreshClients = true
+					case "MAVLinkPort":
+						globalSettings.MAVLinkPort = int(val.(float64))
+						refreshClients = true
+					case "MAVLinkVersion":
+						globalSettings.MAVLinkVersion = int(val.(float64))
+					case "MAVLinkOwnship":
+						globalSettings.MAVLinkOwnship = val.(bool)
+					case "CoT_Enabled":
+						globalSettings.CoT_Enabled = val.(bool)
+					case "CoTOutputs":
+						outputsJSON, _ := json.Marshal(val)
+						var outputs []CoTOutput
+						if err := json.Unmarshal(outputsJSON, &outputs); err != nil {
+							log.Printf("handleSettingsSetRequest:CoTOutputs: %s\n", err.Error())
+							break
+						}
+						globalSettings.CoTOutputs = outputs
+					case "ASTERIX_Enabled":
+						globalSettings.ASTERIX_Enabled = val.(bool)
+						refreshClients = true
+					case "ASTERIXPort":
+						globalSettings.ASTERIXPort = int(val.(float64))
+						refreshClients = true
+					case "ASTERIXSAC":
+						globalSettings.ASTERIXSAC = int(val.(float64))
+					case "ASTERIXSIC":
+						globalSettings.ASTERIXSIC = int(val.(float64))
+					case "MQTT_Enabled":
+						globalSettings.MQTT_Enabled = val.(bool)
+					case "MQTTBroker":
+						globalSettings.MQTTBroker = val.(string)
+					case "MQTTClientID":
+						globalSettings.MQTTClientID = val.(string)
+					case "MQTTUsername":
+						globalSettings.MQTTUsername = val.(string)
+					case "MQTTPassword":
+						globalSettings.MQTTPassword = val.(string)
+					case "MQTTTopicPrefix":
+						globalSettings.MQTTTopicPrefix = val.(string)
+					case "MQTTQoS":
+						qos := int(val.(float64))
+						if qos != 0 && qos != 1 {
+							log.Printf("handleSettingsSetRequest:MQTTQoS: QoS %d not supported, only 0 and 1\n", qos)
+							continue
+						}
+						globalSettings.MQTTQoS = qos
+					case "MQTT_TLS":
+						globalSettings.MQTT_TLS = val.(bool)
+					case "MQTTTLSInsecure":
+						globalSettings.MQTTTLSInsecure = val.(bool)
+					case "MQTTCAFile":
+						globalSettings.MQTTCAFile = val.(string)
+					case "MQTTTrafficExpiry":
+						globalSettings.MQTTTrafficExpiry = int(val.(float64))
+					case "AISOut_Enabled":
+						globalSettings.AISOut_Enabled = val.(bool)
+						refreshClients = true
+					case "AISOutPort":
+						globalSettings.AISOutPort = int(val.(float64))
+						refreshClients = true
+					case "AISOutAircraft":
+						globalSettings.AISOutAircraft = val.(bool)
+					case "IGC_Enabled":
+						globalSettings.IGC_Enabled = val.(bool)
+					case "IGCInterval":
+						globalSettings.IGCInterval = int(val.(float64))
 					case "Ping_Enabled":
 						globalSettings.Ping_Enabled = val.(bool)
 					case "OGNI2CTXEnabled":
@@ -489,6 +914,15 @@ func handleSettingsSetRequest(w http.ResponseWriter, r *http.Request) {
 							networks = append(networks, wifiClientNetwork{network["SSID"].(string), network["Password"].(string)})
 						}
 						setWifiClientNetworks(networks)
+					case "TrafficWatchRules":
+						rulesJSON, _ := json.Marshal(val)
+						var rules []TrafficWatchRule
+						if err := json.Unmarshal(rulesJSON, &rules); err != nil {
+							log.Printf("handleSettingsSetRequest:TrafficWatchRules: %s\n", err.Error())
+							break
+						}
+						globalSettings.TrafficWatchRules = rules
+						resetTrafficWatch()
 					case "WiFiInternetPassThroughEnabled":
 						setWifiInternetPassthroughEnabled(val.(bool))
 					case "EstimateBearinglessDist":
@@ -541,6 +975,9 @@ func handleSettingsSetRequest(w http.ResponseWriter, r *http.Request) {
 				if reconfigureGXTracker {
 					configureGxAirComTracker()
 				}
+				if refreshClients {
+					go refreshConnectedClients() // don't wait up to 30 s for outputs to start or stop
+				}
 				if reconfigureFancontrol {
 					exec.Command("killall", "-SIGUSR1", "fancontrol").Run();
 				}
@@ -836,6 +1273,53 @@ func handleUpdatePostRequest(w http.ResponseWriter, r *http.Request) {
 	go delayReboot()
 }
 
+// AJAX call - /uploadAircraftDB. Receives a CSV or SQLite aircraft database via multipart POST ("aircraftdb_file")
+// and installs it. Responds with the number of records.
+func handleAircraftDBUploadRequest(w http.ResponseWriter, r *http.Request) {
+	setNoCache(w)
+	setJSONHeaders(w)
+	reader, err := r.MultipartReader()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	tmpFile := "/tmp/aircraftdb_upload"
+	for {
+		part, err := reader.NextPart()
+		if err != nil {
+			log.Printf("Aircraft database upload failed from %s (%s).\n", r.RemoteAddr, err.Error())
+			http.Error(w, "aircraftdb_file missing", http.StatusBadRequest)
+			return
+		}
+		if part.FormName() != "aircraftdb_file" {
+			continue
+		}
+		fi, err := os.OpenFile(tmpFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		_, err = io.Copy(fi, part)
+		fi.Close()
+		if err != nil {
+			log.Printf("Aircraft database upload failed from %s (%s).\n", r.RemoteAddr, err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		break
+	}
+	defer os.Remove(tmpFile)
+
+	count, err := installAircraftDB(tmpFile)
+	if err != nil {
+		log.Printf("Aircraft database from %s rejected: %s\n", r.RemoteAddr, err.Error())
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	log.Printf("%s uploaded aircraft database with %d records.\n", r.RemoteAddr, count)
+	fmt.Fprintf(w, "{\"Records\": %d}\n", count)
+}
+
 func setNoCache(w http.ResponseWriter) {
 	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
 	w.Header().Set("Pragma", "no-cache")
@@ -1066,6 +1550,10 @@ func handleTilesets(w http.ResponseWriter, r *http.Request) {
 			result[f.Name()] = meta
 		}
 	}
+	// Rendered on demand from FIS-B, see nexradmosaic.go
+	for name, meta := range getNexradTilesets() {
+		result[name] = meta
+	}
 	resJson, _ := json.Marshal(result)
 	w.Write(resJson)
 }
@@ -1118,6 +1606,18 @@ func handleTile(w http.ResponseWriter, r *http.Request) {
 	z, _ := strconv.Atoi(parts[idx])
 	idx--
 	file, _ := url.QueryUnescape(parts[idx])
+	if mosaic := getNexradMosaicByTileset(file); mosaic != nil {
+		// TMS row like the MBTiles sets
+		tileData, err := renderNexradTile(mosaic, z, x, (1<<uint(z))-1-y)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		setNoCache(w)
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(tileData)
+		return
+	}
 	tileData, err := loadTile(file, z, x, y)
 	if err != nil {
 		http.Error(w, err.Error(), 500)
@@ -1135,6 +1635,8 @@ func managementInterface() {
 	situationUpdate = NewUIBroadcaster()
 	weatherRawUpdate = NewUIBroadcaster()
 	gdl90Update = NewUIBroadcaster()
+	trafficHistoryUpdate = NewUIBroadcaster()
+	trafficEventUpdate = NewUIBroadcaster()
 
 	http.HandleFunc("/", defaultServer)
 	//http.Handle("/logs/", http.StripPrefix("/logs/", http.FileServer(http.Dir("/var/log"))))
@@ -1171,6 +1673,18 @@ func managementInterface() {
 				Handler: websocket.Handler(handleTrafficWS)}
 			s.ServeHTTP(w, req)
 		})
+	http.HandleFunc("/trafficHistory",
+		func(w http.ResponseWriter, req *http.Request) {
+			s := websocket.Server{
+				Handler: websocket.Handler(handleTrafficHistoryWS)}
+			s.ServeHTTP(w, req)
+		})
+	http.HandleFunc("/trafficEvents",
+		func(w http.ResponseWriter, req *http.Request) {
+			s := websocket.Server{
+				Handler: websocket.Handler(handleTrafficEventsWS)}
+			s.ServeHTTP(w, req)
+		})
 	http.HandleFunc("/radar",
 		func(w http.ResponseWriter, req *http.Request) {
 			s := websocket.Server{
@@ -1190,6 +1704,17 @@ func managementInterface() {
 	http.HandleFunc("/getSituation", handleSituationRequest)
 	http.HandleFunc("/getTowers", handleTowersRequest)
 	http.HandleFunc("/getSatellites", handleSatellitesRequest)
+	http.HandleFunc("/getTrafficHistory", handleTrafficHistoryRequest)
+	http.HandleFunc("/getTrafficEvents", handleTrafficEventsRequest)
+	http.HandleFunc("/getCoverage", handleCoverageRequest)
+	http.HandleFunc("/resetCoverage", handleCoverageResetRequest)
+	http.HandleFunc("/data/aircraft.json", handleReadsbAircraftRequest)
+	http.HandleFunc("/data/receiver.json", handleReadsbReceiverRequest)
+	http.HandleFunc("/startScenario", handleScenarioStartRequest)
+	http.HandleFunc("/stopScenario", handleScenarioStopRequest)
+	http.HandleFunc("/getScenario", handleScenarioStatusRequest)
+	http.HandleFunc("/getIGCFiles", handleIGCFilesRequest)
+	http.HandleFunc("/igc/", handleIGCDownloadRequest)
 	http.HandleFunc("/getSettings", handleSettingsGetRequest)
 	http.HandleFunc("/setSettings", handleSettingsSetRequest)
 	http.HandleFunc("/restart", handleRestartRequest)
@@ -1197,6 +1722,7 @@ func managementInterface() {
 	http.HandleFunc("/reboot", handleRebootRequest)
 	http.HandleFunc("/getClients", handleClientsGetRequest)
 	http.HandleFunc("/updateUpload", handleUpdatePostRequest)
+	http.HandleFunc("/uploadAircraftDB", handleAircraftDBUploadRequest)
 	http.HandleFunc("/roPartitionRebuild", handleroPartitionRebuild)
 	http.HandleFunc("/develmodetoggle", handleDevelModeToggle)
 	http.HandleFunc("/orientAHRS", handleOrientAHRS)
@@ -1208,6 +1734,11 @@ func managementInterface() {
 	http.HandleFunc("/deleteahrslogfiles", handleDeleteAHRSLogFiles)
 	http.HandleFunc("/downloadahrslogs", handleDownloadAHRSLogsRequest)
 	http.HandleFunc("/downloaddb", handleDownloadDBRequest)
+	http.HandleFunc("/getFlights", handleFlightsRequest)
+	http.HandleFunc("/exportFlight", handleFlightExportRequest)
+	http.HandleFunc("/weather/overlays", handleWeatherOverlaysRequest)
+	http.HandleFunc("/weather/restrictions", handleAirspaceRestrictionsRequest)
+	http.HandleFunc("/getMetars", handleMetarsRequest)
 	http.HandleFunc("/tiles/tilesets", handleTilesets)
 	http.HandleFunc("/tiles/", handleTile)
 
@@ -0,0 +1,208 @@
+/*
+	Copyright (c) 2026 Stratux contributors
+	Distributable under the terms of The "BSD New" License
+	that can be found in the LICENSE file, herein included
+	as part of this header.
+
+	mavlink.go: MAVLink output for drone ground control stations (QGroundControl, Mission Planner). All traffic is sent
+		as ADSB_VEHICLE, optionally with HEARTBEAT and GPS_RAW_INT for our own position. UDP to every client on
+		globalSettings.MAVLinkPort (14550 by default) and /dev/serialout_mavlink* serial outputs.
+		See https://mavlink.io/en/guide/serialization.html and https://mavlink.io/en/messages/common.html
+*/
+
+package main
+
+import (
+	"encoding/binary"
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	MAVLINK_STX_V1 = 0xFE
+	MAVLINK_STX_V2 = 0xFD
+
+	MAVLINK_SYSTEM_ID    = 200 // distinct from the vehicle, which is usually system 1
+	MAVLINK_COMPONENT_ID = 156 // MAV_COMP_ID_ADSB
+
+	MAVLINK_MSG_HEARTBEAT    = 0
+	MAVLINK_MSG_GPS_RAW_INT  = 24
+	MAVLINK_MSG_ADSB_VEHICLE = 246
+
+	MAV_TYPE_ADSB         = 27
+	MAV_AUTOPILOT_INVALID = 8
+	MAV_STATE_ACTIVE      = 4
+
+	ADSB_FLAGS_VALID_COORDS            = 1
+	ADSB_FLAGS_VALID_ALTITUDE          = 2
+	ADSB_FLAGS_VALID_HEADING           = 4
+	ADSB_FLAGS_VALID_VELOCITY          = 8
+	ADSB_FLAGS_VALID_CALLSIGN          = 16
+	ADSB_FLAGS_VALID_SQUAWK            = 32
+	ADSB_FLAGS_SIMULATED               = 64
+	ADSB_FLAGS_VERTICAL_VELOCITY_VALID = 128
+	ADSB_FLAGS_BARO_VALID              = 256
+	ADSB_FLAGS_SOURCE_UAT              = 32768
+
+	ADSB_ALTITUDE_TYPE_PRESSURE_QNH = 0
+	ADSB_ALTITUDE_TYPE_GEOMETRIC    = 1
+)
+
+// CRC_EXTRA of the messages we send, from the message definitions
+var mavlinkCrcExtra = map[uint32]byte{
+	MAVLINK_MSG_HEARTBEAT:    50,
+	MAVLINK_MSG_GPS_RAW_INT:  24,
+	MAVLINK_MSG_ADSB_VEHICLE: 184,
+}
+
+var mavlinkSequence uint32
+
+// X.25 / MCRF4XX checksum as used by MAVLink
+func mavlinkCrcAccumulate(crc uint16, b byte) uint16 {
+	tmp := b ^ byte(crc&0xFF)
+	tmp ^= tmp << 4
+	return (crc >> 8) ^ (uint16(tmp) << 8) ^ (uint16(tmp) << 3) ^ (uint16(tmp) >> 4)
+}
+
+func mavlinkCrc(data []byte, crcExtra byte) uint16 {
+	crc := uint16(0xFFFF)
+	for _, b := range data {
+		crc = mavlinkCrcAccumulate(crc, b)
+	}
+	return mavlinkCrcAccumulate(crc, crcExtra)
+}
+
+// Frames a payload as MAVLink v1 or v2 packet, depending on globalSettings.MAVLinkVersion.
+func makeMAVLinkPacket(msgId uint32, payload []byte) []byte {
+	seq := byte(atomic.AddUint32(&mavlinkSequence, 1))
+	var packet []byte
+	if globalSettings.MAVLinkVersion == 1 {
+		packet = []byte{MAVLINK_STX_V1, byte(len(payload)), seq, MAVLINK_SYSTEM_ID, MAVLINK_COMPONENT_ID, byte(msgId)}
+	} else {
+		// v2 truncates trailing zero bytes of the payload (but keeps at least one byte)
+		for len(payload) > 1 && payload[len(payload)-1] == 0 {
+			payload = payload[:len(payload)-1]
+		}
+		packet = []byte{MAVLINK_STX_V2, byte(len(payload)), 0, 0, seq, MAVLINK_SYSTEM_ID, MAVLINK_COMPONENT_ID,
+			byte(msgId), byte(msgId >> 8), byte(msgId >> 16)}
+	}
+	packet = append(packet, payload...)
+	crc := mavlinkCrc(packet[1:], mavlinkCrcExtra[msgId])
+	return append(packet, byte(crc&0xFF), byte(crc>>8))
+}
+
+func makeMAVLinkHeartbeat() []byte {
+	payload := make([]byte, 9)
+	// payload[0:4] custom_mode
+	payload[4] = MAV_TYPE_ADSB
+	payload[5] = MAV_AUTOPILOT_INVALID
+	payload[6] = 0 // base_mode
+	payload[7] = MAV_STATE_ACTIVE
+	payload[8] = 3 // mavlink_version
+	return makeMAVLinkPacket(MAVLINK_MSG_HEARTBEAT, payload)
+}
+
+func makeMAVLinkGPSRawInt() []byte {
+	payload := make([]byte, 30)
+	binary.LittleEndian.PutUint64(payload[0:], uint64(time.Now().UnixNano()/1000))
+	binary.LittleEndian.PutUint32(payload[8:], uint32(int32(float64(mySituation.GPSLatitude)*1e7)))
+	binary.LittleEndian.PutUint32(payload[12:], uint32(int32(float64(mySituation.GPSLongitude)*1e7)))
+	binary.LittleEndian.PutUint32(payload[16:], uint32(int32(float64(mySituation.GPSAltitudeMSL)*304.8))) // mm
+	eph, epv := uint16(math.MaxUint16), uint16(math.MaxUint16)
+	if mySituation.GPSHorizontalAccuracy < 600 {
+		eph = uint16(mySituation.GPSHorizontalAccuracy * 100 / 2) // HDOP-ish, from 95% accuracy in meters
+	}
+	if mySituation.GPSVerticalAccuracy < 600 {
+		epv = uint16(mySituation.GPSVerticalAccuracy * 100 / 2)
+	}
+	binary.LittleEndian.PutUint16(payload[20:], eph)
+	binary.LittleEndian.PutUint16(payload[22:], epv)
+	vel, cog := uint16(math.MaxUint16), uint16(math.MaxUint16)
+	if isGPSGroundTrackValid() {
+		vel = uint16(mySituation.GPSGroundSpeed * 1852 / 36) // knots to cm/s
+		cog = uint16(mySituation.GPSTrueCourse*100) % 36000
+	}
+	binary.LittleEndian.PutUint16(payload[24:], vel)
+	binary.LittleEndian.PutUint16(payload[26:], cog)
+	payload[28] = 3 // GPS_FIX_TYPE_3D_FIX
+	if mySituation.GPSFixQuality == 2 {
+		payload[28] = 4 // GPS_FIX_TYPE_DGPS
+	}
+	payload[29] = byte(mySituation.GPSSatellites)
+	return makeMAVLinkPacket(MAVLINK_MSG_GPS_RAW_INT, payload)
+}
+
+func makeMAVLinkAdsbVehicle(ti TrafficInfo) []byte {
+	payload := make([]byte, 38)
+	flags := uint16(0)
+	binary.LittleEndian.PutUint32(payload[0:], ti.Icao_addr&0xFFFFFF)
+	if ti.Position_valid {
+		binary.LittleEndian.PutUint32(payload[4:], uint32(int32(float64(ti.Lat)*1e7)))
+		binary.LittleEndian.PutUint32(payload[8:], uint32(int32(float64(ti.Lng)*1e7)))
+		flags |= ADSB_FLAGS_VALID_COORDS
+	}
+	if ti.Alt != 0 || ti.OnGround {
+		binary.LittleEndian.PutUint32(payload[12:], uint32(int32(float64(ti.Alt)*304.8))) // mm
+		flags |= ADSB_FLAGS_VALID_ALTITUDE
+		if !ti.AltIsGNSS {
+			flags |= ADSB_FLAGS_BARO_VALID
+		}
+	}
+	if ti.Speed_valid {
+		binary.LittleEndian.PutUint16(payload[16:], uint16(ti.Track*100)%36000)
+		binary.LittleEndian.PutUint16(payload[18:], uint16(float32(ti.Speed)*1852/36))     // knots to cm/s
+		binary.LittleEndian.PutUint16(payload[20:], uint16(int16(float32(ti.Vvel)*0.508))) // fpm to cm/s
+		flags |= ADSB_FLAGS_VALID_HEADING | ADSB_FLAGS_VALID_VELOCITY | ADSB_FLAGS_VERTICAL_VELOCITY_VALID
+	}
+	if ti.Squawk != 0 {
+		// MAVLink carries the squawk as the number seen on the transponder, e.g. 7700
+		binary.LittleEndian.PutUint16(payload[24:], uint16(ti.Squawk))
+		flags |= ADSB_FLAGS_VALID_SQUAWK
+	}
+	if ti.Last_source == TRAFFIC_SOURCE_UAT {
+		flags |= ADSB_FLAGS_SOURCE_UAT
+	}
+	if ti.AltIsGNSS {
+		payload[26] = ADSB_ALTITUDE_TYPE_GEOMETRIC
+	} else {
+		payload[26] = ADSB_ALTITUDE_TYPE_PRESSURE_QNH
+	}
+	callsign := ti.Tail
+	if len(callsign) > 8 {
+		callsign = callsign[:8]
+	}
+	if len(callsign) > 0 {
+		copy(payload[27:35], callsign) // 9 bytes, NUL terminated
+		flags |= ADSB_FLAGS_VALID_CALLSIGN
+	}
+	// ADSB_EMITTER_TYPE has the same values as the GDL90 emitter category up to point obstacles (19)
+	if ti.Emitter_category <= 19 {
+		payload[36] = ti.Emitter_category
+	}
+	tslc := stratuxClock.Since(ti.Last_seen).Seconds()
+	if tslc > 255 {
+		tslc = 255
+	}
+	payload[37] = byte(tslc)
+	binary.LittleEndian.PutUint16(payload[22:], flags)
+	return makeMAVLinkPacket(MAVLINK_MSG_ADSB_VEHICLE, payload)
+}
+
+// Called once per second from heartBeatSender()
+func sendMAVLinkOwnship() {
+	if !globalSettings.MAVLinkOwnship {
+		return
+	}
+	sendMAVLink(makeMAVLinkHeartbeat(), time.Second, -20) // sent even to sleeping clients, like the GDL90 heartbeat
+	if isGPSValid() {
+		sendMAVLink(makeMAVLinkGPSRawInt(), time.Second, -1)
+	}
+}
+
+func sendMAVLink(msg []byte, maxAge time.Duration, priority int32) {
+	if !globalSettings.MAVLink_Enabled {
+		return // also not to /dev/serialout_mavlink*
+	}
+	sendMsg(msg, NETWORK_MAVLINK, maxAge, priority)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/hex"
+	"testing"
+	"time"
+)
+
+// CRC-16/MCRF4XX check value
+func TestMAVLinkCrc(t *testing.T) {
+	if crc := mavlinkCrc([]byte("12345678"), '9'); crc != 0x6F91 {
+		t.Errorf("crc %04X, want 6F91", crc)
+	}
+}
+
+// Golden packets were framed and checksummed independently of mavlink.go, following
+// https://mavlink.io/en/guide/serialization.html and the ADSB_VEHICLE and HEARTBEAT definitions of common.xml.
+func TestMAVLinkAdsbVehicle(t *testing.T) {
+	initTestTraffic()
+	defer func() { globalSettings.MAVLinkVersion = 0 }()
+
+	ti := TrafficInfo{
+		Icao_addr:        0xA12345,
+		Tail:             "N12345",
+		Emitter_category: 1,
+		Squawk:           1200,
+		Lat:              44.5,
+		Lng:              -88.25,
+		Position_valid:   true,
+		Alt:              3500,
+		Track:            270,
+		Speed:            120,
+		Speed_valid:      true,
+		Vvel:             -500,
+		Last_source:      TRAFFIC_SOURCE_1090ES,
+		Last_seen:        stratuxClock.Time.Add(-2 * time.Second),
+	}
+	minimal := TrafficInfo{Icao_addr: 0xA12345, Last_seen: stratuxClock.Time}
+	tests := []struct {
+		name    string
+		version int
+		seq     uint32
+		packet  func() []byte
+		golden  string
+	}{
+		{"v1 ADSB_VEHICLE", 1, 7, func() []byte { return makeMAVLinkAdsbVehicle(ti) },
+			"fe2607c89cf6" + // STX, length 38, sequence, system, component, message ID 246
+				"4523a100" + "4029861a" + "601e66cb" + "30471000" + // ICAO address, lat, lon, altitude (mm)
+				"7869" + "1d18" + "02ff" + "bf01" + "b004" + "00" + // heading, hor. and ver. velocity, flags, squawk, altitude type
+				"4e3132333435000000" + "01" + "02" + // callsign, emitter type, time since last communication
+				"8ba0"}, // CRC with CRC_EXTRA 184
+		{"v2 ADSB_VEHICLE", 2, 8, func() []byte { return makeMAVLinkAdsbVehicle(ti) },
+			"fd26000008c89cf60000" + // STX, length 38, incompat and compat flags, sequence, system, component, message ID
+				"4523a1004029861a601e66cb3047100078691d1802ffbf01b004004e31323334350000000102" + "aaeb"},
+		{"v2 ADSB_VEHICLE truncated", 2, 9, func() []byte { return makeMAVLinkAdsbVehicle(minimal) },
+			"fd03000009c89cf60000" + "4523a1" + "ac59"}, // trailing zeros of the payload are not sent
+		{"v1 HEARTBEAT", 1, 1, makeMAVLinkHeartbeat,
+			"fe0901c89c00" + "00000000" + "1b" + "08" + "00" + "04" + "03" + "f4f6"}, // custom mode, type, autopilot, base mode, state, version
+		{"v2 HEARTBEAT", 2, 2, makeMAVLinkHeartbeat,
+			"fd09000002c89c000000" + "000000001b08000403" + "f26c"},
+	}
+	for _, tt := range tests {
+		globalSettings.MAVLinkVersion = tt.version
+		mavlinkSequence = tt.seq - 1
+		if got := hex.EncodeToString(tt.packet()); got != tt.golden {
+			t.Errorf("%s:\n got %s\nwant %s", tt.name, got, tt.golden)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+/*
+	Copyright (c) 2026 Stratux contributors
+	Distributable under the terms of The "BSD New" License
+	that can be found in the LICENSE file, herein included
+	as part of this header.
+
+	metars.go: Latest METAR/SPECI and TAF per station, parsed with the weather package and served on /getMetars.
+		Station coordinates come from an OurAirports style airports.csv in the mapdata directory if there is one,
+		otherwise from the airport positions of the FIS-B NOTAM graphics (see weatheroverlays.go).
+*/
+
+package main
+
+import (
+	"encoding/csv"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/b3nn0/stratux/uatparse"
+	"github.com/b3nn0/stratux/weather"
+)
+
+const (
+	METAR_MAX_AGE   = 3 * time.Hour
+	airportsCSVFile = STRATUX_HOME + "mapdata/airports.csv"
+)
+
+type StationWeather struct {
+	Station        string
+	Name           string // from airports.csv
+	Lat            float64
+	Lng            float64
+	Position_valid bool
+	METAR          *weather.METAR
+	TAF            *weather.TAF
+	FlightCategory string    // from the METAR, or the prevailing TAF conditions if there is no METAR
+	LastUpdate     time.Time // UTC
+}
+
+type airportPosition struct {
+	Name string
+	Lat  float64
+	Lng  float64
+}
+
+var stationWeather = make(map[string]*StationWeather)
+var stationWeatherMutex sync.Mutex
+
+var airportPositions map[string]airportPosition
+var airportPositionsOnce sync.Once
+
+// Called from registerADSBTextMessageReceived() for every text report.
+func registerWeatherReport(reportType string, text string) {
+	now := time.Now().UTC()
+	var metar *weather.METAR
+	var taf *weather.TAF
+	var err error
+	switch reportType {
+	case "METAR", "SPECI":
+		metar, err = weather.ParseMETAR(text, now)
+	case "TAF", "TAF.AMD":
+		taf, err = weather.ParseTAF(text, now)
+	default:
+		return
+	}
+	if err != nil {
+		return
+	}
+
+	stationWeatherMutex.Lock()
+	defer stationWeatherMutex.Unlock()
+	station := ""
+	if metar != nil {
+		station = metar.Station
+	} else {
+		station = taf.Station
+	}
+	sw, ok := stationWeather[station]
+	if !ok {
+		sw = &StationWeather{Station: station}
+		stationWeather[station] = sw
+	}
+	if metar != nil && (sw.METAR == nil || !metar.Time.Before(sw.METAR.Time)) {
+		sw.METAR = metar
+	}
+	if taf != nil && (sw.TAF == nil || !taf.ValidFrom.Before(sw.TAF.ValidFrom)) {
+		sw.TAF = taf
+	}
+	sw.LastUpdate = now
+}
+
+// Reads airports.csv (OurAirports format: ident, type, name, latitude_deg, longitude_deg, gps_code, icao_code, ...).
+func loadAirportPositions() {
+	airportPositions = make(map[string]airportPosition)
+	f, err := os.Open(airportsCSVFile)
+	if err != nil {
+		return // optional
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err != nil {
+		log.Printf("%s: %s\n", airportsCSVFile, err.Error())
+		return
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	latCol, latOk := columns["latitude_deg"]
+	lngCol, lngOk := columns["longitude_deg"]
+	if !latOk || !lngOk {
+		log.Printf("%s: no latitude_deg/longitude_deg columns\n", airportsCSVFile)
+		return
+	}
+	identCols := make([]int, 0)
+	for _, name := range []string{"ident", "gps_code", "icao_code"} {
+		if i, ok := columns[name]; ok {
+			identCols = append(identCols, i)
+		}
+	}
+	nameCol, nameOk := columns["name"]
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil || len(record) <= latCol || len(record) <= lngCol {
+			continue
+		}
+		lat, err1 := strconv.ParseFloat(record[latCol], 64)
+		lng, err2 := strconv.ParseFloat(record[lngCol], 64)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		pos := airportPosition{Lat: lat, Lng: lng}
+		if nameOk && nameCol < len(record) {
+			pos.Name = record[nameCol]
+		}
+		for _, i := range identCols {
+			if i < len(record) && len(record[i]) > 0 {
+				airportPositions[strings.ToUpper(record[i])] = pos
+			}
+		}
+	}
+	log.Printf("Loaded %d airport positions from %s\n", len(airportPositions), airportsCSVFile)
+}
+
+// Airport positions learned from NOTAM point graphics, which are broadcast for the airport of the report.
+func notamAirportPositions() map[string]airportPosition {
+	result := make(map[string]airportPosition)
+	weatherOverlaysMutex.Lock()
+	defer weatherOverlaysMutex.Unlock()
+	for _, o := range weatherOverlays {
+		if o.ProductID != uatparse.PRODUCT_NOTAM || len(o.Location) == 0 {
+			continue
+		}
+		for _, r := range o.Records {
+			if (r.Geometry == uatparse.GEOMETRY_POINT_AGL || r.Geometry == uatparse.GEOMETRY_POINT_MSL) && len(r.Points) > 0 {
+				result[o.Location] = airportPosition{Lat: r.Points[0].Lat, Lng: r.Points[0].Lon}
+			}
+		}
+	}
+	return result
+}
+
+// Latest reports of all stations or of the given ones, sorted by station.
+func getStationWeather(stations map[string]bool) []StationWeather {
+	airportPositionsOnce.Do(loadAirportPositions)
+	notamPositions := notamAirportPositions()
+	now := time.Now().UTC()
+
+	stationWeatherMutex.Lock()
+	defer stationWeatherMutex.Unlock()
+	result := make([]StationWeather, 0)
+	for station, sw := range stationWeather {
+		if sw.METAR != nil && now.Sub(sw.METAR.Time) > METAR_MAX_AGE {
+			sw.METAR = nil
+		}
+		if sw.TAF != nil && !now.Before(sw.TAF.ValidTo) {
+			sw.TAF = nil
+		}
+		if sw.METAR == nil && sw.TAF == nil {
+			delete(stationWeather, station)
+			continue
+		}
+		if len(stations) > 0 && !stations[station] {
+			continue
+		}
+
+		sw.FlightCategory = ""
+		if sw.METAR != nil {
+			sw.FlightCategory = sw.METAR.FlightCategory
+		} else if g := sw.TAF.Prevailing(now); g != nil {
+			sw.FlightCategory = g.FlightCategory
+		}
+		if pos, ok := airportPositions[station]; ok {
+			sw.Name, sw.Lat, sw.Lng, sw.Position_valid = pos.Name, pos.Lat, pos.Lng, true
+		} else if pos, ok := notamPositions[station]; ok {
+			sw.Lat, sw.Lng, sw.Position_valid = pos.Lat, pos.Lng, true
+		}
+		result = append(result, *sw)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Station < result[j].Station })
+	return result
+}
@@ -0,0 +1,282 @@
+/*
+	Copyright (c) 2026 Stratux contributors
+	Distributable under the terms of The "BSD New" License
+	that can be found in the LICENSE file, herein included
+	as part of this header.
+
+	mqtt.go: MQTT publisher for dashboards and fleet tracking. Publishes to globalSettings.MQTTBroker:
+		<prefix>/online            "true"/"false", retained (last will)
+		<prefix>/situation         mySituation as in /getSituation, retained, once per second
+		<prefix>/status            globalStatus as in /getStatus, retained, every MQTT_STATUS_PERIOD seconds
+		<prefix>/traffic/<hex>     TrafficInfo per target, retained. Cleared after MQTTTrafficExpiry seconds
+		<prefix>/weather/<type>    text weather messages (METAR, TAF, PIREP, ...)
+		Messages are queued without blocking and dropped if the broker can't keep up or isn't connected.
+		With QoS 1, messages the broker didn't acknowledge before the connection was lost are published again after
+		reconnecting.
+*/
+
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/b3nn0/stratux/mqtt"
+)
+
+const (
+	MQTT_STATUS_PERIOD = 5 // seconds between status messages
+	MQTT_QUEUE_SIZE    = 1024
+	MQTT_MAX_INFLIGHT  = 256
+	MQTT_BACKOFF_MIN   = 1 * time.Second
+	MQTT_BACKOFF_MAX   = 2 * time.Minute
+)
+
+type mqttMessage struct {
+	topic   string
+	payload []byte
+	retain  bool
+}
+
+var mqttQueue = make(chan mqttMessage, MQTT_QUEUE_SIZE)
+var mqttStatusCounter uint
+
+// Never blocks: sendTrafficUpdates() must not wait for the broker.
+func mqttPublish(topic string, payload []byte, retain bool) {
+	if !globalSettings.MQTT_Enabled {
+		return
+	}
+	select {
+	case mqttQueue <- mqttMessage{mqttTopic(topic), payload, retain}:
+	default:
+		// queue full, broker too slow
+	}
+}
+
+func mqttPublishJSON(topic string, v interface{}, retain bool) {
+	if !globalSettings.MQTT_Enabled {
+		return
+	}
+	payload, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("MQTT %s: %s\n", topic, err.Error())
+		return
+	}
+	mqttPublish(topic, payload, retain)
+}
+
+func mqttTopic(topic string) string {
+	prefix := strings.TrimSuffix(globalSettings.MQTTTopicPrefix, "/")
+	if len(prefix) == 0 {
+		return topic
+	}
+	return prefix + "/" + topic
+}
+
+func mqttTrafficTopic(ti TrafficInfo) string {
+	if _, icao := readsbAddressType(ti); icao {
+		return fmt.Sprintf("traffic/%06x", ti.Icao_addr&0xFFFFFF)
+	}
+	return fmt.Sprintf("traffic/~%06x", ti.Icao_addr&0xFFFFFF)
+}
+
+// Called from sendTrafficUpdates() for every current target with position.
+func sendMQTTTrafficUpdate(ti TrafficInfo) {
+	mqttPublishJSON(mqttTrafficTopic(ti), ti, true)
+}
+
+// Called once per second from heartBeatSender()
+func sendMQTTUpdates() {
+	if !globalSettings.MQTT_Enabled {
+		return
+	}
+	mqttPublishJSON("situation", &mySituation, true)
+	mqttStatusCounter++
+	if mqttStatusCounter%MQTT_STATUS_PERIOD == 1 {
+		mqttPublishJSON("status", &globalStatus, true)
+	}
+}
+
+func mqttTLSConfig() (*tls.Config, error) {
+	if !globalSettings.MQTT_TLS {
+		return nil, nil
+	}
+	host := globalSettings.MQTTBroker
+	if idx := strings.LastIndex(host, ":"); idx >= 0 {
+		host = host[:idx]
+	}
+	config := &tls.Config{ServerName: host, InsecureSkipVerify: globalSettings.MQTTTLSInsecure}
+	if len(globalSettings.MQTTCAFile) > 0 {
+		pem, err := os.ReadFile(globalSettings.MQTTCAFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in %s", globalSettings.MQTTCAFile)
+		}
+	}
+	return config, nil
+}
+
+func mqttConnect() (*mqtt.Client, error) {
+	if globalSettings.MQTTQoS != 0 && globalSettings.MQTTQoS != 1 {
+		return nil, fmt.Errorf("QoS %d not supported, only 0 and 1", globalSettings.MQTTQoS)
+	}
+	tlsConfig, err := mqttTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	clientID := globalSettings.MQTTClientID
+	if len(clientID) == 0 {
+		clientID = "stratux"
+	}
+	opts := mqtt.Options{
+		ClientID:    clientID,
+		Username:    globalSettings.MQTTUsername,
+		Password:    globalSettings.MQTTPassword,
+		KeepAlive:   30 * time.Second,
+		TLS:         tlsConfig,
+		WillTopic:   mqttTopic("online"),
+		WillPayload: []byte("false"),
+		WillRetain:  true,
+		MaxInflight: MQTT_MAX_INFLIGHT,
+	}
+	client, err := mqtt.Dial(globalSettings.MQTTBroker, opts)
+	if err != nil {
+		return nil, err
+	}
+	client.Publish(mqttTopic("online"), []byte("true"), byte(globalSettings.MQTTQoS), true)
+	return client, nil
+}
+
+// Clears the retained messages of targets that weren't updated for MQTTTrafficExpiry seconds.
+func expireMQTTTraffic(client *mqtt.Client, published map[string]time.Time) {
+	expiry := time.Duration(globalSettings.MQTTTrafficExpiry) * time.Second
+	for topic, t := range published {
+		if stratuxClock.Since(t) < expiry {
+			continue
+		}
+		if client.Publish(topic, nil, byte(globalSettings.MQTTQoS), true) == nil {
+			delete(published, topic)
+		}
+	}
+}
+
+// Publishes the messages that were not acknowledged on the previous connection, in order. Returns the ones that
+// could not be published (yet), e.g. because the broker hasn't acknowledged enough of the others.
+func mqttResend(client *mqtt.Client, unacked []mqtt.Message) []mqtt.Message {
+	sent := 0
+	for _, msg := range unacked {
+		if client.Publish(msg.Topic, msg.Payload, byte(globalSettings.MQTTQoS), msg.Retain) != nil {
+			break
+		}
+		sent++
+	}
+	if sent > 0 {
+		log.Printf("MQTT: published %d of %d unacknowledged messages again\n", sent, len(unacked))
+	}
+	return unacked[sent:]
+}
+
+// Clears the retained traffic topics before a deliberate disconnect, so subscribers don't keep showing the targets.
+func clearMQTTTraffic(client *mqtt.Client, published map[string]time.Time) {
+	for topic := range published {
+		client.Publish(topic, nil, 0, true) // QoS 0 isn't limited by the unacknowledged messages
+		delete(published, topic)
+	}
+}
+
+// Connects to the broker, reconnects with backoff and publishes everything from mqttQueue. QoS 1 messages that were
+// not acknowledged when the connection was lost are published again after reconnecting to the same broker.
+func mqttPublisher() {
+	backoff := MQTT_BACKOFF_MIN
+	trafficPrefix := ""
+	published := make(map[string]time.Time) // retained traffic topics and their last update (stratuxClock)
+	var unacked []mqtt.Message
+	unackedBroker := ""
+	expiryTicker := time.NewTicker(10 * time.Second)
+	for {
+		if !globalSettings.MQTT_Enabled || len(globalSettings.MQTTBroker) == 0 {
+			drainMQTTQueue()
+			time.Sleep(5 * time.Second)
+			continue
+		}
+		broker := globalSettings.MQTTBroker
+		client, err := mqttConnect()
+		if err != nil {
+			log.Printf("MQTT %s: %s. Retrying in %s\n", broker, err.Error(), backoff)
+			drainMQTTQueue()
+			time.Sleep(backoff)
+			backoff *= 2
+			if backoff > MQTT_BACKOFF_MAX {
+				backoff = MQTT_BACKOFF_MAX
+			}
+			continue
+		}
+		log.Printf("MQTT: connected to %s\n", broker)
+		backoff = MQTT_BACKOFF_MIN
+		trafficPrefix = mqttTopic("traffic/")
+		if unackedBroker == broker {
+			unacked = mqttResend(client, unacked)
+		} else {
+			unacked = nil
+		}
+
+	connected:
+		for {
+			select {
+			case msg := <-mqttQueue:
+				if len(unacked) > 0 {
+					unacked = mqttResend(client, unacked)
+				}
+				err := client.Publish(msg.topic, msg.payload, byte(globalSettings.MQTTQoS), msg.retain)
+				if err == mqtt.ErrInflightFull {
+					continue // broker is slow to acknowledge, drop
+				}
+				if err != nil {
+					break connected
+				}
+				if msg.retain && strings.HasPrefix(msg.topic, trafficPrefix) {
+					published[msg.topic] = stratuxClock.Time
+				}
+			case <-expiryTicker.C:
+				expireMQTTTraffic(client, published)
+				if !globalSettings.MQTT_Enabled || globalSettings.MQTTBroker != broker {
+					clearMQTTTraffic(client, published)
+					client.Publish(mqttTopic("online"), []byte("false"), byte(globalSettings.MQTTQoS), true)
+					client.Close()
+					log.Printf("MQTT: disconnected from %s\n", broker)
+					break connected
+				}
+			case <-client.Done():
+				break connected
+			}
+		}
+		if err := client.Err(); err != nil && err != mqtt.ErrClosed {
+			log.Printf("MQTT %s: %s\n", broker, err.Error())
+			// Connection lost. Keep what the broker didn't acknowledge for the next connection, including what
+			// couldn't be published again on this one.
+			unacked = append(client.Unacknowledged(), unacked...)
+			unackedBroker = broker
+		} else {
+			unacked = nil
+		}
+	}
+}
+
+func drainMQTTQueue() {
+	for {
+		select {
+		case <-mqttQueue:
+		default:
+			return
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/b3nn0/stratux/mqtt"
+)
+
+type testPublish struct {
+	topic   string
+	payload string
+	flags   byte // QoS and retain
+}
+
+// Connects a client to a broker on the loopback interface that accepts the connection and never acknowledges
+// anything. The PUBLISH packets it receives are passed on.
+func dialTestBroker(t *testing.T, maxInflight int) (*mqtt.Client, chan testPublish) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	received := make(chan testPublish, 16)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		for {
+			header, err := r.ReadByte()
+			if err != nil {
+				return
+			}
+			length := 0
+			for shift := uint(0); ; shift += 7 {
+				b, err := r.ReadByte()
+				if err != nil {
+					return
+				}
+				length |= int(b&0x7F) << shift
+				if b&0x80 == 0 {
+					break
+				}
+			}
+			body := make([]byte, length)
+			if _, err := io.ReadFull(r, body); err != nil {
+				return
+			}
+			switch header >> 4 {
+			case mqtt.CONNECT:
+				conn.Write([]byte{mqtt.CONNACK << 4, 2, 0, 0})
+			case mqtt.PUBLISH:
+				l := int(body[0])<<8 | int(body[1])
+				payload := body[2+l:]
+				if header&0x06 != 0 {
+					payload = payload[2:] // packet identifier
+				}
+				received <- testPublish{string(body[2 : 2+l]), string(payload), header & 0x07}
+			}
+		}
+	}()
+	client, err := mqtt.Dial(ln.Addr().String(), mqtt.Options{ClientID: "stratux", MaxInflight: maxInflight})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client, received
+}
+
+func receivePublish(t *testing.T, received chan testPublish) testPublish {
+	select {
+	case p := <-received:
+		return p
+	case <-time.After(5 * time.Second):
+		t.Fatal("nothing published")
+	}
+	return testPublish{}
+}
+
+// Messages that don't fit into the inflight window are returned in order instead of being dropped.
+func TestMQTTResend(t *testing.T) {
+	saved := globalSettings.MQTTQoS
+	defer func() { globalSettings.MQTTQoS = saved }()
+	globalSettings.MQTTQoS = 1
+	client, received := dialTestBroker(t, 2)
+
+	unacked := []mqtt.Message{
+		{Topic: "stratux/status", Payload: []byte("1")},
+		{Topic: "stratux/traffic/a12345", Payload: []byte("2"), Retain: true},
+		{Topic: "stratux/situation", Payload: []byte("3"), Retain: true},
+		{Topic: "stratux/traffic/a12346", Payload: []byte("4"), Retain: true},
+	}
+	left := mqttResend(client, unacked)
+	if len(left) != 2 || left[0].Topic != "stratux/situation" || left[1].Topic != "stratux/traffic/a12346" {
+		t.Errorf("left %v", left)
+	}
+	for _, want := range []testPublish{{"stratux/status", "1", 0x02}, {"stratux/traffic/a12345", "2", 0x03}} {
+		if p := receivePublish(t, received); p != want {
+			t.Errorf("received %+v, want %+v", p, want)
+		}
+	}
+	if left := mqttResend(client, left); len(left) != 2 {
+		t.Errorf("%d left with a full inflight window", len(left))
+	}
+}
+
+func TestClearMQTTTraffic(t *testing.T) {
+	saved := globalSettings.MQTTQoS
+	defer func() { globalSettings.MQTTQoS = saved }()
+	globalSettings.MQTTQoS = 1
+	client, received := dialTestBroker(t, 1)
+	client.Publish("stratux/online", []byte("true"), 1, true) // fills the inflight window
+	receivePublish(t, received)
+
+	published := map[string]time.Time{
+		"stratux/traffic/a12345":  {},
+		"stratux/traffic/~a12346": {},
+	}
+	clearMQTTTraffic(client, published)
+	if len(published) != 0 {
+		t.Errorf("%d topics left", len(published))
+	}
+	var topics []string
+	for i := 0; i < 2; i++ {
+		p := receivePublish(t, received)
+		if p.payload != "" || p.flags != 0x01 {
+			t.Errorf("%s: payload %q flags %X, want an empty retained QoS 0 message", p.topic, p.payload, p.flags)
+		}
+		topics = append(topics, p.topic)
+	}
+	sort.Strings(topics)
+	if topics[0] != "stratux/traffic/a12345" || topics[1] != "stratux/traffic/~a12346" {
+		t.Errorf("cleared %v", topics)
+	}
+}
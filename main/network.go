@@ -41,6 +41,11 @@ const (
 	NETWORK_AHRS_GDL90     = 4
 	NETWORK_FLARM_NMEA     = 8
 	NETWORK_POSITION_FFSIM = 16
+	NETWORK_SBS            = 32
+	NETWORK_MAVLINK        = 64
+	NETWORK_COT            = 128
+	NETWORK_ASTERIX        = 256
+	NETWORK_AIS_NMEA       = 512
 	dhcp_lease_file        = "/var/lib/misc/dnsmasq.leases"
 	dhcp_lease_dir         = "/var/lib/misc/"
 	extra_hosts_file       = "/etc/stratux-static-hosts.conf"
@@ -139,6 +144,9 @@ func serialOutWatcher() {
 	for i := 0; i < 10; i++ {
 		serialDevs = append(serialDevs, fmt.Sprintf("/dev/serialout%d", i))
 		serialDevs = append(serialDevs, fmt.Sprintf("/dev/serialout_nmea%d", i))
+		serialDevs = append(serialDevs, fmt.Sprintf("/dev/serialout_mavlink%d", i))
+		serialDevs = append(serialDevs, fmt.Sprintf("/dev/serialout_asterix%d", i))
+		serialDevs = append(serialDevs, fmt.Sprintf("/dev/serialout_ais%d", i))
 	}
 
 	for {
@@ -150,9 +158,15 @@ func serialOutWatcher() {
 
 					// Master is globalSettings.SerialOutputs. Once we connect to one, it will be copied to the active connections map
 					if val, ok := globalSettings.SerialOutputs[serialDev]; !ok {
-						proto := uint8(NETWORK_GDL90_STANDARD)
+						proto := uint16(NETWORK_GDL90_STANDARD)
 						if strings.Contains(serialDev, "_nmea") {
 							proto = NETWORK_FLARM_NMEA
+						} else if strings.Contains(serialDev, "_mavlink") {
+							proto = NETWORK_MAVLINK
+						} else if strings.Contains(serialDev, "_asterix") {
+							proto = NETWORK_ASTERIX
+						} else if strings.Contains(serialDev, "_ais") {
+							proto = NETWORK_AIS_NMEA
 						}
 						if globalSettings.SerialOutputs == nil {
 							globalSettings.SerialOutputs = make(map[string]serialConnection)
@@ -312,7 +326,6 @@ func getNetworkStats() {
 
 // See who has a DHCP lease and make a UDP connection to each of them.
 func refreshConnectedClients() {
-	validConnections := make(map[string]bool)
 	t, err := getDHCPLeases()
 	if err != nil {
 		log.Printf("getDHCPLeases(): %s\n", err.Error())
@@ -322,12 +335,48 @@ func refreshConnectedClients() {
 	defer netMutex.Unlock()
 
 	dhcpLeases = t
-	// Client connected that wasn't before.
+	updateClientConnections()
+}
+
+// UDP ports to send to every client, with the capabilities of each. Outputs configured on the same port share the
+// connection. Outputs that are disabled in the settings are left out, so their connections are removed.
+func networkOutputCapabilities() map[uint32]uint16 {
+	outputs := make(map[uint32]uint16)
+	for _, networkOutput := range globalSettings.NetworkOutputs {
+		outputs[networkOutput.Port] |= networkOutput.Capability
+	}
+	if globalSettings.MAVLink_Enabled {
+		outputs[uint32(globalSettings.MAVLinkPort)] |= NETWORK_MAVLINK
+	}
+	if globalSettings.ASTERIX_Enabled {
+		outputs[uint32(globalSettings.ASTERIXPort)] |= NETWORK_ASTERIX
+	}
+	if globalSettings.AISOut_Enabled {
+		outputs[uint32(globalSettings.AISOutPort)] |= NETWORK_AIS_NMEA
+	}
+	return outputs
+}
+
+// Opens and closes the UDP connections to match dhcpLeases and the network outputs. Must be called with netMutex held.
+func updateClientConnections() {
+	validConnections := make(map[string]bool)
+	outputs := networkOutputCapabilities()
 	for ip, hostname := range dhcpLeases {
-		for _, networkOutput := range globalSettings.NetworkOutputs {
-			ipAndPort := ip + ":" + strconv.Itoa(int(networkOutput.Port))
+		for port, capability := range outputs {
+			ipAndPort := ip + ":" + strconv.Itoa(int(port))
+			if netconn, ok := clientConnections[ipAndPort]; ok {
+				if conn, ok := netconn.(*networkConnection); ok && conn.Capability != capability {
+					// Output enabled, disabled or moved to another port in the settings. Reconnect, the writer may be
+					// using the capabilities of the old connection.
+					log.Printf("client %s: capabilities changed from %d to %d.\n", ipAndPort, conn.Capability, capability)
+					conn.Queue.Close()
+					conn.Conn.Close()
+					delete(clientConnections, ipAndPort)
+				}
+			}
 			if _, ok := clientConnections[ipAndPort]; !ok {
-				log.Printf("client connected: %s:%d (%s).\n", ip, networkOutput.Port, hostname)
+				// Client connected that wasn't before.
+				log.Printf("client connected: %s:%d (%s).\n", ip, port, hostname)
 				addr, err := net.ResolveUDPAddr("udp", ipAndPort)
 				if err != nil {
 					log.Printf("ResolveUDPAddr(%s): %s\n", ipAndPort, err.Error())
@@ -341,8 +390,8 @@ func refreshConnectedClients() {
 				clientConnections[ipAndPort] = &networkConnection{
 					Conn: outConn,
 					Ip: ip,
-					Port: networkOutput.Port,
-					Capability: networkOutput.Capability,
+					Port: port,
+					Capability: capability,
 					Queue: NewMessageQueue(1024),
 				}
 				go connectionWriter(clientConnections[ipAndPort])
@@ -350,7 +399,7 @@ func refreshConnectedClients() {
 			validConnections[ipAndPort] = true
 		}
 	}
-	// Client that was connected before that isn't.
+	// Client that was connected before that isn't, or output that was disabled.
 	for ipAndPort, netconn := range clientConnections {
 		if conn, ok := netconn.(*networkConnection); ok {
 			if _, valid := validConnections[ipAndPort]; !valid {
@@ -440,7 +489,7 @@ func connectionWriter(connection connection) {
 }
 
 
-func sendMsg(msg []byte, msgType uint8, maxAge time.Duration, priority int32) {
+func sendMsg(msg []byte, msgType uint16, maxAge time.Duration, priority int32) {
 	if (msgType & NETWORK_GDL90_STANDARD) != 0 {
 		// It's a GDL90 message - do ui broadcast.
 		networkGDL90Chan <- msg
@@ -651,5 +700,10 @@ func initNetwork() {
 	go networkOutWatcher() // Pushes to websocket
 	go tcpNMEAOutListener()
 	go tcpNMEAInListener()
+	go tcpSBSOutListener()
+	go tcpAISOutListener()
+	go gdl90InListener()
+	go cotOutputWatcher()
+	go mqttPublisher()
 	go getNetworkStats()
 }
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func testUpdateClientConnections(leases map[string]string) {
+	netMutex.Lock()
+	dhcpLeases = leases
+	updateClientConnections()
+	netMutex.Unlock()
+}
+
+// Connections of a client, by port
+func testClientConnections(t *testing.T) map[uint32]*networkConnection {
+	conns := make(map[uint32]*networkConnection)
+	netMutex.Lock()
+	defer netMutex.Unlock()
+	for ipAndPort, netconn := range clientConnections {
+		conn, ok := netconn.(*networkConnection)
+		if !ok {
+			continue
+		}
+		if conn.Ip != "127.0.0.1" || conn.GetConnectionKey() != ipAndPort {
+			t.Errorf("connection %s to %s:%d", ipAndPort, conn.Ip, conn.Port)
+		}
+		conns[conn.Port] = conn
+	}
+	return conns
+}
+
+func TestUpdateClientConnections(t *testing.T) {
+	initTestTraffic()
+	saved := globalSettings
+	defer func() {
+		globalSettings = saved
+		testUpdateClientConnections(nil)
+	}()
+	globalSettings.NetworkOutputs = []networkConnection{
+		{Port: 4000, Capability: NETWORK_GDL90_STANDARD | NETWORK_AHRS_GDL90},
+		{Port: 2000, Capability: NETWORK_FLARM_NMEA},
+	}
+	globalSettings.MAVLink_Enabled = true
+	globalSettings.MAVLinkPort = 14550
+	globalSettings.ASTERIX_Enabled = true
+	globalSettings.ASTERIXPort = 8600
+	globalSettings.AISOut_Enabled = true
+	globalSettings.AISOutPort = 10111
+	leases := map[string]string{"127.0.0.1": "efb"}
+
+	testUpdateClientConnections(leases)
+	conns := testClientConnections(t)
+	want := map[uint32]uint16{
+		4000:  NETWORK_GDL90_STANDARD | NETWORK_AHRS_GDL90,
+		2000:  NETWORK_FLARM_NMEA,
+		14550: NETWORK_MAVLINK,
+		8600:  NETWORK_ASTERIX,
+		10111: NETWORK_AIS_NMEA,
+	}
+	if len(conns) != len(want) {
+		t.Errorf("%d connections, want %d", len(conns), len(want))
+	}
+	for port, capability := range want {
+		if conn, ok := conns[port]; !ok {
+			t.Errorf("port %d: no connection", port)
+		} else if conn.Capability != capability {
+			t.Errorf("port %d: capabilities %d, want %d", port, conn.Capability, capability)
+		}
+	}
+
+	// Disabled outputs are removed, the others are kept
+	globalSettings.MAVLink_Enabled = false
+	globalSettings.AISOut_Enabled = false
+	testUpdateClientConnections(leases)
+	disabled := testClientConnections(t)
+	if len(disabled) != 3 || disabled[14550] != nil || disabled[10111] != nil {
+		t.Errorf("MAVLink and AIS disabled: connections %v", disabled)
+	}
+	if !conns[14550].Queue.Closed || conns[4000].Queue.Closed || disabled[4000] != conns[4000] {
+		t.Error("MAVLink and AIS disabled: wrong connections closed")
+	}
+
+	// Output moved to a port that is already used: one connection with both capabilities
+	globalSettings.ASTERIXPort = 4000
+	testUpdateClientConnections(leases)
+	moved := testClientConnections(t)
+	if len(moved) != 2 || moved[4000].Capability != NETWORK_GDL90_STANDARD|NETWORK_AHRS_GDL90|NETWORK_ASTERIX ||
+		!conns[4000].Queue.Closed || !conns[8600].Queue.Closed {
+		t.Errorf("ASTERIX on port 4000: connections %v", moved)
+	}
+
+	// Client gone
+	testUpdateClientConnections(map[string]string{})
+	if gone := testClientConnections(t); len(gone) != 0 || !moved[4000].Queue.Closed {
+		t.Errorf("no clients: connections %v", gone)
+	}
+}
+
+// Disabled outputs are not sent to serial outputs either
+func TestSendDisabledOutput(t *testing.T) {
+	initTestTraffic()
+	saved := globalSettings
+	defer func() { globalSettings = saved }()
+	mavlink := &serialConnection{DeviceString: "/dev/serialout_mavlink0", Capability: NETWORK_MAVLINK, Queue: NewMessageQueue(16)}
+	asterix := &serialConnection{DeviceString: "/dev/serialout_asterix0", Capability: NETWORK_ASTERIX, Queue: NewMessageQueue(16)}
+	netMutex.Lock()
+	clientConnections[mavlink.DeviceString] = mavlink
+	clientConnections[asterix.DeviceString] = asterix
+	netMutex.Unlock()
+	defer func() {
+		netMutex.Lock()
+		delete(clientConnections, mavlink.DeviceString)
+		delete(clientConnections, asterix.DeviceString)
+		netMutex.Unlock()
+	}()
+
+	for _, enabled := range []bool{false, true} {
+		globalSettings.MAVLink_Enabled = enabled
+		globalSettings.ASTERIX_Enabled = enabled
+		sendMAVLink([]byte{MAVLINK_STX_V2}, time.Second, 0)
+		sendAsterix([]byte{21}, time.Second, 0)
+		queued := len(mavlink.Queue.GetQueueDump(false)) + len(asterix.Queue.GetQueueDump(false))
+		if enabled && queued != 2 || !enabled && queued != 0 {
+			t.Errorf("enabled %t: %d messages queued", enabled, queued)
+		}
+	}
+}
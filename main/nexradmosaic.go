@@ -0,0 +1,255 @@
+/*
+	Copyright (c) 2026 Stratux contributors
+	Distributable under the terms of The "BSD New" License
+	that can be found in the LICENSE file, herein included
+	as part of this header.
+
+	nexradmosaic.go: Server side NEXRAD mosaic. The global block representation products (63 regional, 64 CONUS,
+		see uatparse/nexrad.go) are assembled into one grid per product, each block with its product time and the time
+		it was received. Blocks that are not rebroadcast age out. The grids are rendered as PNG XYZ tiles on demand and
+		served through /tiles/ like the MBTiles sets, listed in /tiles/tilesets as nexrad-regional and nexrad-conus.
+*/
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/b3nn0/stratux/uatparse"
+)
+
+const (
+	NEXRAD_TILE_SIZE        = 256
+	NEXRAD_BLOCK_COLUMNS    = 32               // bins per block, west to east
+	NEXRAD_BLOCK_ROWS       = 4                // bins per block, north to south
+	NEXRAD_REGIONAL_MAX_AGE = 15 * time.Minute // regional is updated every 2.5 minutes
+	NEXRAD_CONUS_MAX_AGE    = 30 * time.Minute // CONUS every 15 minutes
+	NEXRAD_CLEANUP_INTERVAL = time.Minute
+	NEXRAD_TILE_REFRESH     = 60 // seconds, hint for the map
+	NEXRAD_TILE_MIN_ZOOM    = 3
+	NEXRAD_TILE_MAX_ZOOM    = 10
+	NEXRAD_PRODUCT_REGIONAL = 63
+	NEXRAD_PRODUCT_CONUS    = 64
+)
+
+type nexradMosaicBlock struct {
+	Block       uatparse.NEXRADBlock
+	ProductTime time.Time // UTC, from the FIS-B APDU header
+	Received    time.Time // stratuxClock
+}
+
+type nexradMosaic struct {
+	ProductID uint32
+	Tileset   string // file name in /tiles/
+	Name      string
+	MaxAge    time.Duration
+	Blocks    map[string]*nexradMosaicBlock // by scale and block position
+	Updated   time.Time                     // UTC
+}
+
+var nexradMosaics = []*nexradMosaic{
+	{ProductID: NEXRAD_PRODUCT_REGIONAL, Tileset: "nexrad-regional", Name: "NEXRAD Regional", MaxAge: NEXRAD_REGIONAL_MAX_AGE, Blocks: make(map[string]*nexradMosaicBlock)},
+	{ProductID: NEXRAD_PRODUCT_CONUS, Tileset: "nexrad-conus", Name: "NEXRAD CONUS", MaxAge: NEXRAD_CONUS_MAX_AGE, Blocks: make(map[string]*nexradMosaicBlock)},
+}
+var nexradMosaicMutex sync.Mutex
+var nexradLastCleanup time.Time // stratuxClock
+
+// Reflectivity levels 0-7 (below 5 dBZ, 5-20, 20-30, 30-40, 40-45, 45-50, 50-55, 55 and above). The two lowest
+// levels are left transparent, like on most EFBs.
+var nexradColors = []color.NRGBA{
+	{0, 0, 0, 0},
+	{0, 0, 0, 0},
+	{0, 200, 0, 160},
+	{0, 130, 0, 180},
+	{255, 255, 0, 190},
+	{255, 140, 0, 200},
+	{230, 0, 0, 210},
+	{200, 0, 200, 220},
+}
+
+func getNexradMosaic(productID uint32) *nexradMosaic {
+	for _, m := range nexradMosaics {
+		if m.ProductID == productID {
+			return m
+		}
+	}
+	return nil
+}
+
+func getNexradMosaicByTileset(tileset string) *nexradMosaic {
+	for _, m := range nexradMosaics {
+		if m.Tileset == tileset {
+			return m
+		}
+	}
+	return nil
+}
+
+// Called from parseInput() for every FIS-B frame.
+func registerNexradFrame(f *uatparse.UATFrame) {
+	if len(f.NEXRAD) == 0 {
+		return
+	}
+	m := getNexradMosaic(f.Product_id)
+	if m == nil {
+		return
+	}
+	now := time.Now().UTC()
+	productTime := uatparse.FISBTime{Format: uatparse.FISB_TIME_HOURS, Hour: uint8(f.FISB_hours), Minute: uint8(f.FISB_minutes)}.Time(now)
+
+	nexradMosaicMutex.Lock()
+	defer nexradMosaicMutex.Unlock()
+	for _, b := range f.NEXRAD {
+		if len(b.Intensity) < NEXRAD_BLOCK_COLUMNS*NEXRAD_BLOCK_ROWS {
+			continue
+		}
+		key := fmt.Sprintf("%d/%.4f/%.4f", b.Scale, b.LatNorth, b.LonWest)
+		m.Blocks[key] = &nexradMosaicBlock{Block: b, ProductTime: productTime, Received: stratuxClock.Time}
+	}
+	m.Updated = now
+
+	if stratuxClock.Since(nexradLastCleanup) > NEXRAD_CLEANUP_INTERVAL {
+		for _, mosaic := range nexradMosaics {
+			for key, b := range mosaic.Blocks {
+				if stratuxClock.Since(b.Received) > mosaic.MaxAge {
+					delete(mosaic.Blocks, key)
+				}
+			}
+		}
+		nexradLastCleanup = stratuxClock.Time
+	}
+}
+
+// Tileset metadata for handleTilesets(), in the same form as readMbTilesMetadata().
+func getNexradTilesets() map[string]map[string]string {
+	nexradMosaicMutex.Lock()
+	defer nexradMosaicMutex.Unlock()
+	result := make(map[string]map[string]string)
+	for _, m := range nexradMosaics {
+		meta := map[string]string{
+			"name":            m.Name,
+			"format":          "png",
+			"type":            "overlay",
+			"minzoom":         fmt.Sprintf("%d", NEXRAD_TILE_MIN_ZOOM),
+			"maxzoom":         fmt.Sprintf("%d", NEXRAD_TILE_MAX_ZOOM),
+			"description":     fmt.Sprintf("FIS-B %s reflectivity received over UAT", m.Name),
+			"stratux_refresh": fmt.Sprintf("%d", NEXRAD_TILE_REFRESH),
+			"stratux_blocks":  fmt.Sprintf("%d", len(m.Blocks)),
+		}
+		if !m.Updated.IsZero() {
+			meta["stratux_updated"] = m.Updated.Format(time.RFC3339)
+		}
+		result[m.Tileset] = meta
+	}
+	return result
+}
+
+// Web mercator pixel position of lon/lat at zoom z
+func nexradPixel(z int, lon, lat float64) (float64, float64) {
+	scale := float64(NEXRAD_TILE_SIZE) * math.Exp2(float64(z))
+	lat = math.Max(-85.0511, math.Min(85.0511, lat))
+	sin := math.Sin(lat * math.Pi / 180)
+	x := (lon + 180) / 360 * scale
+	y := (0.5 - math.Log((1+sin)/(1-sin))/(4*math.Pi)) * scale
+	return x, y
+}
+
+// Renders the tile z/x/y (XYZ scheme, y counted from the north) of the mosaic. Older and coarser blocks are drawn
+// first so newer and finer data ends up on top.
+func renderNexradTile(m *nexradMosaic, z, x, y int) ([]byte, error) {
+	west, north := float64(x*NEXRAD_TILE_SIZE), float64(y*NEXRAD_TILE_SIZE)
+
+	nexradMosaicMutex.Lock()
+	blocks := make([]*nexradMosaicBlock, 0)
+	for _, b := range m.Blocks {
+		if stratuxClock.Since(b.Received) > m.MaxAge {
+			continue
+		}
+		x0, y0 := nexradPixel(z, b.Block.LonWest, b.Block.LatNorth)
+		x1, y1 := nexradPixel(z, b.Block.LonWest+b.Block.Width, b.Block.LatNorth-b.Block.Height)
+		if x1 < west || x0 > west+NEXRAD_TILE_SIZE || y1 < north || y0 > north+NEXRAD_TILE_SIZE {
+			continue
+		}
+		blocks = append(blocks, b)
+	}
+	nexradMosaicMutex.Unlock()
+
+	if len(blocks) == 0 {
+		return nexradEmptyTilePNG()
+	}
+	sort.Slice(blocks, func(i, j int) bool {
+		if blocks[i].Block.Scale != blocks[j].Block.Scale {
+			return blocks[i].Block.Scale > blocks[j].Block.Scale
+		}
+		return blocks[i].Received.Before(blocks[j].Received)
+	})
+
+	img := image.NewNRGBA(image.Rect(0, 0, NEXRAD_TILE_SIZE, NEXRAD_TILE_SIZE))
+	for _, mb := range blocks {
+		b := mb.Block
+		binWidth := b.Width / NEXRAD_BLOCK_COLUMNS
+		binHeight := b.Height / NEXRAD_BLOCK_ROWS
+		for row := 0; row < NEXRAD_BLOCK_ROWS; row++ {
+			for col := 0; col < NEXRAD_BLOCK_COLUMNS; col++ {
+				level := b.Intensity[row*NEXRAD_BLOCK_COLUMNS+col]
+				if int(level) >= len(nexradColors) {
+					continue
+				}
+				lon := b.LonWest + float64(col)*binWidth
+				lat := b.LatNorth - float64(row)*binHeight
+				px0, py0 := nexradPixel(z, lon, lat)
+				px1, py1 := nexradPixel(z, lon+binWidth, lat-binHeight)
+				fillNexradBin(img, px0-west, py0-north, px1-west, py1-north, nexradColors[level])
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Fills the pixels touched by a bin, at least one pixel. Transparent levels clear what older blocks drew, but only
+// the pixels they cover completely, so small bins without precipitation don't hide their neighbours at low zoom.
+func fillNexradBin(img *image.NRGBA, x0, y0, x1, y1 float64, c color.NRGBA) {
+	ix0, iy0 := int(math.Floor(x0)), int(math.Floor(y0))
+	ix1, iy1 := int(math.Ceil(x1)), int(math.Ceil(y1))
+	if c.A == 0 {
+		ix0, iy0 = int(math.Ceil(x0)), int(math.Ceil(y0))
+		ix1, iy1 = int(math.Floor(x1)), int(math.Floor(y1))
+		if ix1 <= ix0 || iy1 <= iy0 {
+			return
+		}
+	}
+	if ix1 <= ix0 {
+		ix1 = ix0 + 1
+	}
+	if iy1 <= iy0 {
+		iy1 = iy0 + 1
+	}
+	r := image.Rect(ix0, iy0, ix1, iy1).Intersect(img.Rect)
+	for py := r.Min.Y; py < r.Max.Y; py++ {
+		for px := r.Min.X; px < r.Max.X; px++ {
+			img.SetNRGBA(px, py, c)
+		}
+	}
+}
+
+// Transparent tile, so the map doesn't treat areas without data as errors
+func nexradEmptyTilePNG() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, NEXRAD_TILE_SIZE, NEXRAD_TILE_SIZE))); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -336,7 +336,7 @@ func importOgnTrafficMessage(msg OgnMessage, data string, fakeCurrentTime bool)
 
 	traffic[key] = ti
 	postProcessTraffic(&ti)
-	registerTrafficUpdate(ti)
+	registerTrafficUpdate(key, ti)
 	seenTraffic[key] = true
 
 	if globalSettings.DEBUG {
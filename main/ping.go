@@ -465,7 +465,7 @@ func mavLinkFormat(x []byte) {
 		ti.SignalLevel = float64(signalLevelSimulated)
 		postProcessTraffic(&ti)
 		traffic[ti.Icao_addr] = ti
-		registerTrafficUpdate(ti)
+		registerTrafficUpdate(ti.Icao_addr, ti)
 		seenTraffic[ti.Icao_addr] = true
 		trafficMutex.Unlock()
 	}
@@ -0,0 +1,231 @@
+/*
+	Copyright (c) 2026 Stratux contributors
+	Distributable under the terms of The "BSD New" License
+	that can be found in the LICENSE file, herein included
+	as part of this header.
+
+	readsbjson.go: Current traffic picture in the readsb / dump1090-fa aircraft.json and receiver.json schema, so that
+		map frontends (tar1090, dump1090 skyview) and log scrapers can be used with Stratux.
+		See https://github.com/wiedehopf/readsb/blob/dev/README-json.md
+*/
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+type ReadsbAircraft struct {
+	Hex       string      `json:"hex"`
+	Type      string      `json:"type"`
+	Flight    string      `json:"flight,omitempty"`
+	R         string      `json:"r,omitempty"`        // registration, readsb with aircraft database
+	T         string      `json:"t,omitempty"`        // ICAO type designator
+	AltBaro   interface{} `json:"alt_baro,omitempty"` // feet or "ground"
+	AltGeom   *int32      `json:"alt_geom,omitempty"`
+	Gs        *float64    `json:"gs,omitempty"`
+	Track     *float64    `json:"track,omitempty"`
+	BaroRate  *int16      `json:"baro_rate,omitempty"`
+	GeomRate  *int16      `json:"geom_rate,omitempty"`
+	Squawk    string      `json:"squawk,omitempty"`
+	Emergency string      `json:"emergency,omitempty"`
+	Category  string      `json:"category,omitempty"`
+	Lat       *float64    `json:"lat,omitempty"`
+	Lon       *float64    `json:"lon,omitempty"`
+	Nic       *int        `json:"nic,omitempty"`
+	NacP      *int        `json:"nac_p,omitempty"`
+	Messages  uint64      `json:"messages"`
+	Seen      float64     `json:"seen"`
+	SeenPos   *float64    `json:"seen_pos,omitempty"`
+	Rssi      float64     `json:"rssi"`
+	Mlat      []string    `json:"mlat"`
+	Tisb      []string    `json:"tisb"`
+}
+
+type ReadsbAircraftList struct {
+	Now      float64          `json:"now"`
+	Messages uint64           `json:"messages"`
+	Aircraft []ReadsbAircraft `json:"aircraft"`
+}
+
+type ReadsbReceiver struct {
+	Version string   `json:"version"`
+	Refresh int      `json:"refresh"` // ms
+	History int      `json:"history"`
+	Lat     *float64 `json:"lat,omitempty"`
+	Lon     *float64 `json:"lon,omitempty"`
+}
+
+// readsb address types. Non-ICAO addresses are prefixed with '~' in the hex field.
+func readsbAddressType(ti TrafficInfo) (addrType string, icao bool) {
+	switch ti.Last_source {
+	case TRAFFIC_SOURCE_1090ES, TRAFFIC_SOURCE_UAT, TRAFFIC_SOURCE_GDL90:
+		icao = ti.Addr_type == 0 || ti.Addr_type == 2
+		switch ti.TargetType {
+		case TARGET_TYPE_MODE_S:
+			return "mode_s", true
+		case TARGET_TYPE_ADSB:
+			if icao {
+				return "adsb_icao", true
+			}
+			return "adsb_other", false
+		case TARGET_TYPE_ADSR:
+			if icao {
+				return "adsr_icao", true
+			}
+			return "adsr_other", false
+		case TARGET_TYPE_TISB_S:
+			return "tisb_icao", true
+		case TARGET_TYPE_TISB:
+			if ti.Addr_type == 3 {
+				return "tisb_trackfile", false
+			}
+			if icao {
+				return "tisb_icao", true
+			}
+			return "tisb_other", false
+		}
+	case TRAFFIC_SOURCE_OGN:
+		return "other", ti.Addr_type == 0 // FLARM / OGN with ICAO address
+	}
+	return "other", false
+}
+
+// GDL90 emitter category to the ADS-B category set/code, e.g. 1 => "A1", 9 => "B1"
+func readsbCategory(cat uint8) string {
+	if cat == 0 || cat > 39 {
+		return ""
+	}
+	return fmt.Sprintf("%c%d", 'A'+cat/8, cat%8)
+}
+
+func readsbEmergency(status uint8) string {
+	switch status {
+	case EMERGENCY_GENERAL:
+		return "general"
+	case EMERGENCY_MEDICAL:
+		return "lifeguard"
+	case EMERGENCY_MINIMUM_FUEL:
+		return "minfuel"
+	case EMERGENCY_NO_COMMUNICATION:
+		return "nordo"
+	case EMERGENCY_UNLAWFUL_INTERFERENCE:
+		return "unlawful"
+	case EMERGENCY_DOWNED_AIRCRAFT:
+		return "downed"
+	}
+	return "none"
+}
+
+func makeReadsbAircraft(ti TrafficInfo) ReadsbAircraft {
+	addrType, icao := readsbAddressType(ti)
+	ac := ReadsbAircraft{
+		Type:      addrType,
+		Hex:       fmt.Sprintf("%06x", ti.Icao_addr&0xFFFFFF),
+		Flight:    strings.TrimSpace(ti.Tail),
+		R:         ti.Reg,
+		T:         ti.AircraftType,
+		Emergency: readsbEmergency(ti.Emergency),
+		Category:  readsbCategory(ti.Emitter_category),
+		Messages:  ti.ReceivedMsgs,
+		Rssi:      ti.SignalLevel,
+		Mlat:      []string{},
+		Tisb:      []string{},
+	}
+	if !icao {
+		ac.Hex = "~" + ac.Hex
+	}
+	if len(ac.Flight) > 0 && len(ac.Flight) < 8 {
+		ac.Flight = fmt.Sprintf("%-8s", ac.Flight) // readsb pads to 8 characters
+	}
+	if ti.Squawk != 0 {
+		ac.Squawk = fmt.Sprintf("%04d", ti.Squawk)
+	}
+
+	lastSeen := ti.Last_seen
+	if ti.Last_alt.After(lastSeen) {
+		lastSeen = ti.Last_alt
+	}
+	ac.Seen = stratuxClock.Since(lastSeen).Seconds()
+
+	if ti.OnGround {
+		ac.AltBaro = "ground"
+	} else if ti.Alt != 0 {
+		alt := ti.Alt
+		if ti.AltIsGNSS {
+			ac.AltGeom = &alt
+		} else {
+			ac.AltBaro = alt
+			if ti.GnssDiffFromBaroAlt != 0 {
+				geom := ti.Alt + ti.GnssDiffFromBaroAlt
+				ac.AltGeom = &geom
+			}
+		}
+	}
+	if ti.Speed_valid {
+		gs := float64(ti.Speed)
+		track := float64(ti.Track)
+		vvel := ti.Vvel
+		ac.Gs = &gs
+		ac.Track = &track
+		if ti.AltIsGNSS {
+			ac.GeomRate = &vvel
+		} else {
+			ac.BaroRate = &vvel
+		}
+	}
+	if ti.Position_valid {
+		lat := float64(ti.Lat)
+		lon := float64(ti.Lng)
+		seenPos := stratuxClock.Since(ti.Last_seen).Seconds()
+		ac.Lat = &lat
+		ac.Lon = &lon
+		ac.SeenPos = &seenPos
+	}
+	if ti.Last_source == TRAFFIC_SOURCE_1090ES || ti.Last_source == TRAFFIC_SOURCE_UAT || ti.Last_source == TRAFFIC_SOURCE_GDL90 {
+		nic := ti.NIC
+		nacp := ti.NACp
+		ac.Nic = &nic
+		ac.NacP = &nacp
+	}
+	if ti.TargetType == TARGET_TYPE_TISB || ti.TargetType == TARGET_TYPE_TISB_S {
+		ac.Tisb = []string{"lat", "lon", "altitude", "gs", "track"}
+	}
+	return ac
+}
+
+// Returns all current aircraft. Tracks that were fused into another target are reported as part of that target.
+func getReadsbAircraftList() ReadsbAircraftList {
+	trafficMutex.Lock()
+	defer trafficMutex.Unlock()
+	list := ReadsbAircraftList{
+		Now:      float64(time.Now().UnixNano()) / 1e9,
+		Aircraft: make([]ReadsbAircraft, 0, len(traffic)),
+	}
+	for key, ti := range traffic {
+		list.Messages += ti.ReceivedMsgs
+		if ti.Fused || ti.Last_source == TRAFFIC_SOURCE_AIS {
+			continue // AIS: vessels, and the 9 digit MMSI doesn't fit into the 24 bit hex address
+		}
+		list.Aircraft = append(list.Aircraft, makeReadsbAircraft(fuseTrafficInfo(key, ti)))
+	}
+	return list
+}
+
+func getReadsbReceiver() ReadsbReceiver {
+	receiver := ReadsbReceiver{
+		Version: fmt.Sprintf("Stratux %s", stratuxVersion),
+		Refresh: 1000,
+		History: 0,
+	}
+	if isGPSValid() {
+		// Rounded, like readsb does for privacy
+		lat := float64(int(mySituation.GPSLatitude*100)) / 100
+		lon := float64(int(mySituation.GPSLongitude*100)) / 100
+		receiver.Lat = &lat
+		receiver.Lon = &lon
+	}
+	return receiver
+}
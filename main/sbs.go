@@ -0,0 +1,216 @@
+/*
+	Copyright (c) 2026 Stratux contributors
+	Distributable under the terms of The "BSD New" License
+	that can be found in the LICENSE file, herein included
+	as part of this header.
+
+	sbs.go: SBS-1 BaseStation output ("port 30003" format) for Virtual Radar Server, PlanePlotter and similar tools.
+		Generated from the traffic map, so it also contains UAT and OGN targets. AIS targets are left out, their 9 digit
+		MMSI doesn't fit into the 24 bit hex address.
+		See notes/SBS-Description-Doc_SRT_47_rev01_20111024.pdf
+*/
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	SBS_PORT            = 30003
+	SBS_RESEND_INTERVAL = 10 * time.Second // identification and squawk are repeated this often, even if unchanged
+
+	SBS_ES_IDENT         = 1 // ES identification and category
+	SBS_ES_SURFACE_POS   = 2
+	SBS_ES_AIRBORNE_POS  = 3
+	SBS_ES_AIRBORNE_VEL  = 4
+	SBS_SURVEILLANCE_ALT = 5 // DF4, DF20
+	SBS_SURVEILLANCE_ID  = 6 // DF5, DF21
+	SBS_AIR_TO_AIR       = 7 // DF0, DF16
+	SBS_ALL_CALL_REPLY   = 8 // DF11
+)
+
+// What was last sent for a target, so that only new information is sent. Protected by trafficMutex.
+type sbsTargetState struct {
+	lastPosition time.Time // stratuxClock times of the data last sent
+	lastVelocity time.Time
+	lastAlt      time.Time
+	lastIdent    time.Time
+	lastSquawk   time.Time
+	tail         string
+	squawk       int
+}
+
+var sbsTargets map[uint32]*sbsTargetState = make(map[uint32]*sbsTargetState)
+
+func sbsBool(b bool) string {
+	if b {
+		return "-1"
+	}
+	return "0"
+}
+
+/*
+	makeSBSMessage() formats one MSG line. Fields that are not part of the given transmission type are left empty,
+		as described in the SBS document: callsign (1), altitude (2, 3, 5, 6, 7), ground speed and track (2, 4),
+		position (2, 3), vertical rate (4), squawk (6), flags (3, 5, 6; ground flag for all but 1 and 4).
+*/
+func makeSBSMessage(msgType int, ti TrafficInfo, generated time.Time, squawkChanged bool) string {
+	logged := time.Now().UTC()
+	generated = generated.UTC()
+	fields := make([]string, 22)
+	fields[0] = "MSG"
+	fields[1] = fmt.Sprintf("%d", msgType)
+	fields[2] = "1" // session ID
+	fields[3] = "1" // aircraft ID
+	fields[4] = fmt.Sprintf("%06X", ti.Icao_addr&0xFFFFFF)
+	fields[5] = "1" // flight ID
+	fields[6] = generated.Format("2006/01/02")
+	fields[7] = generated.Format("15:04:05.000")
+	fields[8] = logged.Format("2006/01/02")
+	fields[9] = logged.Format("15:04:05.000")
+
+	switch msgType {
+	case SBS_ES_IDENT:
+		fields[10] = strings.TrimSpace(ti.Tail)
+	case SBS_ES_SURFACE_POS, SBS_ES_AIRBORNE_POS:
+		fields[11] = fmt.Sprintf("%d", ti.Alt)
+		if msgType == SBS_ES_SURFACE_POS && ti.Speed_valid {
+			fields[12] = fmt.Sprintf("%d", ti.Speed)
+			fields[13] = fmt.Sprintf("%.0f", ti.Track)
+		}
+		fields[14] = fmt.Sprintf("%.5f", ti.Lat)
+		fields[15] = fmt.Sprintf("%.5f", ti.Lng)
+	case SBS_ES_AIRBORNE_VEL:
+		fields[12] = fmt.Sprintf("%d", ti.Speed)
+		fields[13] = fmt.Sprintf("%.0f", ti.Track)
+		fields[16] = fmt.Sprintf("%d", ti.Vvel)
+	case SBS_SURVEILLANCE_ALT, SBS_AIR_TO_AIR:
+		fields[11] = fmt.Sprintf("%d", ti.Alt)
+	case SBS_SURVEILLANCE_ID:
+		if ti.Alt != 0 {
+			fields[11] = fmt.Sprintf("%d", ti.Alt)
+		}
+		fields[17] = fmt.Sprintf("%04d", ti.Squawk)
+	}
+
+	switch msgType {
+	case SBS_ES_AIRBORNE_POS, SBS_SURVEILLANCE_ALT, SBS_SURVEILLANCE_ID:
+		fields[18] = sbsBool(squawkChanged) // alert: squawk has changed
+		fields[19] = sbsBool(ti.Emergency != EMERGENCY_NONE)
+		fields[20] = "0" // SPI / ident not decoded
+	}
+	if msgType != SBS_ES_IDENT && msgType != SBS_ES_AIRBORNE_VEL {
+		fields[21] = sbsBool(ti.OnGround)
+	}
+	return strings.Join(fields, ",") + "\r\n"
+}
+
+/*
+	sendSBSTrafficUpdate() is called from sendTrafficUpdates() for every current target and sends SBS messages for
+		everything that was received since the last call. Must be called with trafficMutex held.
+*/
+func sendSBSTrafficUpdate(key uint32, ti TrafficInfo, priority int32) {
+	if ti.Last_source == TRAFFIC_SOURCE_AIS {
+		return
+	}
+	state, ok := sbsTargets[key]
+	if !ok {
+		state = &sbsTargetState{}
+		sbsTargets[key] = state
+	}
+	send := func(msgType int, generated time.Time, squawkChanged bool) {
+		sendSBS(makeSBSMessage(msgType, ti, generated, squawkChanged), time.Second, priority)
+	}
+
+	if len(ti.Tail) > 0 && (ti.Tail != state.tail || stratuxClock.Since(state.lastIdent) >= SBS_RESEND_INTERVAL) {
+		send(SBS_ES_IDENT, ti.Timestamp, false)
+		state.tail = ti.Tail
+		state.lastIdent = stratuxClock.Time
+	}
+
+	squawkChanged := state.squawk != 0 && ti.Squawk != state.squawk
+	if ti.Squawk != 0 && (ti.Squawk != state.squawk || stratuxClock.Since(state.lastSquawk) >= SBS_RESEND_INTERVAL) {
+		send(SBS_SURVEILLANCE_ID, ti.Timestamp, squawkChanged)
+		state.squawk = ti.Squawk
+		state.lastSquawk = stratuxClock.Time
+	}
+
+	if ti.Position_valid && !ti.ExtrapolatedPosition && ti.Last_seen.After(state.lastPosition) {
+		if ti.OnGround {
+			send(SBS_ES_SURFACE_POS, ti.Timestamp, false)
+		} else {
+			send(SBS_ES_AIRBORNE_POS, ti.Timestamp, squawkChanged)
+		}
+		state.lastPosition = ti.Last_seen
+		state.lastAlt = ti.Last_alt
+	}
+
+	if ti.Speed_valid && !ti.OnGround && ti.Last_speed.After(state.lastVelocity) {
+		send(SBS_ES_AIRBORNE_VEL, ti.Timestamp, false)
+		state.lastVelocity = ti.Last_speed
+	}
+
+	// Mode S targets without position: report the surveillance reply type that was received last
+	if !ti.Position_valid && ti.Last_source == TRAFFIC_SOURCE_1090ES && ti.Last_alt.After(state.lastAlt) {
+		switch ti.Last_DF {
+		case 0, 16:
+			send(SBS_AIR_TO_AIR, ti.Timestamp, false)
+		case 11:
+			send(SBS_ALL_CALL_REPLY, ti.Timestamp, false)
+		case 5, 21:
+			// already covered by MSG,6
+		default:
+			if ti.Alt != 0 {
+				send(SBS_SURVEILLANCE_ALT, ti.Timestamp, squawkChanged)
+			}
+		}
+		state.lastAlt = ti.Last_alt
+	}
+}
+
+// Drops state of targets that are gone. Must be called with trafficMutex held.
+func cleanupSBSTargets() {
+	for key := range sbsTargets {
+		if _, ok := traffic[key]; !ok {
+			delete(sbsTargets, key)
+		}
+	}
+}
+
+func sendSBS(msg string, maxAge time.Duration, priority int32) {
+	sendMsg([]byte(msg), NETWORK_SBS, maxAge, priority)
+}
+
+// TCP port 30003 for SBS-1 BaseStation output
+func tcpSBSOutListener() {
+	ln, err := net.Listen("tcp", fmt.Sprintf(":%d", SBS_PORT))
+	if err != nil {
+		log.Printf("SBS output: %s\n", err.Error())
+		return
+	}
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			log.Printf("SBS output: %s\n", err.Error())
+			continue
+		}
+		key := "TCP:" + conn.RemoteAddr().String()
+
+		tcpConn := &tcpConnection{
+			conn.(*net.TCPConn),
+			NewMessageQueue(1024),
+			NETWORK_SBS,
+			key,
+		}
+		netMutex.Lock()
+		clientConnections[tcpConn.GetConnectionKey()] = tcpConn
+		netMutex.Unlock()
+		go connectionWriter(tcpConn)
+	}
+}
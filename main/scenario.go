@@ -0,0 +1,557 @@
+/*
+	Copyright (c) 2026 Stratux contributors
+	Distributable under the terms of The "BSD New" License
+	that can be found in the LICENSE file, herein included
+	as part of this header.
+
+	scenario.go: Traffic scenario engine for testing EFBs and reproducing bug reports. A scenario (JSON) describes
+		targets with a start position relative to ownship, heading/speed/climb segments or waypoints, squawk changes
+		and signal loss windows. Targets are encoded as dump1090 JSON (ES), UAT downlink frames (UAT) or ogn-rx JSON (OGN)
+		and injected through the same parsers as real traffic.
+		The simulation advances in fixed one second steps, so a scenario always produces the same traffic.
+*/
+
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/b3nn0/stratux/common"
+)
+
+const (
+	SCENARIO_DIR = STRATUX_HOME + "scenarios/"
+)
+
+// Absolute position (Lat/Lng set) or position relative to the scenario origin (Bearing / Distance in NM).
+// Altitude is Alt (feet MSL) if set, otherwise the origin altitude + RelAlt.
+type ScenarioPosition struct {
+	Lat      float64
+	Lng      float64
+	Bearing  float64
+	Distance float64
+	Alt      float64
+	RelAlt   float64
+	Speed    float64 // knots. For waypoints: speed on the leg to this waypoint. 0 = keep current speed
+	OnGround *bool   // For waypoints: applied when the waypoint is reached, e.g. touchdown
+}
+
+// Flight segment. Track, Speed, Climb and OnGround are applied at the start of the segment if set, TurnRate (deg/s,
+// positive = right) during the segment.
+type ScenarioSegment struct {
+	Duration float64 // seconds
+	Track    *float64
+	Speed    *float64 // knots
+	Climb    *float64 // feet per minute
+	TurnRate float64
+	OnGround *bool
+}
+
+type ScenarioSquawkChange struct {
+	Time   float64 // seconds after scenario start
+	Squawk int
+}
+
+type ScenarioWindow struct {
+	Start float64 // seconds after scenario start
+	End   float64
+}
+
+type ScenarioTarget struct {
+	Icao          string // hex address
+	Tail          string
+	Source        string // "ES" (default), "UAT" or "OGN"
+	Category      int    // GDL90 emitter category
+	Squawk        int
+	OnGround      bool
+	Appear        float64 // seconds after scenario start
+	Start         ScenarioPosition
+	Track         float64 // initial track, speed (knots) and climb (fpm)
+	Speed         float64
+	Climb         float64
+	Segments      []ScenarioSegment
+	Waypoints     []ScenarioPosition // used instead of segments if set
+	SquawkChanges []ScenarioSquawkChange
+	SignalLoss    []ScenarioWindow // no messages are injected during these windows
+}
+
+type Scenario struct {
+	Name        string
+	Description string
+	Origin      *ScenarioPosition // absolute. Default: ownship position at scenario start (or KOSH without GPS)
+	Duration    float64           // seconds. 0 = run until stopped
+	Loop        bool
+	Targets     []ScenarioTarget
+}
+
+type ScenarioStatus struct {
+	Running bool
+	Name    string
+	Elapsed float64 // simulated seconds
+	Targets int
+}
+
+type scenarioTargetState struct {
+	target   *ScenarioTarget
+	icao     uint32
+	lat      float64
+	lng      float64
+	alt      float64 // feet
+	track    float64
+	speed    float64 // knots
+	climb    float64 // fpm
+	turnRate float64
+	onGround bool
+	squawk   int
+	segment  int
+	segTime  float64
+	waypoint int
+}
+
+var scenarioMutex sync.Mutex
+var scenarioStatus ScenarioStatus
+var scenarioStop chan bool
+
+// Reads a scenario from SCENARIO_DIR, with or without the .json extension. Used for names from the web interface,
+// so anything that looks like a path is rejected.
+func loadNamedScenario(name string) (*Scenario, error) {
+	if len(name) == 0 || strings.ContainsAny(name, "/\\") || strings.Contains(name, "..") {
+		return nil, fmt.Errorf("invalid scenario name %s", name)
+	}
+	filename := SCENARIO_DIR + name
+	if !strings.HasSuffix(filename, ".json") {
+		filename += ".json"
+	}
+	return loadScenarioFile(filename)
+}
+
+// Reads a scenario from a file (command line). Names without a path are looked up in SCENARIO_DIR.
+func loadScenarioFile(name string) (*Scenario, error) {
+	if !strings.Contains(name, "/") {
+		return loadNamedScenario(name)
+	}
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return parseScenario(data)
+}
+
+func parseScenario(data []byte) (*Scenario, error) {
+	var scenario Scenario
+	if err := json.Unmarshal(data, &scenario); err != nil {
+		return nil, err
+	}
+	if len(scenario.Targets) == 0 {
+		return nil, fmt.Errorf("scenario has no targets")
+	}
+	for i, t := range scenario.Targets {
+		if _, err := strconv.ParseUint(t.Icao, 16, 24); err != nil {
+			return nil, fmt.Errorf("target %d: invalid address %s", i, t.Icao)
+		}
+		switch strings.ToUpper(t.Source) {
+		case "", "ES", "UAT", "OGN":
+		default:
+			return nil, fmt.Errorf("target %d: unsupported source %s", i, t.Source)
+		}
+	}
+	return &scenario, nil
+}
+
+// Stops a running scenario and starts the given one.
+func startScenario(scenario *Scenario) {
+	stopScenario()
+	scenarioMutex.Lock()
+	defer scenarioMutex.Unlock()
+	scenarioStop = make(chan bool)
+	scenarioStatus = ScenarioStatus{Running: true, Name: scenario.Name, Targets: len(scenario.Targets)}
+	log.Printf("Starting traffic scenario %s with %d targets\n", scenario.Name, len(scenario.Targets))
+	go runScenario(scenario, scenarioStop)
+}
+
+func stopScenario() {
+	scenarioMutex.Lock()
+	defer scenarioMutex.Unlock()
+	if scenarioStop != nil {
+		close(scenarioStop)
+		scenarioStop = nil
+	}
+	scenarioStatus.Running = false
+}
+
+func getScenarioStatus() ScenarioStatus {
+	scenarioMutex.Lock()
+	defer scenarioMutex.Unlock()
+	return scenarioStatus
+}
+
+func scenarioOrigin(scenario *Scenario) ScenarioPosition {
+	if scenario.Origin != nil {
+		return *scenario.Origin
+	}
+	// default location is Oshkosh if GPS not detected, like updateDemoTraffic()
+	origin := ScenarioPosition{Lat: 43.99, Lng: -88.56}
+	if isGPSValid() {
+		origin.Lat = float64(mySituation.GPSLatitude)
+		origin.Lng = float64(mySituation.GPSLongitude)
+		origin.Alt = float64(mySituation.GPSAltitudeMSL)
+		if isTempPressValid() {
+			origin.Alt = float64(mySituation.BaroPressureAltitude)
+		}
+	}
+	return origin
+}
+
+func resolveScenarioPosition(origin, pos ScenarioPosition) (lat, lng, alt float64) {
+	if pos.Lat != 0 || pos.Lng != 0 {
+		lat, lng = pos.Lat, pos.Lng
+	} else {
+		lat, lng = calcLocationForBearingDistance(origin.Lat, origin.Lng, pos.Bearing, pos.Distance)
+	}
+	if pos.Alt != 0 {
+		alt = pos.Alt
+	} else {
+		alt = origin.Alt + pos.RelAlt
+	}
+	return
+}
+
+func newScenarioTargetState(origin ScenarioPosition, t *ScenarioTarget) *scenarioTargetState {
+	icao, _ := strconv.ParseUint(t.Icao, 16, 24)
+	s := &scenarioTargetState{
+		target:   t,
+		icao:     uint32(icao),
+		track:    t.Track,
+		speed:    t.Speed,
+		climb:    t.Climb,
+		onGround: t.OnGround,
+		squawk:   t.Squawk,
+	}
+	s.lat, s.lng, s.alt = resolveScenarioPosition(origin, t.Start)
+	if len(t.Segments) > 0 {
+		s.applySegment(t.Segments[0])
+	}
+	return s
+}
+
+func (s *scenarioTargetState) applySegment(seg ScenarioSegment) {
+	if seg.Track != nil {
+		s.track = *seg.Track
+	}
+	if seg.Speed != nil {
+		s.speed = *seg.Speed
+	}
+	if seg.Climb != nil {
+		s.climb = *seg.Climb
+	}
+	if seg.OnGround != nil {
+		s.onGround = *seg.OnGround
+	}
+	s.turnRate = seg.TurnRate
+}
+
+// Advances the target by dt seconds. t is the scenario time at the end of the step.
+func (s *scenarioTargetState) step(origin ScenarioPosition, t, dt float64) {
+	target := s.target
+	if len(target.Waypoints) > 0 {
+		s.turnRate = 0
+		if s.waypoint < len(target.Waypoints) {
+			wp := target.Waypoints[s.waypoint]
+			if wp.Speed > 0 {
+				s.speed = wp.Speed
+			}
+			wpLat, wpLng, wpAlt := resolveScenarioPosition(origin, wp)
+			dist, bearing := common.Distance(s.lat, s.lng, wpLat, wpLng)
+			distNm := dist / 1852.0
+			s.track = bearing
+			if s.speed > 0 {
+				timeToGo := distNm / s.speed * 3600
+				if timeToGo > dt {
+					s.climb = (wpAlt - s.alt) / timeToGo * 60
+				} else {
+					s.climb = 0
+					s.alt = wpAlt
+					if wp.OnGround != nil {
+						s.onGround = *wp.OnGround
+					}
+					s.waypoint++
+				}
+			}
+		} else {
+			s.climb = 0
+		}
+	} else if s.segment < len(target.Segments) {
+		s.segTime += dt
+		if s.segTime >= target.Segments[s.segment].Duration {
+			s.segment++
+			s.segTime = 0
+			if s.segment < len(target.Segments) {
+				s.applySegment(target.Segments[s.segment])
+			} else {
+				s.turnRate = 0
+			}
+		}
+	}
+
+	s.track = math.Mod(s.track+s.turnRate*dt+360, 360)
+	s.lat, s.lng = calcLocationForBearingDistance(s.lat, s.lng, s.track, s.speed*dt/3600)
+	if !s.onGround {
+		s.alt += s.climb * dt / 60
+	}
+
+	for _, change := range target.SquawkChanges {
+		if t >= change.Time && t-dt < change.Time {
+			s.squawk = change.Squawk
+		}
+	}
+}
+
+func (s *scenarioTargetState) visible(t float64) bool {
+	if t < s.target.Appear {
+		return false
+	}
+	for _, w := range s.target.SignalLoss {
+		if t >= w.Start && t < w.End {
+			return false
+		}
+	}
+	return true
+}
+
+// Vertical speed in the unit of the dump1090 / UAT encodings, signed
+func (s *scenarioTargetState) vvel() int16 {
+	if s.onGround {
+		return 0
+	}
+	return int16(s.climb)
+}
+
+// Encodes the target as dump1090 JSON (DF17 airborne / surface position) and feeds it to parseDump1090Message().
+func (s *scenarioTargetState) injectES() {
+	lat := float32(s.lat)
+	lng := float32(s.lng)
+	alt := int(s.alt)
+	nacp := 9
+	speed := uint16(s.speed)
+	track := uint16(s.track)
+	vvel := s.vvel()
+	onGround := s.onGround
+	category := s.target.Category
+	squawk := s.squawk
+	tail := s.target.Tail
+	data := dump1090Data{
+		Icao_addr:        s.icao,
+		DF:               17,
+		CA:               5,
+		TypeCode:         11,
+		SignalLevel:      0.1,
+		Squawk:           &squawk,
+		Emitter_category: &category,
+		OnGround:         &onGround,
+		Lat:              &lat,
+		Lng:              &lng,
+		Position_valid:   true,
+		NACp:             &nacp,
+		Alt:              &alt,
+		Vvel:             &vvel,
+		Speed_valid:      true,
+		Speed:            &speed,
+		Track:            &track,
+		Timestamp:        time.Now().UTC(),
+	}
+	if len(tail) > 0 {
+		data.Tail = &tail
+	}
+	if onGround {
+		data.TypeCode = 6
+	}
+	buf, _ := json.Marshal(data)
+	parseDump1090Message(string(buf))
+}
+
+const scenarioBase40Alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ  .."
+
+func base40Index(c byte) uint16 {
+	idx := strings.IndexByte(scenarioBase40Alphabet, c)
+	if idx < 0 {
+		return 36 // space
+	}
+	return uint16(idx)
+}
+
+/*
+	Encodes the target as long UAT ADS-B frame (payload type 1: state vector, mode status, AUXSV) and feeds it to
+		parseDownlinkReport(). The mode status alternates between callsign and squawk, like real transmitters do.
+*/
+func (s *scenarioTargetState) injectUAT(t float64) {
+	frame := make([]byte, 34)
+	frame[0] = 1 << 3 // payload type 1, address qualifier 0 (ADS-B, ICAO)
+	frame[1] = byte(s.icao >> 16)
+	frame[2] = byte(s.icao >> 8)
+	frame[3] = byte(s.icao)
+
+	lat := s.lat
+	if lat < 0 {
+		lat += 180
+	}
+	lng := s.lng
+	if lng < 0 {
+		lng += 360
+	}
+	rawLat := uint32(lat*16777216.0/360.0) & 0x7FFFFF
+	rawLng := uint32(lng*16777216.0/360.0) & 0xFFFFFF
+	frame[4] = byte(rawLat >> 15)
+	frame[5] = byte(rawLat >> 7)
+	frame[6] = byte(rawLat<<1) | byte(rawLng>>23)&0x01
+	frame[7] = byte(rawLng >> 15)
+	frame[8] = byte(rawLng >> 7)
+	frame[9] = byte(rawLng << 1) // baro altitude
+
+	rawAlt := uint32((s.alt+1000)/25) + 1
+	frame[10] = byte(rawAlt >> 4)
+	frame[11] = byte(rawAlt<<4) | 8 // NIC 8
+
+	if s.onGround {
+		rawGs := uint16(s.speed) + 1
+		rawTrack := uint16(1<<9) | uint16(s.track*512/360)&0x1FF // true track
+		frame[12] = 2<<6 | byte(rawGs>>6)&0x1F
+		frame[13] = byte(rawGs<<2) | byte(rawTrack>>9)&0x03
+		frame[14] = byte(rawTrack >> 1)
+		frame[15] = byte(rawTrack<<7) & 0x80
+	} else {
+		ns := s.speed * math.Cos(common.Radians(s.track))
+		ew := s.speed * math.Sin(common.Radians(s.track))
+		rawNs := uint16(math.Abs(math.Round(ns))) + 1
+		if ns < 0 {
+			rawNs |= 0x400
+		}
+		rawEw := uint16(math.Abs(math.Round(ew))) + 1
+		if ew < 0 {
+			rawEw |= 0x400
+		}
+		vvel := float64(s.vvel())
+		rawVvel := uint16(0x400) | (uint16(math.Abs(vvel)/64)+1)&0x1FF // baro
+		if vvel < 0 {
+			rawVvel |= 0x200
+		}
+		frame[12] = byte(rawNs>>6) & 0x1F
+		frame[13] = byte(rawNs<<2) | byte(rawEw>>9)&0x03
+		frame[14] = byte(rawEw >> 1)
+		frame[15] = byte(rawEw<<7)&0x80 | byte(rawVvel>>4)&0x7F
+		frame[16] = byte(rawVvel << 4)
+	}
+
+	// Mode status
+	category := uint16(s.target.Category)
+	useSquawk := s.squawk != 0 && int(t)%2 == 1
+	if useSquawk || len(s.target.Tail) == 0 {
+		sq := fmt.Sprintf("%04d", s.squawk)
+		v := category*1600 + base40Index(sq[0])*40 + base40Index(sq[1])
+		frame[17], frame[18] = byte(v>>8), byte(v)
+		v = base40Index(sq[2])*1600 + base40Index(sq[3])*40
+		frame[19], frame[20] = byte(v>>8), byte(v)
+	} else {
+		cs := fmt.Sprintf("%-8s", strings.ToUpper(s.target.Tail))
+		v := category*1600 + base40Index(cs[0])*40 + base40Index(cs[1])
+		frame[17], frame[18] = byte(v>>8), byte(v)
+		v = base40Index(cs[2])*1600 + base40Index(cs[3])*40 + base40Index(cs[4])
+		frame[19], frame[20] = byte(v>>8), byte(v)
+		v = base40Index(cs[5])*1600 + base40Index(cs[6])*40 + base40Index(cs[7])
+		frame[21], frame[22] = byte(v>>8), byte(v)
+		frame[26] = 1 << 1 // CSID: callsign
+	}
+	frame[23] = emergencyStatusFromSquawk(s.squawk)<<5 | 2<<2 | 3 // emergency status, UAT version 2, SIL 3
+	frame[25] = 9<<4 | 1<<1                                      // NACp 9, NACv 1
+
+	parseDownlinkReport("-"+hex.EncodeToString(frame), 500)
+}
+
+// Encodes the target as ogn-rx JSON and feeds it to parseOgnMessage()
+func (s *scenarioTargetState) injectOGN() {
+	msg := OgnMessage{
+		Sys:       "FLR",
+		Time:      float64(time.Now().UTC().Unix()),
+		Addr:      fmt.Sprintf("%06X", s.icao),
+		Addr_type: 2,
+		Acft_cat:  fmt.Sprintf("%02X", s.target.Category),
+		Reg:       s.target.Tail,
+		Lat_deg:   float32(s.lat),
+		Lon_deg:   float32(s.lng),
+		Alt_msl_m: float32(s.alt / 3.28084),
+		Track_deg: s.track,
+		Speed_mps: s.speed / 1.94384,
+		Climb_mps: float64(s.vvel()) / 196.85,
+		Turn_dps:  s.turnRate,
+		SNR_dB:    10,
+	}
+	buf, _ := json.Marshal(msg)
+	parseOgnMessage(string(buf), false)
+}
+
+func (s *scenarioTargetState) inject(t float64) {
+	switch strings.ToUpper(s.target.Source) {
+	case "UAT":
+		s.injectUAT(t)
+	case "OGN":
+		s.injectOGN()
+	default:
+		s.injectES()
+	}
+}
+
+// Advances the targets to the scenario time t (one second after the last call) and injects their messages.
+func stepScenarioTargets(origin ScenarioPosition, states []*scenarioTargetState, t float64) {
+	for _, s := range states {
+		if t > 0 {
+			s.step(origin, t, 1)
+		}
+		if s.visible(t) {
+			s.inject(t)
+		}
+	}
+}
+
+func runScenario(scenario *Scenario, stop chan bool) {
+	ticker := time.NewTicker(1 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		origin := scenarioOrigin(scenario)
+		states := make([]*scenarioTargetState, len(scenario.Targets))
+		for i := range scenario.Targets {
+			states[i] = newScenarioTargetState(origin, &scenario.Targets[i])
+		}
+
+		for t := 0.0; scenario.Duration <= 0 || t <= scenario.Duration; t++ {
+			stepScenarioTargets(origin, states, t)
+			scenarioMutex.Lock()
+			scenarioStatus.Elapsed = t
+			scenarioMutex.Unlock()
+
+			select {
+			case <-stop:
+				log.Printf("Traffic scenario %s stopped\n", scenario.Name)
+				return
+			case <-ticker.C:
+			}
+		}
+		if !scenario.Loop {
+			break
+		}
+	}
+	log.Printf("Traffic scenario %s finished\n", scenario.Name)
+	scenarioMutex.Lock()
+	if scenarioStop == stop {
+		scenarioStatus.Running = false
+	}
+	scenarioMutex.Unlock()
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"sync"
+	"testing"
+)
+
+// Sets up the traffic map and the network output without any clients, like the trace replay mode.
+func initTestTraffic() {
+	if stratuxClock == nil {
+		stratuxClock = NewMonotonic()
+		networkGDL90Chan = make(chan []byte, 1024)
+		go func() {
+			for range networkGDL90Chan {
+			}
+		}()
+		clientConnections = make(map[string]connection)
+		netMutex = &sync.Mutex{}
+	}
+	initTraffic(true)
+	trafficTrackers = make(map[uint32]*targetTracker)
+	fusionLinks = make(map[uint32]uint32)
+	fusionGroups = make(map[uint32][]uint32)
+	emergencyEpisodes = make(map[uint32]*EmergencyEpisode)
+}
+
+// Runs the bundled emergency scenario: the 1090ES target only signals its emergency with squawk 7700, the UAT target
+// with squawk 7600 through the emergency/priority status.
+func TestScenarioEmergency(t *testing.T) {
+	scenario, err := loadScenarioFile("../test-data/scenarios/emergency-ground.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	initTestTraffic()
+	origin := ScenarioPosition{Lat: 43.99, Lng: -88.56, Alt: 800}
+	states := make([]*scenarioTargetState, len(scenario.Targets))
+	for i := range scenario.Targets {
+		states[i] = newScenarioTargetState(origin, &scenario.Targets[i])
+	}
+
+	type emergencyStart struct {
+		t         float64
+		emergency uint8
+		squawk    int
+	}
+	starts := make(map[uint32]emergencyStart)
+	for sec := 0.0; sec <= scenario.Duration; sec++ {
+		n := trafficEventCount()
+		stepScenarioTargets(origin, states, sec)
+		sendTrafficUpdates()
+
+		trafficWatchMutex.Lock()
+		for _, ev := range trafficEventLog[n:] {
+			if ev.Type == TRAFFIC_EVENT_EMERGENCY_END {
+				t.Errorf("%X: emergency ended at %.0f s", ev.Icao_addr, sec)
+			}
+			if _, ok := starts[ev.Icao_addr]; !ok && ev.Type == TRAFFIC_EVENT_EMERGENCY {
+				starts[ev.Icao_addr] = emergencyStart{sec, ev.Emergency, ev.Squawk}
+			}
+		}
+		trafficWatchMutex.Unlock()
+	}
+
+	want := map[uint32]emergencyStart{
+		0xABC003: {250, EMERGENCY_GENERAL, 7700},
+		0xABC004: {120, EMERGENCY_NO_COMMUNICATION, 0}, // UAT doesn't transmit the squawk code
+	}
+	for icao, w := range want {
+		got, ok := starts[icao]
+		if !ok {
+			t.Errorf("%X: no emergency event", icao)
+			continue
+		}
+		if got != w {
+			t.Errorf("%X: emergency %d squawk %d at %.0f s, want %d squawk %d at %.0f s", icao, got.emergency,
+				got.squawk, got.t, w.emergency, w.squawk, w.t)
+		}
+	}
+	if len(starts) != len(want) {
+		t.Errorf("emergencies of %d targets, want %d", len(starts), len(want))
+	}
+}
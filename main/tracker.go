@@ -0,0 +1,513 @@
+/*
+	Copyright (c) 2026 Stratux contributors
+	Distributable under the terms of The "BSD New" License
+	that can be found in the LICENSE file, herein included
+	as part of this header.
+
+	tracker.go: Per-target state estimation. A coordinated turn Kalman filter smoothes incoming position and velocity
+		reports, rejects outliers (e.g. out of order 1090ES position decodes) and coasts targets between reports with a
+		growing position uncertainty. The filter state is kept apart from the traffic map, which holds the raw reports.
+		Only the copies of the tracks that are sent to the outputs carry the estimate.
+*/
+
+package main
+
+import (
+	"log"
+	"math"
+	"time"
+
+	"github.com/b3nn0/stratux/common"
+)
+
+const (
+	TRACKER_ACCEL_NOISE      = 3.0   // m/s², horizontal maneuvering noise
+	TRACKER_VS_ACCEL_NOISE   = 5.0   // ft/s², vertical maneuvering noise
+	TRACKER_VEL_NOISE        = 2.0   // m/s, 1 sigma of a reported ground speed vector
+	TRACKER_ALT_NOISE        = 25.0  // ft, 1 sigma of a reported altitude
+	TRACKER_VS_NOISE         = 3.0   // ft/s, 1 sigma of a reported vertical speed
+	TRACKER_GATE_POS         = 13.8  // chi² 99.9% for 2 degrees of freedom
+	TRACKER_GATE_POS_VEL     = 18.5  // chi² 99.9% for 4 degrees of freedom
+	TRACKER_MAX_REJECTS      = 3     // re-initialize the track after this many consecutive outliers
+	TRACKER_MAX_COAST        = 30.0  // seconds without update after which a track is re-initialized on the next fix
+	TRACKER_TURN_RATE_SMOOTH = 0.3   // exponential smoothing factor for the turn rate estimated from the velocity vector
+	TRACKER_MAX_TURN_RATE    = 9.0   // deg/s. Anything faster is noise.
+	EARTH_RADIUS_METERS      = 6371000.0
+)
+
+/*
+	targetTracker is the filter state of one target. The horizontal state is [east, v_east, north, v_north] in meters
+		and m/s on a flat-earth plane around refLat/refLng. Altitude is filtered separately with state [alt, vs] in ft
+		and ft/s.
+*/
+type targetTracker struct {
+	refLat, refLng float64
+	x              []float64   // horizontal state
+	P              [][]float64 // horizontal covariance
+	alt            []float64   // vertical state
+	altP           [][]float64 // vertical covariance
+	altValid       bool
+	turnRate       float64 // deg/s, positive = right turn
+	lastHeading    float64
+	headingValid   bool
+	t              time.Time // stratuxClock time of the current state
+
+	// Last report fed into the filter. Used to tell a new report from one that was already applied.
+	fixLat, fixLng float32
+	lastAltTime    time.Time
+	lastSpeedTime  time.Time
+	lastFixTime    time.Time
+	rejects        int
+}
+
+// Protected by trafficMutex, keyed like the traffic map.
+var trafficTrackers map[uint32]*targetTracker = make(map[uint32]*targetTracker)
+
+// Measurement noise (1 sigma, meters) from the reported NACp. NACp bounds the 95% estimated position uncertainty.
+func trackerPositionNoise(ti *TrafficInfo) float64 {
+	epu := 0.0
+	switch {
+	case ti.NACp >= 11:
+		epu = 3
+	case ti.NACp == 10:
+		epu = 10
+	case ti.NACp == 9:
+		epu = 30
+	case ti.NACp == 8:
+		epu = 92.6
+	case ti.NACp == 7:
+		epu = 185.2
+	case ti.NACp == 6:
+		epu = 555.6
+	case ti.NACp == 5:
+		epu = 926
+	case ti.NACp >= 1:
+		epu = 1852 * math.Pow(2, float64(4-ti.NACp))
+	default:
+		if ti.Last_source == TRAFFIC_SOURCE_OGN {
+			epu = 30 // FLARM/OGN don't report NACp but are based on a GNSS fix
+		} else {
+			epu = 185.2
+		}
+	}
+	return epu / 2
+}
+
+// Maps a 95% position uncertainty in meters to a NACp value (see DO-260B / AC 20-165A).
+func nacpForUncertainty(epu float64) int {
+	limits := []float64{18520, 7408, 3704, 1852, 926, 555.6, 185.2, 92.6, 30, 10, 3}
+	nacp := 0
+	for i, limit := range limits {
+		if epu < limit {
+			nacp = i + 1
+		}
+	}
+	return nacp
+}
+
+func (trk *targetTracker) toLocal(lat, lng float64) (east, north float64) {
+	north = common.Radians(lat-trk.refLat) * EARTH_RADIUS_METERS
+	east = common.Radians(lng-trk.refLng) * EARTH_RADIUS_METERS * math.Cos(common.Radians(trk.refLat))
+	return
+}
+
+func (trk *targetTracker) toLatLng(east, north float64) (lat, lng float64) {
+	lat = trk.refLat + common.Degrees(north/EARTH_RADIUS_METERS)
+	lng = trk.refLng + common.Degrees(east/(EARTH_RADIUS_METERS*math.Cos(common.Radians(trk.refLat))))
+	return
+}
+
+func newTargetTracker(ti *TrafficInfo, t time.Time) *targetTracker {
+	trk := &targetTracker{refLat: float64(ti.Lat), refLng: float64(ti.Lng), t: t, lastFixTime: t}
+	posVar := math.Pow(trackerPositionNoise(ti), 2)
+	velVar := 100.0 * 100.0 // unknown velocity, up to ~200kt
+	trk.x = []float64{0, 0, 0, 0}
+	if ti.Speed_valid {
+		trk.x[1], trk.x[3] = speedTrackToVelocity(float64(ti.Speed), float64(ti.Track))
+		velVar = TRACKER_VEL_NOISE * TRACKER_VEL_NOISE
+		trk.lastSpeedTime = ti.Last_speed
+	}
+	trk.P = matDiag([]float64{posVar, velVar, posVar, velVar})
+	trk.turnRate = float64(ti.TurnRate)
+	trk.fixLat, trk.fixLng = ti.Lat, ti.Lng
+	if ti.Alt != 0 {
+		trk.alt = []float64{float64(ti.Alt), float64(ti.Vvel) / 60}
+		trk.altP = matDiag([]float64{TRACKER_ALT_NOISE * TRACKER_ALT_NOISE, 30 * 30})
+		trk.altValid = true
+		trk.lastAltTime = ti.Last_alt
+	}
+	return trk
+}
+
+func speedTrackToVelocity(speedKts, trackDeg float64) (vEast, vNorth float64) {
+	speed := speedKts * 0.514444
+	vEast = speed * math.Sin(common.Radians(trackDeg))
+	vNorth = speed * math.Cos(common.Radians(trackDeg))
+	return
+}
+
+// Propagates the state to time t using a coordinated turn model with the current turn rate estimate.
+func (trk *targetTracker) predict(t time.Time) {
+	dt := t.Sub(trk.t).Seconds()
+	if dt <= 0 {
+		return
+	}
+	omega := common.Radians(trk.turnRate)
+	var sinTerm, cosTerm float64 // sin(wt)/w and (1-cos(wt))/w
+	if math.Abs(omega) < 1e-4 {
+		sinTerm = dt
+		cosTerm = 0
+	} else {
+		sinTerm = math.Sin(omega*dt) / omega
+		cosTerm = (1 - math.Cos(omega*dt)) / omega
+	}
+	s := math.Sin(omega * dt)
+	c := math.Cos(omega * dt)
+	F := [][]float64{
+		{1, sinTerm, 0, cosTerm},
+		{0, c, 0, s},
+		{0, -cosTerm, 1, sinTerm},
+		{0, -s, 0, c},
+	}
+	q := TRACKER_ACCEL_NOISE * TRACKER_ACCEL_NOISE
+	dt2 := dt * dt / 2 * q
+	dt3 := dt * dt * dt / 3 * q
+	Q := [][]float64{
+		{dt3, dt2, 0, 0},
+		{dt2, dt * q, 0, 0},
+		{0, 0, dt3, dt2},
+		{0, 0, dt2, dt * q},
+	}
+	trk.x = matVec(F, trk.x)
+	trk.P = matAdd(matMul(matMul(F, trk.P), matTranspose(F)), Q)
+
+	if trk.altValid {
+		Fa := [][]float64{{1, dt}, {0, 1}}
+		qa := TRACKER_VS_ACCEL_NOISE * TRACKER_VS_ACCEL_NOISE
+		Qa := [][]float64{{dt * dt * dt / 3 * qa, dt * dt / 2 * qa}, {dt * dt / 2 * qa, dt * qa}}
+		trk.alt = matVec(Fa, trk.alt)
+		trk.altP = matAdd(matMul(matMul(Fa, trk.altP), matTranspose(Fa)), Qa)
+	}
+	trk.t = t
+}
+
+/*
+	kalmanUpdate() applies measurement z with observation matrix H and noise R to state x / covariance P.
+		If gate > 0 and the measurement's Mahalanobis distance exceeds it, the update is skipped and false is returned.
+*/
+func kalmanUpdate(x []float64, P [][]float64, z []float64, H, R [][]float64, gate float64) ([]float64, [][]float64, bool) {
+	y := matVecSub(z, matVec(H, x))
+	S := matAdd(matMul(matMul(H, P), matTranspose(H)), R)
+	Sinv, ok := matInverse(S)
+	if !ok {
+		return x, P, false
+	}
+	if gate > 0 {
+		d2 := 0.0
+		Sy := matVec(Sinv, y)
+		for i := range y {
+			d2 += y[i] * Sy[i]
+		}
+		if d2 > gate {
+			return x, P, false
+		}
+	}
+	K := matMul(matMul(P, matTranspose(H)), Sinv)
+	Ky := matVec(K, y)
+	newX := make([]float64, len(x))
+	for i := range x {
+		newX[i] = x[i] + Ky[i]
+	}
+	I := matDiag(make([]float64, len(x)))
+	for i := range I {
+		I[i][i] = 1
+	}
+	newP := matMul(matAdd(I, matScale(matMul(K, H), -1)), P)
+	return newX, newP, true
+}
+
+// Incorporates a new position (and velocity, if updated) report. Returns false if it was rejected as an outlier.
+func (trk *targetTracker) updatePosition(ti *TrafficInfo, t time.Time) bool {
+	east, north := trk.toLocal(float64(ti.Lat), float64(ti.Lng))
+	posVar := math.Pow(trackerPositionNoise(ti), 2)
+	velVar := TRACKER_VEL_NOISE * TRACKER_VEL_NOISE
+
+	var accepted bool
+	if ti.Speed_valid && ti.Last_speed.After(trk.lastSpeedTime) {
+		vEast, vNorth := speedTrackToVelocity(float64(ti.Speed), float64(ti.Track))
+		H := [][]float64{{1, 0, 0, 0}, {0, 1, 0, 0}, {0, 0, 1, 0}, {0, 0, 0, 1}}
+		R := matDiag([]float64{posVar, velVar, posVar, velVar})
+		trk.x, trk.P, accepted = kalmanUpdate(trk.x, trk.P, []float64{east, vEast, north, vNorth}, H, R, TRACKER_GATE_POS_VEL)
+		if accepted {
+			trk.lastSpeedTime = ti.Last_speed
+		}
+	} else {
+		H := [][]float64{{1, 0, 0, 0}, {0, 0, 1, 0}}
+		R := matDiag([]float64{posVar, posVar})
+		trk.x, trk.P, accepted = kalmanUpdate(trk.x, trk.P, []float64{east, north}, H, R, TRACKER_GATE_POS)
+	}
+	if !accepted {
+		return false
+	}
+	trk.updateTurnRate(ti, t)
+	trk.lastFixTime = t
+	return true
+}
+
+func (trk *targetTracker) updateAltitude(ti *TrafficInfo) {
+	trk.lastAltTime = ti.Last_alt
+	if !trk.altValid {
+		trk.alt = []float64{float64(ti.Alt), float64(ti.Vvel) / 60}
+		trk.altP = matDiag([]float64{TRACKER_ALT_NOISE * TRACKER_ALT_NOISE, 30 * 30})
+		trk.altValid = true
+		return
+	}
+	H := [][]float64{{1, 0}, {0, 1}}
+	R := matDiag([]float64{TRACKER_ALT_NOISE * TRACKER_ALT_NOISE, TRACKER_VS_NOISE * TRACKER_VS_NOISE})
+	trk.alt, trk.altP, _ = kalmanUpdate(trk.alt, trk.altP, []float64{float64(ti.Alt), float64(ti.Vvel) / 60}, H, R, 0)
+}
+
+// Turn rate as reported by the source, or estimated from the change of the filtered velocity vector.
+func (trk *targetTracker) updateTurnRate(ti *TrafficInfo, t time.Time) {
+	speed := math.Hypot(trk.x[1], trk.x[3])
+	heading := math.Mod(common.Degrees(math.Atan2(trk.x[1], trk.x[3]))+360, 360)
+	if ti.TurnRate != 0 {
+		trk.turnRate = float64(ti.TurnRate)
+	} else if speed > 10 && trk.headingValid {
+		dt := t.Sub(trk.lastFixTime).Seconds()
+		if dt <= 0 {
+			dt = 1
+		}
+		diff := heading - trk.lastHeading
+		if diff > 180 {
+			diff -= 360
+		} else if diff < -180 {
+			diff += 360
+		}
+		rate := math.Max(-TRACKER_MAX_TURN_RATE, math.Min(TRACKER_MAX_TURN_RATE, diff/dt))
+		trk.turnRate = trk.turnRate*(1-TRACKER_TURN_RATE_SMOOTH) + rate*TRACKER_TURN_RATE_SMOOTH
+	}
+	trk.lastHeading = heading
+	trk.headingValid = speed > 10
+}
+
+// 95% horizontal position uncertainty in meters
+func (trk *targetTracker) positionUncertainty() float64 {
+	return 2 * math.Sqrt(trk.P[0][0]+trk.P[2][2])
+}
+
+/*
+	updateTracker() feeds a new report of the given target into its filter, if there is one since the last call.
+		ti is the raw track from the traffic map and is not modified. Must be called with trafficMutex held.
+*/
+func updateTracker(key uint32, ti *TrafficInfo) {
+	trk, ok := trafficTrackers[key]
+	newFix := !ok || ti.Lat != trk.fixLat || ti.Lng != trk.fixLng
+	newAlt := ti.Alt != 0 && ok && ti.Last_alt.After(trk.lastAltTime)
+
+	if newFix {
+		// Reports are timestamped with their time of receipt. Out of order reports are applied at the current filter time.
+		fixTime := ti.Last_seen
+		if ok && fixTime.Before(trk.t) {
+			fixTime = trk.t
+		}
+		if !ok || fixTime.Sub(trk.lastFixTime).Seconds() > TRACKER_MAX_COAST {
+			trk = newTargetTracker(ti, fixTime)
+			trafficTrackers[key] = trk
+		} else {
+			trk.fixLat, trk.fixLng = ti.Lat, ti.Lng
+			trk.predict(fixTime)
+			if trk.updatePosition(ti, fixTime) {
+				trk.rejects = 0
+			} else {
+				trk.rejects++
+				if globalSettings.DEBUG {
+					log.Printf("Tracker %X: rejected position report %f,%f (%d in a row)\n", ti.Icao_addr, ti.Lat, ti.Lng, trk.rejects)
+				}
+				if trk.rejects >= TRACKER_MAX_REJECTS {
+					trk = newTargetTracker(ti, fixTime)
+					trafficTrackers[key] = trk
+				}
+			}
+		}
+	}
+	if newAlt {
+		trk.predict(ti.Last_alt)
+		trk.updateAltitude(ti)
+	}
+	if globalSettings.DEBUG && newFix {
+		lat, lng := trk.toLatLng(trk.x[0], trk.x[2])
+		log.Printf("Tracker %X: fix %f,%f -> %f,%f, uncertainty %.1fm, turn rate %.1f deg/s\n", ti.Icao_addr, ti.Lat, ti.Lng, lat, lng, trk.positionUncertainty(), trk.turnRate)
+	}
+}
+
+// Filter state propagated to time t, without changing the state of trk.
+func (trk *targetTracker) predicted(t time.Time) targetTracker {
+	est := *trk // predict() replaces the state slices, so a shallow copy is enough
+	est.predict(t)
+	return est
+}
+
+/*
+	applyTrackerEstimate() replaces position, altitude and track of ti with the tracker estimate for the current time.
+		Only used on copies that are sent to the outputs, the traffic map keeps the raw reports. The raw position is
+		kept in Lat_fix, Lng_fix and Alt_fix. Must be called with trafficMutex held.
+*/
+func applyTrackerEstimate(key uint32, ti *TrafficInfo) {
+	trk, ok := trafficTrackers[key]
+	if !ok || !ti.Position_valid {
+		return
+	}
+	ti.Lat_fix = ti.Lat
+	ti.Lng_fix = ti.Lng
+	ti.Alt_fix = ti.Alt
+	est := trk.predicted(stratuxClock.Time)
+	lat, lng := est.toLatLng(est.x[0], est.x[2])
+	ti.Lat = float32(lat)
+	ti.Lng = float32(lng)
+	if est.altValid {
+		ti.Alt = int32(est.alt[0])
+	}
+	ti.PositionUncertainty = est.positionUncertainty()
+	if ti.Speed_valid && math.Hypot(est.x[1], est.x[3]) > 2.5 {
+		ti.Track = float32(math.Mod(common.Degrees(math.Atan2(est.x[1], est.x[3]))+360, 360))
+	}
+	if ti.Speed_valid && stratuxClock.Since(ti.Last_seen).Seconds() >= 2 {
+		// No report for a while, we are coasting the target
+		ti.ExtrapolatedPosition = true
+		ti.Last_extrapolation = stratuxClock.Time
+	}
+}
+
+// Raw track of the given target with the tracker estimate applied. Must be called with trafficMutex held.
+func estimatedTrafficInfo(key uint32) (TrafficInfo, bool) {
+	ti, ok := traffic[key]
+	if ok {
+		applyTrackerEstimate(key, &ti)
+	}
+	return ti, ok
+}
+
+func cleanupTrackers() {
+	for key := range trafficTrackers {
+		if _, ok := traffic[key]; !ok {
+			delete(trafficTrackers, key)
+		}
+	}
+}
+
+// Small dense matrix helpers for the filter
+
+func matDiag(d []float64) [][]float64 {
+	m := make([][]float64, len(d))
+	for i := range d {
+		m[i] = make([]float64, len(d))
+		m[i][i] = d[i]
+	}
+	return m
+}
+
+func matMul(a, b [][]float64) [][]float64 {
+	res := make([][]float64, len(a))
+	for i := range a {
+		res[i] = make([]float64, len(b[0]))
+		for j := range b[0] {
+			for k := range b {
+				res[i][j] += a[i][k] * b[k][j]
+			}
+		}
+	}
+	return res
+}
+
+func matTranspose(a [][]float64) [][]float64 {
+	res := make([][]float64, len(a[0]))
+	for i := range res {
+		res[i] = make([]float64, len(a))
+		for j := range a {
+			res[i][j] = a[j][i]
+		}
+	}
+	return res
+}
+
+func matAdd(a, b [][]float64) [][]float64 {
+	res := make([][]float64, len(a))
+	for i := range a {
+		res[i] = make([]float64, len(a[i]))
+		for j := range a[i] {
+			res[i][j] = a[i][j] + b[i][j]
+		}
+	}
+	return res
+}
+
+func matScale(a [][]float64, f float64) [][]float64 {
+	res := make([][]float64, len(a))
+	for i := range a {
+		res[i] = make([]float64, len(a[i]))
+		for j := range a[i] {
+			res[i][j] = a[i][j] * f
+		}
+	}
+	return res
+}
+
+func matVec(a [][]float64, v []float64) []float64 {
+	res := make([]float64, len(a))
+	for i := range a {
+		for j := range v {
+			res[i] += a[i][j] * v[j]
+		}
+	}
+	return res
+}
+
+func matVecSub(a, b []float64) []float64 {
+	res := make([]float64, len(a))
+	for i := range a {
+		res[i] = a[i] - b[i]
+	}
+	return res
+}
+
+// Gauss-Jordan elimination with partial pivoting
+func matInverse(a [][]float64) ([][]float64, bool) {
+	n := len(a)
+	m := make([][]float64, n)
+	for i := range a {
+		m[i] = make([]float64, 2*n)
+		copy(m[i], a[i])
+		m[i][n+i] = 1
+	}
+	for col := 0; col < n; col++ {
+		pivot := col
+		for row := col + 1; row < n; row++ {
+			if math.Abs(m[row][col]) > math.Abs(m[pivot][col]) {
+				pivot = row
+			}
+		}
+		if math.Abs(m[pivot][col]) < 1e-12 {
+			return nil, false
+		}
+		m[col], m[pivot] = m[pivot], m[col]
+		div := m[col][col]
+		for j := range m[col] {
+			m[col][j] /= div
+		}
+		for row := 0; row < n; row++ {
+			if row == col {
+				continue
+			}
+			f := m[row][col]
+			for j := range m[row] {
+				m[row][j] -= f * m[col][j]
+			}
+		}
+	}
+	res := make([][]float64, n)
+	for i := range m {
+		res[i] = m[i][n:]
+	}
+	return res, true
+}
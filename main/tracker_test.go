@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bufio"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/b3nn0/stratux/common"
+)
+
+// Synthetic target flying straight and then turning, reporting its position and velocity once per second.
+type turnScenario struct {
+	start      time.Time
+	lat, lng   float64
+	speedKts   float64
+	straight   int // seconds before the turn starts
+	turnRate   float64
+	turnLength int        // seconds
+	noise      *rand.Rand // adds position noise with a 1 sigma of 10m (NACp 9) to the reports if set
+}
+
+func (s turnScenario) truth(sec int) (lat, lng, track float64) {
+	speed := s.speedKts * 0.514444
+	east, north, heading := 0.0, 0.0, 0.0
+	for i := 0; i < sec; i++ {
+		rate := 0.0
+		if i >= s.straight && i < s.straight+s.turnLength {
+			rate = s.turnRate
+		}
+		// integrate in small steps so the truth is accurate during the turn
+		for j := 0; j < 100; j++ {
+			east += speed * math.Sin(common.Radians(heading)) * 0.01
+			north += speed * math.Cos(common.Radians(heading)) * 0.01
+			heading += rate * 0.01
+		}
+	}
+	lat = s.lat + common.Degrees(north/EARTH_RADIUS_METERS)
+	lng = s.lng + common.Degrees(east/(EARTH_RADIUS_METERS*math.Cos(common.Radians(s.lat))))
+	return lat, lng, math.Mod(heading+360, 360)
+}
+
+func (s turnScenario) report(sec int) TrafficInfo {
+	lat, lng, track := s.truth(sec)
+	if s.noise != nil {
+		lat += common.Degrees(s.noise.NormFloat64() * 10 / EARTH_RADIUS_METERS)
+		lng += common.Degrees(s.noise.NormFloat64() * 10 / (EARTH_RADIUS_METERS * math.Cos(common.Radians(s.lat))))
+	}
+	t := s.start.Add(time.Duration(sec) * time.Second)
+	return TrafficInfo{
+		Lat:         float32(lat),
+		Lng:         float32(lng),
+		Alt:         3000,
+		NACp:        9,
+		Speed:       uint16(s.speedKts),
+		Speed_valid: true,
+		Track:       float32(track),
+		Last_seen:   t,
+		Last_speed:  t,
+		Last_alt:    t,
+	}
+}
+
+func trackerError(trk *targetTracker, lat, lng float64) float64 {
+	east, north := trk.toLocal(lat, lng)
+	return math.Hypot(trk.x[0]-east, trk.x[2]-north)
+}
+
+func TestTrackerCoordinatedTurn(t *testing.T) {
+	s := turnScenario{
+		start:      time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC),
+		lat:        47.0,
+		lng:        8.0,
+		speedKts:   120,
+		straight:   10,
+		turnRate:   3, // standard rate
+		turnLength: 60,
+		noise:      rand.New(rand.NewSource(1)),
+	}
+	first := s.report(0)
+	trk := newTargetTracker(&first, first.Last_seen)
+
+	end := s.straight + s.turnLength/2
+	for sec := 1; sec <= end; sec++ {
+		ti := s.report(sec)
+		trk.predict(ti.Last_seen)
+		if !trk.updatePosition(&ti, ti.Last_seen) {
+			t.Fatalf("report at %ds rejected", sec)
+		}
+	}
+	if math.Abs(trk.turnRate-s.turnRate) > 0.5 {
+		t.Errorf("turn rate %.2f deg/s, want %.1f", trk.turnRate, s.turnRate)
+	}
+
+	// Coast through the turn for 10 seconds without reports. The coordinated turn model keeps the target on the arc,
+	// where straight dead reckoning would be off by ~160m.
+	coast := 10
+	est := trk.predicted(s.start.Add(time.Duration(end+coast) * time.Second))
+	lat, lng, _ := s.truth(end + coast)
+	if e := trackerError(&est, lat, lng); e > 50 {
+		t.Errorf("coasted position off by %.0fm after %ds", e, coast)
+	}
+	if est.positionUncertainty() <= trk.positionUncertainty() {
+		t.Errorf("uncertainty didn't grow while coasting: %.1fm -> %.1fm", trk.positionUncertainty(), est.positionUncertainty())
+	}
+	if !trk.t.Equal(s.start.Add(time.Duration(end) * time.Second)) {
+		t.Errorf("predicted() changed the filter state")
+	}
+}
+
+func TestTrackerRejectsOutlier(t *testing.T) {
+	s := turnScenario{
+		start:    time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC),
+		lat:      47.0,
+		lng:      8.0,
+		speedKts: 120,
+		straight: 60,
+	}
+	first := s.report(0)
+	trk := newTargetTracker(&first, first.Last_seen)
+	for sec := 1; sec <= 20; sec++ {
+		ti := s.report(sec)
+		trk.predict(ti.Last_seen)
+		trk.updatePosition(&ti, ti.Last_seen)
+	}
+
+	// A position decoded with the wrong reference, 5nm off
+	bad := s.report(21)
+	bad.Lat += float32(common.Degrees(5 * 1852 / EARTH_RADIUS_METERS))
+	trk.predict(bad.Last_seen)
+	if trk.updatePosition(&bad, bad.Last_seen) {
+		t.Errorf("outlier accepted")
+	}
+	lat, lng, _ := s.truth(21)
+	if e := trackerError(trk, lat, lng); e > 50 {
+		t.Errorf("position off by %.0fm after outlier", e)
+	}
+}
+
+// Replays the messages of one aircraft from a recorded 1090ES log (SBS format with nanosecond timestamps) through
+// registerTrafficUpdate(), like the 1090ES parser does. Each new position has to go into the tracker right away, and
+// the tracker has to predict the next one.
+func TestTrackerTraceReplay(t *testing.T) {
+	f, err := os.Open("../test-data/cyoung-09062015-noproblem-stratux-es.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	initTestTraffic()
+	const key = 0xABB459
+
+	ti := TrafficInfo{Icao_addr: key, Last_source: TRAFFIC_SOURCE_1090ES, NACp: 8}
+	var errors []float64
+	fixes, reinits := 0, 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// ns,MSG,type,,,hex,,,,,,,alt,speed,track,lat,lng,vvel,...
+		fields := strings.Split(scanner.Text(), ",")
+		if len(fields) < 18 || fields[1] != "MSG" || fields[5] != "ABB459" {
+			continue
+		}
+		ns, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		ts := time.Time{}.Add(time.Duration(ns))
+		ti.Last_seen = ts
+		if alt, err := strconv.Atoi(fields[12]); err == nil {
+			ti.Alt = int32(alt)
+			ti.Last_alt = ts
+		}
+		if speed, err := strconv.Atoi(fields[13]); err == nil {
+			track, _ := strconv.ParseFloat(fields[14], 32)
+			vvel, _ := strconv.Atoi(fields[17])
+			ti.Speed, ti.Track, ti.Vvel, ti.Speed_valid = uint16(speed), float32(track), int16(vvel), true
+			ti.Last_speed = ts
+		}
+		newFix := false
+		if lat, err := strconv.ParseFloat(fields[15], 32); err == nil {
+			lng, _ := strconv.ParseFloat(fields[16], 32)
+			newFix = float32(lat) != ti.Lat || float32(lng) != ti.Lng // dump1090 repeats the last position
+			ti.Lat, ti.Lng, ti.Position_valid = float32(lat), float32(lng), true
+		}
+
+		trafficMutex.Lock()
+		before := trafficTrackers[key]
+		coasted := time.Duration(0)
+		if newFix && before != nil {
+			coasted = ts.Sub(before.lastFixTime)
+			est := before.predicted(ts)
+			if coasted.Seconds() <= TRACKER_MAX_COAST {
+				errors = append(errors, trackerError(&est, float64(ti.Lat), float64(ti.Lng)))
+			}
+		}
+		traffic[key] = ti
+		registerTrafficUpdate(key, ti)
+		trk := trafficTrackers[key]
+		trafficMutex.Unlock()
+
+		if !newFix {
+			continue
+		}
+		fixes++
+		if trk == nil || trk.fixLat != ti.Lat || trk.fixLng != ti.Lng || !trk.lastFixTime.Equal(ts) {
+			t.Fatalf("%v: position not fed into the tracker", ts.Sub(time.Time{}))
+		}
+		if before != nil && trk != before {
+			reinits++
+			if coasted.Seconds() <= TRACKER_MAX_COAST {
+				t.Errorf("%v: track re-initialized after %v", ts.Sub(time.Time{}), coasted)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Two gaps in the reception are longer than TRACKER_MAX_COAST
+	if fixes != 250 || reinits != 2 {
+		t.Errorf("%d positions, %d re-initializations", fixes, reinits)
+	}
+	sort.Float64s(errors)
+	if median, max := errors[len(errors)/2], errors[len(errors)-1]; median > 100 || max > 300 {
+		t.Errorf("next position predicted with a median error of %.0fm, max %.0fm", median, max)
+	}
+}
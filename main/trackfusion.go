@@ -0,0 +1,336 @@
+/*
+	Copyright (c) 2026 Stratux contributors
+	Distributable under the terms of The "BSD New" License
+	that can be found in the LICENSE file, herein included
+	as part of this header.
+
+	trackfusion.go: Links tracks of the same aircraft that are received from different sources under different
+		addresses (e.g. FLARM with a random ID and 1090ES with the ICAO address) and merges them into one target.
+*/
+
+package main
+
+import (
+	"math"
+	"sort"
+
+	"github.com/b3nn0/stratux/common"
+)
+
+const (
+	FUSION_MAX_DISTANCE       = 500.0 // meters. Tracks need to be this close to be linked..
+	FUSION_MAX_ALT_DIFF       = 300.0 // feet
+	FUSION_MAX_ALT_DIFF_GNSS  = 600.0 // feet, if one track reports GNSS altitude and the other baro altitude
+	FUSION_MAX_SPEED_DIFF     = 25.0  // knots
+	FUSION_MAX_TRACK_DIFF     = 30.0  // degrees
+	FUSION_MIN_SPEED_FOR_TRACK = 30   // knots. Below that, track is too noisy to compare
+	FUSION_UNLINK_FACTOR      = 2.0   // ..and are unlinked once they diverge by more than twice the thresholds
+	FUSION_MAX_AGE            = 6.0   // seconds. Older tracks are not considered for new links
+)
+
+// One track contributing to a fused target. Exposed in the /traffic websocket JSON.
+type TrafficSourceInfo struct {
+	Key       uint32  // Key of the track in the traffic map
+	Icao_addr uint32
+	Addr_type uint8
+	Source    uint8   // TRAFFIC_SOURCE_*
+	TargetType uint8
+	Age       float64 // seconds since last position / Mode S transmission from this track
+	NACp      int
+	Primary   bool    // set for the track the fused target is reported under
+}
+
+// secondary key => primary key, and primary key => secondary keys. The groups are rebuilt from the links once per
+// sendTrafficUpdates() pass instead of scanning all links for each track. Protected by trafficMutex.
+var fusionLinks map[uint32]uint32 = make(map[uint32]uint32)
+var fusionGroups map[uint32][]uint32 = make(map[uint32][]uint32)
+
+// Ranks sources for choosing the primary track and the identification fields. Direct ADS-B is preferred, because
+// EFBs identify the target by its ICAO address.
+func fusionSourceRank(ti TrafficInfo) int {
+	switch ti.Last_source {
+	case TRAFFIC_SOURCE_1090ES, TRAFFIC_SOURCE_UAT:
+		switch ti.TargetType {
+		case TARGET_TYPE_ADSB:
+			return 4
+		case TARGET_TYPE_ADSR:
+			return 3
+		case TARGET_TYPE_MODE_S:
+			return 1
+		default:
+			return 0 // TIS-B
+		}
+	case TRAFFIC_SOURCE_OGN:
+		return 2
+	case TRAFFIC_SOURCE_GDL90:
+		return 1 // relayed, possibly with extrapolated positions
+	}
+	return 0
+}
+
+// Quality of a position report. Higher is better: each second of age costs one NACp step.
+func fusionPositionQuality(ti TrafficInfo) float64 {
+	nacp := ti.NACp
+	if nacp == 0 && ti.Last_source == TRAFFIC_SOURCE_OGN {
+		nacp = 9 // FLARM/OGN don't report accuracy, but are based on a GNSS fix
+	}
+	return float64(nacp) - stratuxClock.Since(ti.Last_seen).Seconds()
+}
+
+// Checks if two tracks are plausibly the same aircraft. factor scales all thresholds.
+func fusionTracksMatch(a, b TrafficInfo, factor float64) bool {
+	if !a.Position_valid || !b.Position_valid || a.Alt == 0 || b.Alt == 0 {
+		return false
+	}
+	dist, _, _, _ := common.DistRect(float64(a.Lat), float64(a.Lng), float64(b.Lat), float64(b.Lng))
+	if dist > FUSION_MAX_DISTANCE*factor {
+		return false
+	}
+	maxAltDiff := FUSION_MAX_ALT_DIFF
+	if a.AltIsGNSS != b.AltIsGNSS {
+		maxAltDiff = FUSION_MAX_ALT_DIFF_GNSS
+	}
+	if math.Abs(float64(a.Alt-b.Alt)) > maxAltDiff*factor {
+		return false
+	}
+	if a.Speed_valid && b.Speed_valid {
+		if math.Abs(float64(a.Speed)-float64(b.Speed)) > FUSION_MAX_SPEED_DIFF*factor {
+			return false
+		}
+		if a.Speed >= FUSION_MIN_SPEED_FOR_TRACK && b.Speed >= FUSION_MIN_SPEED_FOR_TRACK {
+			trackDiff := math.Abs(float64(a.Track - b.Track))
+			if trackDiff > 180 {
+				trackDiff = 360 - trackDiff
+			}
+			if trackDiff > FUSION_MAX_TRACK_DIFF*factor {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+/*
+	updateFusionLinks() drops links of tracks that disappeared or diverged and creates new links between current tracks
+		from different sources that match. Must be called with trafficMutex held.
+*/
+func updateFusionLinks() {
+	for secondary, primary := range fusionLinks {
+		secTi, secOk := estimatedTrafficInfo(secondary)
+		primTi, primOk := estimatedTrafficInfo(primary)
+		if !secOk || !primOk || !fusionTracksMatch(secTi, primTi, FUSION_UNLINK_FACTOR) {
+			delete(fusionLinks, secondary)
+		}
+	}
+	fusionGroups = make(map[uint32][]uint32)
+	for secondary, primary := range fusionLinks {
+		fusionGroups[primary] = append(fusionGroups[primary], secondary)
+	}
+
+	// Tracks are compared at their estimated position for the current time, so reports of different age match.
+	// They are sorted by latitude, so each track is only compared to the tracks less than FUSION_MAX_DISTANCE
+	// further north.
+	candidates := make([]uint32, 0)
+	estimates := make(map[uint32]TrafficInfo)
+	for key, ti := range traffic {
+		if ti.Position_valid && ti.Last_source != TRAFFIC_SOURCE_AIS && stratuxClock.Since(ti.Last_seen).Seconds() < FUSION_MAX_AGE {
+			candidates = append(candidates, key)
+			applyTrackerEstimate(key, &ti)
+			estimates[key] = ti
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return estimates[candidates[i]].Lat < estimates[candidates[j]].Lat
+	})
+
+	for i, keyA := range candidates {
+		a := estimates[keyA]
+		for _, keyB := range candidates[i+1:] {
+			b := estimates[keyB]
+			if common.DistRectNorth(float64(a.Lat), float64(b.Lat)) > FUSION_MAX_DISTANCE {
+				break
+			}
+			if a.Last_source == b.Last_source {
+				continue
+			}
+			primA, linkedA := fusionLinks[keyA]
+			primB, linkedB := fusionLinks[keyB]
+			if linkedA && linkedB {
+				continue
+			}
+			if (linkedA && primA == keyB) || (linkedB && primB == keyA) {
+				continue
+			}
+			if !fusionTracksMatch(a, b, 1.0) {
+				continue
+			}
+			// Link to an existing fused target if there is one, otherwise pick the better ranked track as primary.
+			// A fused target never contains two tracks from the same source - those would be two different aircraft.
+			// Primaries are never linked as secondaries, so links are always one level deep.
+			if linkedA {
+				if !isFusionPrimary(keyB) && !fusionGroupHasSource(primA, b.Last_source) {
+					linkFusionTrack(keyB, primA)
+				}
+			} else if linkedB {
+				if !isFusionPrimary(keyA) && !fusionGroupHasSource(primB, a.Last_source) {
+					linkFusionTrack(keyA, primB)
+				}
+			} else if isFusionPrimary(keyA) && isFusionPrimary(keyB) {
+				continue // two fused targets. Leave them alone.
+			} else if isFusionPrimary(keyA) {
+				if !fusionGroupHasSource(keyA, b.Last_source) {
+					linkFusionTrack(keyB, keyA)
+				}
+			} else if isFusionPrimary(keyB) {
+				if !fusionGroupHasSource(keyB, a.Last_source) {
+					linkFusionTrack(keyA, keyB)
+				}
+			} else if fusionSourceRank(b) > fusionSourceRank(a) {
+				linkFusionTrack(keyA, keyB)
+			} else {
+				linkFusionTrack(keyB, keyA)
+			}
+		}
+	}
+}
+
+func linkFusionTrack(secondary, primary uint32) {
+	fusionLinks[secondary] = primary
+	fusionGroups[primary] = append(fusionGroups[primary], secondary)
+}
+
+func fusionGroupHasSource(primary uint32, source uint8) bool {
+	if traffic[primary].Last_source == source {
+		return true
+	}
+	for _, sec := range fusionGroups[primary] {
+		if traffic[sec].Last_source == source {
+			return true
+		}
+	}
+	return false
+}
+
+func isFusionPrimary(key uint32) bool {
+	return len(fusionGroups[key]) > 0
+}
+
+/*
+	fuseTrafficInfo() merges all tracks linked to the given key and picks the best estimate for each field:
+		position from the freshest / most accurate report, baro altitude over GNSS altitude, velocity from the freshest
+		velocity report and identification from the best ranked source.
+		ti is the raw track of key. The result is a copy for the outputs, with the tracker estimates (see tracker.go)
+		applied to all tracks. Tracks that were fused into another target are returned with Fused set.
+		Must be called with trafficMutex held.
+*/
+func fuseTrafficInfo(key uint32, ti TrafficInfo) TrafficInfo {
+	if primary, ok := fusionLinks[key]; ok {
+		ti.Fused = true
+		ti.FusedInto = primary
+		ti.Sources = nil
+		return ti
+	}
+	ti.Fused = false
+	ti.FusedInto = 0
+	applyTrackerEstimate(key, &ti)
+
+	if len(fusionGroups[key]) == 0 {
+		ti.Sources = nil
+		return ti
+	}
+	members := append([]uint32{key}, fusionGroups[key]...)
+
+	fused := ti
+	fused.Sources = make([]TrafficSourceInfo, 0, len(members))
+	bestPos := ti
+	bestAlt := ti
+	bestSpeed := ti
+	identRank := -1
+	for _, memberKey := range members {
+		m := ti
+		if memberKey != key {
+			var ok bool
+			if m, ok = estimatedTrafficInfo(memberKey); !ok {
+				continue // timed out since the last updateFusionLinks()
+			}
+		}
+		fused.Sources = append(fused.Sources, TrafficSourceInfo{
+			Key:        memberKey,
+			Icao_addr:  m.Icao_addr,
+			Addr_type:  m.Addr_type,
+			Source:     m.Last_source,
+			TargetType: m.TargetType,
+			Age:        stratuxClock.Since(m.Last_seen).Seconds(),
+			NACp:       m.NACp,
+			Primary:    memberKey == key,
+		})
+
+		if m.Position_valid && fusionPositionQuality(m) > fusionPositionQuality(bestPos) {
+			bestPos = m
+		}
+		if m.Alt != 0 && (bestAlt.Alt == 0 || (bestAlt.AltIsGNSS && !m.AltIsGNSS) ||
+			(bestAlt.AltIsGNSS == m.AltIsGNSS && m.Last_alt.After(bestAlt.Last_alt))) {
+			bestAlt = m
+		}
+		if m.Speed_valid && (!bestSpeed.Speed_valid || m.Last_speed.After(bestSpeed.Last_speed)) {
+			bestSpeed = m
+		}
+
+		rank := fusionSourceRank(m)
+		if rank > identRank {
+			identRank = rank
+			if len(m.Tail) > 0 {
+				fused.Tail = m.Tail
+			}
+			if len(m.Reg) > 0 {
+				fused.Reg = m.Reg
+			}
+			if m.Emitter_category != 0 {
+				fused.Emitter_category = m.Emitter_category
+			}
+			if m.Squawk != 0 {
+				fused.Squawk = m.Squawk
+			}
+		}
+		// Fill gaps from lower ranked sources
+		if len(fused.Tail) == 0 {
+			fused.Tail = m.Tail
+		}
+		if len(fused.Reg) == 0 {
+			fused.Reg = m.Reg
+		}
+		if fused.Emitter_category == 0 {
+			fused.Emitter_category = m.Emitter_category
+		}
+		if fused.PriorityStatus == 0 {
+			fused.PriorityStatus = m.PriorityStatus
+		}
+		fused.IsStratux = fused.IsStratux || m.IsStratux
+	}
+
+	fused.Lat = bestPos.Lat
+	fused.Lng = bestPos.Lng
+	fused.NACp = bestPos.NACp
+	fused.NIC = bestPos.NIC
+	fused.ExtrapolatedPosition = bestPos.ExtrapolatedPosition
+	fused.Last_extrapolation = bestPos.Last_extrapolation
+	if bestPos.Last_seen.After(fused.Last_seen) {
+		fused.Last_seen = bestPos.Last_seen
+	}
+
+	fused.Alt = bestAlt.Alt
+	fused.AltIsGNSS = bestAlt.AltIsGNSS
+	fused.Last_alt = bestAlt.Last_alt
+
+	fused.Speed_valid = bestSpeed.Speed_valid
+	fused.Speed = bestSpeed.Speed
+	fused.Track = bestSpeed.Track
+	fused.Vvel = bestSpeed.Vvel
+	fused.TurnRate = bestSpeed.TurnRate
+	fused.Last_speed = bestSpeed.Last_speed
+
+	if fused.Timestamp.Before(bestPos.Timestamp) {
+		fused.Timestamp = bestPos.Timestamp
+	}
+	return fused
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+const testFLARMKey = 1<<24 | 0xDD1234 // OGN track with a random (non-ICAO) FLARM ID
+
+// Current track north and east meters from testOwnshipLat/Lng, altFt above testOwnshipAlt, flying north.
+func testFusionTrack(icao uint32, addrType, source, targetType uint8, north, east, altFt float64, speedKts float64) TrafficInfo {
+	ti := testConflictTarget(north, east, 0, speedKts, altFt)
+	ti.Icao_addr = icao
+	ti.Addr_type = addrType
+	ti.Last_source = source
+	ti.TargetType = targetType
+	ti.AltIsGNSS = source == TRAFFIC_SOURCE_OGN
+	ti.NACp = 8
+	ti.Last_seen = stratuxClock.Time
+	ti.Last_alt = stratuxClock.Time
+	ti.Last_speed = stratuxClock.Time
+	return ti
+}
+
+func addTestTraffic(key uint32, ti TrafficInfo) {
+	trafficMutex.Lock()
+	traffic[key] = ti
+	trafficMutex.Unlock()
+}
+
+func TestTrackFusionPrimary(t *testing.T) {
+	initTestTraffic()
+	tests := []struct {
+		name    string
+		key     uint32
+		ti      TrafficInfo
+		primary uint32
+	}{
+		{"1090ES ADS-B", 0xA12345, testFusionTrack(0xA12345, 0, TRAFFIC_SOURCE_1090ES, TARGET_TYPE_ADSB, 0, 0, 0, 100), 0xA12345},
+		{"UAT ADS-R", 0xA12345, testFusionTrack(0xA12345, 0, TRAFFIC_SOURCE_UAT, TARGET_TYPE_ADSR, 0, 0, 0, 100), 0xA12345},
+		{"UAT TIS-B", 0xA12345, testFusionTrack(0xA12345, 2, TRAFFIC_SOURCE_UAT, TARGET_TYPE_TISB, 0, 0, 0, 100), testFLARMKey},
+		{"1090ES Mode S", 0xA12345, testFusionTrack(0xA12345, 0, TRAFFIC_SOURCE_1090ES, TARGET_TYPE_MODE_S, 0, 0, 0, 100), testFLARMKey},
+	}
+	for _, tt := range tests {
+		initTestTraffic()
+		// FLARM reports GNSS altitude, which is ~150 ft above pressure altitude here
+		addTestTraffic(testFLARMKey, testFusionTrack(0xDD1234, 1, TRAFFIC_SOURCE_OGN, TARGET_TYPE_ADSB, 120, 40, 150, 95))
+		addTestTraffic(tt.key, tt.ti)
+		sendTrafficUpdates()
+
+		trafficMutex.Lock()
+		secondary := uint32(testFLARMKey)
+		if tt.primary == testFLARMKey {
+			secondary = tt.key
+		}
+		if fusionLinks[secondary] != tt.primary || len(fusionLinks) != 1 {
+			t.Errorf("%s: links %v, want %X => %X", tt.name, fusionLinks, secondary, tt.primary)
+		}
+		if ti := traffic[secondary]; !ti.Fused || ti.FusedInto != tt.primary {
+			t.Errorf("%s: secondary fused %t into %X", tt.name, ti.Fused, ti.FusedInto)
+		}
+		primary := traffic[tt.primary]
+		if primary.Fused || len(primary.Sources) != 2 || !primary.Sources[0].Primary || primary.Sources[0].Key != tt.primary ||
+			primary.Sources[1].Key != secondary {
+			t.Errorf("%s: primary fused %t, sources %+v", tt.name, primary.Fused, primary.Sources)
+		}
+		fused := fuseTrafficInfo(tt.primary, primary)
+		if fused.AltIsGNSS || fused.Alt != testOwnshipAlt {
+			t.Errorf("%s: fused altitude %d GNSS %t, want the pressure altitude", tt.name, fused.Alt, fused.AltIsGNSS)
+		}
+		trafficMutex.Unlock()
+	}
+}
+
+func TestTrackFusionDistinctAircraft(t *testing.T) {
+	initTestTraffic()
+	es := testFusionTrack(0xA12345, 0, TRAFFIC_SOURCE_1090ES, TARGET_TYPE_ADSB, 0, 0, 0, 100)
+	tests := []struct {
+		name string
+		key  uint32
+		ti   TrafficInfo
+	}{
+		{"600 m apart", testFLARMKey, testFusionTrack(0xDD1234, 1, TRAFFIC_SOURCE_OGN, TARGET_TYPE_ADSB, 400, 450, 150, 100)},
+		{"1000 ft above", testFLARMKey, testFusionTrack(0xDD1234, 1, TRAFFIC_SOURCE_OGN, TARGET_TYPE_ADSB, 100, 0, 1000, 100)},
+		{"50 kt slower", testFLARMKey, testFusionTrack(0xDD1234, 1, TRAFFIC_SOURCE_OGN, TARGET_TYPE_ADSB, 100, 0, 150, 50)},
+		{"same source", 0xA12346, testFusionTrack(0xA12346, 0, TRAFFIC_SOURCE_1090ES, TARGET_TYPE_ADSB, 100, 0, 0, 100)},
+		{"AIS", 366123456, testFusionTrack(366123456, 0, TRAFFIC_SOURCE_AIS, TARGET_TYPE_ADSB, 0, 0, 0, 100)},
+	}
+	crossing := testFusionTrack(0xDD1234, 1, TRAFFIC_SOURCE_OGN, TARGET_TYPE_ADSB, 100, 0, 150, 100)
+	crossing.Track = 90
+	tests = append(tests, struct {
+		name string
+		key  uint32
+		ti   TrafficInfo
+	}{"crossing", testFLARMKey, crossing})
+
+	for _, tt := range tests {
+		initTestTraffic()
+		addTestTraffic(0xA12345, es)
+		addTestTraffic(tt.key, tt.ti)
+		sendTrafficUpdates()
+		trafficMutex.Lock()
+		if len(fusionLinks) != 0 || traffic[tt.key].Fused || traffic[0xA12345].Fused {
+			t.Errorf("%s: fused %v", tt.name, fusionLinks)
+		}
+		trafficMutex.Unlock()
+	}
+
+	// Two FLARMs next to the ADS-B target: only one of them can be the same aircraft
+	initTestTraffic()
+	addTestTraffic(0xA12345, es)
+	addTestTraffic(testFLARMKey, testFusionTrack(0xDD1234, 1, TRAFFIC_SOURCE_OGN, TARGET_TYPE_ADSB, 50, 0, 150, 100))
+	addTestTraffic(1<<24|0xDD1235, testFusionTrack(0xDD1235, 1, TRAFFIC_SOURCE_OGN, TARGET_TYPE_ADSB, -50, 0, 150, 100))
+	sendTrafficUpdates()
+	trafficMutex.Lock()
+	if len(fusionLinks) != 1 || len(fusionGroups[0xA12345]) != 1 {
+		t.Errorf("two FLARMs: links %v", fusionLinks)
+	}
+	trafficMutex.Unlock()
+}
+
+// Links are dropped once the tracks diverge, and the secondary is reported on its own again.
+func TestTrackFusionUnlink(t *testing.T) {
+	initTestTraffic()
+	addTestTraffic(0xA12345, testFusionTrack(0xA12345, 0, TRAFFIC_SOURCE_1090ES, TARGET_TYPE_ADSB, 0, 0, 0, 100))
+	addTestTraffic(testFLARMKey, testFusionTrack(0xDD1234, 1, TRAFFIC_SOURCE_OGN, TARGET_TYPE_ADSB, 100, 0, 150, 100))
+	sendTrafficUpdates()
+	trafficMutex.Lock()
+	if fusionLinks[testFLARMKey] != 0xA12345 {
+		t.Fatalf("links %v", fusionLinks)
+	}
+	trafficMutex.Unlock()
+
+	// Still within twice the linking distance
+	addTestTraffic(testFLARMKey, testFusionTrack(0xDD1234, 1, TRAFFIC_SOURCE_OGN, TARGET_TYPE_ADSB, 900, 0, 150, 100))
+	sendTrafficUpdates()
+	trafficMutex.Lock()
+	if fusionLinks[testFLARMKey] != 0xA12345 {
+		t.Errorf("900 m: links %v", fusionLinks)
+	}
+	trafficMutex.Unlock()
+
+	addTestTraffic(testFLARMKey, testFusionTrack(0xDD1234, 1, TRAFFIC_SOURCE_OGN, TARGET_TYPE_ADSB, 1100, 0, 150, 100))
+	sendTrafficUpdates()
+	trafficMutex.Lock()
+	if len(fusionLinks) != 0 || len(fusionGroups) != 0 || traffic[testFLARMKey].Fused || traffic[0xA12345].Sources != nil {
+		t.Errorf("1100 m: links %v, FLARM fused %t", fusionLinks, traffic[testFLARMKey].Fused)
+	}
+	trafficMutex.Unlock()
+}
+
+// Fused secondaries are not listed by the readsb aircraft.json and the /traffic websocket. Updates of a secondary
+// are sent with Fused set, so the web interface removes it.
+func TestTrackFusionHiddenSecondary(t *testing.T) {
+	initTestTraffic()
+	trafficUpdate = NewUIBroadcaster()
+	defer func() { trafficUpdate = nil }()
+	addTestTraffic(0xA12345, testFusionTrack(0xA12345, 0, TRAFFIC_SOURCE_1090ES, TARGET_TYPE_ADSB, 0, 0, 0, 100))
+	addTestTraffic(testFLARMKey, testFusionTrack(0xDD1234, 1, TRAFFIC_SOURCE_OGN, TARGET_TYPE_ADSB, 100, 0, 150, 100))
+	addTestTraffic(0xA12346, testFusionTrack(0xA12346, 0, TRAFFIC_SOURCE_1090ES, TARGET_TYPE_ADSB, 5000, 0, 0, 100))
+	sendTrafficUpdates()
+
+	list := getReadsbAircraftList()
+	var hex []string
+	for _, ac := range list.Aircraft {
+		hex = append(hex, ac.Hex)
+	}
+	if len(hex) != 2 || strings.Contains(strings.Join(hex, " "), "dd1234") {
+		t.Errorf("aircraft.json: %v", hex)
+	}
+
+	server := httptest.NewServer(websocket.Server{Handler: websocket.Handler(handleTrafficWS)})
+	defer server.Close()
+	ws, err := websocket.Dial("ws"+strings.TrimPrefix(server.URL, "http"), "", server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+	receive := func() []TrafficInfo {
+		var received []TrafficInfo
+		for {
+			ws.SetReadDeadline(time.Now().Add(500 * time.Millisecond))
+			var msg []byte
+			if err := websocket.Message.Receive(ws, &msg); err != nil {
+				return received
+			}
+			var ti TrafficInfo
+			if err := json.Unmarshal(msg, &ti); err != nil {
+				t.Fatal(err)
+			}
+			received = append(received, ti)
+		}
+	}
+	snapshot := receive()
+	if len(snapshot) != 2 {
+		t.Errorf("/traffic: %d targets, want 2", len(snapshot))
+	}
+	for _, ti := range snapshot {
+		if ti.Icao_addr == 0xDD1234 {
+			t.Error("/traffic: fused FLARM track sent")
+		}
+		if ti.Icao_addr == 0xA12345 && len(ti.Sources) != 2 {
+			t.Errorf("/traffic: sources %+v", ti.Sources)
+		}
+	}
+
+	trafficMutex.Lock()
+	registerTrafficUpdate(testFLARMKey, traffic[testFLARMKey])
+	trafficMutex.Unlock()
+	if update := receive(); len(update) != 1 || !update[0].Fused || update[0].FusedInto != 0xA12345 {
+		t.Errorf("/traffic: update %+v", update)
+	}
+}
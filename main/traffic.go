@@ -67,6 +67,7 @@ const (
 	TRAFFIC_SOURCE_UAT    = 2
 	TRAFFIC_SOURCE_OGN    = 4
 	TRAFFIC_SOURCE_AIS    = 8
+	TRAFFIC_SOURCE_GDL90  = 16 // relayed by another GDL90 device, see gdl90in.go
 	TARGET_TYPE_MODE_S    = 0
 	TARGET_TYPE_ADSB      = 1
 	TARGET_TYPE_ADSR      = 2
@@ -83,6 +84,8 @@ type TrafficInfo struct {
 	Icao_addr           uint32
 	Reg                 string    // Registration. Calculated from Icao_addr for civil aircraft of US registry.
 	Tail                string    // Callsign. Transmitted by aircraft.
+	AircraftType        string    // ICAO type designator from the aircraft database (see aircraftdb.go)
+	Operator            string    // Operator from the aircraft database
 	Emitter_category    uint8     // Formatted using GDL90 standard, e.g. in a Mode ES report, A7 becomes 0x07, B0 becomes 0x08, etc.
 	SurfaceVehicleType	uint16    // Type of service vehicle (when Emitter_category==18) 0..255 is reserved for AIS vessels
 	OnGround            bool      // Air-ground status. On-ground is "true".
@@ -106,6 +109,7 @@ type TrafficInfo struct {
 	Vvel                int16     // feet per minute
 	Timestamp           time.Time // timestamp of traffic message, UTC
 	PriorityStatus      uint8     // Emergency or priority code as defined in GDL90 spec, DO-260B (Type 28 msg) and DO-282B
+	Emergency           uint8     // Emergency status (EMERGENCY_*) from PriorityStatus or the squawk code, see emergency.go
 
 	// Parameters starting at 'Age' are calculated from last message receipt on each call of sendTrafficUpdates().
 	// Mode S transmits position and track in separate messages, and altitude can also be
@@ -118,19 +122,29 @@ type TrafficInfo struct {
 	Last_GnssDiffAlt     int32     // Altitude at last GnssDiffFromBaroAlt update.
 	Last_speed           time.Time // Time of last velocity and track update (stratuxClock).
 	Last_source          uint8     // Last frequency on which this target was received.
+	Last_DF              int       // Mode S downlink format of the last 1090 message
 	ExtrapolatedPosition bool      //TODO: True if Stratux is "coasting" the target from last known position.
 	Last_extrapolation   time.Time
 	AgeExtrapolation     float64
 	Lat_fix              float32   // Last real, non-extrapolated latitude
 	Lng_fix              float32   // Last real, non-extrapolated longitude
 	Alt_fix              int32     // Last real, non-extrapolated altitude
+	PositionUncertainty  float64   // 95% horizontal position uncertainty of the tracker estimate (see tracker.go), meters. 0 if not tracked.
 
 	BearingDist_valid    bool      // set when bearing and distance information is valid
 	Bearing              float64   // Bearing in common.Degrees true to traffic from ownship, if it can be calculated. Units: common.Degrees.
 	Distance             float64   // Distance to traffic from ownship, if it can be calculated. Units: meters.
 	DistanceEstimated    float64   // Estimated distance of the target if real distance can't be calculated, Estimated from signal strength with exponential smoothing.
 	DistanceEstimatedLastTs time.Time // Used to compute moving average
+	CPA_valid            bool      // set when a closest point of approach could be predicted from ownship and target motion
+	CPATime              float64   // Time until closest point of approach, seconds. 0 if already diverging.
+	CPADistance          float64   // Predicted horizontal distance at closest point of approach, meters.
+	CPAVertical          float64   // Predicted vertical separation at closest point of approach (traffic minus ownship), meters.
+	AlarmLevel           uint8     // FLARM alarm level (0-3) derived from the closest point of approach
 	ReceivedMsgs         uint64    // Number of messages received by this aircraft
+	Sources              []TrafficSourceInfo // Tracks this target was fused from (see trackfusion.go). Empty if not fused.
+	Fused                bool      // set if this track was fused into another target and is not reported on its own
+	FusedInto            uint32    // traffic map key of the target this track was fused into
 	IsStratux            bool      // Target is equipped with a Stratux that transmits via OGN tracker
 	//FIXME: Rename variables for consistency, especially "Last_".
 }
@@ -201,7 +215,7 @@ func removeTarget(id uint32) {
 		// Make sure the web interface times it out..
 		val.Age = 60
 		val.Position_valid = false
-		registerTrafficUpdate(val)
+		registerTrafficUpdate(id, val)
 		delete(traffic, id)
 	}
 }
@@ -299,6 +313,13 @@ func sendTrafficUpdates() {
 	trafficMutex.Lock()
 	defer trafficMutex.Unlock()
 	cleanupOldEntries()
+	cleanupEmergencies()
+	cleanupSBSTargets()
+	cleanupAISOut()
+	cleanupTrafficHistory()
+	cleanupCoverage()
+	updateFusionLinks()
+	checkTrafficWatchTimeouts()
 
 	// Summarize number of UAT and 1090ES traffic targets for reports that follow.
 	globalStatus.UAT_traffic_targets_tracking = 0
@@ -326,26 +347,27 @@ func sendTrafficUpdates() {
 		log.Printf("List of all aircraft being tracked:\n")
 		log.Printf("==================================================================\n")
 	}
-	for key, ti := range traffic { // ForeFlight 7.5 chokes at ~1000-2000 messages depending on iDevice RAM. Practical limit likely around ~500 aircraft without filtering.
-		if isGPSValid() && ti.Position_valid {
-			// func distRect(lat1, lon1, lat2, lon2 float64) (dist, bearing, distN, distE float64) {
-			dist, bearing := common.Distance(float64(mySituation.GPSLatitude), float64(mySituation.GPSLongitude), float64(ti.Lat), float64(ti.Lng))
-			ti.Distance = dist
-			ti.Bearing = bearing
-			ti.BearingDist_valid = true
-		} else {
-			ti.Distance = 0
-			ti.Bearing = 0
-			ti.BearingDist_valid = false
-		}
-		ti.Age = stratuxClock.Since(ti.Last_seen).Seconds()
-		ti.AgeExtrapolation = stratuxClock.Since(ti.Last_extrapolation).Seconds()
-		ti.AgeLastAlt = stratuxClock.Since(ti.Last_alt).Seconds()
+	for key, track := range traffic { // ForeFlight 7.5 chokes at ~1000-2000 messages depending on iDevice RAM. Practical limit likely around ~500 aircraft without filtering.
+		fillTrafficFromAircraftDB(&track)
+		// The traffic map keeps the raw track of each source. The outputs below get the fused target instead
+		// (see trackfusion.go), which is just a copy of the raw track if nothing was fused into it.
+		ti := fuseTrafficInfo(key, track)
+		track.Fused = ti.Fused
+		track.FusedInto = ti.FusedInto
+		track.Sources = ti.Sources
+		updateTrafficRelativeInfo(&track)
+		updateTrafficRelativeInfo(&ti)
+		detectEmergency(key, &ti)
+		track.Emergency = ti.Emergency
 
 		// Keep non-extrapolated traffic for 6 seconds, but extrapolate for 20
 		isCurrent := (ti.ExtrapolatedPosition && ti.AgeExtrapolation < 2 && ti.Age < 25) || (!ti.ExtrapolatedPosition && ti.Age < 6)
 
 		isOwnshipTi, shouldIgnore := isOwnshipTrafficInfo(ti)
+		if ti.Fused {
+			// Reported as part of the target it was fused into
+			shouldIgnore = true
+		}
 
 		// As bearingless targets, we show the closest estimated traffic that is between +-2000ft
 		if !shouldIgnore && !ti.Position_valid && ti.DistanceEstimated > 0 &&
@@ -365,10 +387,10 @@ func sendTrafficUpdates() {
 			}
 			// end of debug block
 		}
-		traffic[key] = ti // write the updated ti back to the map
+		traffic[key] = track // write the updated track back to the map
 		//log.Printf("Traffic age of %X is %f seconds\n",icao,ti.Age)
-		if ti.Age > 2 { // if nothing polls an inactive ti, it won't push to the webUI, and its Age won't update.
-			trafficUpdate.SendJSON(ti)
+		if track.Age > 2 { // if nothing polls an inactive ti, it won't push to the webUI, and its Age won't update.
+			trafficUpdate.SendJSON(track)
 		}
 		if !shouldIgnore && isCurrent {
 			if float32(ti.Alt) <= currAlt + float32(globalSettings.RadarLimits) * 1.3 && //take 30% more to see moving outs
@@ -380,6 +402,9 @@ func sendTrafficUpdates() {
 		if ti.Position_valid && isCurrent { // ... but don't pass stale data to the EFB.
 			//TODO: Coast old traffic? Need to determine how FF, WingX, etc deal with stale targets.
 			logTraffic(ti) // only add to the SQLite log if it's not stale
+			if !shouldIgnore {
+				recordTrafficHistory(key, ti)
+			}
 
 			if isOwnshipTi {
 				if globalSettings.DEBUG {
@@ -404,11 +429,20 @@ func sendTrafficUpdates() {
 
 				// send traffic message to X-Plane
 				sendXPlane(createXPlaneTrafficMsg(ti.Icao_addr, ti.Lat, ti.Lng, ti.Alt, uint32(ti.Speed), int32(ti.Vvel), ti.OnGround, uint32(ti.Track), trafficCallsign), 1000, priority)
+				sendMAVLink(makeMAVLinkAdsbVehicle(ti), time.Second, priority)
+				sendCoTTrafficUpdate(ti, priority)
+				sendAsterixTrafficUpdate(ti, priority)
+				sendMQTTTrafficUpdate(ti)
+				sendAISTrafficUpdate(ti)
 				if validFLARM {
 					sendNetFLARM(thisMsgFLARM, time.Second, priority)
 				}
 			}
 		}
+		if !shouldIgnore && !isOwnshipTi && ti.Age < 10 {
+			// SBS also carries Mode S targets without position
+			sendSBSTrafficUpdate(key, ti, computeTrafficPriority(&ti))
+		}
 	}
 
 	// Also send the nearest best bearingless
@@ -435,7 +469,29 @@ func sendTrafficUpdates() {
 	sendNetFLARM(msgPFLAU, time.Second, 0)
 }
 
+// Distance and bearing from ownship, closest approach and the age fields, which are recomputed on every update cycle.
+func updateTrafficRelativeInfo(ti *TrafficInfo) {
+	if isGPSValid() && ti.Position_valid {
+		// func distRect(lat1, lon1, lat2, lon2 float64) (dist, bearing, distN, distE float64) {
+		dist, bearing := common.Distance(float64(mySituation.GPSLatitude), float64(mySituation.GPSLongitude), float64(ti.Lat), float64(ti.Lng))
+		ti.Distance = dist
+		ti.Bearing = bearing
+		ti.BearingDist_valid = true
+	} else {
+		ti.Distance = 0
+		ti.Bearing = 0
+		ti.BearingDist_valid = false
+	}
+	computeConflict(ti)
+	ti.Age = stratuxClock.Since(ti.Last_seen).Seconds()
+	ti.AgeExtrapolation = stratuxClock.Since(ti.Last_extrapolation).Seconds()
+	ti.AgeLastAlt = stratuxClock.Since(ti.Last_alt).Seconds()
+}
+
 func computeTrafficPriority(ti *TrafficInfo) int32 {
+	if ti.Emergency != EMERGENCY_NONE {
+		return EMERGENCY_TRAFFIC_PRIORITY
+	}
 	if !ti.BearingDist_valid || ti.Alt == 0 {
 		return 9999999
 	}
@@ -451,6 +507,113 @@ func computeTrafficPriority(ti *TrafficInfo) int32 {
 	return int32((altDiff / 3.33 + ti.Distance) / 10000.0)
 }
 
+// Conflict prediction. Ownship and target are projected forward along a constant turn rate / constant climb rate
+// path and the point in time where the separation (normalized to the given protection volume) is smallest is
+// reported as the closest point of approach.
+const (
+	CONFLICT_PREDICTION_STEP     = 0.5   // seconds between two projected positions
+	FLARM_ALARM_HORIZON          = 18.0  // FLARM alarm level 1 ends at 18 seconds to impact
+	FLARM_ALARM_RADIUS           = 300.0 // meters. Horizontal protection radius for FLARM alarms
+	FLARM_ALARM_HEIGHT           = 150.0 // meters, ~500'. Vertical protection for FLARM alarms
+	TRAFFIC_ALERT_HORIZON        = 40.0  // seconds. Roughly what TCAS uses for a traffic advisory
+	TRAFFIC_ALERT_RADIUS         = 926.0 // meters, 0.5 NM
+	TRAFFIC_ALERT_HEIGHT         = 304.0 // meters, ~1000'
+)
+
+// Position of a body moving with constant speed, turn rate and climb rate after t seconds, relative to its start.
+// speed and vvel in m/s, track in degrees true, turnRate in deg/s (positive = right).
+func projectMotion(track, speed, vvel, turnRate, t float64) (north, east, up float64) {
+	trackRad := common.Radians(track)
+	omega := common.Radians(turnRate)
+	if math.Abs(omega) < 0.001 {
+		north = speed * t * math.Cos(trackRad)
+		east = speed * t * math.Sin(trackRad)
+	} else {
+		radius := speed / omega
+		north = radius * (math.Sin(trackRad + omega*t) - math.Sin(trackRad))
+		east = -radius * (math.Cos(trackRad + omega*t) - math.Cos(trackRad))
+	}
+	up = vvel * t
+	return
+}
+
+// Ownship motion in the units expected by projectMotion(). Vertical speed prefers the baro sensor.
+func ownshipMotion() (track, speed, vvel, turnRate float64) {
+	track = float64(mySituation.GPSTrueCourse)
+	speed = mySituation.GPSGroundSpeed * 0.514444
+	turnRate = mySituation.GPSTurnRate
+	if isTempPressValid() {
+		vvel = float64(mySituation.BaroVerticalSpeed) * 0.3048 / 60 // ft/min
+	} else {
+		vvel = float64(mySituation.GPSVerticalSpeed) * 0.3048 // ft/s
+	}
+	return
+}
+
+/*
+	predictClosestApproach() projects ownship and the target forward for up to horizon seconds and returns the time, horizontal
+		distance and vertical separation (target minus ownship) at the point where the two get closest with respect to the
+		protection volume given by radius and height (both meters).
+		Returns valid=false if ownship or target motion is unknown.
+*/
+func predictClosestApproach(ti TrafficInfo, horizon, radius, height float64) (tCPA, distCPA, vertCPA float64, valid bool) {
+	if !isGPSValid() || !ti.Position_valid || !ti.Speed_valid || ti.Alt == 0 {
+		return
+	}
+	_, _, relN, relE := common.DistRect(float64(mySituation.GPSLatitude), float64(mySituation.GPSLongitude), float64(ti.Lat), float64(ti.Lng))
+	relUp := float64(computeRelativeVertical(ti))
+
+	ownTrack, ownSpeed, ownVvel, ownTurnRate := ownshipMotion()
+	trafficSpeed := float64(ti.Speed) * 0.514444
+	trafficVvel := float64(ti.Vvel) * 0.3048 / 60
+
+	bestSep := math.MaxFloat64
+	for t := 0.0; t <= horizon; t += CONFLICT_PREDICTION_STEP {
+		ownN, ownE, ownUp := projectMotion(ownTrack, ownSpeed, ownVvel, ownTurnRate, t)
+		trafficN, trafficE, trafficUp := projectMotion(float64(ti.Track), trafficSpeed, trafficVvel, float64(ti.TurnRate), t)
+		dist := math.Hypot(relN + trafficN - ownN, relE + trafficE - ownE)
+		vert := relUp + trafficUp - ownUp
+		sep := math.Hypot(dist / radius, vert / height)
+		if sep < bestSep {
+			bestSep = sep
+			tCPA = t
+			distCPA = dist
+			vertCPA = vert
+		}
+	}
+	valid = true
+	return
+}
+
+// Maps a predicted closest approach to FLARM alarm levels: 1 = 13-18s, 2 = 9-12s, 3 = 0-8s to impact
+func alarmLevelForClosestApproach(tCPA, distCPA, vertCPA float64) uint8 {
+	if distCPA >= FLARM_ALARM_RADIUS || math.Abs(vertCPA) >= FLARM_ALARM_HEIGHT {
+		return 0
+	}
+	if tCPA <= 8 {
+		return 3
+	} else if tCPA <= 12 {
+		return 2
+	} else if tCPA <= FLARM_ALARM_HORIZON {
+		return 1
+	}
+	return 0
+}
+
+// Fills the CPA* and AlarmLevel fields of the given TrafficInfo.
+func computeConflict(ti *TrafficInfo) {
+	tCPA, distCPA, vertCPA, valid := predictClosestApproach(*ti, TRAFFIC_ALERT_HORIZON, TRAFFIC_ALERT_RADIUS, TRAFFIC_ALERT_HEIGHT)
+	ti.CPA_valid = valid
+	ti.CPATime = tCPA
+	ti.CPADistance = distCPA
+	ti.CPAVertical = vertCPA
+	ti.AlarmLevel = 0
+	if valid {
+		tCPA, distCPA, vertCPA, _ = predictClosestApproach(*ti, FLARM_ALARM_HORIZON, FLARM_ALARM_RADIUS, FLARM_ALARM_HEIGHT)
+		ti.AlarmLevel = alarmLevelForClosestApproach(tCPA, distCPA, vertCPA)
+	}
+}
+
 // Used to tune to our radios. We compare our estimate to real values for ADS-B Traffic.
 // If we tend to estimate too high, we reduce this value, otherwise we increase it.
 // We also try to correct for different transponder transmit power, by assuming that aircraft that fly high are bigger aircraft
@@ -535,24 +698,35 @@ func postProcessTraffic(ti *TrafficInfo) {
 	estimateDistance(ti)
 }
 
-// Send update to attached JSON client.
-func registerTrafficUpdate(ti TrafficInfo) {
+// Send update to attached JSON client. key is the traffic map key of ti. Must be called with trafficMutex held.
+func registerTrafficUpdate(key uint32, ti TrafficInfo) {
 	//logTraffic(ti) // moved to sendTrafficUpdates() to reduce SQLite log size
 	/*
 		if !ti.Position_valid { // Don't send unless a valid position exists.
 			return
 		}
 	*/ // Send all traffic to the websocket and let JS sort it out. This will provide user indication of why they see 1000 ES messages and no traffic.
+	if ti.Position_valid {
+		// Feed each new position into the tracker as it arrives. trafficInfoExtrapolator() would only pick up the
+		// latest one per second, at the wrong time.
+		updateTracker(key, &ti)
+	}
 	trafficUpdate.SendJSON(ti)
+	checkTrafficWatch(ti)
+	recordCoverage(key, ti)
 }
 
 func isTrafficAlertable(ti TrafficInfo) bool {
 	// Set alert bit if possible and traffic is within some threshold
-	// TODO: Could be more intelligent, taking into account headings etc.
 	if !ti.BearingDist_valid {
 		// If not able to calculate the distance to the target, let the alert bit be set always.
 		return true
 	}
+	if ti.CPA_valid {
+		// Alert if the predicted closest approach penetrates the traffic advisory volume within the prediction horizon.
+		return ti.CPADistance < TRAFFIC_ALERT_RADIUS && math.Abs(ti.CPAVertical) < TRAFFIC_ALERT_HEIGHT
+	}
+	// Without velocity information, fall back to a simple distance check
 	if ti.BearingDist_valid &&
 		ti.Distance < 3704 { // 3704 meters, 2 nm.
 		return true
@@ -561,6 +735,19 @@ func isTrafficAlertable(ti TrafficInfo) bool {
 	return false
 }
 
+// NACp for the traffic report. Coasted targets lose accuracy over time, so we use the tracker's uncertainty if it is
+// worse than the reported NACp.
+func trafficReportNACp(ti TrafficInfo) int {
+	if ti.PositionUncertainty <= 0 {
+		return ti.NACp
+	}
+	nacp := nacpForUncertainty(ti.PositionUncertainty)
+	if ti.NACp > 0 && nacp > ti.NACp {
+		nacp = ti.NACp
+	}
+	return nacp
+}
+
 func makeTrafficReportMsg(ti TrafficInfo) []byte {
 	msg := make([]byte, 28)
 	// See p.16.
@@ -648,7 +835,7 @@ func makeTrafficReportMsg(ti TrafficInfo) []byte {
 	}
 
 	// Position containment / navigational accuracy
-	msg[13] = ((byte(ti.NIC) << 4) & 0xF0) | (byte(ti.NACp) & 0x0F)
+	msg[13] = ((byte(ti.NIC) << 4) & 0xF0) | (byte(trafficReportNACp(ti)) & 0x0F)
 
 	// Horizontal velocity (speed).
 
@@ -677,6 +864,9 @@ func makeTrafficReportMsg(ti TrafficInfo) []byte {
 
 	//msg[27] is priority / emergency status per GDL90 spec (DO260B and DO282B are same codes)
 	msg[27] = ti.PriorityStatus << 4
+	if ti.Emergency != EMERGENCY_NONE {
+		msg[27] = ti.Emergency << 4
+	}
 
 	return prepareMessage(msg)
 }
@@ -1034,7 +1224,7 @@ func parseDownlinkReport(s string, signalLevel int) {
 	ti.Last_source = TRAFFIC_SOURCE_UAT
 	postProcessTraffic(&ti)
 	traffic[ti.Icao_addr] = ti
-	registerTrafficUpdate(ti)
+	registerTrafficUpdate(ti.Icao_addr, ti)
 	seenTraffic[ti.Icao_addr] = true // Mark as seen.
 }
 
@@ -1310,6 +1500,9 @@ func parseDump1090Message(buf string) {
 	if newTi.Squawk != nil {
 		ti.Squawk = int(*newTi.Squawk) // only provided by Mode S messages, so we don't do this in parseUAT.
 	}
+
+	ti.Last_DF = newTi.DF
+
 	// Set the target type. DF=18 messages are sent by ground station, so we look at CA
 	// (repurposed to Control Field in DF18) to determine if it's ADS-R or TIS-B.
 	if newTi.DF == 17 {
@@ -1377,7 +1570,7 @@ func parseDump1090Message(buf string) {
 	*/
 	postProcessTraffic(&ti)
 	traffic[ti.Icao_addr] = ti // Update information on this ICAO code.
-	registerTrafficUpdate(ti)
+	registerTrafficUpdate(ti.Icao_addr, ti)
 	seenTraffic[ti.Icao_addr] = true // Mark as seen.
 	//log.Printf("%v\n",traffic)
 	trafficMutex.Unlock()
@@ -1387,54 +1580,20 @@ func trafficInfoExtrapolator() {
 	for {
 		time.Sleep(1 * time.Second)
 		trafficMutex.Lock()
+		cleanupTrackers()
 		for key, ti := range traffic {
-			if ti.Age < 2 || !ti.Position_valid || !ti.Speed_valid {
+			if !ti.Position_valid {
 				continue
 			}
-			extrapolateTraffic(&ti)
-			//log.Printf("Extrapolating " + ti.Tail + " oldPos: %f,%f,%d, newPos: %f,%f,%d", ti.Lat_fix, ti.Lng_fix, ti.Alt_fix, ti.Lat, ti.Lng, ti.Alt)
-			traffic[key] = ti
+			// New positions are normally fed in by registerTrafficUpdate() already. This catches the rest.
+			// The estimate is applied when the target is sent, see applyTrackerEstimate()
+			updateTracker(key, &ti)
 		}
 		trafficMutex.Unlock()
 	}
 
 }
 
-func extrapolateTraffic(ti *TrafficInfo) {
-	if !ti.ExtrapolatedPosition {
-		ti.Lat_fix = ti.Lat
-		ti.Lng_fix = ti.Lng
-		ti.Alt_fix = ti.Alt
-		ti.Last_extrapolation = ti.Last_seen // to make computation below simpler
-	}
-
-	seconds := stratuxClock.Since(ti.Last_extrapolation).Seconds()
-	travelDist := float64(ti.Speed) * (seconds / 60 / 60) // speed is knots=nm per hour. /60/60 = nm per second
-
-	// Estimate alt
-	ti.Alt = int32(float64(ti.Alt) + (float64(ti.Vvel) * (seconds / 60)))
-	// Estimate position
-	lat, lng := calcLocationForBearingDistance(float64(ti.Lat), float64(ti.Lng), float64(ti.Track), travelDist)
-	ti.Lat = float32(lat)
-	ti.Lng = float32(lng)
-	// Estimate track
-	ti.Track += float32(seconds) * ti.TurnRate
-	for ti.Track < 0 {
-		ti.Track += 360
-	}
-	for ti.Track > 360 {
-		ti.Track -= 360
-	}
-	// ti.Track = ti.Track % 360
-
-	ti.ExtrapolatedPosition = true
-	ti.Last_extrapolation = stratuxClock.Time
-	
-	// TODO: should we call registerTrafficUpdate() to send this traffic to the web interface?
-	// Pro: web interface also shows interpolated position
-	// Con: it doesn't show the really received position with the age any more (i.e. age gets older but position updates)
-}
-
 /*
 updateDemoTraffic creates / updates a simulated traffic target for demonstration / debugging
 purpose. Target will circle clockwise around the current GPS position (if valid) or around
@@ -1537,7 +1696,7 @@ func updateDemoTraffic(icao uint32, tail string, relAlt float32, gs float64, off
 		// now insert this into the traffic map...
 		postProcessTraffic(&ti)
 		traffic[ti.Icao_addr] = ti
-		registerTrafficUpdate(ti)
+		registerTrafficUpdate(ti.Icao_addr, ti)
 		seenTraffic[ti.Icao_addr] = true
 	}
 }
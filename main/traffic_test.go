@@ -0,0 +1,188 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/b3nn0/stratux/common"
+)
+
+const (
+	testOwnshipLat = 44.0
+	testOwnshipLng = -88.5
+	testOwnshipAlt = 3000.0 // feet
+	testSpeedKts   = 100.0
+	testClosing    = 2 * testSpeedKts * 0.514444 // m/s, two aircraft at testSpeedKts head-on
+)
+
+// Ownship with a valid GPS fix at testOwnshipLat/Lng and testOwnshipAlt, flying straight and level.
+func setTestOwnship(t *testing.T, track, speedKts float64) {
+	initTestTraffic()
+	globalStatus.GPS_connected = true
+	mySituation.GPSFixQuality = 1
+	mySituation.GPSLastFixLocalTime = stratuxClock.Time
+	mySituation.GPSLatitude = testOwnshipLat
+	mySituation.GPSLongitude = testOwnshipLng
+	mySituation.GPSAltitudeMSL = testOwnshipAlt
+	mySituation.BaroPressureAltitude = testOwnshipAlt
+	mySituation.GPSTrueCourse = float32(track)
+	mySituation.GPSGroundSpeed = speedKts
+	mySituation.GPSTurnRate = 0
+	mySituation.GPSVerticalSpeed = 0
+	mySituation.BaroVerticalSpeed = 0
+	t.Cleanup(func() {
+		globalStatus.GPS_connected = false
+		mySituation.GPSFixQuality = 0
+	})
+}
+
+// Target north and east meters from ownship, altFt above it.
+func testConflictTarget(north, east, track, speedKts, altFt float64) TrafficInfo {
+	return TrafficInfo{
+		Lat:               float32(testOwnshipLat + common.Degrees(north/EARTH_RADIUS_METERS)),
+		Lng:               float32(testOwnshipLng + common.Degrees(east/(EARTH_RADIUS_METERS*math.Cos(common.Radians(testOwnshipLat))))),
+		Alt:               int32(testOwnshipAlt + altFt),
+		Position_valid:    true,
+		Track:             float32(track),
+		Speed:             uint16(speedKts),
+		Speed_valid:       true,
+		Distance:          math.Hypot(north, east),
+		BearingDist_valid: true,
+	}
+}
+
+func TestPredictClosestApproach(t *testing.T) {
+	tests := []struct {
+		name  string
+		ti    TrafficInfo
+		tCPA  float64 // -1 if any time is fine
+		alert bool
+		alarm uint8
+	}{
+		{"head-on 2 NM", testConflictTarget(3704, 0, 180, testSpeedKts, 0), 3704 / testClosing, true, 0},
+		{"head-on impact at 40 s", testConflictTarget(40*testClosing, 0, 180, testSpeedKts, 0), 40, true, 0},
+		{"head-on impact at 48 s", testConflictTarget(48*testClosing, 0, 180, testSpeedKts, 0), 40, true, 0},
+		{"head-on impact at 50 s", testConflictTarget(50*testClosing, 0, 180, testSpeedKts, 0), 40, false, 0},
+		{"head-on 900 ft above", testConflictTarget(3704, 0, 180, testSpeedKts, 900), 3704 / testClosing, true, 0},
+		{"head-on 1100 ft above", testConflictTarget(3704, 0, 180, testSpeedKts, 1100), 3704 / testClosing, false, 0},
+		{"head-on 1100 ft below", testConflictTarget(3704, 0, 180, testSpeedKts, -1100), 3704 / testClosing, false, 0},
+		{"overtaking", testConflictTarget(300, 0, 0, 60, 0), 14.5, true, 1},
+		{"overtaken", testConflictTarget(-300, 0, 0, 140, 0), 14.5, true, 1},
+		{"diverging abeam", testConflictTarget(0, 1500, 90, testSpeedKts, 0), 0, false, 0},
+		{"diverging behind", testConflictTarget(-1200, 0, 180, testSpeedKts, 0), 0, false, 0},
+		{"diverging inside the alert volume", testConflictTarget(-500, 0, 180, testSpeedKts, 0), 0, true, 0},
+		{"co-altitude 900 m abeam", testConflictTarget(3704, 900, 180, testSpeedKts, 0), 3704 / testClosing, true, 0},
+		{"co-altitude 950 m abeam", testConflictTarget(3704, 950, 180, testSpeedKts, 0), 3704 / testClosing, false, 0},
+		{"co-altitude parallel 1500 m abeam", testConflictTarget(0, 1500, 0, testSpeedKts, 0), -1, false, 0},
+		{"co-altitude 250 m abeam", testConflictTarget(8*testClosing, 250, 180, testSpeedKts, 0), 8, true, 3},
+	}
+	setTestOwnship(t, 0, testSpeedKts)
+	for _, tt := range tests {
+		ti := tt.ti
+		computeConflict(&ti)
+		if !ti.CPA_valid {
+			t.Errorf("%s: no closest approach", tt.name)
+			continue
+		}
+		if tt.tCPA >= 0 && math.Abs(ti.CPATime-tt.tCPA) > CONFLICT_PREDICTION_STEP {
+			t.Errorf("%s: closest approach in %.1f s, want %.1f s", tt.name, ti.CPATime, tt.tCPA)
+		}
+		if isTrafficAlertable(ti) != tt.alert || ti.AlarmLevel != tt.alarm {
+			t.Errorf("%s: alert %t alarm level %d, want %t %d (CPA %.0f m %.0f m in %.1f s)", tt.name,
+				isTrafficAlertable(ti), ti.AlarmLevel, tt.alert, tt.alarm, ti.CPADistance, ti.CPAVertical, ti.CPATime)
+		}
+	}
+}
+
+// FLARM alarm levels are predicted over the 18 s horizon: level 3 up to 8 s, level 2 up to 12 s and level 1 up to
+// 18 s before impact. Later conflicts are only alarms if the 300 m protection radius is entered within 18 s.
+func TestPredictClosestApproachFLARMHorizon(t *testing.T) {
+	tests := []struct {
+		impact float64 // seconds
+		alarm  uint8
+	}{
+		{0.5, 3},
+		{8, 3},
+		{8.5, 2},
+		{12, 2},
+		{12.5, 1},
+		{18, 1},
+		{20, 1}, // 206 m apart after 18 s
+		{22, 0}, // 412 m apart after 18 s
+		{30, 0},
+	}
+	setTestOwnship(t, 0, testSpeedKts)
+	for _, tt := range tests {
+		ti := testConflictTarget(tt.impact*testClosing, 0, 180, testSpeedKts, 0)
+		computeConflict(&ti)
+		if ti.AlarmLevel != tt.alarm || !isTrafficAlertable(ti) {
+			t.Errorf("impact in %.1f s: alarm level %d alert %t, want %d", tt.impact, ti.AlarmLevel, isTrafficAlertable(ti),
+				tt.alarm)
+		}
+	}
+
+	for _, tt := range []struct {
+		tCPA, dist, vert float64
+		alarm            uint8
+	}{
+		{18, 0, 0, 1},
+		{18.5, 0, 0, 0},
+		{5, 299, 0, 3},
+		{5, 300, 0, 0},
+		{5, 0, -149, 3},
+		{5, 0, -150, 0},
+	} {
+		if alarm := alarmLevelForClosestApproach(tt.tCPA, tt.dist, tt.vert); alarm != tt.alarm {
+			t.Errorf("%.1f s %.0f m %.0f m: alarm level %d, want %d", tt.tCPA, tt.dist, tt.vert, alarm, tt.alarm)
+		}
+	}
+}
+
+func TestProjectMotion(t *testing.T) {
+	speed := 50.0
+	radius := speed / common.Radians(3)
+	tests := []struct {
+		name                 string
+		track, turnRate, sec float64
+		north, east          float64
+	}{
+		{"north", 0, 0, 10, 500, 0},
+		{"east", 90, 0, 10, 0, 500},
+		{"south west", 225, 0, 10, -500 / math.Sqrt2, -500 / math.Sqrt2},
+		{"half right turn", 0, 3, 60, 0, 2 * radius},
+		{"half left turn", 0, -3, 60, 0, -2 * radius},
+		{"quarter right turn heading east", 90, 3, 30, -radius, radius},
+		{"full turn", 45, 3, 120, 0, 0},
+	}
+	for _, tt := range tests {
+		north, east, up := projectMotion(tt.track, speed, 2, tt.turnRate, tt.sec)
+		if math.Abs(north-tt.north) > 0.01 || math.Abs(east-tt.east) > 0.01 || up != 2*tt.sec {
+			t.Errorf("%s: %.2f/%.2f/%.2f, want %.2f/%.2f/%.2f", tt.name, north, east, up, tt.north, tt.east, 2*tt.sec)
+		}
+	}
+}
+
+func TestIsTrafficAlertableWithoutCPA(t *testing.T) {
+	setTestOwnship(t, 0, testSpeedKts)
+	ti := testConflictTarget(3000, 0, 180, testSpeedKts, 0)
+	ti.Speed_valid = false // no velocity, no prediction
+	computeConflict(&ti)
+	if ti.CPA_valid || !isTrafficAlertable(ti) {
+		t.Errorf("3000 m: CPA %t alert %t", ti.CPA_valid, isTrafficAlertable(ti))
+	}
+	ti.Distance = 4000
+	if isTrafficAlertable(ti) {
+		t.Error("4000 m: alert")
+	}
+	ti.BearingDist_valid = false
+	if !isTrafficAlertable(ti) {
+		t.Error("no bearing and distance: no alert")
+	}
+
+	ti = testConflictTarget(3704, 0, 180, testSpeedKts, 0)
+	mySituation.GPSFixQuality = 0
+	computeConflict(&ti)
+	if ti.CPA_valid || ti.AlarmLevel != 0 {
+		t.Errorf("without GPS: CPA %t alarm level %d", ti.CPA_valid, ti.AlarmLevel)
+	}
+}
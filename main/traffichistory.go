@@ -0,0 +1,199 @@
+/*
+	Copyright (c) 2026 Stratux contributors
+	Distributable under the terms of The "BSD New" License
+	that can be found in the LICENSE file, herein included
+	as part of this header.
+
+	traffichistory.go: Bounded per-target history of past positions ("trails"), for display and post-flight debriefing.
+*/
+
+package main
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	TRAFFIC_HISTORY_POINTS   = 600              // max points kept per target
+	TRAFFIC_HISTORY_TARGETS  = 500              // max number of targets kept. Oldest ones are dropped first
+	TRAFFIC_HISTORY_INTERVAL = 2 * time.Second  // min time between two points, unless the target maneuvers
+	TRAFFIC_HISTORY_MAX_AGE  = 2 * time.Hour    // targets not seen for this long are dropped
+)
+
+type TrafficHistoryPoint struct {
+	Time         time.Time // UTC
+	Lat          float32
+	Lng          float32
+	Alt          int32
+	Track        float32
+	Speed        uint16
+	Vvel         int16
+	Extrapolated bool
+}
+
+type TrafficTrail struct {
+	Icao_addr uint32
+	Addr_type uint8
+	Tail      string
+	Reg       string
+	Points    []TrafficHistoryPoint // oldest first
+}
+
+type trafficHistoryEntry struct {
+	Icao_addr uint32
+	Addr_type uint8
+	Tail      string
+	Reg       string
+	points    []TrafficHistoryPoint // ring buffer
+	head      int                   // index of the oldest point once the buffer is full
+	lastSeen  time.Time             // stratuxClock
+}
+
+// Keyed like the traffic map
+var trafficHistory map[uint32]*trafficHistoryEntry = make(map[uint32]*trafficHistoryEntry)
+var trafficHistoryMutex sync.Mutex
+
+// Trail updates (one TrafficTrail with only the new point) for the /trafficHistory websocket
+var trafficHistoryUpdate *uibroadcaster
+
+func (e *trafficHistoryEntry) add(p TrafficHistoryPoint) {
+	if len(e.points) < TRAFFIC_HISTORY_POINTS {
+		e.points = append(e.points, p)
+	} else {
+		e.points[e.head] = p
+		e.head = (e.head + 1) % TRAFFIC_HISTORY_POINTS
+	}
+}
+
+func (e *trafficHistoryEntry) last() *TrafficHistoryPoint {
+	if len(e.points) == 0 {
+		return nil
+	}
+	idx := (e.head + len(e.points) - 1) % len(e.points)
+	return &e.points[idx]
+}
+
+func (e *trafficHistoryEntry) trail() TrafficTrail {
+	points := make([]TrafficHistoryPoint, 0, len(e.points))
+	points = append(points, e.points[e.head:]...)
+	points = append(points, e.points[:e.head]...)
+	return TrafficTrail{Icao_addr: e.Icao_addr, Addr_type: e.Addr_type, Tail: e.Tail, Reg: e.Reg, Points: points}
+}
+
+// A new point is stored if enough time has passed or the target maneuvered since the last one.
+func shouldRecordHistoryPoint(last *TrafficHistoryPoint, ti TrafficInfo, now time.Time) bool {
+	if last == nil {
+		return true
+	}
+	if now.Sub(last.Time) >= TRAFFIC_HISTORY_INTERVAL {
+		return true
+	}
+	trackDiff := ti.Track - last.Track
+	if trackDiff < 0 {
+		trackDiff = -trackDiff
+	}
+	if trackDiff > 180 {
+		trackDiff = 360 - trackDiff
+	}
+	altDiff := ti.Alt - last.Alt
+	if altDiff < 0 {
+		altDiff = -altDiff
+	}
+	return trackDiff > 10 || altDiff > 200
+}
+
+// Called from sendTrafficUpdates() for every current target with a valid position.
+func recordTrafficHistory(key uint32, ti TrafficInfo) {
+	trafficHistoryMutex.Lock()
+	defer trafficHistoryMutex.Unlock()
+
+	entry, ok := trafficHistory[key]
+	if !ok {
+		if len(trafficHistory) >= TRAFFIC_HISTORY_TARGETS {
+			dropOldestTrafficHistory()
+		}
+		entry = &trafficHistoryEntry{points: make([]TrafficHistoryPoint, 0, 16)}
+		trafficHistory[key] = entry
+	}
+	entry.Icao_addr = ti.Icao_addr
+	entry.Addr_type = ti.Addr_type
+	entry.lastSeen = stratuxClock.Time
+	if len(ti.Tail) > 0 {
+		entry.Tail = ti.Tail
+	}
+	if len(ti.Reg) > 0 {
+		entry.Reg = ti.Reg
+	}
+
+	now := time.Now().UTC()
+	if !shouldRecordHistoryPoint(entry.last(), ti, now) {
+		return
+	}
+	p := TrafficHistoryPoint{
+		Time:         now,
+		Lat:          ti.Lat,
+		Lng:          ti.Lng,
+		Alt:          ti.Alt,
+		Track:        ti.Track,
+		Speed:        ti.Speed,
+		Vvel:         ti.Vvel,
+		Extrapolated: ti.ExtrapolatedPosition,
+	}
+	entry.add(p)
+	trafficHistoryUpdate.SendJSON(TrafficTrail{Icao_addr: entry.Icao_addr, Addr_type: entry.Addr_type, Tail: entry.Tail, Reg: entry.Reg, Points: []TrafficHistoryPoint{p}})
+}
+
+// Must be called with trafficHistoryMutex held
+func dropOldestTrafficHistory() {
+	var oldestKey uint32
+	var oldest *trafficHistoryEntry
+	for key, entry := range trafficHistory {
+		if oldest == nil || entry.lastSeen.Before(oldest.lastSeen) {
+			oldestKey = key
+			oldest = entry
+		}
+	}
+	if oldest != nil {
+		delete(trafficHistory, oldestKey)
+	}
+}
+
+func cleanupTrafficHistory() {
+	trafficHistoryMutex.Lock()
+	defer trafficHistoryMutex.Unlock()
+	for key, entry := range trafficHistory {
+		if stratuxClock.Since(entry.lastSeen) > TRAFFIC_HISTORY_MAX_AGE {
+			delete(trafficHistory, key)
+		}
+	}
+}
+
+/*
+	getTrafficTrails() returns the trails of all targets whose 24 bit address matches icao (both ICAO and non-ICAO
+		address types), or all trails if all is set. Most recently seen targets first.
+*/
+func getTrafficTrails(icao uint32, all bool) []TrafficTrail {
+	trafficHistoryMutex.Lock()
+	defer trafficHistoryMutex.Unlock()
+	return trafficTrails(icao, all)
+}
+
+// Must be called with trafficHistoryMutex held
+func trafficTrails(icao uint32, all bool) []TrafficTrail {
+	entries := make([]*trafficHistoryEntry, 0)
+	for key, entry := range trafficHistory {
+		if all || key&0xFFFFFF == icao {
+			entries = append(entries, entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].lastSeen.After(entries[j].lastSeen)
+	})
+	result := make([]TrafficTrail, len(entries))
+	for i, entry := range entries {
+		result[i] = entry.trail()
+	}
+	return result
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// Every point is sent to the /trafficHistory websocket, either with the trails it starts off with or as an update,
+// even while points are recorded during the connection.
+func TestTrafficHistoryWSNoGap(t *testing.T) {
+	initTestTraffic()
+	trafficHistory = make(map[uint32]*trafficHistoryEntry)
+	trafficHistoryUpdate = NewUIBroadcaster()
+	defer func() {
+		trafficHistoryUpdate = nil
+		trafficHistory = make(map[uint32]*trafficHistoryEntry)
+	}()
+	server := httptest.NewServer(websocket.Server{Handler: websocket.Handler(handleTrafficHistoryWS)})
+	defer server.Close()
+
+	// Each 300 ft step is recorded as a new point. The altitude identifies the point.
+	// Many full trails make the websocket start off slowly, so points are recorded meanwhile.
+	for key := uint32(1); key <= 100; key++ {
+		ti := TrafficInfo{Icao_addr: key, Lat: 44, Lng: -88.5, Position_valid: true}
+		for i := 0; i < TRAFFIC_HISTORY_POINTS; i++ {
+			ti.Alt += 300
+			recordTrafficHistory(key, ti)
+		}
+	}
+	stop := make(chan bool)
+	recorded := make(chan int32)
+	go func() {
+		ti := TrafficInfo{Icao_addr: 0xA12345, Lat: 44, Lng: -88.5, Position_valid: true}
+		for {
+			select {
+			case <-stop:
+				recorded <- ti.Alt
+				return
+			default:
+			}
+			ti.Alt += 300
+			recordTrafficHistory(0xA12345, ti)
+			time.Sleep(100 * time.Microsecond)
+		}
+	}()
+	// Connect while recording
+	for len(getTrafficTrails(0xA12345, false)) == 0 {
+		runtime.Gosched()
+	}
+
+	ws, err := websocket.Dial("ws"+strings.TrimPrefix(server.URL, "http"), "", server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+	received := make(map[int32]bool)
+	first := int32(0) // first point of the trail the websocket started off with
+	messages := 0
+	for {
+		ws.SetReadDeadline(time.Now().Add(500 * time.Millisecond))
+		var msg []byte
+		if err := websocket.Message.Receive(ws, &msg); err != nil {
+			break
+		}
+		var trail TrafficTrail
+		if err := json.Unmarshal(msg, &trail); err != nil {
+			t.Fatal(err)
+		}
+		if trail.Icao_addr != 0xA12345 {
+			continue
+		}
+		if first == 0 && len(trail.Points) > 0 {
+			first = trail.Points[0].Alt
+		}
+		for _, p := range trail.Points {
+			received[p.Alt] = true
+		}
+		if messages++; messages == 500 {
+			close(stop)
+		}
+	}
+	if messages < 500 {
+		close(stop)
+		<-recorded
+		t.Fatalf("%d messages received", messages)
+	}
+	last := <-recorded
+
+	if first == 0 {
+		t.Fatal("no points received")
+	}
+	missing := 0
+	for alt := first; alt <= last; alt += 300 {
+		if !received[alt] {
+			missing++
+		}
+	}
+	if missing > 0 {
+		t.Errorf("%d of %d points missing", missing, (last-first)/300+1)
+	}
+}
@@ -0,0 +1,324 @@
+/*
+	Copyright (c) 2026 Stratux contributors
+	Distributable under the terms of The "BSD New" License
+	that can be found in the LICENSE file, herein included
+	as part of this header.
+
+	trafficwatch.go: Traffic watch rules. Targets are matched against user defined rules (address, registration /
+		callsign pattern, squawk, emitter category, distance / altitude envelope) and structured events (first seen,
+		entered radius, lost) are sent to the /trafficEvents websocket and kept in a queryable event log.
+		Emergency events (emergency.go) use the same log and websocket.
+*/
+
+package main
+
+import (
+	"log"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/b3nn0/stratux/common"
+)
+
+const (
+	TRAFFIC_EVENT_FIRST_SEEN     = "first_seen"
+	TRAFFIC_EVENT_ENTERED_RADIUS = "entered_radius"
+	TRAFFIC_EVENT_LOST           = "lost"
+	TRAFFIC_EVENT_EMERGENCY      = "emergency" // see emergency.go
+	TRAFFIC_EVENT_EMERGENCY_END  = "emergency_end"
+
+	TRAFFIC_WATCH_LOST_TIMEOUT = 60 * time.Second // same as the traffic map
+	TRAFFIC_EVENT_LOG_SIZE     = 1000
+)
+
+/*
+	TrafficWatchRule is stored in the settings. All criteria that are set need to match; empty criteria match everything.
+		MaxDistance / MaxRelAlt define the envelope for "entered radius" events. Rules without envelope only generate
+		"first seen" and "lost" events.
+*/
+type TrafficWatchRule struct {
+	Name        string
+	Icao        string  // comma or space separated list of hex addresses
+	Pattern     string  // shell pattern matched against registration and callsign, e.g. "D-K*"
+	Squawk      string  // comma or space separated list of squawk codes
+	Categories  []int   // GDL90 emitter categories
+	MaxDistance float64 // NM. 0 = no distance limit
+	MaxRelAlt   int32   // feet above / below ownship. 0 = no altitude limit
+}
+
+type TrafficEvent struct {
+	EventID   int64     // sequence number, see getTrafficEvents()
+	Time      time.Time // UTC
+	Type      string    // TRAFFIC_EVENT_*
+	Rule      string    // empty for emergency events
+	Icao_addr uint32
+	Addr_type uint8
+	Tail      string
+	Reg       string
+	Squawk    int
+	Emergency uint8 // EMERGENCY_* for emergency events
+	Lat       float32
+	Lng       float32
+	Alt       int32
+	Distance  float64 // meters, 0 if unknown
+	OnGround  bool
+}
+
+type trafficWatchState struct {
+	inside   bool
+	lastSeen time.Time // stratuxClock
+	last     TrafficInfo
+}
+
+var trafficWatchMutex sync.Mutex
+var trafficWatchStates map[int]map[uint32]*trafficWatchState = make(map[int]map[uint32]*trafficWatchState) // rule index => target => state
+var trafficEventLog []TrafficEvent = make([]TrafficEvent, 0)
+var trafficEventNextId int64 = 1
+
+// Event channel for the /trafficEvents websocket
+var trafficEventUpdate *uibroadcaster
+
+func splitWatchList(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' })
+}
+
+/*
+	migrateWatchList() converts the plain watch list of older versions into traffic watch rules and clears it: one rule
+		for all hex addresses and one registration / callsign rule for each other entry. Called after the settings are
+		read.
+*/
+func migrateWatchList() {
+	if len(strings.TrimSpace(globalSettings.WatchList)) == 0 {
+		return
+	}
+	var addresses []string
+	var rules []TrafficWatchRule
+	for _, entry := range splitWatchList(strings.ToUpper(globalSettings.WatchList)) {
+		if _, err := strconv.ParseUint(entry, 16, 24); err == nil && len(entry) == 6 {
+			addresses = append(addresses, entry)
+		} else {
+			rules = append(rules, TrafficWatchRule{Name: "Watch list " + entry, Pattern: entry})
+		}
+	}
+	if len(addresses) > 0 {
+		rules = append([]TrafficWatchRule{{Name: "Watch list", Icao: strings.Join(addresses, " ")}}, rules...)
+	}
+	log.Printf("Converted watch list '%s' into %d traffic watch rules\n", globalSettings.WatchList, len(rules))
+	globalSettings.TrafficWatchRules = append(globalSettings.TrafficWatchRules, rules...)
+	globalSettings.WatchList = ""
+}
+
+// Checks the identity criteria of the rule (everything except the envelope)
+func (rule *TrafficWatchRule) matches(ti TrafficInfo) bool {
+	if len(rule.Icao) > 0 {
+		found := false
+		for _, code := range splitWatchList(rule.Icao) {
+			icao, err := strconv.ParseUint(code, 16, 32)
+			if err == nil && uint32(icao) == ti.Icao_addr {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(rule.Pattern) > 0 {
+		pattern := strings.ToUpper(rule.Pattern)
+		regMatch, _ := path.Match(pattern, strings.ToUpper(strings.TrimSpace(ti.Reg)))
+		tailMatch, _ := path.Match(pattern, strings.ToUpper(strings.TrimSpace(ti.Tail)))
+		if !regMatch && !tailMatch {
+			return false
+		}
+	}
+	if len(rule.Squawk) > 0 {
+		found := false
+		for _, code := range splitWatchList(rule.Squawk) {
+			squawk, err := strconv.Atoi(code)
+			if err == nil && squawk == ti.Squawk {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(rule.Categories) > 0 {
+		found := false
+		for _, cat := range rule.Categories {
+			if cat == int(ti.Emitter_category) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func (rule *TrafficWatchRule) hasEnvelope() bool {
+	return rule.MaxDistance > 0 || rule.MaxRelAlt > 0
+}
+
+// Checks if the target is within the rule's distance / altitude envelope around ownship.
+func (rule *TrafficWatchRule) inEnvelope(ti TrafficInfo, dist float64, distValid bool) bool {
+	if rule.MaxDistance > 0 && (!distValid || dist > rule.MaxDistance*1852.0) {
+		return false
+	}
+	if rule.MaxRelAlt > 0 {
+		if ti.Alt == 0 {
+			return false
+		}
+		myAlt := mySituation.GPSAltitudeMSL
+		if isTempPressValid() {
+			myAlt = mySituation.BaroPressureAltitude
+		}
+		if math.Abs(float64(ti.Alt)-float64(myAlt)) > float64(rule.MaxRelAlt) {
+			return false
+		}
+	}
+	return true
+}
+
+func watchKey(ti TrafficInfo) uint32 {
+	return uint32(ti.Addr_type)<<24 | ti.Icao_addr
+}
+
+// Must be called with trafficWatchMutex held
+func emitTrafficEvent(eventType string, rule TrafficWatchRule, ti TrafficInfo, dist float64) {
+	storeTrafficEvent(TrafficEvent{
+		Type:      eventType,
+		Rule:      rule.Name,
+		Icao_addr: ti.Icao_addr,
+		Addr_type: ti.Addr_type,
+		Tail:      ti.Tail,
+		Reg:       ti.Reg,
+		Squawk:    ti.Squawk,
+		Lat:       ti.Lat,
+		Lng:       ti.Lng,
+		Alt:       ti.Alt,
+		Distance:  dist,
+		OnGround:  ti.OnGround,
+	})
+}
+
+// Assigns EventID and Time, adds the event to the event log, the websocket and the datalog.
+func addTrafficEvent(ev TrafficEvent) {
+	trafficWatchMutex.Lock()
+	defer trafficWatchMutex.Unlock()
+	storeTrafficEvent(ev)
+}
+
+// Must be called with trafficWatchMutex held
+func storeTrafficEvent(ev TrafficEvent) {
+	ev.EventID = trafficEventNextId
+	ev.Time = time.Now().UTC()
+	trafficEventNextId++
+	trafficEventLog = append(trafficEventLog, ev)
+	if len(trafficEventLog) > TRAFFIC_EVENT_LOG_SIZE {
+		trafficEventLog = trafficEventLog[len(trafficEventLog)-TRAFFIC_EVENT_LOG_SIZE:]
+	}
+	trafficEventUpdate.SendJSON(ev)
+	logTrafficEvent(ev)
+}
+
+// Called from registerTrafficUpdate() for every update of a target.
+func checkTrafficWatch(ti TrafficInfo) {
+	if len(globalSettings.TrafficWatchRules) == 0 || ti.Age >= TRAFFIC_WATCH_LOST_TIMEOUT.Seconds() {
+		return
+	}
+	dist := 0.0
+	distValid := false
+	if isGPSValid() && ti.Position_valid {
+		dist, _ = common.Distance(float64(mySituation.GPSLatitude), float64(mySituation.GPSLongitude), float64(ti.Lat), float64(ti.Lng))
+		distValid = true
+	}
+
+	trafficWatchMutex.Lock()
+	defer trafficWatchMutex.Unlock()
+	key := watchKey(ti)
+	for i, rule := range globalSettings.TrafficWatchRules {
+		if !rule.matches(ti) {
+			continue
+		}
+		states, ok := trafficWatchStates[i]
+		if !ok {
+			states = make(map[uint32]*trafficWatchState)
+			trafficWatchStates[i] = states
+		}
+		state, ok := states[key]
+		if !ok {
+			state = &trafficWatchState{}
+			states[key] = state
+			emitTrafficEvent(TRAFFIC_EVENT_FIRST_SEEN, rule, ti, dist)
+		}
+		state.lastSeen = stratuxClock.Time
+		state.last = ti
+		if rule.hasEnvelope() {
+			inside := rule.inEnvelope(ti, dist, distValid)
+			if inside && !state.inside {
+				emitTrafficEvent(TRAFFIC_EVENT_ENTERED_RADIUS, rule, ti, dist)
+			}
+			state.inside = inside
+		}
+	}
+}
+
+// Called once per second from sendTrafficUpdates(). Generates "lost" events for targets that timed out.
+func checkTrafficWatchTimeouts() {
+	trafficWatchMutex.Lock()
+	defer trafficWatchMutex.Unlock()
+	for i, states := range trafficWatchStates {
+		if i >= len(globalSettings.TrafficWatchRules) {
+			delete(trafficWatchStates, i)
+			continue
+		}
+		rule := globalSettings.TrafficWatchRules[i]
+		for key, state := range states {
+			if stratuxClock.Since(state.lastSeen) > TRAFFIC_WATCH_LOST_TIMEOUT {
+				emitTrafficEvent(TRAFFIC_EVENT_LOST, rule, state.last, 0)
+				delete(states, key)
+			}
+		}
+	}
+}
+
+// Called when the rules change. Targets will be reported as first seen again.
+func resetTrafficWatch() {
+	trafficWatchMutex.Lock()
+	defer trafficWatchMutex.Unlock()
+	trafficWatchStates = make(map[int]map[uint32]*trafficWatchState)
+}
+
+/*
+	getTrafficEvents() returns logged events with an EventID greater than sinceId, optionally filtered by event type,
+		target address and rule name (empty / 0 = any).
+*/
+func getTrafficEvents(sinceId int64, eventType string, icao uint32, rule string) []TrafficEvent {
+	trafficWatchMutex.Lock()
+	defer trafficWatchMutex.Unlock()
+	result := make([]TrafficEvent, 0)
+	for _, ev := range trafficEventLog {
+		if ev.EventID <= sinceId {
+			continue
+		}
+		if len(eventType) > 0 && ev.Type != eventType {
+			continue
+		}
+		if icao != 0 && ev.Icao_addr != icao {
+			continue
+		}
+		if len(rule) > 0 && ev.Rule != rule {
+			continue
+		}
+		result = append(result, ev)
+	}
+	return result
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMigrateWatchList(t *testing.T) {
+	existing := TrafficWatchRule{Name: "Club", Pattern: "D-E*"}
+	tests := []struct {
+		watchList string
+		want      []TrafficWatchRule
+	}{
+		{"", []TrafficWatchRule{existing}},
+		{"  ", []TrafficWatchRule{existing}},
+		{"a12345", []TrafficWatchRule{existing, {Name: "Watch list", Icao: "A12345"}}},
+		{"A12345 3c6444,n123ab D-E*", []TrafficWatchRule{
+			existing,
+			{Name: "Watch list", Icao: "A12345 3C6444"},
+			{Name: "Watch list N123AB", Pattern: "N123AB"},
+			{Name: "Watch list D-E*", Pattern: "D-E*"},
+		}},
+		// Not an address: too short / long, or not hex
+		{"ABC 1234567 KOSHXY", []TrafficWatchRule{
+			existing,
+			{Name: "Watch list ABC", Pattern: "ABC"},
+			{Name: "Watch list 1234567", Pattern: "1234567"},
+			{Name: "Watch list KOSHXY", Pattern: "KOSHXY"},
+		}},
+	}
+	saved := globalSettings
+	defer func() { globalSettings = saved }()
+	for _, tt := range tests {
+		globalSettings.WatchList = tt.watchList
+		globalSettings.TrafficWatchRules = []TrafficWatchRule{existing}
+		migrateWatchList()
+		if !reflect.DeepEqual(globalSettings.TrafficWatchRules, tt.want) {
+			t.Errorf("%q: rules %+v, want %+v", tt.watchList, globalSettings.TrafficWatchRules, tt.want)
+		}
+		if len(strings.TrimSpace(globalSettings.WatchList)) != 0 {
+			t.Errorf("%q: watch list not cleared", tt.watchList)
+		}
+	}
+
+	// The converted rules match like the old watch list entries
+	globalSettings.WatchList = "A12345 N123AB"
+	globalSettings.TrafficWatchRules = nil
+	migrateWatchList()
+	matched := 0
+	for _, ti := range []TrafficInfo{{Icao_addr: 0xA12345}, {Icao_addr: 0xABCDEF, Reg: "N123AB"}, {Icao_addr: 0xABCDEF, Tail: "N123AB"}, {Icao_addr: 0x3C6444}} {
+		for _, rule := range globalSettings.TrafficWatchRules {
+			if rule.matches(ti) {
+				matched++
+			}
+		}
+	}
+	if matched != 3 {
+		t.Errorf("%d matches, want 3", matched)
+	}
+}
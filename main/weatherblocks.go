@@ -0,0 +1,128 @@
+/*
+	Copyright (c) 2026 Stratux contributors
+	Distributable under the terms of The "BSD New" License
+	that can be found in the LICENSE file, herein included
+	as part of this header.
+
+	weatherblocks.go: Passes the decoded lightning, cloud tops, icing and turbulence grids (global block products,
+		see uatparse/nexrad.go) and the SUA status records (uatparse/sua.go) on to the /weather websocket and MQTT.
+*/
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/b3nn0/stratux/uatparse"
+)
+
+// WeatherMessage.Type of the grid products.
+var weatherBlocksTypes = map[uint32]string{
+	uatparse.PRODUCT_ICING_LOW:       "ICING",
+	uatparse.PRODUCT_ICING_HIGH:      "ICING",
+	uatparse.PRODUCT_CLOUD_TOPS:      "CLOUDTOPS",
+	uatparse.PRODUCT_TURBULENCE_LOW:  "TURBULENCE",
+	uatparse.PRODUCT_TURBULENCE_HIGH: "TURBULENCE",
+	uatparse.PRODUCT_LIGHTNING:       "LIGHTNING",
+}
+
+// How long cached frames of the grid products are replayed, see fisbcache.go. Lightning is updated every
+// 5 minutes, the others hourly.
+var weatherBlocksMaxAge = map[uint32]time.Duration{
+	uatparse.PRODUCT_ICING_LOW:       90 * time.Minute,
+	uatparse.PRODUCT_ICING_HIGH:      90 * time.Minute,
+	uatparse.PRODUCT_CLOUD_TOPS:      90 * time.Minute,
+	uatparse.PRODUCT_TURBULENCE_LOW:  90 * time.Minute,
+	uatparse.PRODUCT_TURBULENCE_HIGH: 90 * time.Minute,
+	uatparse.PRODUCT_LIGHTNING:       10 * time.Minute,
+}
+
+type WeatherBlocksMessage struct {
+	WeatherMessage
+	ProductID uint32
+	Altitude  int32 // feet, icing and turbulence
+	Blocks    []uatparse.NEXRADBlock
+}
+
+type SUAStatusMessage struct {
+	WeatherMessage
+	SUA *uatparse.SUAStatus
+}
+
+// Called from parseInput() for every FIS-B frame.
+func registerWeatherBlocksFrame(f *uatparse.UATFrame) {
+	if f.Frame_type != 0 {
+		return
+	}
+	if len(f.Blocks) > 0 {
+		sendWeatherBlocks(f)
+	}
+	for i := range f.SUA {
+		sendSUAStatus(&f.SUA[i])
+	}
+}
+
+func sendWeatherBlocks(f *uatparse.UATFrame) {
+	t, ok := weatherBlocksTypes[f.Product_id]
+	if !ok {
+		return
+	}
+	maxLevel := uint16(0)
+	for _, b := range f.Blocks {
+		for _, v := range b.Intensity {
+			if v > maxLevel {
+				maxLevel = v
+			}
+		}
+	}
+	productTime := uatparse.FISBTime{Format: uatparse.FISB_TIME_HOURS, Hour: uint8(f.FISB_hours), Minute: uint8(f.FISB_minutes)}.Time(time.Now().UTC())
+
+	var msg WeatherBlocksMessage
+	msg.Type = t
+	msg.Location = fmt.Sprintf("%.2f,%.2f", f.Blocks[0].LatNorth, f.Blocks[0].LonWest)
+	msg.Time = productTime.Format("021504Z")
+	msg.Data = fmt.Sprintf("%s: %d blocks, max level %d", uatparse.GlobalBlockProductNames[f.Product_id], len(f.Blocks), maxLevel)
+	if alt := f.Blocks[0].Altitude; alt != 0 {
+		// Each altitude is a grid of its own
+		msg.Location += fmt.Sprintf(",%d", alt)
+		msg.Data = fmt.Sprintf("%s %dft: %d blocks, max level %d", uatparse.GlobalBlockProductNames[f.Product_id], alt, len(f.Blocks), maxLevel)
+	}
+	msg.LocaltimeReceived = stratuxClock.Time
+	msg.ProductID = f.Product_id
+	msg.Altitude = f.Blocks[0].Altitude
+	msg.Blocks = f.Blocks
+	weatherUpdate.SendJSON(msg)
+	mqttPublishJSON("weather/"+strings.ToLower(t), msg, false)
+}
+
+func sendSUAStatus(s *uatparse.SUAStatus) {
+	var msg SUAStatusMessage
+	msg.Type = "SUA"
+	msg.Location = s.Designator
+	if len(msg.Location) == 0 {
+		msg.Location = s.Name
+	}
+	if !s.Start.IsZero() {
+		msg.Time = s.Start.Format("021504Z")
+	}
+	typeName, ok := uatparse.SUATypeNames[s.Type]
+	if !ok {
+		typeName = s.Type
+	}
+	status := map[string]string{
+		uatparse.SUA_STATUS_WAITING: "scheduled",
+		uatparse.SUA_STATUS_PENDING: "pending",
+		uatparse.SUA_STATUS_HOT:     "hot",
+	}[s.Status]
+	msg.Data = fmt.Sprintf("%s %s %s, %s, %s-%s", typeName, s.Name, status, airspaceLimits(s.AltBottom, s.AltTop),
+		s.Start.Format("021504Z"), s.End.Format("021504Z"))
+	if len(s.Description) > 0 {
+		msg.Data += ", " + s.Description
+	}
+	msg.LocaltimeReceived = stratuxClock.Time
+	msg.SUA = s
+	weatherUpdate.SendJSON(msg)
+	mqttPublishJSON("weather/sua", msg, false)
+}
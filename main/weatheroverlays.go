@@ -0,0 +1,256 @@
+/*
+	Copyright (c) 2026 Stratux contributors
+	Distributable under the terms of The "BSD New" License
+	that can be found in the LICENSE file, herein included
+	as part of this header.
+
+	weatheroverlays.go: Keeps the FIS-B graphical products (NOTAM, AIRMET, SIGMET, SUA, G-AIRMET, CWA, NOTAM-TRA and
+		NOTAM-TFR, see uatparse/overlay.go) received over UAT and serves them as GeoJSON on /weather/overlays.
+		Segmented products are reassembled first. Reports are grouped by product, report number and year, the text
+		record of a report is attached to its graphical records. Reports are removed when cancelled, expired or not
+		rebroadcast for WEATHER_OVERLAY_TIMEOUT. NOTAM reports are passed on to airspace.go.
+*/
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/b3nn0/stratux/uatparse"
+)
+
+const (
+	WEATHER_OVERLAY_TIMEOUT = 60 * time.Minute
+	WEATHER_SEGMENT_TIMEOUT = 15 * time.Minute // incomplete segmented products
+	WEATHER_PRISM_SEGMENTS  = 36               // vertices of the polygon approximating a circular prism
+)
+
+type weatherOverlay struct {
+	ProductID    uint32
+	Location     string
+	ReportNumber uint16
+	ReportYear   uint16
+	Text         string
+	Records      map[string]uatparse.OverlayRecord // by record ID and object label
+	LastSeen     time.Time                         // stratuxClock
+}
+
+var weatherOverlays = make(map[string]*weatherOverlay)
+var weatherSegments = uatparse.NewSegmentAssembler(WEATHER_SEGMENT_TIMEOUT)
+var weatherOverlaysMutex sync.Mutex
+
+func weatherOverlayKey(productID uint32, number, year uint16) string {
+	return fmt.Sprintf("%d-%d-%d", productID, number, year)
+}
+
+func getWeatherOverlay(productID uint32, location string, number, year uint16) *weatherOverlay {
+	key := weatherOverlayKey(productID, number, year)
+	o, ok := weatherOverlays[key]
+	if !ok {
+		o = &weatherOverlay{
+			ProductID:    productID,
+			ReportNumber: number,
+			ReportYear:   year,
+			Records:      make(map[string]uatparse.OverlayRecord),
+		}
+		weatherOverlays[key] = o
+	}
+	if len(location) > 0 {
+		o.Location = location
+	}
+	o.LastSeen = stratuxClock.Time
+	return o
+}
+
+// Called from parseInput() for every FIS-B frame of an uplink message. station identifies the ground station.
+func registerWeatherOverlayFrame(f *uatparse.UATFrame, station string) {
+	if f.Frame_type != 0 || !uatparse.IsOverlayProduct(f.Product_id) {
+		return
+	}
+	weatherOverlaysMutex.Lock()
+	defer weatherOverlaysMutex.Unlock()
+
+	p := f.Product
+	if f.SegmentCount > 0 {
+		data := weatherSegments.Add(station, f, stratuxClock.Time)
+		if data == nil {
+			return // waiting for more segments
+		}
+		p, _ = uatparse.DecodeFISBProduct(f.Product_id, data)
+	}
+	if p == nil {
+		return
+	}
+
+	updated := make(map[string]*weatherOverlay)
+	for _, t := range p.TextRecords {
+		key := weatherOverlayKey(p.ProductID, t.ReportNumber, t.ReportYear)
+		if !t.Active {
+			delete(weatherOverlays, key)
+			deleteAirspaceRestriction(key)
+			continue
+		}
+		o := getWeatherOverlay(p.ProductID, p.LocationIdentifier, t.ReportNumber, t.ReportYear)
+		o.Text = t.Text
+		updated[key] = o
+	}
+	for _, r := range p.Overlays {
+		o := getWeatherOverlay(p.ProductID, p.LocationIdentifier, r.ReportNumber, r.ReportYear)
+		o.Records[fmt.Sprintf("%d/%s", r.RecordID, r.ObjectLabel)] = r
+		updated[weatherOverlayKey(p.ProductID, r.ReportNumber, r.ReportYear)] = o
+	}
+	if isRestrictionProduct(p.ProductID) {
+		for key, o := range updated {
+			updateAirspaceRestriction(key, o)
+		}
+	}
+}
+
+// Removes reports that timed out or whose records all ended.
+func cleanupWeatherOverlays(now time.Time) {
+	for key, o := range weatherOverlays {
+		if stratuxClock.Since(o.LastSeen) > WEATHER_OVERLAY_TIMEOUT {
+			delete(weatherOverlays, key)
+			continue
+		}
+		if len(o.Records) == 0 {
+			continue
+		}
+		ended := true
+		for _, r := range o.Records {
+			if _, end := r.Validity(now); end.IsZero() || end.After(now) {
+				ended = false
+			}
+		}
+		if ended {
+			delete(weatherOverlays, key)
+		}
+	}
+}
+
+// Ellipse around the prism center, see uatparse.RestrictionShape.
+func prismRing(r uatparse.OverlayRecord) [][2]float64 {
+	shape := uatparse.RestrictionShape{
+		Kind:        uatparse.RESTRICTION_SHAPE_CIRCLE,
+		Points:      r.Points[:1],
+		RadiusLat:   r.RadiusLat,
+		RadiusLng:   r.RadiusLng,
+		Orientation: r.Orientation,
+	}
+	return geoJSONRing(shape.Ring(WEATHER_PRISM_SEGMENTS))
+}
+
+func geoJSONRing(points []uatparse.GeoPoint) [][2]float64 {
+	ring := make([][2]float64, 0, len(points))
+	for _, p := range points {
+		ring = append(ring, [2]float64{p.Lon, p.Lat})
+	}
+	return ring
+}
+
+// Closed ring of a polygon record, and the bottom and top altitude.
+func polygonRing(r uatparse.OverlayRecord) ([][2]float64, int32, int32) {
+	points, bottom, top := r.Polygon()
+	ring := make([][2]float64, 0, len(points)+1)
+	for _, p := range points {
+		ring = append(ring, [2]float64{p.Lon, p.Lat})
+	}
+	if ring[0] != ring[len(ring)-1] {
+		ring = append(ring, ring[0])
+	}
+	return ring, bottom, top
+}
+
+func weatherOverlayFeature(o *weatherOverlay, r uatparse.OverlayRecord, now time.Time) (geoJSONFeature, bool) {
+	props := map[string]interface{}{
+		"product":        uatparse.FISBProductNames[o.ProductID],
+		"product_id":     o.ProductID,
+		"report":         fmt.Sprintf("%d-%02d", o.ReportNumber, o.ReportYear),
+		"location":       o.Location,
+		"label":          r.ObjectLabel,
+		"record_id":      r.RecordID,
+		"object_element": r.ObjectElement,
+		"object_type":    r.ObjectType,
+		"object_status":  r.ObjectStatus,
+		"qualifier":      r.ObjectQualifier,
+		"altitude_ref":   "MSL",
+		"text":           o.Text,
+		"age":            stratuxClock.Since(o.LastSeen).Seconds(),
+	}
+	if r.AltitudeAGL() {
+		props["altitude_ref"] = "AGL"
+	}
+	start, end := r.Validity(now)
+	if !start.IsZero() {
+		props["start"] = start.Format(time.RFC3339)
+	}
+	if !end.IsZero() {
+		props["end"] = end.Format(time.RFC3339)
+	}
+
+	var geometry geoJSONGeometry
+	switch r.Geometry {
+	case uatparse.GEOMETRY_POLYGON_MSL, uatparse.GEOMETRY_POLYGON_AGL:
+		if len(r.Points) < 3 {
+			return geoJSONFeature{}, false
+		}
+		ring, bottom, top := polygonRing(r)
+		geometry = geoJSONGeometry{Type: "Polygon", Coordinates: [][][2]float64{ring}}
+		props["altitude_bottom"], props["altitude_top"] = bottom, top
+	case uatparse.GEOMETRY_PRISM_MSL, uatparse.GEOMETRY_PRISM_AGL:
+		geometry = geoJSONGeometry{Type: "Polygon", Coordinates: [][][2]float64{prismRing(r)}}
+		props["altitude_bottom"], props["altitude_top"] = r.AltBottom, r.AltTop
+		props["radius"] = math.Max(r.RadiusLat, r.RadiusLng)
+	case uatparse.GEOMETRY_POLYLINE_MSL, uatparse.GEOMETRY_POLYLINE_AGL:
+		if len(r.Points) < 2 {
+			return geoJSONFeature{}, false
+		}
+		line := make([][3]float64, 0, len(r.Points))
+		for _, p := range r.Points {
+			line = append(line, [3]float64{p.Lon, p.Lat, float64(p.Alt)})
+		}
+		geometry = geoJSONGeometry{Type: "LineString", Coordinates: line}
+	case uatparse.GEOMETRY_POINT_AGL, uatparse.GEOMETRY_POINT_MSL:
+		p := r.Points[0]
+		geometry = geoJSONGeometry{Type: "Point", Coordinates: [3]float64{p.Lon, p.Lat, float64(p.Alt)}}
+	default:
+		return geoJSONFeature{}, false
+	}
+	return geoJSONFeature{Type: "Feature", Geometry: geometry, Properties: props}, true
+}
+
+// All current graphical reports, optionally only those of the given product IDs.
+func getWeatherOverlays(products map[uint32]bool) geoJSONFeatureCollection {
+	now := time.Now().UTC()
+	weatherOverlaysMutex.Lock()
+	defer weatherOverlaysMutex.Unlock()
+	cleanupWeatherOverlays(now)
+
+	keys := make([]string, 0, len(weatherOverlays))
+	for key, o := range weatherOverlays {
+		if len(products) == 0 || products[o.ProductID] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	fc := geoJSONFeatureCollection{Type: "FeatureCollection", Features: make([]geoJSONFeature, 0)}
+	for _, key := range keys {
+		o := weatherOverlays[key]
+		recordKeys := make([]string, 0, len(o.Records))
+		for rk := range o.Records {
+			recordKeys = append(recordKeys, rk)
+		}
+		sort.Strings(recordKeys)
+		for _, rk := range recordKeys {
+			if feature, ok := weatherOverlayFeature(o, o.Records[rk], now); ok {
+				fc.Features = append(fc.Features, feature)
+			}
+		}
+	}
+	return fc
+}
@@ -0,0 +1,382 @@
+/*
+	Copyright (c) 2026 Stratux contributors
+	Distributable under the terms of The "BSD New" License
+	that can be found in the LICENSE file, herein included
+	as part of this header.
+
+	mqtt.go: Minimal MQTT 3.1.1 client for publishing. Supports QoS 0 and 1, retained messages, a last will, keep
+		alive and TLS. There is no subscribe and no session persistence: every connection uses a clean session. QoS 1
+		messages that were not acknowledged when the connection is lost can be fetched with Unacknowledged() and
+		published again on the next connection.
+		See https://docs.oasis-open.org/mqtt/mqtt/v3.1.1/mqtt-v3.1.1.html
+*/
+
+package mqtt
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	PROTOCOL_LEVEL = 4 // MQTT 3.1.1
+
+	CONNECT    = 1
+	CONNACK    = 2
+	PUBLISH    = 3
+	PUBACK     = 4
+	PINGREQ    = 12
+	PINGRESP   = 13
+	DISCONNECT = 14
+
+	MAX_REMAINING_LENGTH = 268435455
+)
+
+var (
+	ErrClosed       = errors.New("mqtt: connection closed")
+	ErrTimeout      = errors.New("mqtt: broker did not respond in time")
+	ErrProtocol     = errors.New("mqtt: protocol error")
+	ErrTooLarge     = errors.New("mqtt: packet too large")
+	ErrInflightFull = errors.New("mqtt: too many unacknowledged messages")
+	ErrQoS          = errors.New("mqtt: only QoS 0 and 1 are supported")
+)
+
+// CONNACK return codes 1..5
+var connackErrors = []string{
+	"",
+	"unacceptable protocol version",
+	"identifier rejected",
+	"server unavailable",
+	"bad user name or password",
+	"not authorized",
+}
+
+type Options struct {
+	ClientID    string
+	Username    string // optional
+	Password    string // optional
+	KeepAlive   time.Duration
+	DialTimeout time.Duration
+	TLS         *tls.Config // nil for plain TCP
+
+	WillTopic   string // optional last will, published by the broker when we disappear
+	WillPayload []byte
+	WillRetain  bool
+
+	MaxInflight int // QoS 1 messages without PUBACK, 0 for no limit
+}
+
+type Message struct {
+	Topic   string
+	Payload []byte
+	Retain  bool
+}
+
+type inflightMessage struct {
+	msg Message
+	seq uint64 // publish order
+}
+
+type Client struct {
+	opts     Options
+	conn     net.Conn
+	writeMu  sync.Mutex
+	mu       sync.Mutex // protects the fields below
+	nextID   uint16
+	seq      uint64
+	inflight map[uint16]*inflightMessage
+	err      error
+	done     chan struct{}
+}
+
+// Connects to the broker at address (host:port) and waits for its CONNACK.
+func Dial(address string, opts Options) (*Client, error) {
+	if opts.KeepAlive <= 0 {
+		opts.KeepAlive = 60 * time.Second
+	}
+	if opts.DialTimeout <= 0 {
+		opts.DialTimeout = 10 * time.Second
+	}
+	dialer := &net.Dialer{Timeout: opts.DialTimeout}
+	var conn net.Conn
+	var err error
+	if opts.TLS != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", address, opts.TLS)
+	} else {
+		conn, err = dialer.Dial("tcp", address)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Client{
+		opts:     opts,
+		conn:     conn,
+		inflight: make(map[uint16]*inflightMessage),
+		done:     make(chan struct{}),
+	}
+	conn.SetDeadline(time.Now().Add(opts.DialTimeout))
+	if _, err := conn.Write(c.connectPacket()); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	r := bufio.NewReader(conn)
+	typ, body, err := readPacket(r)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if typ != CONNACK || len(body) != 2 {
+		conn.Close()
+		return nil, ErrProtocol
+	}
+	if rc := body[1]; rc != 0 {
+		conn.Close()
+		if int(rc) < len(connackErrors) {
+			return nil, fmt.Errorf("mqtt: connection refused: %s", connackErrors[rc])
+		}
+		return nil, fmt.Errorf("mqtt: connection refused: code %d", rc)
+	}
+	conn.SetDeadline(time.Time{})
+
+	go c.reader(r)
+	go c.pinger()
+	return c, nil
+}
+
+func (c *Client) connectPacket() []byte {
+	flags := byte(0x02) // clean session
+	payload := appendString(nil, c.opts.ClientID)
+	if len(c.opts.WillTopic) > 0 {
+		flags |= 0x04
+		if c.opts.WillRetain {
+			flags |= 0x20
+		}
+		payload = appendString(payload, c.opts.WillTopic)
+		payload = appendBytes(payload, c.opts.WillPayload)
+	}
+	if len(c.opts.Username) > 0 {
+		flags |= 0x80
+		payload = appendString(payload, c.opts.Username)
+		if len(c.opts.Password) > 0 {
+			flags |= 0x40
+			payload = appendString(payload, c.opts.Password)
+		}
+	}
+	keepAlive := uint16(c.opts.KeepAlive / time.Second)
+	body := appendString(nil, "MQTT")
+	body = append(body, PROTOCOL_LEVEL, flags, byte(keepAlive>>8), byte(keepAlive))
+	body = append(body, payload...)
+	return packet(CONNECT<<4, body)
+}
+
+// Publishes a message. QoS 2 is not supported and returns ErrQoS. With QoS 1, the PUBACK is handled in the
+// background: Publish() only blocks for writing to the socket. A QoS 1 message whose write fails is kept as
+// unacknowledged, as it may have reached the broker in part.
+func (c *Client) Publish(topic string, payload []byte, qos byte, retain bool) error {
+	if qos > 1 {
+		return ErrQoS
+	}
+	select {
+	case <-c.done:
+		return c.Err()
+	default:
+	}
+	if 2+len(topic)+2+len(payload) > MAX_REMAINING_LENGTH {
+		return ErrTooLarge
+	}
+	header := byte(PUBLISH<<4) | qos<<1
+	if retain {
+		header |= 0x01
+	}
+	body := appendString(nil, topic)
+	if qos > 0 {
+		c.mu.Lock()
+		if c.opts.MaxInflight > 0 && len(c.inflight) >= c.opts.MaxInflight {
+			c.mu.Unlock()
+			return ErrInflightFull
+		}
+		c.nextID++
+		if c.nextID == 0 {
+			c.nextID = 1 // 0 is not a valid packet identifier
+		}
+		id := c.nextID
+		c.seq++
+		c.inflight[id] = &inflightMessage{Message{topic, payload, retain}, c.seq}
+		c.mu.Unlock()
+		body = append(body, byte(id>>8), byte(id))
+	}
+	body = append(body, payload...)
+	return c.write(packet(header, body))
+}
+
+// Number of QoS 1 messages that were not acknowledged yet
+func (c *Client) Inflight() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.inflight)
+}
+
+// QoS 1 messages that were not acknowledged, in the order they were published. After the connection is lost, these
+// may or may not have reached the broker and should be published again on a new connection.
+func (c *Client) Unacknowledged() []Message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	pending := make([]*inflightMessage, 0, len(c.inflight))
+	for _, m := range c.inflight {
+		pending = append(pending, m)
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i].seq < pending[j].seq })
+	msgs := make([]Message, len(pending))
+	for i, m := range pending {
+		msgs[i] = m.msg
+	}
+	return msgs
+}
+
+// Closed when the connection is lost. Err() returns the reason.
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+func (c *Client) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// Sends DISCONNECT, so the broker doesn't publish the last will, and closes the connection.
+func (c *Client) Close() error {
+	c.write([]byte{DISCONNECT << 4, 0})
+	c.fail(ErrClosed)
+	return nil
+}
+
+func (c *Client) write(p []byte) error {
+	select {
+	case <-c.done:
+		return c.Err()
+	default:
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(c.opts.KeepAlive))
+	if _, err := c.conn.Write(p); err != nil {
+		c.fail(err)
+		return err
+	}
+	return nil
+}
+
+func (c *Client) fail(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return
+	}
+	c.err = err
+	c.conn.Close()
+	close(c.done)
+}
+
+func (c *Client) reader(r *bufio.Reader) {
+	for {
+		// The broker answers our PINGREQ at least every KeepAlive
+		c.conn.SetReadDeadline(time.Now().Add(c.opts.KeepAlive * 3 / 2))
+		typ, body, err := readPacket(r)
+		if err != nil {
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				err = ErrTimeout
+			}
+			c.fail(err)
+			return
+		}
+		switch typ {
+		case PUBACK:
+			if len(body) >= 2 {
+				c.mu.Lock()
+				delete(c.inflight, uint16(body[0])<<8|uint16(body[1]))
+				c.mu.Unlock()
+			}
+		case PINGRESP:
+		default:
+			c.fail(ErrProtocol) // we didn't subscribe to anything
+			return
+		}
+	}
+}
+
+func (c *Client) pinger() {
+	ticker := time.NewTicker(c.opts.KeepAlive / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			if c.write([]byte{PINGREQ << 4, 0}) != nil {
+				return
+			}
+		}
+	}
+}
+
+func readPacket(r *bufio.Reader) (typ byte, body []byte, err error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	length := 0
+	for shift := uint(0); ; shift += 7 {
+		if shift > 21 {
+			return 0, nil, ErrProtocol
+		}
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		length |= int(b&0x7F) << shift
+		if b&0x80 == 0 {
+			break
+		}
+	}
+	body = make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return header >> 4, body, nil
+}
+
+// Fixed header with remaining length, then body
+func packet(header byte, body []byte) []byte {
+	p := []byte{header}
+	length := len(body)
+	for {
+		b := byte(length & 0x7F)
+		length >>= 7
+		if length > 0 {
+			b |= 0x80
+		}
+		p = append(p, b)
+		if length == 0 {
+			break
+		}
+	}
+	return append(p, body...)
+}
+
+func appendString(b []byte, s string) []byte {
+	return appendBytes(b, []byte(s))
+}
+
+func appendBytes(b []byte, data []byte) []byte {
+	b = append(b, byte(len(data)>>8), byte(len(data)))
+	return append(b, data...)
+}
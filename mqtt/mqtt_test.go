@@ -0,0 +1,351 @@
+package mqtt
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"testing"
+	"time"
+)
+
+// In-process broker on the loopback interface. It accepts connections, answers CONNECT with CONNACK and passes the
+// packets it receives on, acknowledging QoS 1 publishes only when told to.
+type fakeBroker struct {
+	ln    net.Listener
+	conns chan *brokerConn
+}
+
+type brokerConn struct {
+	conn    net.Conn
+	r       *bufio.Reader
+	connect []byte // body of the CONNECT packet
+}
+
+type brokerPacket struct {
+	typ   byte
+	flags byte // lower 4 bits of the fixed header
+	body  []byte
+}
+
+func newFakeBroker(t *testing.T, returnCode byte) *fakeBroker {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &fakeBroker{ln: ln, conns: make(chan *brokerConn, 4)}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			bc := &brokerConn{conn: conn, r: bufio.NewReader(conn)}
+			typ, body, err := readPacket(bc.r)
+			if err != nil || typ != CONNECT {
+				conn.Close()
+				continue
+			}
+			bc.connect = body
+			conn.Write([]byte{CONNACK << 4, 2, 0, returnCode})
+			b.conns <- bc
+		}
+	}()
+	return b
+}
+
+func (b *fakeBroker) addr() string {
+	return b.ln.Addr().String()
+}
+
+func (b *fakeBroker) accept(t *testing.T) *brokerConn {
+	select {
+	case c := <-b.conns:
+		t.Cleanup(func() { c.conn.Close() })
+		return c
+	case <-time.After(5 * time.Second):
+		t.Fatal("no connection")
+	}
+	return nil
+}
+
+// Next packet other than PINGREQ
+func (c *brokerConn) read(t *testing.T) brokerPacket {
+	for {
+		c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		header, err := c.r.Peek(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		flags := header[0] & 0x0F
+		typ, body, err := readPacket(c.r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if typ == PINGREQ {
+			c.conn.Write([]byte{PINGRESP << 4, 0})
+			continue
+		}
+		return brokerPacket{typ, flags, body}
+	}
+}
+
+// Topic, packet identifier (0 for QoS 0) and payload of a PUBLISH packet
+func (p brokerPacket) publish(t *testing.T) (topic string, id uint16, payload []byte) {
+	if p.typ != PUBLISH || len(p.body) < 2 {
+		t.Fatalf("packet type %d, want PUBLISH", p.typ)
+	}
+	l := int(p.body[0])<<8 | int(p.body[1])
+	topic = string(p.body[2 : 2+l])
+	rest := p.body[2+l:]
+	if p.flags&0x06 != 0 {
+		id = uint16(rest[0])<<8 | uint16(rest[1])
+		rest = rest[2:]
+	}
+	return topic, id, rest
+}
+
+func (c *brokerConn) puback(id uint16) {
+	c.conn.Write([]byte{PUBACK << 4, 2, byte(id >> 8), byte(id)})
+}
+
+func dialTest(t *testing.T, b *fakeBroker, opts Options) (*Client, *brokerConn) {
+	if opts.KeepAlive == 0 {
+		opts.KeepAlive = 10 * time.Second
+	}
+	client, err := Dial(b.addr(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client, b.accept(t)
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	for i := 0; i < 500; i++ {
+		if cond() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("timeout waiting for %s", what)
+}
+
+func TestConnectFlags(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  Options
+		flags byte
+		// payload fields after the client ID
+		fields []string
+	}{
+		{"clean session", Options{ClientID: "stratux"}, 0x02, nil},
+		{"will", Options{ClientID: "stratux", WillTopic: "stratux/online", WillPayload: []byte("false")}, 0x06,
+			[]string{"stratux/online", "false"}},
+		{"retained will", Options{ClientID: "stratux", WillTopic: "stratux/online", WillPayload: []byte("false"),
+			WillRetain: true}, 0x26, []string{"stratux/online", "false"}},
+		{"user name", Options{ClientID: "stratux", Username: "pilot"}, 0x82, []string{"pilot"}},
+		{"user name and password", Options{ClientID: "stratux", Username: "pilot", Password: "secret"}, 0xC2,
+			[]string{"pilot", "secret"}},
+		{"all", Options{ClientID: "s1", Username: "pilot", Password: "secret", WillTopic: "s1/online",
+			WillPayload: []byte("false"), WillRetain: true}, 0xE6, []string{"s1/online", "false", "pilot", "secret"}},
+		// A password without user name is not allowed by MQTT 3.1.1
+		{"password only", Options{ClientID: "stratux", Password: "secret"}, 0x02, nil},
+	}
+	for _, tt := range tests {
+		b := newFakeBroker(t, 0)
+		tt.opts.KeepAlive = 30 * time.Second
+		_, c := dialTest(t, b, tt.opts)
+
+		want := appendString(nil, "MQTT")
+		want = append(want, PROTOCOL_LEVEL, tt.flags, 0, 30)
+		want = appendString(want, tt.opts.ClientID)
+		for _, f := range tt.fields {
+			want = appendString(want, f)
+		}
+		if !bytes.Equal(c.connect, want) {
+			t.Errorf("%s: CONNECT % X\nwant % X", tt.name, c.connect, want)
+		}
+	}
+}
+
+func TestConnectRefused(t *testing.T) {
+	b := newFakeBroker(t, 5)
+	if _, err := Dial(b.addr(), Options{ClientID: "stratux"}); err == nil || err.Error() != "mqtt: connection refused: not authorized" {
+		t.Errorf("error %v", err)
+	}
+}
+
+func TestPublish(t *testing.T) {
+	b := newFakeBroker(t, 0)
+	client, c := dialTest(t, b, Options{ClientID: "stratux"})
+
+	tests := []struct {
+		topic   string
+		payload string
+		qos     byte
+		retain  bool
+		flags   byte
+	}{
+		{"stratux/situation", "{}", 0, false, 0x00},
+		{"stratux/traffic/a12345", "{\"Icao_addr\":10560325}", 0, true, 0x01},
+		{"stratux/status", "{}", 1, false, 0x02},
+		{"stratux/online", "true", 1, true, 0x03},
+		{"stratux/traffic/a12345", "", 1, true, 0x03}, // clears the retained message
+	}
+	for _, tt := range tests {
+		if err := client.Publish(tt.topic, []byte(tt.payload), tt.qos, tt.retain); err != nil {
+			t.Fatal(err)
+		}
+		p := c.read(t)
+		topic, id, payload := p.publish(t)
+		if p.flags != tt.flags || topic != tt.topic || string(payload) != tt.payload || (id != 0) != (tt.qos > 0) {
+			t.Errorf("%s: flags %X topic %s id %d payload %q", tt.topic, p.flags, topic, id, payload)
+		}
+		if id != 0 {
+			c.puback(id)
+		}
+	}
+	waitFor(t, "PUBACK", func() bool { return client.Inflight() == 0 })
+}
+
+// QoS 2 is rejected without sending anything
+func TestPublishQoS2(t *testing.T) {
+	b := newFakeBroker(t, 0)
+	client, c := dialTest(t, b, Options{ClientID: "stratux"})
+	if err := client.Publish("stratux/situation", []byte("{}"), 2, false); err != ErrQoS {
+		t.Errorf("QoS 2: %v", err)
+	}
+	if client.Inflight() != 0 {
+		t.Errorf("%d messages inflight", client.Inflight())
+	}
+	client.Publish("stratux/status", []byte("{}"), 0, false)
+	if topic, _, _ := c.read(t).publish(t); topic != "stratux/status" {
+		t.Errorf("received %s", topic)
+	}
+}
+
+// A PUBACK removes the message from the inflight set, the others stay unacknowledged in publish order.
+func TestPuback(t *testing.T) {
+	b := newFakeBroker(t, 0)
+	client, c := dialTest(t, b, Options{ClientID: "stratux", MaxInflight: 3})
+
+	ids := make(map[string]uint16)
+	for _, topic := range []string{"t/1", "t/2", "t/3"} {
+		if err := client.Publish(topic, []byte(topic), 1, false); err != nil {
+			t.Fatal(err)
+		}
+		topic, id, _ := c.read(t).publish(t)
+		ids[topic] = id
+	}
+	if client.Inflight() != 3 {
+		t.Errorf("%d messages inflight, want 3", client.Inflight())
+	}
+	if err := client.Publish("t/4", nil, 1, false); err != ErrInflightFull {
+		t.Errorf("inflight full: %v", err)
+	}
+	if err := client.Publish("t/5", nil, 0, false); err != nil {
+		t.Errorf("QoS 0 with inflight full: %v", err)
+	}
+	c.read(t)
+
+	c.puback(ids["t/2"])
+	waitFor(t, "PUBACK", func() bool { return client.Inflight() == 2 })
+	msgs := client.Unacknowledged()
+	if len(msgs) != 2 || msgs[0].Topic != "t/1" || msgs[1].Topic != "t/3" {
+		t.Errorf("unacknowledged %v", msgs)
+	}
+	// Unknown packet identifiers are ignored
+	c.puback(0x7FFF)
+	c.puback(ids["t/1"])
+	waitFor(t, "PUBACK", func() bool { return client.Inflight() == 1 })
+	if err := client.Publish("t/6", nil, 1, false); err != nil {
+		t.Errorf("publish after PUBACK: %v", err)
+	}
+}
+
+// The messages that weren't acknowledged when the connection dropped are published again on the next connection, in
+// the order they were published first.
+func TestResendAfterDisconnect(t *testing.T) {
+	b := newFakeBroker(t, 0)
+	client, c := dialTest(t, b, Options{ClientID: "stratux"})
+
+	topics := []string{"t/1", "t/2", "t/3", "t/4", "t/5", "t/6"}
+	for i, topic := range topics {
+		if err := client.Publish(topic, []byte(topic), 1, i%2 == 0); err != nil {
+			t.Fatal(err)
+		}
+		_, id, _ := c.read(t).publish(t)
+		if topic == "t/2" || topic == "t/5" {
+			c.puback(id)
+		}
+	}
+	waitFor(t, "PUBACK", func() bool { return client.Inflight() == 4 })
+	c.conn.Close()
+	select {
+	case <-client.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("connection loss not detected")
+	}
+	if client.Err() == nil || client.Err() == ErrClosed {
+		t.Errorf("error %v", client.Err())
+	}
+	if err := client.Publish("t/7", nil, 1, false); err == nil {
+		t.Error("published on a closed connection")
+	}
+
+	unacked := client.Unacknowledged()
+	want := []Message{{"t/1", []byte("t/1"), true}, {"t/3", []byte("t/3"), true}, {"t/4", []byte("t/4"), false},
+		{"t/6", []byte("t/6"), false}}
+	if len(unacked) != len(want) {
+		t.Fatalf("unacknowledged %v", unacked)
+	}
+	client2, c2 := dialTest(t, b, Options{ClientID: "stratux"})
+	for i, msg := range unacked {
+		if msg.Topic != want[i].Topic || !bytes.Equal(msg.Payload, want[i].Payload) || msg.Retain != want[i].Retain {
+			t.Errorf("unacknowledged %d: %+v, want %+v", i, msg, want[i])
+		}
+		if err := client2.Publish(msg.Topic, msg.Payload, 1, msg.Retain); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := range want {
+		p := c2.read(t)
+		topic, id, payload := p.publish(t)
+		if topic != want[i].Topic || string(payload) != string(want[i].Payload) || (p.flags&0x01 != 0) != want[i].Retain {
+			t.Errorf("received %s %q retain %t, want %s", topic, payload, p.flags&0x01 != 0, want[i].Topic)
+		}
+		c2.puback(id)
+	}
+	waitFor(t, "PUBACK", func() bool { return client2.Inflight() == 0 })
+}
+
+// Close() sends DISCONNECT, so the broker discards the will
+func TestClose(t *testing.T) {
+	b := newFakeBroker(t, 0)
+	client, c := dialTest(t, b, Options{ClientID: "stratux", WillTopic: "stratux/online", WillPayload: []byte("false")})
+	client.Close()
+	if p := c.read(t); p.typ != DISCONNECT || len(p.body) != 0 {
+		t.Errorf("packet type %d, want DISCONNECT", p.typ)
+	}
+	if client.Err() != ErrClosed {
+		t.Errorf("error %v", client.Err())
+	}
+	if err := client.Publish("stratux/status", nil, 0, false); err != ErrClosed {
+		t.Errorf("publish after close: %v", err)
+	}
+}
+
+func TestPacketLength(t *testing.T) {
+	for _, l := range []int{0, 127, 128, 16383, 16384, 2097151, 2097152} {
+		p := packet(PUBLISH<<4, make([]byte, l))
+		typ, body, err := readPacket(bufio.NewReader(bytes.NewReader(p)))
+		if err != nil || typ != PUBLISH || len(body) != l {
+			t.Errorf("length %d: type %d, %d bytes, %v", l, typ, len(body), err)
+		}
+	}
+	// More than 4 length bytes
+	if _, _, err := readPacket(bufio.NewReader(bytes.NewReader([]byte{PUBLISH << 4, 0xFF, 0xFF, 0xFF, 0xFF, 0x01}))); err != ErrProtocol {
+		t.Errorf("5 length bytes: %v", err)
+	}
+}
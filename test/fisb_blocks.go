@@ -0,0 +1,122 @@
+package main
+
+// Decodes the FIS-B global block products (NEXRAD, icing, cloud tops, turbulence, lightning, see uatparse/nexrad.go)
+// and the SUA status records (uatparse/sua.go) of UAT captures and prints a summary per product.
+// Exits with status 1 if a block has the wrong number of bins or an SUA status record can't be parsed.
+//
+//	go run fisb_blocks.go ../test-data/example.dump978 ../test-data/gms5002-09072015-problem-stratux-uat.log
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/b3nn0/stratux/uatparse"
+)
+
+type blockStats struct {
+	frames   int
+	blocks   int
+	badBins  int
+	maxLevel uint16
+	levels   map[uint16]int
+}
+
+func main() {
+	verbose := flag.Bool("v", false, "Print every SUA status record")
+	flag.Parse()
+
+	ok := true
+	for _, fn := range flag.Args() {
+		fp, err := os.Open(fn)
+		if err != nil {
+			fmt.Printf("%s: %s\n", fn, err.Error())
+			os.Exit(1)
+		}
+		stats := make(map[uint32]*blockStats)
+		suaRecords, suaParsed := 0, 0
+		suaStatus := make(map[string]int)
+		scanner := bufio.NewScanner(fp)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			line := scanner.Text()
+			// Stratux logs prefix the message with a timestamp.
+			if i := strings.Index(line, "+"); i > 0 {
+				line = line[i:]
+			}
+			msg, err := uatparse.New(line)
+			if err != nil {
+				continue
+			}
+			msg.DecodeUplink()
+			for _, f := range msg.Frames {
+				if f.Frame_type != 0 {
+					continue
+				}
+				if f.Product_id == uatparse.PRODUCT_SUA && f.Product != nil {
+					suaRecords += len(f.Product.TextRecords)
+					suaParsed += len(f.SUA)
+					for _, s := range f.SUA {
+						suaStatus[s.Status]++
+						if *verbose {
+							fmt.Printf("SUA %-8s %s %-2s %5d-%-5d %s - %s %s\n", s.Designator, s.Type, s.Status, s.AltBottom, s.AltTop,
+								s.Start.Format("0102 1504Z"), s.End.Format("0102 1504Z"), s.Description)
+						}
+					}
+					continue
+				}
+				if !uatparse.IsGlobalBlockProduct(f.Product_id) {
+					continue
+				}
+				s, found := stats[f.Product_id]
+				if !found {
+					s = &blockStats{levels: make(map[uint16]int)}
+					stats[f.Product_id] = s
+				}
+				s.frames++
+				blocks := f.Blocks
+				if f.Product_id == uatparse.PRODUCT_NEXRAD_REGIONAL || f.Product_id == uatparse.PRODUCT_NEXRAD_CONUS {
+					blocks = f.NEXRAD
+				}
+				for _, b := range blocks {
+					s.blocks++
+					if len(b.Intensity) != uatparse.BLOCK_BINS {
+						s.badBins++
+					}
+					for _, v := range b.Intensity {
+						s.levels[v]++
+						if v > s.maxLevel {
+							s.maxLevel = v
+						}
+					}
+				}
+			}
+		}
+		fp.Close()
+
+		ids := make([]int, 0, len(stats))
+		for id := range stats {
+			ids = append(ids, int(id))
+		}
+		sort.Ints(ids)
+		fmt.Printf("%s:\n", fn)
+		for _, id := range ids {
+			s := stats[uint32(id)]
+			fmt.Printf("  %-16s frames=%-5d blocks=%-6d bad=%-4d max=%-2d levels=%v\n",
+				uatparse.GlobalBlockProductNames[uint32(id)], s.frames, s.blocks, s.badBins, s.maxLevel, s.levels)
+			if s.badBins > 0 {
+				ok = false
+			}
+		}
+		fmt.Printf("  %-16s records=%-5d parsed=%-5d status=%v\n", "SUA", suaRecords, suaParsed, suaStatus)
+		if suaParsed != suaRecords {
+			ok = false
+		}
+	}
+	if !ok {
+		os.Exit(1)
+	}
+}
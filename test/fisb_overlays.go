@@ -0,0 +1,130 @@
+package main
+
+// Decodes the FIS-B graphical products (uatparse/overlay.go) of UAT captures and prints a summary per report.
+// Exits with status 1 if a capture doesn't contain any decodable overlay.
+//
+//	go run fisb_overlays.go ../test-data/example.dump978 ../test-data/gms5002-09072015-problem-stratux-uat.log
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/b3nn0/stratux/uatparse"
+)
+
+type productStats struct {
+	overlays  int
+	texts     int
+	cancelled int
+	segments  int
+	assembled int
+	errors    int
+}
+
+func summarize(p *uatparse.FISBProduct, verbose bool) {
+	if !verbose {
+		return
+	}
+	for _, r := range p.Overlays {
+		fmt.Printf("%-9s %-4s %d-%02d label=%-9q geometry=%-2d points=%-3d %s - %s",
+			uatparse.FISBProductNames[p.ProductID], p.LocationIdentifier, r.ReportNumber, r.ReportYear, r.ObjectLabel,
+			r.Geometry, len(r.Points), r.Start, r.End)
+		if len(r.Points) > 0 {
+			fmt.Printf(" first=%.4f,%.4f,%d", r.Points[0].Lat, r.Points[0].Lon, r.Points[0].Alt)
+		}
+		if r.Geometry == uatparse.GEOMETRY_PRISM_MSL || r.Geometry == uatparse.GEOMETRY_PRISM_AGL {
+			fmt.Printf(" prism=%d-%dft r=%.1fx%.1fNM", r.AltBottom, r.AltTop, r.RadiusLat, r.RadiusLng)
+		}
+		fmt.Printf("\n")
+	}
+}
+
+func main() {
+	verbose := flag.Bool("v", false, "Print every overlay record")
+	flag.Parse()
+
+	ok := true
+	for _, fn := range flag.Args() {
+		fp, err := os.Open(fn)
+		if err != nil {
+			fmt.Printf("%s: %s\n", fn, err.Error())
+			os.Exit(1)
+		}
+		stats := make(map[uint32]*productStats)
+		segments := uatparse.NewSegmentAssembler(15 * time.Minute)
+		scanner := bufio.NewScanner(fp)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			line := scanner.Text()
+			// Stratux logs prefix the message with a timestamp.
+			if i := strings.Index(line, "+"); i > 0 {
+				line = line[i:]
+			}
+			msg, err := uatparse.New(line)
+			if err != nil {
+				continue
+			}
+			msg.DecodeUplink()
+			station := fmt.Sprintf("(%f,%f)", msg.Lat, msg.Lon)
+			for _, f := range msg.Frames {
+				if f.Frame_type != 0 || !uatparse.IsOverlayProduct(f.Product_id) {
+					continue
+				}
+				s, found := stats[f.Product_id]
+				if !found {
+					s = &productStats{}
+					stats[f.Product_id] = s
+				}
+				p := f.Product
+				if f.SegmentCount > 0 {
+					s.segments++
+					data := segments.Add(station, f, time.Now())
+					if data == nil {
+						continue
+					}
+					s.assembled++
+					p, err = uatparse.DecodeFISBProduct(f.Product_id, data)
+				}
+				if p == nil {
+					s.errors++
+					continue
+				}
+				s.overlays += len(p.Overlays)
+				s.texts += len(p.TextRecords)
+				for _, t := range p.TextRecords {
+					if !t.Active {
+						s.cancelled++
+					}
+				}
+				summarize(p, *verbose)
+			}
+		}
+		fp.Close()
+
+		ids := make([]int, 0, len(stats))
+		total := 0
+		for id, s := range stats {
+			ids = append(ids, int(id))
+			total += s.overlays
+		}
+		sort.Ints(ids)
+		fmt.Printf("%s:\n", fn)
+		for _, id := range ids {
+			s := stats[uint32(id)]
+			fmt.Printf("  %-9s overlays=%-5d texts=%-5d cancelled=%-3d segments=%-4d assembled=%-3d errors=%d\n",
+				uatparse.FISBProductNames[uint32(id)], s.overlays, s.texts, s.cancelled, s.segments, s.assembled, s.errors)
+		}
+		if total == 0 {
+			fmt.Printf("  no overlays decoded\n")
+			ok = false
+		}
+	}
+	if !ok {
+		os.Exit(1)
+	}
+}
@@ -8,16 +8,40 @@ const (
 	BLOCK_HEIGHT     = float64(4.0 / 60.0)
 	BLOCK_THRESHOLD  = 405000
 	BLOCKS_PER_RING  = 450
+	BLOCK_BINS       = 128 // 32 columns, 4 rows
+
+	// Global block representation products (DO-358B section 3.2)
+	PRODUCT_NEXRAD_REGIONAL = 63
+	PRODUCT_NEXRAD_CONUS    = 64
+	PRODUCT_ICING_LOW       = 70
+	PRODUCT_ICING_HIGH      = 71
+	PRODUCT_CLOUD_TOPS      = 84
+	PRODUCT_TURBULENCE_LOW  = 90
+	PRODUCT_TURBULENCE_HIGH = 91
+	PRODUCT_LIGHTNING       = 103
 )
 
+var GlobalBlockProductNames = map[uint32]string{
+	PRODUCT_NEXRAD_REGIONAL: "NEXRAD Regional",
+	PRODUCT_NEXRAD_CONUS:    "NEXRAD CONUS",
+	PRODUCT_ICING_LOW:       "Icing Low",
+	PRODUCT_ICING_HIGH:      "Icing High",
+	PRODUCT_CLOUD_TOPS:      "Cloud Tops",
+	PRODUCT_TURBULENCE_LOW:  "Turbulence Low",
+	PRODUCT_TURBULENCE_HIGH: "Turbulence High",
+	PRODUCT_LIGHTNING:       "Lightning",
+}
+
+// One block of a global block representation product (NEXRAD, icing, cloud tops, turbulence, lightning).
 type NEXRADBlock struct {
-	Radar_Type uint32
+	Radar_Type uint32 // product ID
 	Scale      int
 	LatNorth   float64
 	LonWest    float64
 	Height     float64
 	Width      float64
-	Intensity  []uint16 // Really only 4-bit values, but using this as a hack for the JSON encoding.
+	Altitude   int32    // feet. Altitude of the icing and turbulence slice, 0 for the other products.
+	Intensity  []uint16 // Bin values, 3 or 4 bits (8 for icing), but using this as a hack for the JSON encoding.
 }
 
 func block_location(block_num int, ns_flag bool, scale_factor int) (float64, float64, float64, float64) {
@@ -60,8 +84,38 @@ func block_location(block_num int, ns_flag bool, scale_factor int) (float64, flo
 
 }
 
-func (f *UATFrame) decodeNexradFrame() {
-	if len(f.FISB_data) < 4 { // Short read.
+// Bin encoding of the global block representation products. NEXRAD, cloud tops, lightning and turbulence pack the
+// bin value and the run length - 1 into one byte, icing uses a value byte (2 bits supercooled large drops, 3 bits
+// severity, 3 bits probability) followed by a run length byte. Icing and turbulence are sent as one grid per
+// altitude, with the altitude (1000ft units) in the byte following the block reference indicator.
+type globalBlockEncoding struct {
+	ValueBits  uint   // low bits of an RLE byte holding the bin value, the rest is the run length - 1
+	RunByte    bool   // value and run length in separate bytes
+	EmptyValue uint16 // bin value of the blocks listed in an empty block bitmap
+	Altitude   bool   // altitude byte after the block reference indicator
+}
+
+var globalBlockEncodings = map[uint32]globalBlockEncoding{
+	PRODUCT_NEXRAD_REGIONAL: {ValueBits: 3},
+	PRODUCT_NEXRAD_CONUS:    {ValueBits: 3, EmptyValue: 1},
+	PRODUCT_ICING_LOW:       {RunByte: true, Altitude: true},
+	PRODUCT_ICING_HIGH:      {RunByte: true, Altitude: true},
+	PRODUCT_CLOUD_TOPS:      {ValueBits: 4},
+	PRODUCT_TURBULENCE_LOW:  {ValueBits: 4, Altitude: true},
+	PRODUCT_TURBULENCE_HIGH: {ValueBits: 4, Altitude: true},
+	PRODUCT_LIGHTNING:       {ValueBits: 4}, // strike count in the low 3 bits, bit 3 set for positive polarity
+}
+
+func IsGlobalBlockProduct(product_id uint32) bool {
+	_, ok := globalBlockEncodings[product_id]
+	return ok
+}
+
+// Decodes a global block representation APDU into blocks of BLOCK_BINS bins. NEXRAD blocks go to f.NEXRAD, the
+// other products to f.Blocks.
+func (f *UATFrame) decodeGlobalBlockFrame() {
+	encoding, ok := globalBlockEncodings[f.Product_id]
+	if !ok || len(f.FISB_data) < 4 { // Short read.
 		return
 	}
 
@@ -70,6 +124,17 @@ func (f *UATFrame) decodeNexradFrame() {
 	block_num := ((int(f.FISB_data[0]) & 0x0f) << 16) | (int(f.FISB_data[1]) << 8) | (int(f.FISB_data[2]))
 	scale_factor := (int(f.FISB_data[0]) & 0x30) >> 4
 
+	data := f.FISB_data[3:]
+	altitude := int32(0)
+	if encoding.Altitude {
+		if len(data) < 2 { // Short read.
+			return
+		}
+		altitude = int32(data[0]) * 1000
+		data = data[1:]
+	}
+
+	blocks := make([]NEXRADBlock, 0)
 	if rle_flag { // Single bin, RLE encoded.
 		lat, lon, h, w := block_location(block_num, ns_flag, scale_factor)
 		var tmp NEXRADBlock
@@ -79,18 +144,30 @@ func (f *UATFrame) decodeNexradFrame() {
 		tmp.LonWest = lon
 		tmp.Height = h
 		tmp.Width = w
+		tmp.Altitude = altitude
 		tmp.Intensity = make([]uint16, 0)
 
-		intensityData := f.FISB_data[3:]
-		for _, v := range intensityData {
-			intensity := uint16(v) & 0x7
-			runlength := (uint16(v) >> 3) + 1
+		intensityData := data
+		for i := 0; i < len(intensityData); i++ {
+			v := uint16(intensityData[i])
+			var intensity, runlength uint16
+			if encoding.RunByte {
+				if i+1 >= len(intensityData) {
+					break
+				}
+				i++
+				intensity = v
+				runlength = uint16(intensityData[i]) + 1
+			} else {
+				intensity = v & (1<<encoding.ValueBits - 1)
+				runlength = (v >> encoding.ValueBits) + 1
+			}
 			for runlength > 0 {
 				tmp.Intensity = append(tmp.Intensity, intensity)
 				runlength--
 			}
 		}
-		f.NEXRAD = []NEXRADBlock{tmp}
+		blocks = append(blocks, tmp)
 	} else {
 		var row_start int
 		var row_size int
@@ -104,18 +181,18 @@ func (f *UATFrame) decodeNexradFrame() {
 
 		row_offset := block_num - row_start
 
-		L := int(f.FISB_data[3] & 15)
+		L := int(data[0] & 15)
 
-		if len(f.FISB_data) < L+3 { // Short read.
+		if len(data) < L { // Short read.
 			return
 		}
 
 		for i := 0; i < L; i++ {
 			var bb int
 			if i == 0 {
-				bb = (int(f.FISB_data[3]) & 0xF0) | 0x08
+				bb = (int(data[0]) & 0xF0) | 0x08
 			} else {
-				bb = int(f.FISB_data[i+3])
+				bb = int(data[i])
 			}
 
 			for j := 0; j < 8; j++ {
@@ -130,18 +207,20 @@ func (f *UATFrame) decodeNexradFrame() {
 					tmp.LonWest = lon
 					tmp.Height = h
 					tmp.Width = w
+					tmp.Altitude = altitude
 					tmp.Intensity = make([]uint16, 0)
-					for k := 0; k < 128; k++ {
-						z := uint16(0)
-						if f.Product_id == 64 {
-							z = 1
-						}
-						tmp.Intensity = append(tmp.Intensity, z)
+					for k := 0; k < BLOCK_BINS; k++ {
+						tmp.Intensity = append(tmp.Intensity, encoding.EmptyValue)
 					}
-					f.NEXRAD = append(f.NEXRAD, tmp)
+					blocks = append(blocks, tmp)
 				}
 			}
 		}
 	}
 
+	if f.Product_id == PRODUCT_NEXRAD_REGIONAL || f.Product_id == PRODUCT_NEXRAD_CONUS {
+		f.NEXRAD = append(f.NEXRAD, blocks...)
+	} else {
+		f.Blocks = append(f.Blocks, blocks...)
+	}
 }
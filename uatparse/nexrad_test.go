@@ -0,0 +1,256 @@
+package uatparse
+
+import (
+	"encoding/hex"
+	"math"
+	"testing"
+)
+
+// Ground station and block used for the constructed uplinks: 40°04'N 100°W, at the south-east corner of block
+// 600*450+325.
+const (
+	testStationLat = 40.0
+	testStationLon = -100.0
+	testBlock      = 600*BLOCKS_PER_RING + 325
+)
+
+// Uplink message in the dump978 format, with the given information frames as FIS-B frames.
+func encodeUplink(frames ...[]byte) string {
+	msg := make([]byte, UPLINK_FRAME_DATA_BYTES)
+	lat := uint32(math.Round(testStationLat/360.0*16777216.0)) & 0x7FFFFF
+	lon := uint32(math.Round((testStationLon+360)/360.0*16777216.0)) & 0xFFFFFF
+	msg[0] = byte(lat >> 15)
+	msg[1] = byte(lat >> 7)
+	msg[2] = byte(lat<<1) | byte(lon>>23)
+	msg[3] = byte(lon >> 15)
+	msg[4] = byte(lon >> 7)
+	msg[5] = byte(lon<<1) | 0x01 // position valid
+	msg[6] = 0x20                // application data valid
+	pos := 8
+	for _, f := range frames {
+		msg[pos] = byte(len(f) >> 1)
+		msg[pos+1] = byte(len(f)&0x01) << 7 // frame type 0: FIS-B
+		copy(msg[pos+2:], f)
+		pos += 2 + len(f)
+	}
+	return "+" + hex.EncodeToString(msg) + ";"
+}
+
+// FIS-B APDU with an hours/minutes time stamp and no segmentation.
+func encodeAPDU(product_id uint32, hour, minute int, payload []byte) []byte {
+	apdu := []byte{
+		byte(product_id>>6) & 0x1F,
+		byte(product_id&0x3F) << 2,
+		byte(hour&0x1F)<<2 | byte(minute>>4),
+		byte(minute&0x0F) << 4,
+	}
+	return append(apdu, payload...)
+}
+
+// Block reference indicator.
+func encodeBlockReference(rle bool, scale int, block_num int) []byte {
+	b := byte(scale&0x03)<<4 | byte(block_num>>16)&0x0F
+	if rle {
+		b |= 0x80
+	}
+	return []byte{b, byte(block_num >> 8), byte(block_num)}
+}
+
+// RLE bytes of products with the run length - 1 and the value in one byte.
+func encodeRuns(valueBits uint, runs ...[2]int) []byte {
+	var b []byte
+	for _, r := range runs {
+		for n := r[1]; n > 0; n -= 1 << (8 - valueBits) {
+			run := n
+			if run > 1<<(8-valueBits) {
+				run = 1 << (8 - valueBits)
+			}
+			b = append(b, byte(run-1)<<valueBits|byte(r[0]))
+		}
+	}
+	return b
+}
+
+// Expands runs of {value, count} into bins.
+func expandRuns(runs ...[2]int) []uint16 {
+	var bins []uint16
+	for _, r := range runs {
+		for i := 0; i < r[1]; i++ {
+			bins = append(bins, uint16(r[0]))
+		}
+	}
+	return bins
+}
+
+// Decodes an uplink with one frame through the same path as the live uplinks and returns the frame.
+func decodeTestUplink(t *testing.T, frame []byte) *UATFrame {
+	msg, err := New(encodeUplink(frame))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := msg.DecodeUplink(); err != nil {
+		t.Fatal(err)
+	}
+	if len(msg.Frames) != 1 {
+		t.Fatalf("%d frames", len(msg.Frames))
+	}
+	if math.Abs(msg.Lat-testStationLat) > 0.001 || math.Abs(msg.Lon-testStationLon) > 0.001 {
+		t.Errorf("ground station at %.4f,%.4f", msg.Lat, msg.Lon)
+	}
+	return msg.Frames[0]
+}
+
+func checkBlock(t *testing.T, name string, b NEXRADBlock, product_id uint32, block_num int, altitude int32, bins []uint16) {
+	latNorth := float64(block_num/BLOCKS_PER_RING+1) * BLOCK_HEIGHT
+	lonWest := float64(block_num%BLOCKS_PER_RING)*BLOCK_WIDTH - 360
+	if b.Radar_Type != product_id || b.Scale != 0 || b.Altitude != altitude {
+		t.Errorf("%s: product %d scale %d altitude %d", name, b.Radar_Type, b.Scale, b.Altitude)
+	}
+	if math.Abs(b.LatNorth-latNorth) > 1e-9 || math.Abs(b.LonWest-lonWest) > 1e-9 || b.Height != BLOCK_HEIGHT || b.Width != BLOCK_WIDTH {
+		t.Errorf("%s: block at %.4f,%.4f %.4fx%.4f, want %.4f,%.4f", name, b.LatNorth, b.LonWest, b.Height, b.Width, latNorth, lonWest)
+	}
+	if len(b.Intensity) != BLOCK_BINS {
+		t.Errorf("%s: %d bins, want %d", name, len(b.Intensity), BLOCK_BINS)
+		return
+	}
+	for i := range bins {
+		if b.Intensity[i] != bins[i] {
+			t.Errorf("%s: bin %d is %d, want %d", name, i, b.Intensity[i], bins[i])
+		}
+	}
+}
+
+func TestDecodeGlobalBlockRLE(t *testing.T) {
+	tests := []struct {
+		name       string
+		product_id uint32
+		altitude   int32
+		runs       [][2]int
+	}{
+		// Strike counts, bit 3 for positive polarity.
+		{"lightning", PRODUCT_LIGHTNING, 0, [][2]int{{0, 60}, {3, 1}, {0x08 | 1, 2}, {0, 30}, {7, 3}, {0, 32}}},
+		{"cloud tops", PRODUCT_CLOUD_TOPS, 0, [][2]int{{0, 40}, {9, 20}, {12, 8}, {15, 4}, {0, 56}}},
+		{"turbulence low", PRODUCT_TURBULENCE_LOW, 16000, [][2]int{{0, 64}, {4, 16}, {8, 16}, {0, 32}}},
+		{"turbulence high", PRODUCT_TURBULENCE_HIGH, 34000, [][2]int{{2, 100}, {14, 28}}},
+	}
+	for _, tt := range tests {
+		payload := encodeBlockReference(true, 0, testBlock)
+		if tt.altitude != 0 {
+			payload = append(payload, byte(tt.altitude/1000))
+		}
+		payload = append(payload, encodeRuns(globalBlockEncodings[tt.product_id].ValueBits, tt.runs...)...)
+		f := decodeTestUplink(t, encodeAPDU(tt.product_id, 14, 35, payload))
+		if f.Product_id != tt.product_id || f.FISB_hours != 14 || f.FISB_minutes != 35 {
+			t.Errorf("%s: product %d at %02d:%02d", tt.name, f.Product_id, f.FISB_hours, f.FISB_minutes)
+		}
+		if len(f.NEXRAD) != 0 || len(f.Blocks) != 1 {
+			t.Errorf("%s: %d NEXRAD blocks, %d blocks", tt.name, len(f.NEXRAD), len(f.Blocks))
+			continue
+		}
+		checkBlock(t, tt.name, f.Blocks[0], tt.product_id, testBlock, tt.altitude, expandRuns(tt.runs...))
+	}
+}
+
+// Icing: value byte (supercooled large drops, severity, probability) and run length byte.
+func TestDecodeIcing(t *testing.T) {
+	sld, severity, probability := 1, 3, 5
+	value := sld<<6 | severity<<3 | probability
+	for _, product_id := range []uint32{PRODUCT_ICING_LOW, PRODUCT_ICING_HIGH} {
+		for _, altitude := range []int32{2000, 8000, 24000} {
+			payload := encodeBlockReference(true, 0, testBlock)
+			payload = append(payload, byte(altitude/1000), 0x00, 99, byte(value), 19, 0x00, 7)
+			f := decodeTestUplink(t, encodeAPDU(product_id, 14, 35, payload))
+			if len(f.Blocks) != 1 {
+				t.Errorf("icing %d: %d blocks", product_id, len(f.Blocks))
+				continue
+			}
+			bins := expandRuns([2]int{0, 100}, [2]int{value, 20}, [2]int{0, 8})
+			checkBlock(t, GlobalBlockProductNames[product_id], f.Blocks[0], product_id, testBlock, altitude, bins)
+			b := f.Blocks[0].Intensity[100]
+			if int(b>>6) != sld || int(b>>3)&0x07 != severity || int(b)&0x07 != probability {
+				t.Errorf("icing bin %08b", b)
+			}
+		}
+	}
+}
+
+// Empty block bitmap: the reference block and the blocks flagged after it have no data.
+func TestDecodeGlobalBlockEmpty(t *testing.T) {
+	tests := []struct {
+		product_id uint32
+		altitude   int32
+		empty      uint16
+	}{
+		{PRODUCT_NEXRAD_CONUS, 0, 1},
+		{PRODUCT_NEXRAD_REGIONAL, 0, 0},
+		{PRODUCT_LIGHTNING, 0, 0},
+		{PRODUCT_ICING_HIGH, 30000, 0},
+		{PRODUCT_TURBULENCE_LOW, 6000, 0},
+	}
+	for _, tt := range tests {
+		name := GlobalBlockProductNames[tt.product_id]
+		payload := encodeBlockReference(false, 0, testBlock)
+		if tt.altitude != 0 {
+			payload = append(payload, byte(tt.altitude/1000))
+		}
+		// Bitmap length 2: the reference block (bit 3, implicit), +1 (bit 4) and +6 (second byte, bit 1)
+		payload = append(payload, 0x10|2, 0x02)
+		f := decodeTestUplink(t, encodeAPDU(tt.product_id, 14, 35, payload))
+		blocks := f.Blocks
+		if tt.product_id == PRODUCT_NEXRAD_CONUS || tt.product_id == PRODUCT_NEXRAD_REGIONAL {
+			blocks = f.NEXRAD
+		}
+		if len(blocks) != 3 {
+			t.Errorf("%s: %d blocks, want 3", name, len(blocks))
+			continue
+		}
+		bins := expandRuns([2]int{int(tt.empty), BLOCK_BINS})
+		for i, offset := range []int{0, 1, 6} {
+			checkBlock(t, name, blocks[i], tt.product_id, testBlock+offset, tt.altitude, bins)
+		}
+	}
+}
+
+func TestDecodeGlobalBlockShort(t *testing.T) {
+	for product_id := range globalBlockEncodings {
+		// Block reference and altitude only
+		payload := append(encodeBlockReference(true, 0, testBlock), 10)
+		f := decodeTestUplink(t, encodeAPDU(product_id, 14, 35, payload))
+		if globalBlockEncodings[product_id].Altitude && (len(f.Blocks) != 0 || len(f.NEXRAD) != 0) {
+			t.Errorf("%s: %d blocks from a short frame", GlobalBlockProductNames[product_id], len(f.Blocks))
+		}
+	}
+}
+
+// The captures only contain NEXRAD of the global block products.
+func TestCaptureNEXRAD(t *testing.T) {
+	for _, c := range captures {
+		blocks := 0
+		forEachFrame(t, c.file, func(msg *UATMsg, f *UATFrame) {
+			if !IsGlobalBlockProduct(f.Product_id) {
+				return
+			}
+			if f.Product_id != PRODUCT_NEXRAD_REGIONAL && f.Product_id != PRODUCT_NEXRAD_CONUS {
+				t.Errorf("%s: unexpected product %d", c.file, f.Product_id)
+			}
+			for _, b := range f.NEXRAD {
+				blocks++
+				if len(b.Intensity) != BLOCK_BINS {
+					t.Errorf("%s: block at %.4f,%.4f has %d bins", c.file, b.LatNorth, b.LonWest, len(b.Intensity))
+				}
+				if b.LatNorth < 15 || b.LatNorth > 60 || b.LonWest < -140 || b.LonWest > -50 {
+					t.Errorf("%s: block at %.4f,%.4f", c.file, b.LatNorth, b.LonWest)
+				}
+				for _, v := range b.Intensity {
+					if v > 7 {
+						t.Errorf("%s: NEXRAD level %d", c.file, v)
+						break
+					}
+				}
+			}
+		})
+		if blocks == 0 {
+			t.Errorf("%s: no NEXRAD blocks", c.file)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package uatparse
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// NOTAM-D, NOTAM-FDC, NOTAM-TRA and NOTAM-TFR reports (products 8, 16 and 17) as airspace restrictions. The text
+// record of a report carries the NOTAM number and the effective times, the overlay records with the same report
+// number carry the area and the altitude limits. Both are usually broadcast in separate APDUs.
+
+const (
+	RESTRICTION_SHAPE_POLYGON = "polygon"
+	RESTRICTION_SHAPE_CIRCLE  = "circle" // circular prism, actually an ellipse
+	RESTRICTION_SHAPE_POINT   = "point"
+
+	restrictionTimeLayout = "0601021504"
+)
+
+// "!FDC 5/8737" or "!DTW 07/516"
+var notamNumberRegexp = regexp.MustCompile(`!(\S+) (\d+/\d+)`)
+
+// "1507280957-1507290930EST" or "1507190452-PERM"
+var notamTimesRegexp = regexp.MustCompile(`(\d{10})-(\d{10}|PERM)`)
+
+type RestrictionShape struct {
+	Kind        string     // RESTRICTION_SHAPE_*
+	Points      []GeoPoint // polygon vertices (not closed), or the circle center / point
+	RadiusLat   float64    // NM, circles only
+	RadiusLng   float64    // NM, circles only
+	Orientation float64    // degrees, circles only
+	AltBottom   int32      // feet
+	AltTop      int32      // feet, 0 = no upper limit given
+	AGL         bool       // altitudes are above ground level
+	Start       time.Time  // zero if not given
+	End         time.Time  // zero if not given
+}
+
+type AirspaceRestriction struct {
+	ID           string // NOTAM number, e.g. "FDC 5/8737". Product and report number if the text wasn't received yet
+	Type         string // NOTAM-D, NOTAM-FDC, NOTAM-TFR or NOTAM-TRA
+	ProductID    uint32
+	Location     string
+	ReportNumber uint16
+	ReportYear   uint16
+	Start        time.Time // zero if unknown
+	End          time.Time // zero if permanent or unknown
+	AltBottom    int32     // feet, lowest bottom of the area shapes
+	AltTop       int32     // feet, highest top of the area shapes. 0 = no upper limit given
+	Shapes       []RestrictionShape
+	Text         string
+}
+
+// Builds the restriction of a report from its text (may be empty) and overlay records.
+func NewAirspaceRestriction(product_id uint32, location string, number, year uint16, text string, records []OverlayRecord, now time.Time) *AirspaceRestriction {
+	a := &AirspaceRestriction{
+		ID:           fmt.Sprintf("%s %d/%d", FISBProductNames[product_id], year, number),
+		Type:         FISBProductNames[product_id],
+		ProductID:    product_id,
+		Location:     location,
+		ReportNumber: number,
+		ReportYear:   year,
+		Text:         text,
+	}
+	for _, r := range records {
+		s := RestrictionShape{AGL: r.AltitudeAGL()}
+		if r.Start.Valid() {
+			s.Start = r.Start.Time(now)
+		}
+		if r.End.Valid() {
+			s.End = r.End.Time(now)
+		}
+		switch r.Geometry {
+		case GEOMETRY_POLYGON_MSL, GEOMETRY_POLYGON_AGL:
+			if len(r.Points) < 3 {
+				continue
+			}
+			s.Kind = RESTRICTION_SHAPE_POLYGON
+			s.Points, s.AltBottom, s.AltTop = r.Polygon()
+		case GEOMETRY_PRISM_MSL, GEOMETRY_PRISM_AGL:
+			s.Kind = RESTRICTION_SHAPE_CIRCLE
+			s.Points = r.Points[:1]
+			s.RadiusLat, s.RadiusLng, s.Orientation = r.RadiusLat, r.RadiusLng, r.Orientation
+			s.AltBottom, s.AltTop = r.AltBottom, r.AltTop
+		case GEOMETRY_POINT_AGL, GEOMETRY_POINT_MSL:
+			s.Kind = RESTRICTION_SHAPE_POINT
+			s.Points = r.Points
+		default:
+			continue
+		}
+		a.Shapes = append(a.Shapes, s)
+	}
+
+	first := true
+	for _, s := range a.Shapes {
+		if !s.IsArea() {
+			continue
+		}
+		if first || s.AltBottom < a.AltBottom {
+			a.AltBottom = s.AltBottom
+		}
+		if first || (a.AltTop != 0 && (s.AltTop == 0 || s.AltTop > a.AltTop)) {
+			a.AltTop = s.AltTop
+		}
+		if first || s.Start.IsZero() || (!a.Start.IsZero() && s.Start.Before(a.Start)) {
+			a.Start = s.Start
+		}
+		if first || s.End.IsZero() || (!a.End.IsZero() && s.End.After(a.End)) {
+			a.End = s.End
+		}
+		first = false
+	}
+	a.parseText()
+	return a
+}
+
+// NOTAM type, number and effective times from the text. The times of the text take precedence, they include the year.
+func (a *AirspaceRestriction) parseText() {
+	if fields := strings.Fields(a.Text); len(fields) > 0 && strings.HasPrefix(fields[0], "NOTAM-") {
+		a.Type = fields[0]
+	}
+	if m := notamNumberRegexp.FindStringSubmatch(a.Text); m != nil {
+		a.ID = m[1] + " " + m[2]
+	}
+	times := notamTimesRegexp.FindAllStringSubmatch(a.Text, -1)
+	if len(times) == 0 {
+		return
+	}
+	m := times[len(times)-1]
+	if t, err := time.Parse(restrictionTimeLayout, m[1]); err == nil {
+		a.Start = t
+	}
+	if m[2] == "PERM" {
+		a.End = time.Time{}
+	} else if t, err := time.Parse(restrictionTimeLayout, m[2]); err == nil {
+		a.End = t
+	}
+}
+
+// True if the restriction has at least one polygon or circle.
+func (a *AirspaceRestriction) IsArea() bool {
+	for _, s := range a.Shapes {
+		if s.IsArea() {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *AirspaceRestriction) Active(now time.Time) bool {
+	return (a.Start.IsZero() || !now.Before(a.Start)) && (a.End.IsZero() || now.Before(a.End))
+}
+
+// Checks if a position is inside one of the active areas. AGL limits are compared with alt as well since the terrain
+// elevation isn't known, use an altitude above ground if available.
+func (a *AirspaceRestriction) Contains(lat, lng float64, alt int32, now time.Time) bool {
+	if !a.Active(now) {
+		return false
+	}
+	for _, s := range a.Shapes {
+		if s.Active(now) && s.Contains(lat, lng, alt) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *RestrictionShape) IsArea() bool {
+	return s.Kind == RESTRICTION_SHAPE_POLYGON || s.Kind == RESTRICTION_SHAPE_CIRCLE
+}
+
+func (s *RestrictionShape) Active(now time.Time) bool {
+	return (s.Start.IsZero() || !now.Before(s.Start)) && (s.End.IsZero() || now.Before(s.End))
+}
+
+// Offset of a position from the circle center in NM, rotated into the ellipse axes.
+func (s *RestrictionShape) ellipseOffset(lat, lng float64) (float64, float64) {
+	c := s.Points[0]
+	north := (lat - c.Lat) * 60
+	east := (lng - c.Lon) * 60 * math.Cos(c.Lat*math.Pi/180)
+	rot := s.Orientation * math.Pi / 180
+	return east*math.Cos(rot) - north*math.Sin(rot), north*math.Cos(rot) + east*math.Sin(rot)
+}
+
+func (s *RestrictionShape) Contains(lat, lng float64, alt int32) bool {
+	if alt < s.AltBottom || (s.AltTop != 0 && alt > s.AltTop) {
+		return false
+	}
+	switch s.Kind {
+	case RESTRICTION_SHAPE_CIRCLE:
+		if s.RadiusLat <= 0 || s.RadiusLng <= 0 {
+			return false
+		}
+		x, y := s.ellipseOffset(lat, lng)
+		return (x*x)/(s.RadiusLng*s.RadiusLng)+(y*y)/(s.RadiusLat*s.RadiusLat) <= 1
+	case RESTRICTION_SHAPE_POLYGON:
+		// Ray casting
+		inside := false
+		for i, j := 0, len(s.Points)-1; i < len(s.Points); j, i = i, i+1 {
+			pi, pj := s.Points[i], s.Points[j]
+			if (pi.Lat > lat) != (pj.Lat > lat) && lng < (pj.Lon-pi.Lon)*(lat-pi.Lat)/(pj.Lat-pi.Lat)+pi.Lon {
+				inside = !inside
+			}
+		}
+		return inside
+	}
+	return false
+}
+
+// Outline of the shape with n vertices for circles, first vertex repeated at the end. Nil for points.
+func (s *RestrictionShape) Ring(n int) []GeoPoint {
+	switch s.Kind {
+	case RESTRICTION_SHAPE_POLYGON:
+		ring := append([]GeoPoint{}, s.Points...)
+		if ring[0].Lat != ring[len(ring)-1].Lat || ring[0].Lon != ring[len(ring)-1].Lon {
+			ring = append(ring, ring[0])
+		}
+		return ring
+	case RESTRICTION_SHAPE_CIRCLE:
+		c := s.Points[0]
+		rot := s.Orientation * math.Pi / 180
+		ring := make([]GeoPoint, 0, n+1)
+		for i := 0; i <= n; i++ {
+			a := 2 * math.Pi * float64(i%n) / float64(n)
+			x, y := s.RadiusLng*math.Sin(a), s.RadiusLat*math.Cos(a)
+			east, north := x*math.Cos(rot)+y*math.Sin(rot), y*math.Cos(rot)-x*math.Sin(rot)
+			ring = append(ring, GeoPoint{
+				Lat: c.Lat + north/60,
+				Lon: c.Lon + east/(60*math.Cos(c.Lat*math.Pi/180)),
+				Alt: s.AltBottom,
+			})
+		}
+		return ring
+	}
+	return nil
+}
@@ -0,0 +1,502 @@
+package uatparse
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// Text and graphical FIS-B products (DO-358B section 3.3). Products 8 and 11-17 share the same APDU payload: a six
+// byte product header followed by text or graphical overlay records. Large products are split into several APDUs
+// (segmentation), see SegmentAssembler.
+
+const (
+	PRODUCT_NOTAM     = 8
+	PRODUCT_AIRMET    = 11
+	PRODUCT_SIGMET    = 12 // also convective SIGMET
+	PRODUCT_SUA       = 13
+	PRODUCT_G_AIRMET  = 14
+	PRODUCT_CWA       = 15
+	PRODUCT_NOTAM_TRA = 16
+	PRODUCT_NOTAM_TFR = 17
+
+	RECORD_FORMAT_TEXT    = 2 // Unformatted DLAC text
+	RECORD_FORMAT_OVERLAY = 8 // Graphical overlay
+
+	// Geometry overlay options
+	GEOMETRY_NONE         = 0
+	GEOMETRY_POLYGON_MSL  = 3
+	GEOMETRY_POLYGON_AGL  = 4
+	GEOMETRY_PRISM_MSL    = 7 // circular prism, an ellipse with bottom and top altitude
+	GEOMETRY_PRISM_AGL    = 8
+	GEOMETRY_POINT_AGL    = 9
+	GEOMETRY_POINT_MSL    = 10
+	GEOMETRY_POLYLINE_MSL = 11
+	GEOMETRY_POLYLINE_AGL = 12
+
+	// Date/time formats of the record applicability times
+	FISB_TIME_NONE            = 0
+	FISB_TIME_MONTH_DAY_HOURS = 1
+	FISB_TIME_DAY_HOURS       = 2
+	FISB_TIME_HOURS           = 3
+
+	EXTENDED_RANGE_3D_RES = 360.0 / (1 << 19) // degrees per LSB of extended range 3D vertices
+	PRISM_RES             = 360.0 / (1 << 18)
+)
+
+var FISBProductNames = map[uint32]string{
+	PRODUCT_NOTAM:     "NOTAM",
+	PRODUCT_AIRMET:    "AIRMET",
+	PRODUCT_SIGMET:    "SIGMET",
+	PRODUCT_SUA:       "SUA",
+	PRODUCT_G_AIRMET:  "G-AIRMET",
+	PRODUCT_CWA:       "CWA",
+	PRODUCT_NOTAM_TRA: "NOTAM-TRA",
+	PRODUCT_NOTAM_TFR: "NOTAM-TFR",
+}
+
+var ErrShortRecord = errors.New("FIS-B record too short")
+
+func IsOverlayProduct(product_id uint32) bool {
+	_, ok := FISBProductNames[product_id]
+	return ok
+}
+
+// UTC time without year (and without month or day, depending on Format).
+type FISBTime struct {
+	Format uint8
+	Month  uint8
+	Day    uint8
+	Hour   uint8
+	Minute uint8
+}
+
+func (t FISBTime) Valid() bool {
+	return t.Format != FISB_TIME_NONE
+}
+
+// Candidate times with the missing fields taken from around ref, in ascending order.
+func (t FISBTime) candidates(ref time.Time) []time.Time {
+	ref = ref.UTC()
+	var candidates []time.Time
+	switch t.Format {
+	case FISB_TIME_MONTH_DAY_HOURS:
+		for y := ref.Year() - 1; y <= ref.Year()+1; y++ {
+			candidates = append(candidates, time.Date(y, time.Month(t.Month), int(t.Day), int(t.Hour), int(t.Minute), 0, 0, time.UTC))
+		}
+	case FISB_TIME_DAY_HOURS:
+		for m := ref.Month() - 1; m <= ref.Month()+1; m++ {
+			candidates = append(candidates, time.Date(ref.Year(), m, int(t.Day), int(t.Hour), int(t.Minute), 0, 0, time.UTC))
+		}
+	case FISB_TIME_HOURS:
+		for d := ref.Day() - 1; d <= ref.Day()+1; d++ {
+			candidates = append(candidates, time.Date(ref.Year(), ref.Month(), d, int(t.Hour), int(t.Minute), 0, 0, time.UTC))
+		}
+	}
+	return candidates
+}
+
+// Completes the time with the missing fields from now, picking the candidate closest to now.
+func (t FISBTime) Time(now time.Time) time.Time {
+	candidates := t.candidates(now)
+	if len(candidates) == 0 {
+		return time.Time{}
+	}
+	best := candidates[0]
+	for _, c := range candidates[1:] {
+		if math.Abs(c.Sub(now).Hours()) < math.Abs(best.Sub(now).Hours()) {
+			best = c
+		}
+	}
+	return best
+}
+
+// Completes the time with the missing fields, picking the first candidate not before start. Used for end times:
+// e.g. a NOTAM valid for a year has the same month and day in its start and end time.
+func (t FISBTime) TimeAfter(start time.Time) time.Time {
+	for _, c := range t.candidates(start) {
+		if !c.Before(start) {
+			return c
+		}
+	}
+	return time.Time{}
+}
+
+func (t FISBTime) String() string {
+	switch t.Format {
+	case FISB_TIME_MONTH_DAY_HOURS:
+		return fmt.Sprintf("%02d-%02d %02d:%02d", t.Month, t.Day, t.Hour, t.Minute)
+	case FISB_TIME_DAY_HOURS:
+		return fmt.Sprintf("%02d %02d:%02d", t.Day, t.Hour, t.Minute)
+	case FISB_TIME_HOURS:
+		return fmt.Sprintf("%02d:%02d", t.Hour, t.Minute)
+	}
+	return ""
+}
+
+// Number of bytes of a time in the given format
+func fisbTimeLength(format uint8) int {
+	switch format {
+	case FISB_TIME_MONTH_DAY_HOURS:
+		return 4
+	case FISB_TIME_DAY_HOURS:
+		return 3
+	case FISB_TIME_HOURS:
+		return 2
+	}
+	return 0
+}
+
+func parseFISBTime(b []byte, format uint8) FISBTime {
+	t := FISBTime{Format: format}
+	switch format {
+	case FISB_TIME_MONTH_DAY_HOURS:
+		t.Month, t.Day, t.Hour, t.Minute = b[0], b[1], b[2], b[3]
+	case FISB_TIME_DAY_HOURS:
+		t.Day, t.Hour, t.Minute = b[0], b[1], b[2]
+	case FISB_TIME_HOURS:
+		t.Hour, t.Minute = b[0], b[1]
+	}
+	return t
+}
+
+// One graphical overlay record. A report (ReportNumber/ReportYear) can consist of several records.
+type OverlayRecord struct {
+	ReportNumber    uint16
+	ReportYear      uint16 // two digits
+	RecordID        uint8  // overlay record identifier, 1-16
+	ObjectLabel     string // DLAC label or numeric index
+	ObjectElement   uint8
+	ObjectType      uint8
+	ObjectStatus    uint8
+	ObjectQualifier uint32
+	Start           FISBTime
+	End             FISBTime
+	Geometry        uint8 // GEOMETRY_*
+	Operator        uint8 // overlay operator
+	Points          []GeoPoint
+
+	// Circular prism only. The points are the bottom and top centers.
+	AltBottom   int32   // feet
+	AltTop      int32   // feet
+	RadiusLat   float64 // NM, north-south
+	RadiusLng   float64 // NM, east-west
+	Orientation float64 // degrees
+}
+
+// Start and end of the record applicability, completed from now. Zero if not given. The end is after the start.
+func (r *OverlayRecord) Validity(now time.Time) (start, end time.Time) {
+	if r.Start.Valid() {
+		start = r.Start.Time(now)
+	}
+	if r.End.Valid() {
+		if start.IsZero() {
+			end = r.End.Time(now)
+		} else {
+			end = r.End.TimeAfter(start)
+		}
+	}
+	return start, end
+}
+
+func (r *OverlayRecord) AltitudeAGL() bool {
+	switch r.Geometry {
+	case GEOMETRY_POLYGON_AGL, GEOMETRY_PRISM_AGL, GEOMETRY_POINT_AGL, GEOMETRY_POLYLINE_AGL:
+		return true
+	}
+	return false
+}
+
+// Polygon vertices with the bottom and top altitude. 3D polygons can list the vertices twice, at the top and at the
+// bottom altitude; the repeated vertices are dropped. Polygons with a single altitude only give the top.
+func (r *OverlayRecord) Polygon() ([]GeoPoint, int32, int32) {
+	points := r.Points
+	if len(points) == 0 {
+		return points, 0, 0
+	}
+	bottom, top := points[0].Alt, points[0].Alt
+	for _, p := range points {
+		if p.Alt < bottom {
+			bottom = p.Alt
+		}
+		if p.Alt > top {
+			top = p.Alt
+		}
+	}
+	if n := len(points) / 2; len(points)%2 == 0 && n > 2 {
+		twice := true
+		for i := 0; i < n; i++ {
+			if points[i].Lat != points[n+i].Lat || points[i].Lon != points[n+i].Lon {
+				twice = false
+				break
+			}
+		}
+		if twice {
+			points = points[:n]
+		}
+	}
+	if bottom == top {
+		bottom = 0
+	}
+	return points, bottom, top
+}
+
+type TextRecord struct {
+	ReportNumber uint16
+	ReportYear   uint16
+	Active       bool // false: the report was cancelled
+	Text         string
+}
+
+// Identity of a record within a product: the report and, for overlays, the record.
+type FISBRecordID struct {
+	ReportNumber uint16
+	ReportYear   uint16
+	RecordID     uint8 // 0 for text records
+}
+
+// Decoded payload of a text/graphics product APDU, or of a complete segmented product file.
+type FISBProduct struct {
+	ProductID          uint32
+	RecordFormat       uint8
+	ProductVersion     uint8
+	LocationIdentifier string
+	RecordReference    uint8
+	Overlays           []OverlayRecord
+	TextRecords        []TextRecord
+	RecordIDs          []FISBRecordID // all records, including the overlay records that couldn't be decoded
+}
+
+func trimDLAC(s string) string {
+	return strings.TrimSpace(strings.Replace(s, "\x03", "", -1))
+}
+
+// Decodes the product header and all records. Records that can't be decoded are skipped.
+func DecodeFISBProduct(product_id uint32, data []byte) (*FISBProduct, error) {
+	if len(data) < 6 {
+		return nil, ErrShortRecord
+	}
+	p := &FISBProduct{
+		ProductID:          product_id,
+		RecordFormat:       data[0] >> 4,
+		ProductVersion:     data[0] & 0x0F,
+		LocationIdentifier: trimDLAC(dlac_decode(data[2:], 3)),
+		RecordReference:    data[5],
+	}
+	record_count := int(data[1] >> 4)
+	records := data[6:]
+	for i := 0; i < record_count; i++ {
+		var n int
+		switch p.RecordFormat {
+		case RECORD_FORMAT_TEXT:
+			if len(records) < 2 {
+				return p, ErrShortRecord
+			}
+			n = int(records[0])<<8 | int(records[1])
+			if n < 5 || n > len(records) {
+				return p, ErrShortRecord
+			}
+			t := decodeTextRecord(records[:n])
+			p.TextRecords = append(p.TextRecords, t)
+			p.RecordIDs = append(p.RecordIDs, FISBRecordID{ReportNumber: t.ReportNumber, ReportYear: t.ReportYear})
+		case RECORD_FORMAT_OVERLAY:
+			if len(records) < 2 {
+				return p, ErrShortRecord
+			}
+			n = int(records[0])<<2 | int(records[1]>>6)
+			if n < 5 || n > len(records) {
+				return p, ErrShortRecord
+			}
+			r, err := decodeOverlayRecord(records[:n])
+			if err == nil {
+				p.Overlays = append(p.Overlays, r)
+			}
+			p.RecordIDs = append(p.RecordIDs, FISBRecordID{ReportNumber: r.ReportNumber, ReportYear: r.ReportYear, RecordID: r.RecordID})
+		default:
+			return p, fmt.Errorf("unsupported FIS-B record format %d", p.RecordFormat)
+		}
+		records = records[n:]
+	}
+	return p, nil
+}
+
+// Text record: record length (16 bits, including itself), report number (14), report year (7), report status (1), DLAC text.
+func decodeTextRecord(rec []byte) TextRecord {
+	return TextRecord{
+		ReportNumber: uint16(rec[2])<<6 | uint16(rec[3]&0xFC)>>2,
+		ReportYear:   uint16(rec[3]&0x03)<<5 | uint16(rec[4]&0xF8)>>3,
+		Active:       rec[4]&0x04 != 0,
+		Text:         strings.TrimRight(dlac_decode(rec[5:], uint32(len(rec)-5)), "\x03 "),
+	}
+}
+
+// Extended range 3D vertex: longitude (19 bits), latitude (19 bits), altitude (10 bits, 100 ft).
+func decodeExtendedRangeVertex(b []byte) GeoPoint {
+	lng_raw := int32(b[0])<<11 | int32(b[1])<<3 | int32(b[2]&0xE0)>>5
+	lat_raw := int32(b[2]&0x1F)<<14 | int32(b[3])<<6 | int32(b[4]&0xFC)>>2
+	alt_raw := int32(b[4]&0x03)<<8 | int32(b[5])
+	lat, lng := airmetLatLng(lat_raw, lng_raw, false)
+	return GeoPoint{Lat: lat, Lon: lng, Alt: alt_raw * 100}
+}
+
+// Graphical overlay record (DO-358B table 3-22). The report number, year and record ID are also set on errors.
+// rec is at least five bytes.
+func decodeOverlayRecord(rec []byte) (OverlayRecord, error) {
+	var r OverlayRecord
+	r.ReportNumber = uint16(rec[1]&0x3F)<<8 | uint16(rec[2])
+	r.ReportYear = uint16(rec[3]&0xFE) >> 1
+	r.RecordID = (rec[4]&0x1E)>>1 + 1
+	if len(rec) < 7 {
+		return r, ErrShortRecord
+	}
+
+	pos := 7
+	if rec[4]&0x01 == 0 { // Numeric index.
+		r.ObjectLabel = fmt.Sprintf("%d", uint16(rec[5])<<8|uint16(rec[6]))
+	} else {
+		if len(rec) < 14 {
+			return r, ErrShortRecord
+		}
+		r.ObjectLabel = trimDLAC(dlac_decode(rec[5:], 9))
+		pos = 14
+	}
+
+	if len(rec) < pos+2 {
+		return r, ErrShortRecord
+	}
+	qualifier_flag := rec[pos]&0x40 != 0
+	param_flag := rec[pos]&0x20 != 0
+	r.ObjectElement = rec[pos] & 0x1F
+	r.ObjectType = rec[pos+1] >> 4
+	r.ObjectStatus = rec[pos+1] & 0x0F
+	pos += 2
+	if qualifier_flag {
+		if len(rec) < pos+3 {
+			return r, ErrShortRecord
+		}
+		r.ObjectQualifier = uint32(rec[pos])<<16 | uint32(rec[pos+1])<<8 | uint32(rec[pos+2])
+		pos += 3
+	}
+	if param_flag {
+		return r, errors.New("FIS-B overlay object parameters not supported")
+	}
+
+	if len(rec) < pos+2 {
+		return r, ErrShortRecord
+	}
+	applicability := rec[pos] >> 6
+	time_format := (rec[pos] & 0x30) >> 4
+	r.Geometry = rec[pos] & 0x0F
+	r.Operator = rec[pos+1] >> 6
+	vertices := int(rec[pos+1]&0x3F) + 1
+	pos += 2
+
+	tlen := fisbTimeLength(time_format)
+	if applicability&0x01 != 0 { // Start time (WEF)
+		if len(rec) < pos+tlen {
+			return r, ErrShortRecord
+		}
+		r.Start = parseFISBTime(rec[pos:], time_format)
+		pos += tlen
+	}
+	if applicability&0x02 != 0 { // End time (TIL)
+		if len(rec) < pos+tlen {
+			return r, ErrShortRecord
+		}
+		r.End = parseFISBTime(rec[pos:], time_format)
+		pos += tlen
+	}
+
+	data := rec[pos:]
+	switch r.Geometry {
+	case GEOMETRY_POLYGON_MSL, GEOMETRY_POLYGON_AGL, GEOMETRY_POLYLINE_MSL, GEOMETRY_POLYLINE_AGL, GEOMETRY_POINT_AGL, GEOMETRY_POINT_MSL:
+		if r.Geometry == GEOMETRY_POINT_AGL || r.Geometry == GEOMETRY_POINT_MSL {
+			vertices = 1
+		}
+		if len(data) < 6*vertices {
+			return r, ErrShortRecord
+		}
+		for i := 0; i < vertices; i++ {
+			r.Points = append(r.Points, decodeExtendedRangeVertex(data[6*i:]))
+		}
+	case GEOMETRY_PRISM_MSL, GEOMETRY_PRISM_AGL:
+		if len(data) < 14 {
+			return r, ErrShortRecord
+		}
+		lng_bot_raw := int32(data[0])<<10 | int32(data[1])<<2 | int32(data[2]&0xC0)>>6
+		lat_bot_raw := int32(data[2]&0x3F)<<12 | int32(data[3])<<4 | int32(data[4]&0xF0)>>4
+		lng_top_raw := int32(data[4]&0x0F)<<14 | int32(data[5])<<6 | int32(data[6]&0xFC)>>2
+		lat_top_raw := int32(data[6]&0x03)<<16 | int32(data[7])<<8 | int32(data[8])
+		r.AltBottom = (int32(data[9]&0xFE) >> 1) * 500
+		r.AltTop = (int32(data[9]&0x01)<<6 | int32(data[10]&0xFC)>>2) * 500
+		r.RadiusLng = float64(int32(data[10]&0x03)<<7|int32(data[11]&0xFE)>>1) * 0.2
+		r.RadiusLat = float64(int32(data[11]&0x01)<<8|int32(data[12])) * 0.2
+		r.Orientation = float64(data[13])
+		lat_bot, lng_bot := airmetLatLng(lat_bot_raw, lng_bot_raw, true)
+		lat_top, lng_top := airmetLatLng(lat_top_raw, lng_top_raw, true)
+		r.Points = []GeoPoint{{Lat: lat_bot, Lon: lng_bot, Alt: r.AltBottom}, {Lat: lat_top, Lon: lng_top, Alt: r.AltTop}}
+	case GEOMETRY_NONE:
+	default:
+		return r, fmt.Errorf("unsupported FIS-B geometry overlay option %d", r.Geometry)
+	}
+	return r, nil
+}
+
+type segmentKey struct {
+	station    string
+	product_id uint32
+	file_id    uint16
+}
+
+type segmentedFile struct {
+	segments [][]byte // by APDU number - 1
+	received int
+	lastSeen time.Time
+}
+
+// Reassembles segmented product files (DO-358B section 3.3.3). Not safe for concurrent use.
+type SegmentAssembler struct {
+	Timeout time.Duration // incomplete files are dropped when no segment was received for this long
+	files   map[segmentKey]*segmentedFile
+}
+
+func NewSegmentAssembler(timeout time.Duration) *SegmentAssembler {
+	return &SegmentAssembler{Timeout: timeout, files: make(map[segmentKey]*segmentedFile)}
+}
+
+// Adds a segment of a product file received from station (any identifier of the ground station). Returns the
+// complete file data once all segments were received. Every segment repeats the six byte product header, which is
+// only kept from the first one.
+func (a *SegmentAssembler) Add(station string, f *UATFrame, now time.Time) []byte {
+	for k, file := range a.files {
+		if now.Sub(file.lastSeen) > a.Timeout {
+			delete(a.files, k)
+		}
+	}
+	if !f.s_f || f.SegmentNumber == 0 || f.SegmentNumber > f.SegmentCount || len(f.FISB_data) < 6 {
+		return nil
+	}
+	key := segmentKey{station, f.Product_id, f.SegmentFileID}
+	file, ok := a.files[key]
+	if !ok || len(file.segments) != int(f.SegmentCount) {
+		file = &segmentedFile{segments: make([][]byte, f.SegmentCount)}
+		a.files[key] = file
+	}
+	file.lastSeen = now
+	idx := int(f.SegmentNumber) - 1
+	if file.segments[idx] == nil {
+		file.received++
+	}
+	file.segments[idx] = f.FISB_data
+	if file.received < len(file.segments) {
+		return nil
+	}
+	delete(a.files, key)
+	data := append([]byte{}, file.segments[0]...)
+	for _, s := range file.segments[1:] {
+		data = append(data, s[6:]...)
+	}
+	return data
+}
@@ -0,0 +1,611 @@
+package uatparse
+
+import (
+	"bufio"
+	"bytes"
+	"math"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+var captures = []struct {
+	file string
+	now  time.Time // reception time, to complete the FIS-B times
+}{
+	{"../test-data/example.dump978", time.Date(2015, 7, 29, 0, 0, 0, 0, time.UTC)},
+	{"../test-data/gms5002-09072015-problem-stratux-uat.log", time.Date(2015, 9, 7, 14, 17, 0, 0, time.UTC)},
+}
+
+// Calls fn for every decoded uplink frame of a capture.
+func forEachFrame(t *testing.T, file string, fn func(msg *UATMsg, f *UATFrame)) {
+	fp, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fp.Close()
+	scanner := bufio.NewScanner(fp)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		// Stratux logs prefix the message with a timestamp.
+		if i := strings.Index(line, "+"); i > 0 {
+			line = line[i:]
+		}
+		msg, err := New(line)
+		if err != nil {
+			continue
+		}
+		msg.DecodeUplink()
+		for _, f := range msg.Frames {
+			if f.Frame_type == 0 {
+				fn(msg, f)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Returns the first overlay record of a capture with the given product, location and report number.
+func findOverlay(t *testing.T, file string, product_id uint32, location string, report uint16) (*FISBProduct, OverlayRecord) {
+	var p *FISBProduct
+	var rec OverlayRecord
+	forEachFrame(t, file, func(msg *UATMsg, f *UATFrame) {
+		if p != nil || f.Product_id != product_id || f.Product == nil || f.Product.LocationIdentifier != location {
+			return
+		}
+		for _, r := range f.Product.Overlays {
+			if r.ReportNumber == report {
+				p, rec = f.Product, r
+				return
+			}
+		}
+	})
+	if p == nil {
+		t.Fatalf("%s: product %d %s report %d not found", file, product_id, location, report)
+	}
+	return p, rec
+}
+
+func closeTo(a, b, tolerance float64) bool {
+	return math.Abs(a-b) <= tolerance
+}
+
+func checkPoints(t *testing.T, name string, got, want []GeoPoint, tolerance float64) {
+	if len(got) != len(want) {
+		t.Errorf("%s: %d points, want %d", name, len(got), len(want))
+		return
+	}
+	for i := range want {
+		if !closeTo(got[i].Lat, want[i].Lat, tolerance) || !closeTo(got[i].Lon, want[i].Lon, tolerance) || got[i].Alt != want[i].Alt {
+			t.Errorf("%s: point %d is %.4f,%.4f,%d, want %.4f,%.4f,%d", name, i,
+				got[i].Lat, got[i].Lon, got[i].Alt, want[i].Lat, want[i].Lon, want[i].Alt)
+		}
+	}
+}
+
+// Every overlay of the captures lies in or around the CONUS, with plausible altitudes and validity times.
+func TestCaptureOverlays(t *testing.T) {
+	for _, c := range captures {
+		products := make(map[uint32]int)
+		forEachFrame(t, c.file, func(msg *UATMsg, f *UATFrame) {
+			if !IsOverlayProduct(f.Product_id) || f.Product == nil {
+				return
+			}
+			for _, r := range f.Product.Overlays {
+				products[f.Product_id]++
+				if len(r.Points) == 0 {
+					t.Errorf("%s: product %d report %d-%d without points", c.file, f.Product_id, r.ReportNumber, r.ReportYear)
+				}
+				for _, pt := range r.Points {
+					if pt.Lat < 20 || pt.Lat > 55 || pt.Lon < -130 || pt.Lon > -60 || pt.Alt < 0 || pt.Alt > 60000 {
+						t.Errorf("%s: product %d report %d-%d point %.4f,%.4f,%d out of range", c.file, f.Product_id,
+							r.ReportNumber, r.ReportYear, pt.Lat, pt.Lon, pt.Alt)
+					}
+				}
+				if start, end := r.Validity(c.now); !start.IsZero() && !end.IsZero() && !end.After(start) {
+					t.Errorf("%s: product %d report %d-%d ends before it starts: %s - %s", c.file, f.Product_id,
+						r.ReportNumber, r.ReportYear, r.Start, r.End)
+				}
+			}
+		})
+		for _, id := range []uint32{PRODUCT_NOTAM, PRODUCT_AIRMET, PRODUCT_SIGMET} {
+			if products[id] == 0 && c.file == captures[0].file {
+				t.Errorf("%s: no %s overlays", c.file, FISBProductNames[id])
+			}
+		}
+	}
+}
+
+func TestCaptureAirmetPolygon(t *testing.T) {
+	_, r := findOverlay(t, captures[0].file, PRODUCT_AIRMET, "", 1118)
+	if r.ReportYear != 15 || r.Geometry != GEOMETRY_POLYGON_MSL || r.AltitudeAGL() {
+		t.Errorf("report %d-%d geometry %d", r.ReportNumber, r.ReportYear, r.Geometry)
+	}
+	if r.Start.String() != "07-28 20:45" || r.End.String() != "07-29 03:00" {
+		t.Errorf("valid %s - %s, want 07-28 20:45 - 07-29 03:00", r.Start, r.End)
+	}
+	start := r.Start.Time(captures[0].now)
+	if !start.Equal(time.Date(2015, 7, 28, 20, 45, 0, 0, time.UTC)) {
+		t.Errorf("start %s", start)
+	}
+	points, bottom, top := r.Polygon()
+	checkPoints(t, "AIRMET 1118", points, []GeoPoint{
+		{Lat: 44.3655, Lon: -91.4838, Alt: 1000},
+		{Lat: 42.0234, Lon: -92.8558, Alt: 1000},
+		{Lat: 40.2827, Lon: -91.9398, Alt: 1000},
+		{Lat: 40.5437, Lon: -89.2516, Alt: 1000},
+		{Lat: 43.7853, Lon: -90.0900, Alt: 1000},
+		{Lat: 44.3655, Lon: -91.4838, Alt: 1000},
+	}, 0.0001)
+	// Single altitude: the polygon is below it.
+	if bottom != 0 || top != 1000 {
+		t.Errorf("altitudes %d-%d, want 0-1000", bottom, top)
+	}
+}
+
+// 3D polygon with the vertices listed at the top and at the bottom altitude.
+func TestCaptureAirmetPolygon3D(t *testing.T) {
+	_, r := findOverlay(t, captures[0].file, PRODUCT_AIRMET, "", 1123)
+	if len(r.Points) != 24 {
+		t.Fatalf("%d points, want 24", len(r.Points))
+	}
+	points, bottom, top := r.Polygon()
+	if len(points) != 12 || bottom != 32000 || top != 42000 {
+		t.Errorf("%d points %d-%d, want 12 points 32000-42000", len(points), bottom, top)
+	}
+	if points[0] != points[11] {
+		t.Errorf("polygon not closed: %v - %v", points[0], points[11])
+	}
+}
+
+func TestCaptureSigmetPolygon(t *testing.T) {
+	_, r := findOverlay(t, captures[0].file, PRODUCT_SIGMET, "", 2915)
+	points, bottom, top := r.Polygon()
+	checkPoints(t, "SIGMET 2915", points, []GeoPoint{
+		{Lat: 39.5666, Lon: -87.1806, Alt: 45000},
+		{Lat: 37.9035, Lon: -89.8167, Alt: 45000},
+		{Lat: 38.3553, Lon: -90.2850, Alt: 45000},
+		{Lat: 40.0198, Lon: -87.6585, Alt: 45000},
+		{Lat: 39.5666, Lon: -87.1806, Alt: 45000},
+	}, 0.0001)
+	if bottom != 0 || top != 45000 {
+		t.Errorf("altitudes %d-%d, want 0-45000", bottom, top)
+	}
+	if r.Start.String() != "07-28 21:55" || r.End.String() != "07-28 23:55" {
+		t.Errorf("valid %s - %s, want 07-28 21:55 - 07-28 23:55", r.Start, r.End)
+	}
+}
+
+func TestCaptureNotamPoint(t *testing.T) {
+	p, r := findOverlay(t, captures[0].file, PRODUCT_NOTAM, "KPTK", 12028)
+	if p.LocationIdentifier != "KPTK" || r.ObjectLabel != "KPTK" {
+		t.Errorf("location %q label %q, want KPTK", p.LocationIdentifier, r.ObjectLabel)
+	}
+	if r.Geometry != GEOMETRY_POINT_AGL || !r.AltitudeAGL() {
+		t.Errorf("geometry %d, want point AGL", r.Geometry)
+	}
+	checkPoints(t, "NOTAM KPTK 12028", r.Points, []GeoPoint{{Lat: 42.6654, Lon: -83.4206, Alt: 0}}, 0.0001)
+	start, end := r.Validity(captures[0].now)
+	if !start.Equal(time.Date(2015, 7, 29, 10, 0, 0, 0, time.UTC)) || !end.Equal(time.Date(2015, 7, 30, 4, 0, 0, 0, time.UTC)) {
+		t.Errorf("valid %s - %s", start, end)
+	}
+}
+
+// NOTAM-D KACY.06/057, valid 1506301956-1606301955. The times don't include the year.
+func TestCaptureNotamValidForAYear(t *testing.T) {
+	_, r := findOverlay(t, captures[1].file, PRODUCT_NOTAM, "KACY", 12057)
+	start, end := r.Validity(captures[1].now)
+	if !start.Equal(time.Date(2015, 6, 30, 19, 56, 0, 0, time.UTC)) || !end.Equal(time.Date(2016, 6, 30, 19, 55, 0, 0, time.UTC)) {
+		t.Errorf("valid %s - %s", start, end)
+	}
+}
+
+func TestCaptureSUA(t *testing.T) {
+	var found *SUAStatus
+	records, parsed := 0, 0
+	forEachFrame(t, captures[0].file, func(msg *UATMsg, f *UATFrame) {
+		if f.Product_id != PRODUCT_SUA || f.Product == nil {
+			return
+		}
+		records += len(f.Product.TextRecords)
+		parsed += len(f.SUA)
+		for i := range f.SUA {
+			if found == nil && f.SUA[i].Designator == "R5802C" {
+				found = &f.SUA[i]
+			}
+		}
+	})
+	if records == 0 || parsed != records {
+		t.Errorf("parsed %d of %d SUA records", parsed, records)
+	}
+	if found == nil {
+		t.Fatal("R5802C not found")
+	}
+	want := SUAStatus{
+		ScheduleID:     "3698675",
+		AirspaceID:     "25050",
+		Status:         SUA_STATUS_WAITING,
+		Type:           "R",
+		Name:           "5802C",
+		Start:          time.Date(2015, 7, 29, 12, 45, 0, 0, time.UTC),
+		End:            time.Date(2015, 7, 29, 23, 59, 0, 0, time.UTC),
+		AltBottom:      500,
+		AltTop:         17000,
+		SeparationRule: "A",
+		ShapeDefined:   true,
+		NFDCDesignator: "5802C",
+		Designator:     "R5802C",
+		Description:    "FORT INDIANTOWN GAP, PA",
+	}
+	if *found != want {
+		t.Errorf("got %+v\nwant %+v", *found, want)
+	}
+}
+
+// The captures only contain the first segments of a segmented NOTAM file.
+func TestCaptureSegments(t *testing.T) {
+	a := NewSegmentAssembler(15 * time.Minute)
+	var header []byte
+	segments := 0
+	forEachFrame(t, captures[0].file, func(msg *UATMsg, f *UATFrame) {
+		if f.SegmentCount == 0 {
+			return
+		}
+		segments++
+		if f.Product_id != PRODUCT_NOTAM || f.SegmentFileID != 398 || f.SegmentCount != 23 || int(f.SegmentNumber) != segments {
+			t.Errorf("segment product %d file %d %d/%d", f.Product_id, f.SegmentFileID, f.SegmentNumber, f.SegmentCount)
+		}
+		if f.Product != nil {
+			t.Errorf("segment %d decoded before reassembly", f.SegmentNumber)
+		}
+		if header == nil {
+			header = f.FISB_data[:6]
+		} else if !bytes.Equal(f.FISB_data[:6], header) {
+			t.Errorf("segment %d header %x, want %x", f.SegmentNumber, f.FISB_data[:6], header)
+		}
+		if a.Add("KDTW", f, captures[0].now) != nil {
+			t.Errorf("incomplete file returned after segment %d", f.SegmentNumber)
+		}
+	})
+	if segments != 4 {
+		t.Errorf("%d segments, want 4", segments)
+	}
+}
+
+// Writes values MSB first, for constructing records.
+type bitWriter struct {
+	b []byte
+	n int
+}
+
+func (w *bitWriter) put(v uint32, bits int) {
+	for i := bits - 1; i >= 0; i-- {
+		if w.n%8 == 0 {
+			w.b = append(w.b, 0)
+		}
+		w.b[w.n/8] |= byte((v>>uint(i))&0x01) << uint(7-w.n%8)
+		w.n++
+	}
+}
+
+func encodeAngle(deg float64, res float64, bits int) uint32 {
+	if deg < 0 {
+		deg += 360
+	}
+	return uint32(math.Round(deg/res)) & (1<<uint(bits) - 1)
+}
+
+func encodeFISBTime(w *bitWriter, t FISBTime) {
+	switch t.Format {
+	case FISB_TIME_MONTH_DAY_HOURS:
+		w.put(uint32(t.Month), 8)
+		fallthrough
+	case FISB_TIME_DAY_HOURS:
+		w.put(uint32(t.Day), 8)
+		fallthrough
+	case FISB_TIME_HOURS:
+		w.put(uint32(t.Hour), 8)
+		w.put(uint32(t.Minute), 8)
+	}
+}
+
+// Encodes a graphical overlay record with a numeric object label, the inverse of decodeOverlayRecord().
+func encodeOverlayRecord(r OverlayRecord) []byte {
+	w := &bitWriter{}
+	w.put(0, 10) // length, set below
+	w.put(uint32(r.ReportNumber), 14)
+	w.put(uint32(r.ReportYear), 7)
+	w.put(0, 4)
+	w.put(uint32(r.RecordID-1), 4)
+	w.put(0, 1) // numeric label
+	w.put(0, 16)
+	w.put(0, 3) // no qualifier or parameters
+	w.put(uint32(r.ObjectElement), 5)
+	w.put(uint32(r.ObjectType), 4)
+	w.put(uint32(r.ObjectStatus), 4)
+	applicability := uint32(0)
+	if r.Start.Valid() {
+		applicability |= 0x01
+	}
+	if r.End.Valid() {
+		applicability |= 0x02
+	}
+	format := r.Start.Format
+	if format == FISB_TIME_NONE {
+		format = r.End.Format
+	}
+	w.put(applicability, 2)
+	w.put(uint32(format), 2)
+	w.put(uint32(r.Geometry), 4)
+	w.put(uint32(r.Operator), 2)
+	if r.Geometry == GEOMETRY_PRISM_MSL || r.Geometry == GEOMETRY_PRISM_AGL {
+		w.put(0, 6)
+	} else {
+		w.put(uint32(len(r.Points)-1), 6)
+	}
+	if r.Start.Valid() {
+		encodeFISBTime(w, r.Start)
+	}
+	if r.End.Valid() {
+		encodeFISBTime(w, r.End)
+	}
+	switch r.Geometry {
+	case GEOMETRY_PRISM_MSL, GEOMETRY_PRISM_AGL:
+		for _, p := range r.Points {
+			w.put(encodeAngle(p.Lon, PRISM_RES, 18), 18)
+			w.put(encodeAngle(p.Lat, PRISM_RES, 18), 18)
+		}
+		w.put(uint32(r.AltBottom/500), 7)
+		w.put(uint32(r.AltTop/500), 7)
+		w.put(uint32(math.Round(r.RadiusLng/0.2)), 9)
+		w.put(uint32(math.Round(r.RadiusLat/0.2)), 9)
+		w.put(uint32(r.Orientation), 8)
+	default:
+		for _, p := range r.Points {
+			w.put(encodeAngle(p.Lon, EXTENDED_RANGE_3D_RES, 19), 19)
+			w.put(encodeAngle(p.Lat, EXTENDED_RANGE_3D_RES, 19), 19)
+			w.put(uint32(p.Alt/100), 10)
+		}
+	}
+	w.b[0] = byte(len(w.b) >> 2)
+	w.b[1] |= byte(len(w.b)&0x03) << 6
+	return w.b
+}
+
+// Product header with the records appended. No location identifier.
+func encodeOverlayProduct(records ...OverlayRecord) []byte {
+	data := []byte{RECORD_FORMAT_OVERLAY<<4 | 1, byte(len(records)) << 4, 0, 0, 0, 0}
+	for _, r := range records {
+		data = append(data, encodeOverlayRecord(r)...)
+	}
+	return data
+}
+
+var testStart = FISBTime{Format: FISB_TIME_DAY_HOURS, Day: 31, Hour: 21, Minute: 0}
+var testEnd = FISBTime{Format: FISB_TIME_DAY_HOURS, Day: 1, Hour: 3, Minute: 0}
+
+// One record for each of the products that aren't in the captures.
+var constructedOverlays = []struct {
+	product_id uint32
+	record     OverlayRecord
+}{
+	{PRODUCT_G_AIRMET, OverlayRecord{ // IFR, 3D polygon between 3000 and 12000ft AGL
+		ReportNumber: 101, ReportYear: 26, RecordID: 1, ObjectLabel: "0", ObjectType: 14, ObjectStatus: 15,
+		Start: testStart, End: testEnd, Geometry: GEOMETRY_POLYGON_AGL,
+		Points: []GeoPoint{
+			{Lat: 40.0, Lon: -100.0, Alt: 12000}, {Lat: 41.5, Lon: -100.0, Alt: 12000},
+			{Lat: 41.5, Lon: -97.25, Alt: 12000}, {Lat: 40.0, Lon: -97.25, Alt: 12000},
+			{Lat: 40.0, Lon: -100.0, Alt: 3000}, {Lat: 41.5, Lon: -100.0, Alt: 3000},
+			{Lat: 41.5, Lon: -97.25, Alt: 3000}, {Lat: 40.0, Lon: -97.25, Alt: 3000},
+		},
+	}},
+	{PRODUCT_CWA, OverlayRecord{
+		ReportNumber: 2, ReportYear: 26, RecordID: 2, ObjectLabel: "0", ObjectType: 14, ObjectStatus: 15,
+		Start: testStart, End: testEnd, Geometry: GEOMETRY_POLYGON_MSL,
+		Points: []GeoPoint{
+			{Lat: 33.0, Lon: -112.5, Alt: 25000}, {Lat: 34.25, Lon: -111.0, Alt: 25000},
+			{Lat: 32.5, Lon: -110.0, Alt: 25000}, {Lat: 33.0, Lon: -112.5, Alt: 25000},
+		},
+	}},
+	{PRODUCT_NOTAM_TRA, OverlayRecord{
+		ReportNumber: 6001, ReportYear: 26, RecordID: 1, ObjectLabel: "0", ObjectType: 0, ObjectStatus: 15,
+		End: FISBTime{Format: FISB_TIME_HOURS, Hour: 23, Minute: 59}, Geometry: GEOMETRY_POLYLINE_MSL,
+		Points: []GeoPoint{{Lat: 35.5, Lon: -117.0, Alt: 18000}, {Lat: 36.0, Lon: -116.0, Alt: 18000}},
+	}},
+	{PRODUCT_NOTAM_TFR, OverlayRecord{ // 3NM, up to 17500ft MSL
+		ReportNumber: 6045, ReportYear: 26, RecordID: 1, ObjectLabel: "0", ObjectType: 0, ObjectStatus: 15,
+		Start: testStart, End: testEnd, Geometry: GEOMETRY_PRISM_MSL,
+		AltBottom: 0, AltTop: 17500, RadiusLat: 3, RadiusLng: 3, Orientation: 0,
+		Points: []GeoPoint{{Lat: 38.8977, Lon: -77.0365, Alt: 0}, {Lat: 38.8977, Lon: -77.0365, Alt: 17500}},
+	}},
+}
+
+func checkOverlay(t *testing.T, name string, got, want OverlayRecord) {
+	if got.ReportNumber != want.ReportNumber || got.ReportYear != want.ReportYear || got.RecordID != want.RecordID ||
+		got.ObjectLabel != want.ObjectLabel || got.ObjectType != want.ObjectType || got.ObjectStatus != want.ObjectStatus {
+		t.Errorf("%s: got %d-%d/%d label %q type %d status %d", name, got.ReportNumber, got.ReportYear, got.RecordID,
+			got.ObjectLabel, got.ObjectType, got.ObjectStatus)
+	}
+	if got.Geometry != want.Geometry || got.Start != want.Start || got.End != want.End {
+		t.Errorf("%s: geometry %d valid %s - %s, want %d %s - %s", name, got.Geometry, got.Start, got.End,
+			want.Geometry, want.Start, want.End)
+	}
+	if got.AltBottom != want.AltBottom || got.AltTop != want.AltTop || got.RadiusLat != want.RadiusLat ||
+		got.RadiusLng != want.RadiusLng || got.Orientation != want.Orientation {
+		t.Errorf("%s: prism %d-%d %.1fx%.1f %.0f", name, got.AltBottom, got.AltTop, got.RadiusLat, got.RadiusLng, got.Orientation)
+	}
+	tolerance := EXTENDED_RANGE_3D_RES
+	if want.Geometry == GEOMETRY_PRISM_MSL || want.Geometry == GEOMETRY_PRISM_AGL {
+		tolerance = PRISM_RES
+	}
+	checkPoints(t, name, got.Points, want.Points, tolerance)
+}
+
+func TestDecodeOverlayProducts(t *testing.T) {
+	for _, c := range constructedOverlays {
+		name := FISBProductNames[c.product_id]
+		p, err := DecodeFISBProduct(c.product_id, encodeOverlayProduct(c.record))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if p.RecordFormat != RECORD_FORMAT_OVERLAY || len(p.Overlays) != 1 {
+			t.Errorf("%s: format %d, %d overlays", name, p.RecordFormat, len(p.Overlays))
+			continue
+		}
+		checkOverlay(t, name, p.Overlays[0], c.record)
+	}
+
+	// G-AIRMET: 3D AGL polygon
+	p, _ := DecodeFISBProduct(PRODUCT_G_AIRMET, encodeOverlayProduct(constructedOverlays[0].record))
+	r := p.Overlays[0]
+	points, bottom, top := r.Polygon()
+	if !r.AltitudeAGL() || len(points) != 4 || bottom != 3000 || top != 12000 {
+		t.Errorf("G-AIRMET: AGL %v, %d points %d-%d", r.AltitudeAGL(), len(points), bottom, top)
+	}
+}
+
+// Records that can't be decoded are skipped, but still identified.
+func TestDecodeOverlayRecordIDs(t *testing.T) {
+	unsupported := constructedOverlays[1].record
+	unsupported.ReportNumber = 3
+	unsupported.RecordID = 5
+	unsupported.Geometry = 5 // reserved
+	p, err := DecodeFISBProduct(PRODUCT_CWA, encodeOverlayProduct(constructedOverlays[1].record, unsupported))
+	if err != nil || len(p.Overlays) != 1 {
+		t.Fatalf("%v, %d overlays", err, len(p.Overlays))
+	}
+	want := []FISBRecordID{{ReportNumber: 2, ReportYear: 26, RecordID: 2}, {ReportNumber: 3, ReportYear: 26, RecordID: 5}}
+	if len(p.RecordIDs) != len(want) || p.RecordIDs[0] != want[0] || p.RecordIDs[1] != want[1] {
+		t.Errorf("record IDs %v, want %v", p.RecordIDs, want)
+	}
+}
+
+func TestDecodeOverlayTruncated(t *testing.T) {
+	data := encodeOverlayProduct(constructedOverlays[0].record)
+	for n := 6; n < len(data); n++ {
+		p, err := DecodeFISBProduct(PRODUCT_G_AIRMET, data[:n])
+		if err == nil || len(p.Overlays) != 0 {
+			t.Errorf("%d of %d bytes: err %v, %d overlays", n, len(data), err, len(p.Overlays))
+		}
+	}
+}
+
+func TestFISBTime(t *testing.T) {
+	now := time.Date(2026, 8, 1, 0, 30, 0, 0, time.UTC)
+	newYear := time.Date(2026, 1, 1, 0, 30, 0, 0, time.UTC)
+	tests := []struct {
+		t    FISBTime
+		now  time.Time
+		want time.Time
+	}{
+		{testStart, now, time.Date(2026, 7, 31, 21, 0, 0, 0, time.UTC)},
+		{testEnd, now, time.Date(2026, 8, 1, 3, 0, 0, 0, time.UTC)},
+		{FISBTime{Format: FISB_TIME_HOURS, Hour: 23, Minute: 59}, now, time.Date(2026, 7, 31, 23, 59, 0, 0, time.UTC)},
+		{FISBTime{Format: FISB_TIME_MONTH_DAY_HOURS, Month: 12, Day: 31, Hour: 12}, newYear, time.Date(2025, 12, 31, 12, 0, 0, 0, time.UTC)},
+		{FISBTime{Format: FISB_TIME_DAY_HOURS, Day: 31, Hour: 12}, newYear, time.Date(2025, 12, 31, 12, 0, 0, 0, time.UTC)},
+		{FISBTime{}, now, time.Time{}},
+	}
+	for _, tt := range tests {
+		if got := tt.t.Time(tt.now); !got.Equal(tt.want) {
+			t.Errorf("%q at %s: %s, want %s", tt.t.String(), tt.now, got, tt.want)
+		}
+	}
+
+	start := time.Date(2026, 6, 30, 19, 56, 0, 0, time.UTC)
+	after := []struct {
+		t    FISBTime
+		want time.Time
+	}{
+		{FISBTime{Format: FISB_TIME_MONTH_DAY_HOURS, Month: 6, Day: 30, Hour: 19, Minute: 55}, time.Date(2027, 6, 30, 19, 55, 0, 0, time.UTC)},
+		{FISBTime{Format: FISB_TIME_MONTH_DAY_HOURS, Month: 7, Day: 2, Hour: 0, Minute: 0}, time.Date(2026, 7, 2, 0, 0, 0, 0, time.UTC)},
+		{FISBTime{Format: FISB_TIME_DAY_HOURS, Day: 1, Hour: 3, Minute: 0}, time.Date(2026, 7, 1, 3, 0, 0, 0, time.UTC)},
+		{FISBTime{Format: FISB_TIME_HOURS, Hour: 19, Minute: 56}, start},
+		{FISBTime{Format: FISB_TIME_HOURS, Hour: 2, Minute: 0}, time.Date(2026, 7, 1, 2, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range after {
+		if got := tt.t.TimeAfter(start); !got.Equal(tt.want) {
+			t.Errorf("%q after %s: %s, want %s", tt.t.String(), start, got, tt.want)
+		}
+	}
+}
+
+// Splits a product file into segments of at most size record bytes, each with the product header.
+func segmentFrames(product_id uint32, file_id uint16, data []byte, size int) []*UATFrame {
+	var frames []*UATFrame
+	records := data[6:]
+	count := (len(records) + size - 1) / size
+	for i := 0; i < count; i++ {
+		end := (i + 1) * size
+		if end > len(records) {
+			end = len(records)
+		}
+		segment := append(append([]byte{}, data[:6]...), records[i*size:end]...)
+		frames = append(frames, &UATFrame{
+			Product_id:    product_id,
+			s_f:           true,
+			SegmentFileID: file_id,
+			SegmentCount:  uint16(count),
+			SegmentNumber: uint16(i + 1),
+			FISB_data:     segment,
+		})
+	}
+	return frames
+}
+
+func TestSegmentAssembler(t *testing.T) {
+	var records []OverlayRecord
+	for _, c := range constructedOverlays {
+		records = append(records, c.record)
+	}
+	data := encodeOverlayProduct(records...)
+	frames := segmentFrames(PRODUCT_NOTAM_TFR, 7, data, 20)
+	if len(frames) < 3 {
+		t.Fatalf("%d segments", len(frames))
+	}
+	now := time.Date(2026, 8, 1, 0, 30, 0, 0, time.UTC)
+
+	// Out of order, with a repeated segment and an interleaved file of another station.
+	a := NewSegmentAssembler(time.Minute)
+	other := segmentFrames(PRODUCT_NOTAM_TFR, 7, data, 20)
+	var assembled []byte
+	order := []int{2, 0, 2}
+	for i := 3; i < len(frames); i++ {
+		order = append(order, i)
+	}
+	for _, i := range order {
+		if a.Add("A", frames[i], now) != nil {
+			t.Fatalf("file returned before segment 2")
+		}
+		if a.Add("B", other[i], now) != nil {
+			t.Fatalf("file of station B returned")
+		}
+	}
+	assembled = a.Add("A", frames[1], now)
+	if !bytes.Equal(assembled, data) {
+		t.Fatalf("assembled %x\nwant %x", assembled, data)
+	}
+	p, err := DecodeFISBProduct(PRODUCT_NOTAM_TFR, assembled)
+	if err != nil || len(p.Overlays) != len(records) {
+		t.Fatalf("assembled file: %v, %d overlays", err, len(p.Overlays))
+	}
+	for i := range records {
+		checkOverlay(t, "assembled", p.Overlays[i], records[i])
+	}
+	if a.Add("A", frames[1], now) != nil {
+		t.Errorf("file returned twice")
+	}
+
+	// Incomplete files time out.
+	a = NewSegmentAssembler(time.Minute)
+	for _, f := range frames[:len(frames)-1] {
+		a.Add("A", f, now)
+	}
+	if a.Add("A", frames[len(frames)-1], now.Add(2*time.Minute)) != nil {
+		t.Errorf("file assembled from timed out segments")
+	}
+}
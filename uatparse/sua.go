@@ -0,0 +1,109 @@
+package uatparse
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SUA status (product 13). Text records of the form
+//
+//	SUA 291245 3698675|25050|W|R|5802C|1507291245|1507292359|005|170|A|Y||5802C|R5802C|FORT INDIANTOWN GAP, PA
+//
+// schedule ID, airspace ID, status, airspace type, name, start and end (yymmddhhmm UTC), low and high altitude in
+// hundreds of feet, separation rule, shape indicator, NFDC ID, NFDC designator, designator and description.
+
+const (
+	SUA_STATUS_WAITING = "W" // scheduled
+	SUA_STATUS_PENDING = "P" // about to become active
+	SUA_STATUS_HOT     = "H" // active
+
+	SUA_ALTITUDE_UNLIMITED = 999
+)
+
+var SUATypeNames = map[string]string{
+	"A": "Alert",
+	"M": "MOA",
+	"P": "Prohibited",
+	"R": "Restricted",
+	"W": "Warning",
+}
+
+var ErrNotSUA = errors.New("not an SUA status record")
+
+type SUAStatus struct {
+	ScheduleID     string
+	AirspaceID     string
+	Status         string // SUA_STATUS_*
+	Type           string // key of SUATypeNames, or other single letter codes
+	Name           string
+	Start          time.Time // UTC
+	End            time.Time // UTC
+	AltBottom      int32     // feet
+	AltTop         int32     // feet, 0 = unlimited
+	SeparationRule string
+	ShapeDefined   bool // graphics are broadcast for the airspace
+	NFDCID         string
+	NFDCDesignator string
+	Designator     string
+	Description    string
+}
+
+func (s *SUAStatus) Active() bool {
+	return s.Status == SUA_STATUS_HOT
+}
+
+func parseSUATime(s string) time.Time {
+	t, err := time.Parse("0601021504", s)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// Hundreds of feet to feet.
+func parseSUAAltitude(s string) int32 {
+	v, err := strconv.Atoi(s)
+	if err != nil || v == SUA_ALTITUDE_UNLIMITED {
+		return 0
+	}
+	return int32(v * 100)
+}
+
+func ParseSUAStatus(text string) (*SUAStatus, error) {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, "SUA ") {
+		return nil, ErrNotSUA
+	}
+	// Skip the "SUA ddhhmm " header.
+	x := strings.SplitN(text, " ", 3)
+	if len(x) < 3 {
+		return nil, ErrNotSUA
+	}
+	fields := strings.Split(x[2], "|")
+	if len(fields) < 11 {
+		return nil, ErrNotSUA
+	}
+	for len(fields) < 15 {
+		fields = append(fields, "")
+	}
+	s := &SUAStatus{
+		ScheduleID:     fields[0],
+		AirspaceID:     fields[1],
+		Status:         fields[2],
+		Type:           fields[3],
+		Name:           fields[4],
+		Start:          parseSUATime(fields[5]),
+		End:            parseSUATime(fields[6]),
+		AltBottom:      parseSUAAltitude(fields[7]),
+		AltTop:         parseSUAAltitude(fields[8]),
+		SeparationRule: fields[9],
+		ShapeDefined:   fields[10] == "Y",
+		NFDCID:         fields[11],
+		NFDCDesignator: fields[12],
+		Designator:     fields[13],
+		Description:    strings.TrimSpace(fields[14]),
+	}
+	return s, nil
+}
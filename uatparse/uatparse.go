@@ -64,6 +64,18 @@ type UATFrame struct {
 
 	// For NEXRAD.
 	NEXRAD []NEXRADBlock
+	// Icing, cloud tops, turbulence and lightning. Same block grid as NEXRAD.
+	Blocks []NEXRADBlock
+	// SUA status (product 13).
+	SUA []SUAStatus
+
+	// Segmented products (s_f set). FISB_data is one segment, see SegmentAssembler.
+	SegmentFileID uint16
+	SegmentCount  uint16 // product file length, in APDUs
+	SegmentNumber uint16 // APDU number, 1..SegmentCount
+
+	// Text and graphical products (8, 11-17) that are not segmented.
+	Product *FISBProduct
 }
 
 type UATMsg struct {
@@ -122,6 +134,7 @@ func (f *UATFrame) decodeTimeFormat() {
 	t_opt := ((uint32(f.Raw_data[1]) & 0x01) << 1) | (uint32(f.Raw_data[2]) >> 7)
 
 	var fisb_data []byte
+	var header_bits int
 	switch t_opt {
 	case 0: // Hours, Minutes.
 		if f.frame_length < 4 {
@@ -131,6 +144,7 @@ func (f *UATFrame) decodeTimeFormat() {
 		f.FISB_minutes = ((uint32(f.Raw_data[2]) & 0x03) << 4) | (uint32(f.Raw_data[3]) >> 4)
 		f.FISB_length = f.frame_length - 4
 		fisb_data = f.Raw_data[4:]
+		header_bits = 28
 	case 1: // Hours, Minutes, Seconds.
 		if f.frame_length < 5 {
 			return
@@ -140,6 +154,7 @@ func (f *UATFrame) decodeTimeFormat() {
 		f.FISB_seconds = ((uint32(f.Raw_data[3]) & 0x0f) << 2) | (uint32(f.Raw_data[4]) >> 6)
 		f.FISB_length = f.frame_length - 5
 		fisb_data = f.Raw_data[5:]
+		header_bits = 34
 	case 2: // Month, Day, Hours, Minutes.
 		if f.frame_length < 5 {
 			return
@@ -150,6 +165,7 @@ func (f *UATFrame) decodeTimeFormat() {
 		f.FISB_minutes = ((uint32(f.Raw_data[3]) & 0x01) << 5) | (uint32(f.Raw_data[4]) >> 3)
 		f.FISB_length = f.frame_length - 5
 		fisb_data = f.Raw_data[5:]
+		header_bits = 37
 	case 3: // Month, Day, Hours, Minutes, Seconds.
 		if f.frame_length < 6 {
 			return
@@ -161,6 +177,7 @@ func (f *UATFrame) decodeTimeFormat() {
 		f.FISB_seconds = ((uint32(f.Raw_data[4]) & 0x03) << 3) | (uint32(f.Raw_data[5]) >> 5)
 		f.FISB_length = f.frame_length - 6
 		fisb_data = f.Raw_data[6:]
+		header_bits = 43
 	default:
 		return // Should never reach this.
 	}
@@ -169,7 +186,29 @@ func (f *UATFrame) decodeTimeFormat() {
 
 	if (uint16(f.Raw_data[1]) & 0x02) != 0 {
 		f.s_f = true // Default false.
+
+		// Segmentation fields follow the time: product file ID (10 bits), product file length (9), APDU number (9).
+		data_start := (header_bits + 28 + 7) / 8
+		if int(f.frame_length) < data_start {
+			f.FISB_data = nil
+			f.FISB_length = 0
+			return
+		}
+		f.SegmentFileID = uint16(readBits(f.Raw_data, header_bits, 10))
+		f.SegmentCount = uint16(readBits(f.Raw_data, header_bits+10, 9))
+		f.SegmentNumber = uint16(readBits(f.Raw_data, header_bits+19, 9))
+		f.FISB_data = f.Raw_data[data_start:]
+		f.FISB_length = f.frame_length - uint32(data_start)
+	}
+}
+
+// Reads n bits starting at bit pos (MSB first).
+func readBits(b []byte, pos, n int) uint32 {
+	v := uint32(0)
+	for i := pos; i < pos+n; i++ {
+		v = v<<1 | uint32(b[i/8]>>(7-uint(i%8)))&0x01
 	}
+	return v
 }
 
 // Format newlines.
@@ -201,36 +240,10 @@ func (f *UATFrame) decodeTextFrame() {
 	f.Text_data = formatDLACData(p)
 }
 
-// Gets month, day, hours, minutes.
-// Formats into a string.
-func airmetParseDate(b []byte, date_time_format uint8) string {
-	switch date_time_format {
-	case 0: // No date/time used.
-		return ""
-	case 1: // Month, Day, Hours, Minutes.
-		month := uint8(b[0])
-		day := uint8(b[1])
-		hours := uint8(b[2])
-		minutes := uint8(b[3])
-		return fmt.Sprintf("%02d-%02d %02d:%02d", month, day, hours, minutes)
-	case 2: // Day, Hours, Minutes.
-		day := uint8(b[0])
-		hours := uint8(b[1])
-		minutes := uint8(b[2])
-		return fmt.Sprintf("%02d %02d:%02d", day, hours, minutes)
-	case 3: // Hours, Minutes.
-		hours := uint8(b[0])
-		minutes := uint8(b[1])
-		return fmt.Sprintf("%02d:%02d", hours, minutes)
-	}
-
-	return ""
-}
-
 func airmetLatLng(lat_raw, lng_raw int32, alt bool) (float64, float64) {
-	fct := float64(0.000687)
+	fct := float64(EXTENDED_RANGE_3D_RES)
 	if alt {
-		fct = float64(0.001373)
+		fct = float64(PRISM_RES)
 	}
 	lat := fct * float64(lat_raw)
 	lng := fct * float64(lng_raw)
@@ -243,243 +256,40 @@ func airmetLatLng(lat_raw, lng_raw int32, alt bool) (float64, float64) {
 	return lat, lng
 }
 
-//TODO: Ignoring flags (segmentation, etc.)
-// Aero_FISB_ProdDef_Rev4.pdf
-// Decode product IDs 8-13.
+// Decodes text and graphical products (8, 11-17), see overlay.go. Segmented products are decoded after reassembly.
 func (f *UATFrame) decodeAirmet() {
-	// APDU header: 48 bits  (3-3) - assume no segmentation.
-
-	record_format := (uint8(f.FISB_data[0]) & 0xF0) >> 4
-	f.RecordFormat = record_format
-	fmt.Fprintf(ioutil.Discard, "record_format=%d\n", record_format)
-	product_version := (uint8(f.FISB_data[0]) & 0x0F)
-	fmt.Fprintf(ioutil.Discard, "product_version=%d\n", product_version)
-	record_count := (uint8(f.FISB_data[1]) & 0xF0) >> 4
-	fmt.Fprintf(ioutil.Discard, "record_count=%d\n", record_count)
-	location_identifier := dlac_decode(f.FISB_data[2:], 3)
-	fmt.Fprintf(ioutil.Discard, "%s\n", hex.Dump(f.FISB_data))
-	f.LocationIdentifier = location_identifier
-	fmt.Fprintf(ioutil.Discard, "location_identifier=%s\n", location_identifier)
-	record_reference := (uint8(f.FISB_data[5])) //FIXME: Special values. 0x00 means "use location_identifier". 0xFF means "use different reference". (4-3).
-	fmt.Fprintf(ioutil.Discard, "record_reference=%d\n", record_reference)
-	// Not sure when this is even used.
-	// rwy_designator := (record_reference & FC) >> 4
-	// parallel_rwy_designator := record_reference & 0x03 // 0 = NA, 1 = R, 2 = L, 3 = C (Figure 4-2).
-
-	//FIXME: Assume one record.
-	if record_count != 1 {
-		fmt.Fprintf(ioutil.Discard, "record_count=%d, != 1\n", record_count)
+	if f.s_f || len(f.FISB_data) < int(f.FISB_length) {
 		return
 	}
-	/*
-		0 - No data
-		1 - Unformatted ASCII Text
-		2 - Unformatted DLAC Text
-		3 - Unformatted DLAC Text w/ dictionary
-		4 - Formatted Text using ASN.1/PER
-		5-7 - Future Use
-		8 - Graphical Overlay
-		9-15 - Future Use
-	*/
-	switch record_format {
-	case 2:
-		record_length := (uint16(f.FISB_data[6]) << 8) | uint16(f.FISB_data[7])
-		if len(f.FISB_data)-int(record_length) < 6 {
-			fmt.Fprintf(ioutil.Discard, "FISB record not long enough: record_length=%d, len(f.FISB_data)=%d\n", record_length, len(f.FISB_data))
-			return
-		}
-		fmt.Fprintf(ioutil.Discard, "record_length=%d\n", record_length)
-		// Report identifier = report number + report year.
-		report_number := (uint16(f.FISB_data[8]) << 6) | ((uint16(f.FISB_data[9]) & 0xFC) >> 2)
-		f.ReportNumber = report_number
-		fmt.Fprintf(ioutil.Discard, "report_number=%d\n", report_number)
-		report_year := ((uint16(f.FISB_data[9]) & 0x03) << 5) | ((uint16(f.FISB_data[10]) & 0xF8) >> 3)
-		f.ReportYear = report_year
-		fmt.Fprintf(ioutil.Discard, "report_year=%d\n", report_year)
-		report_status := (uint8(f.FISB_data[10]) & 0x04) >> 2 //TODO: 0 = cancelled, 1 = active.
-		fmt.Fprintf(ioutil.Discard, "report_status=%d\n", report_status)
-		fmt.Fprintf(ioutil.Discard, "record_length=%d,len=%d\n", record_length, len(f.FISB_data))
-		text_data_len := record_length - 5
-		text_data := dlac_decode(f.FISB_data[11:], uint32(text_data_len))
-		fmt.Fprintf(ioutil.Discard, "text_data=%s\n", text_data)
-		f.Text_data = formatDLACData(text_data)
-	case 8:
-		// (6-1). (6.22 - Graphical Overlay Record Format).
-		record_data := f.FISB_data[6:] // Start after the record header.
-		record_length := (uint16(record_data[0]) << 2) | ((uint16(record_data[1]) & 0xC0) >> 6)
-		fmt.Fprintf(ioutil.Discard, "record_length=%d\n", record_length)
-		// Report identifier = report number + report year.
-		report_number := ((uint16(record_data[1]) & 0x3F) << 8) | uint16(record_data[2])
-		f.ReportNumber = report_number
-		fmt.Fprintf(ioutil.Discard, "report_number=%d\n", report_number)
-		report_year := (uint16(record_data[3]) & 0xFE) >> 1
-		f.ReportYear = report_year
-		fmt.Fprintf(ioutil.Discard, "report_year=%d\n", report_year)
-		overlay_record_identifier := ((uint8(record_data[4]) & 0x1E) >> 1) + 1 // Document instructs to add 1.
-		fmt.Fprintf(ioutil.Discard, "overlay_record_identifier=%d\n", overlay_record_identifier)
-		object_label_flag := uint8(record_data[4] & 0x01)
-		fmt.Fprintf(ioutil.Discard, "object_label_flag=%d\n", object_label_flag)
-
-		if object_label_flag == 0 { // Numeric index.
-			object_label := (uint8(record_data[5]) << 8) | uint8(record_data[6])
-			record_data = record_data[7:]
-			fmt.Fprintf(ioutil.Discard, "object_label=%d\n", object_label)
-		} else {
-			object_label := dlac_decode(record_data[5:], 9)
-			record_data = record_data[14:]
-			fmt.Fprintf(ioutil.Discard, "object_label=%s\n", object_label)
-		}
-
-		element_flag := (uint8(record_data[0]) & 0x80) >> 7
-		fmt.Fprintf(ioutil.Discard, "element_flag=%d\n", element_flag)
-		qualifier_flag := (uint8(record_data[0]) & 0x40) >> 6
-		fmt.Fprintf(ioutil.Discard, "qualifier_flag=%d\n", qualifier_flag)
-		param_flag := (uint8(record_data[0]) & 0x20) >> 5
-		fmt.Fprintf(ioutil.Discard, "param_flag=%d\n", param_flag)
-		object_element := uint8(record_data[0]) & 0x1F
-		fmt.Fprintf(ioutil.Discard, "object_element=%d\n", object_element)
-
-		object_type := (uint8(record_data[1]) & 0xF0) >> 4
-		fmt.Fprintf(ioutil.Discard, "object_type=%d\n", object_type)
-
-		object_status := uint8(record_data[1]) & 0x0F
-		fmt.Fprintf(ioutil.Discard, "object_status=%d\n", object_status)
-
-		//FIXME
-		if qualifier_flag == 0 { //TODO: Check.
-			record_data = record_data[2:]
-		} else {
-			object_qualifier := (uint32(record_data[2]) << 16) | (uint32(record_data[3]) << 8) | uint32(record_data[4])
-			fmt.Fprintf(ioutil.Discard, "object_qualifier=%d\n", object_qualifier)
-			fmt.Fprintf(ioutil.Discard, "%02x%02x%02x\n", record_data[2], record_data[3], record_data[4])
-			record_data = record_data[5:]
-		}
-		//FIXME
-		//if param_flag == 0 { //TODO: Check.
-		//	record_data = record_data[2:]
-		//} else {
-		//	//TODO.
-		//	//			record_data = record_data[4:]
-		//}
-
-		record_applicability_options := (uint8(record_data[0]) & 0xC0) >> 6
-		fmt.Fprintf(ioutil.Discard, "record_applicability_options=%d\n", record_applicability_options)
-		date_time_format := (uint8(record_data[0]) & 0x30) >> 4
-		fmt.Fprintf(ioutil.Discard, "date_time_format=%d\n", date_time_format)
-		geometry_overlay_options := uint8(record_data[0]) & 0x0F
-		fmt.Fprintf(ioutil.Discard, "geometry_overlay_options=%d\n", geometry_overlay_options)
-
-		overlay_operator := (uint8(record_data[1]) & 0xC0) >> 6
-		fmt.Fprintf(ioutil.Discard, "overlay_operator=%d\n", overlay_operator)
-
-		overlay_vertices_count := (uint8(record_data[1]) & 0x3F) + 1 // Document instructs to add 1. (6.20).
-		fmt.Fprintf(ioutil.Discard, "overlay_vertices_count=%d\n", overlay_vertices_count)
-
-		// Parse all of the dates.
-		switch record_applicability_options {
-		case 0: // No times given. UFN.
-			record_data = record_data[2:]
-		case 1: // Start time only. WEF.
-			f.ReportStart = airmetParseDate(record_data[2:], date_time_format)
-			record_data = record_data[6:]
-		case 2: // End time only. TIL.
-			f.ReportEnd = airmetParseDate(record_data[2:], date_time_format)
-			record_data = record_data[6:]
-		case 3: // Both start and end times. WEF.
-			f.ReportStart = airmetParseDate(record_data[2:], date_time_format)
-			f.ReportEnd = airmetParseDate(record_data[6:], date_time_format)
-			record_data = record_data[10:]
-		}
-
-		// Now we have the vertices.
-		switch geometry_overlay_options {
-		case 3: // Extended Range 3D Polygon (MSL).
-			points := make([]GeoPoint, 0) // Slice containing all of the points.
-			fmt.Fprintf(ioutil.Discard, "%d\n", len(record_data))
-			for i := 0; i < int(overlay_vertices_count); i++ {
-				lng_raw := (int32(record_data[6*i]) << 11) | (int32(record_data[6*i+1]) << 3) | (int32(record_data[6*i+2]) & 0xE0 >> 5)
-				lat_raw := ((int32(record_data[6*i+2]) & 0x1F) << 14) | (int32(record_data[6*i+3]) << 6) | ((int32(record_data[6*i+4]) & 0xFC) >> 2)
-				alt_raw := ((int32(record_data[6*i+4]) & 0x03) << 8) | int32(record_data[6*i+5])
-
-				fmt.Fprintf(ioutil.Discard, "lat_raw=%d, lng_raw=%d, alt_raw=%d\n", lat_raw, lng_raw, alt_raw)
-				lat, lng := airmetLatLng(lat_raw, lng_raw, false)
-
-				alt := alt_raw * 100
-				fmt.Fprintf(ioutil.Discard, "lat=%f,lng=%f,alt=%d\n", lat, lng, alt)
-				fmt.Fprintf(ioutil.Discard, "coord:%f,%f\n", lat, lng)
-				var point GeoPoint
-				point.Lat = lat
-				point.Lon = lng
-				point.Alt = alt
-				points = append(points, point)
-				f.Points = points
-			}
-		case 9: // Extended Range 3D Point (AGL). p.47.
-			if len(record_data) < 6 {
-				fmt.Fprintf(ioutil.Discard, "invalid data: Extended Range 3D Point. Should be 6 bytes; % seen.\n", len(record_data))
-			} else {
-				lng_raw := (int32(record_data[0]) << 11) | (int32(record_data[1]) << 3) | (int32(record_data[2]) & 0xE0 >> 5)
-				lat_raw := ((int32(record_data[2]) & 0x1F) << 14) | (int32(record_data[3]) << 6) | ((int32(record_data[4]) & 0xFC) >> 2)
-				alt_raw := ((int32(record_data[4]) & 0x03) << 8) | int32(record_data[5])
-
-				fmt.Fprintf(ioutil.Discard, "lat_raw=%d, lng_raw=%d, alt_raw=%d\n", lat_raw, lng_raw, alt_raw)
-				lat, lng := airmetLatLng(lat_raw, lng_raw, false)
-
-				alt := alt_raw * 100
-				fmt.Fprintf(ioutil.Discard, "lat=%f,lng=%f,alt=%d\n", lat, lng, alt)
-				fmt.Fprintf(ioutil.Discard, "coord:%f,%f\n", lat, lng)
-				var point GeoPoint
-				point.Lat = lat
-				point.Lon = lng
-				point.Alt = alt
-				f.Points = []GeoPoint{point}
-			}
-		case 7, 8: // Extended Range Circular Prism (7 = MSL, 8 = AGL)
-			if len(record_data) < 14 {
-				fmt.Fprintf(ioutil.Discard, "invalid data: Extended Range Circular Prism. Should be 14 bytes; % seen.\n", len(record_data))
-			} else {
-
-				lng_bot_raw := (int32(record_data[0]) << 10) | (int32(record_data[1]) << 2) | (int32(record_data[2]) & 0xC0 >> 6)
-				lat_bot_raw := ((int32(record_data[2]) & 0x3F) << 12) | (int32(record_data[3]) << 4) | ((int32(record_data[4]) & 0xF0) >> 4)
-				lng_top_raw := ((int32(record_data[4]) & 0x0F) << 14) | (int32(record_data[5]) << 6) | ((int32(record_data[6]) & 0xFC) >> 2)
-				lat_top_raw := ((int32(record_data[6]) & 0x03) << 16) | (int32(record_data[7]) << 8) | int32(record_data[8])
-
-				alt_bot_raw := (int32(record_data[9]) & 0xFE) >> 1
-				alt_top_raw := ((int32(record_data[9]) & 0x01) << 6) | ((int32(record_data[10]) & 0xFC) >> 2)
-
-				r_lng_raw := ((int32(record_data[10]) & 0x03) << 7) | ((int32(record_data[11]) & 0xFE) >> 1)
-				r_lat_raw := ((int32(record_data[11]) & 0x01) << 8) | int32(record_data[12])
-				alpha := int32(record_data[13])
-
-				lat_bot, lng_bot := airmetLatLng(lat_bot_raw, lng_bot_raw, true)
-				lat_top, lng_top := airmetLatLng(lat_top_raw, lng_top_raw, true)
-
-				alt_bot := alt_bot_raw * 5
-				alt_top := alt_top_raw * 500
-
-				r_lng := float64(r_lng_raw) * float64(0.2)
-				r_lat := float64(r_lat_raw) * float64(0.2)
-
-				fmt.Fprintf(ioutil.Discard, "lat_bot, lng_bot = %f, %f\n", lat_bot, lng_bot)
-				fmt.Fprintf(ioutil.Discard, "lat_top, lng_top = %f, %f\n", lat_top, lng_top)
-
-				if geometry_overlay_options == 8 {
-					fmt.Fprintf(ioutil.Discard, "alt_bot, alt_top = %d AGL, %d AGL\n", alt_bot, alt_top)
-				} else {
-					fmt.Fprintf(ioutil.Discard, "alt_bot, alt_top = %d MSL, %d MSL\n", alt_bot, alt_top)
-				}
-				fmt.Fprintf(ioutil.Discard, "r_lng, r_lat = %f, %f\n", r_lng, r_lat)
+	p, err := DecodeFISBProduct(f.Product_id, f.FISB_data[:f.FISB_length])
+	if p == nil {
+		fmt.Fprintf(ioutil.Discard, "product %d: %v\n", f.Product_id, err)
+		return
+	}
+	f.Product = p
+	f.RecordFormat = p.RecordFormat
+	f.LocationIdentifier = p.LocationIdentifier
+
+	// First record, for the tools in test/
+	if len(p.Overlays) > 0 {
+		r := p.Overlays[0]
+		f.ReportNumber = r.ReportNumber
+		f.ReportYear = r.ReportYear
+		f.ReportStart = r.Start.String()
+		f.ReportEnd = r.End.String()
+		f.Points = r.Points
+	} else if len(p.TextRecords) > 0 {
+		f.ReportNumber = p.TextRecords[0].ReportNumber
+		f.ReportYear = p.TextRecords[0].ReportYear
+	}
 
-				fmt.Fprintf(ioutil.Discard, "alpha=%d\n", alpha)
+	if f.Product_id == PRODUCT_SUA {
+		for _, t := range p.TextRecords {
+			if s, err := ParseSUAStatus(t.Text); err == nil {
+				f.SUA = append(f.SUA, *s)
 			}
-		default:
-			fmt.Fprintf(ioutil.Discard, "unknown geometry: %d\n", geometry_overlay_options)
 		}
-	//case 1: // Unformatted ASCII Text.
-	default:
-		fmt.Fprintf(ioutil.Discard, "unknown record format: %d\n", record_format)
 	}
-	fmt.Fprintf(ioutil.Discard, "\n\n\n")
 }
 
 func (f *UATFrame) decodeInfoFrame() {
@@ -499,12 +309,10 @@ func (f *UATFrame) decodeInfoFrame() {
 	switch f.Product_id {
 	case 413:
 		f.decodeTextFrame()
-		/*
-			case 8, 11, 13:
-				f.decodeAirmet()
-		*/
-	case 63, 64:
-		f.decodeNexradFrame()
+	case 8, 11, 12, 13, 14, 15, 16, 17:
+		f.decodeAirmet()
+	case 63, 64, 70, 71, 84, 90, 91, 103:
+		f.decodeGlobalBlockFrame()
 
 	default:
 		fmt.Fprintf(ioutil.Discard, "don't know what to do with product id: %d\n", f.Product_id)
@@ -0,0 +1,138 @@
+package weather
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	temperatureRegexp = regexp.MustCompile(`^(M?\d{2})/(M?\d{2})?$`)
+	altimeterRegexp   = regexp.MustCompile(`^([AQ])(\d{4})$`)
+	tempRemarkRegexp  = regexp.MustCompile(`^T([01])(\d{3})([01])(\d{3})$`) // RMK T02670189, tenths of degrees
+)
+
+type METAR struct {
+	Type      string // METAR or SPECI
+	Station   string
+	Time      time.Time // UTC
+	Auto      bool
+	Corrected bool // COR
+	Conditions
+	Temperature *float64 // degrees C
+	Dewpoint    *float64 // degrees C
+	Altimeter   *float64 // inHg
+	Remarks     string
+	Raw         string
+}
+
+// Parses a METAR or SPECI, with or without the leading type. ref is a time close to the report time, used to
+// complete the day/hour/minute report time.
+func ParseMETAR(text string, ref time.Time) (*METAR, error) {
+	tokens := tokenize(text)
+	m := &METAR{Type: "METAR", Raw: strings.TrimSpace(text)}
+	i := 0
+	if i < len(tokens) && (tokens[i] == "METAR" || tokens[i] == "SPECI") {
+		m.Type = tokens[i]
+		i++
+	}
+	if i < len(tokens) && tokens[i] == "COR" {
+		m.Corrected = true
+		i++
+	}
+	if i >= len(tokens) || !stationRegexp.MatchString(tokens[i]) {
+		return nil, ErrNoStation
+	}
+	m.Station = tokens[i]
+	i++
+	if i >= len(tokens) {
+		return nil, ErrNoTime
+	}
+	t, ok := parseDayTime(tokens[i], ref)
+	if !ok {
+		return nil, ErrNoTime
+	}
+	m.Time = t
+	i++
+
+	for i < len(tokens) {
+		tok := tokens[i]
+		if tok == "RMK" {
+			m.parseRemarks(tokens[i+1:])
+			break
+		}
+		switch tok {
+		case "AUTO":
+			m.Auto = true
+			i++
+			continue
+		case "COR":
+			m.Corrected = true
+			i++
+			continue
+		}
+		if tm := temperatureRegexp.FindStringSubmatch(tok); tm != nil {
+			m.Temperature = parseTemperature(tm[1])
+			if len(tm[2]) > 0 {
+				m.Dewpoint = parseTemperature(tm[2])
+			}
+			i++
+			continue
+		}
+		if am := altimeterRegexp.FindStringSubmatch(tok); am != nil {
+			v, _ := strconv.Atoi(am[2])
+			alt := float64(v) / 100
+			if am[1] == "Q" {
+				alt = float64(v) * INHG_PER_HPA
+			}
+			m.Altimeter = &alt
+			i++
+			continue
+		}
+		if n := m.Conditions.parseToken(tokens, i); n > 0 {
+			i += n
+			continue
+		}
+		i++ // runway visual range, recent weather, ...
+	}
+	m.Conditions.finish()
+	return m, nil
+}
+
+func (m *METAR) parseRemarks(tokens []string) {
+	m.Remarks = strings.Join(tokens, " ")
+	for _, tok := range tokens {
+		if tm := tempRemarkRegexp.FindStringSubmatch(tok); tm != nil {
+			m.Temperature = parseTenths(tm[1], tm[2])
+			m.Dewpoint = parseTenths(tm[3], tm[4])
+		}
+	}
+}
+
+// "M05" is -5
+func parseTemperature(s string) *float64 {
+	neg := strings.HasPrefix(s, "M")
+	v, err := strconv.Atoi(strings.TrimPrefix(s, "M"))
+	if err != nil {
+		return nil
+	}
+	t := float64(v)
+	if neg {
+		t = -t
+	}
+	return &t
+}
+
+// Sign digit (1 = negative) and tenths of degrees
+func parseTenths(sign, tenths string) *float64 {
+	v, err := strconv.Atoi(tenths)
+	if err != nil {
+		return nil
+	}
+	t := float64(v) / 10
+	if sign == "1" {
+		t = -t
+	}
+	return &t
+}
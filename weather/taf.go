@@ -0,0 +1,217 @@
+package weather
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	TAF_GROUP_INITIAL = "INITIAL" // conditions from the start of the validity period
+	TAF_GROUP_FM      = "FM"      // from, replaces all previous conditions
+	TAF_GROUP_BECMG   = "BECMG"   // becoming, gradual change during the period
+	TAF_GROUP_TEMPO   = "TEMPO"   // temporary fluctuations during the period
+	TAF_GROUP_PROB    = "PROB"    // probability of temporary conditions, PROB30 or PROB40 (also PROB30 TEMPO)
+)
+
+var (
+	tafPeriodRegexp = regexp.MustCompile(`^(\d{2})(\d{2})/(\d{2})(\d{2})$`)
+	tafFromRegexp   = regexp.MustCompile(`^FM(\d{2})(\d{2})(\d{2})$`)
+	tafProbRegexp   = regexp.MustCompile(`^PROB(\d{2})$`)
+)
+
+type TAFGroup struct {
+	Type        string    // TAF_GROUP_*
+	Probability int       // percent, PROB groups only
+	From        time.Time // UTC
+	To          time.Time // UTC. INITIAL and FM groups last until the next FM group
+	Conditions
+}
+
+type TAF struct {
+	Station   string
+	Amended   bool
+	Corrected bool
+	Issued    time.Time // UTC, zero if not given
+	ValidFrom time.Time // UTC
+	ValidTo   time.Time // UTC
+	Groups    []TAFGroup
+	Remarks   string // temperature forecasts, QNH, amendment remarks and everything else that isn't a change group
+	Raw       string
+}
+
+// "2818/2918"
+func parseTAFPeriod(s string, ref time.Time) (time.Time, time.Time, bool) {
+	m := tafPeriodRegexp.FindStringSubmatch(s)
+	if m == nil {
+		return time.Time{}, time.Time{}, false
+	}
+	d1, _ := strconv.Atoi(m[1])
+	h1, _ := strconv.Atoi(m[2])
+	d2, _ := strconv.Atoi(m[3])
+	h2, _ := strconv.Atoi(m[4])
+	from := resolveDayTime(d1, h1, 0, ref)
+	to := resolveDayTime(d2, h2, 0, from)
+	if to.Before(from) {
+		to = to.AddDate(0, 1, 0)
+	}
+	return from, to, true
+}
+
+// Parses a TAF with or without the leading TAF / TAF AMD / TAF.AMD. ref is a time close to the issue time.
+func ParseTAF(text string, ref time.Time) (*TAF, error) {
+	tokens := tokenize(text)
+	t := &TAF{Raw: strings.TrimSpace(text)}
+	i := 0
+header:
+	for ; i < len(tokens); i++ {
+		switch tokens[i] {
+		case "TAF":
+		case "TAF.AMD", "AMD":
+			t.Amended = true
+		case "TAF.COR", "COR":
+			t.Corrected = true
+		default:
+			break header
+		}
+	}
+	if i >= len(tokens) || !stationRegexp.MatchString(tokens[i]) {
+		return nil, ErrNoStation
+	}
+	t.Station = tokens[i]
+	i++
+	if i < len(tokens) {
+		if issued, ok := parseDayTime(tokens[i], ref); ok {
+			t.Issued = issued
+			ref = issued
+			i++
+		}
+	}
+	if i >= len(tokens) {
+		return nil, ErrNoTime
+	}
+	from, to, ok := parseTAFPeriod(tokens[i], ref)
+	if !ok {
+		return nil, ErrNoTime
+	}
+	t.ValidFrom, t.ValidTo = from, to
+	i++
+
+	group := &TAFGroup{Type: TAF_GROUP_INITIAL, From: t.ValidFrom, To: t.ValidTo}
+	var remarks []string
+	for i < len(tokens) {
+		tok := tokens[i]
+		if tok == "RMK" || tok == "AMD" || tok == "COR" {
+			remarks = append(remarks, tokens[i:]...)
+			break
+		}
+		next := ""
+		if i+1 < len(tokens) {
+			next = tokens[i+1]
+		}
+		if m := tafFromRegexp.FindStringSubmatch(tok); m != nil {
+			t.addGroup(group)
+			day, _ := strconv.Atoi(m[1])
+			hour, _ := strconv.Atoi(m[2])
+			minute, _ := strconv.Atoi(m[3])
+			group = &TAFGroup{Type: TAF_GROUP_FM, From: resolveDayTime(day, hour, minute, t.ValidFrom), To: t.ValidTo}
+			i++
+			continue
+		}
+		if tok == TAF_GROUP_BECMG || tok == TAF_GROUP_TEMPO {
+			if from, to, ok := parseTAFPeriod(next, t.ValidFrom); ok {
+				t.addGroup(group)
+				group = &TAFGroup{Type: tok, From: from, To: to}
+				i += 2
+				continue
+			}
+		}
+		if m := tafProbRegexp.FindStringSubmatch(tok); m != nil {
+			probability, _ := strconv.Atoi(m[1])
+			n := 1
+			if next == TAF_GROUP_TEMPO && i+2 < len(tokens) {
+				next = tokens[i+2]
+				n = 2
+			}
+			if from, to, ok := parseTAFPeriod(next, t.ValidFrom); ok {
+				t.addGroup(group)
+				group = &TAFGroup{Type: TAF_GROUP_PROB, Probability: probability, From: from, To: to}
+				i += n + 1
+				continue
+			}
+		}
+		if n := group.Conditions.parseToken(tokens, i); n > 0 {
+			i += n
+			continue
+		}
+		remarks = append(remarks, tok) // QNH2994INS, TX33/2820Z, wind shear, ...
+		i++
+	}
+	t.addGroup(group)
+	t.Remarks = strings.Join(remarks, " ")
+
+	// FM groups end where the next one starts
+	var last *TAFGroup
+	for i := range t.Groups {
+		g := &t.Groups[i]
+		if g.Type != TAF_GROUP_INITIAL && g.Type != TAF_GROUP_FM {
+			continue
+		}
+		if last != nil {
+			last.To = g.From
+		}
+		last = g
+	}
+	return t, nil
+}
+
+func (t *TAF) addGroup(g *TAFGroup) {
+	g.Conditions.finish()
+	t.Groups = append(t.Groups, *g)
+}
+
+// The INITIAL or FM group in effect at the given time, with BECMG changes applied that are complete by then.
+// TEMPO and PROB groups are ignored. Nil if the TAF isn't valid at that time.
+func (t *TAF) Prevailing(now time.Time) *TAFGroup {
+	if now.Before(t.ValidFrom) || !now.Before(t.ValidTo) {
+		return nil
+	}
+	var current *TAFGroup
+	for i := range t.Groups {
+		g := t.Groups[i]
+		switch g.Type {
+		case TAF_GROUP_INITIAL, TAF_GROUP_FM:
+			if !now.Before(g.From) {
+				current = &g
+			}
+		case TAF_GROUP_BECMG:
+			if current != nil && !now.Before(g.To) {
+				merged := *current
+				merged.Conditions = current.Conditions.merge(g.Conditions)
+				current = &merged
+			}
+		}
+	}
+	return current
+}
+
+// Conditions with the groups given in c2 replaced
+func (c Conditions) merge(c2 Conditions) Conditions {
+	if c2.Wind != nil {
+		c.Wind = c2.Wind
+	}
+	if c2.Visibility != nil {
+		c.Visibility = c2.Visibility
+		c.CAVOK = c2.CAVOK
+	}
+	if len(c2.Weather) > 0 {
+		c.Weather = c2.Weather
+	}
+	if len(c2.Clouds) > 0 {
+		c.Clouds = c2.Clouds
+		c.Ceiling = nil
+	}
+	c.finish()
+	return c
+}
@@ -0,0 +1,257 @@
+/*
+	Copyright (c) 2026 Stratux contributors
+	Distributable under the terms of The "BSD New" License
+	that can be found in the LICENSE file, herein included
+	as part of this header.
+
+	weather.go: METAR/SPECI and TAF parser. Turns the text reports received over FIS-B (or from anywhere else) into
+		structs with wind, visibility, weather, cloud layers, ceiling, temperatures and altimeter setting, TAF change
+		groups, and the flight category (VFR, MVFR, IFR, LIFR). Groups that aren't understood are skipped.
+		This file has the groups shared by METARs and TAFs.
+*/
+
+package weather
+
+import (
+	"errors"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	FLIGHT_CATEGORY_VFR  = "VFR"
+	FLIGHT_CATEGORY_MVFR = "MVFR"
+	FLIGHT_CATEGORY_IFR  = "IFR"
+	FLIGHT_CATEGORY_LIFR = "LIFR"
+
+	METERS_PER_SM   = 1609.344
+	KNOTS_PER_MPS   = 1.943844
+	KNOTS_PER_KMH   = 0.539957
+	INHG_PER_HPA    = 0.0295299830714
+	CAVOK_MILES     = 10000 / METERS_PER_SM // 10 km or more
+	CAVOK_CEILING   = 5000                  // feet, no clouds below
+	VISIBILITY_9999 = CAVOK_MILES           // "9999" is 10 km or more as well
+)
+
+var ErrNoStation = errors.New("weather: no station identifier")
+var ErrNoTime = errors.New("weather: no report time")
+
+var (
+	windRegexp         = regexp.MustCompile(`^(\d{3}|VRB)(\d{2,3})(?:G(\d{2,3}))?(KT|MPS|KMH)$`)
+	windVariableRegexp = regexp.MustCompile(`^(\d{3})V(\d{3})$`)
+	visibilityRegexp   = regexp.MustCompile(`^([PM])?(\d+)(?:/(\d+))?SM$`)
+	visibilityMRegexp  = regexp.MustCompile(`^(\d{4})(NDV)?$`)
+	cloudRegexp        = regexp.MustCompile(`^(FEW|SCT|BKN|OVC|VV)(\d{3}|///)(CB|TCU)?$`)
+	weatherRegexp      = regexp.MustCompile(`^(\+|-|VC)?(MI|PR|BC|DR|BL|SH|TS|FZ)?((?:DZ|RA|SN|SG|IC|PL|GR|GS|UP|BR|FG|FU|VA|DU|SA|HZ|PY|PO|SQ|FC|SS|DS)*)$`)
+	dayTimeRegexp      = regexp.MustCompile(`^(\d{2})(\d{2})(\d{2})Z$`)
+	stationRegexp      = regexp.MustCompile(`^[A-Z][A-Z0-9]{2,3}$`)
+)
+
+type Wind struct {
+	Direction    int  // degrees true, 0 if variable
+	Variable     bool // VRB
+	VariableFrom int  // dddVddd group, 0 if not given
+	VariableTo   int
+	Speed        int // knots
+	Gust         int // knots, 0 without gusts
+}
+
+type Visibility struct {
+	Miles       float64 // statute miles
+	LessThan    bool    // M1/4SM
+	GreaterThan bool    // P6SM, 9999, CAVOK
+}
+
+type CloudLayer struct {
+	Cover string // SKC, CLR, NSC, NCD, FEW, SCT, BKN, OVC or VV (vertical visibility, sky obscured)
+	Base  int    // feet AGL, 0 for clear sky or unknown (///)
+	Type  string // CB, TCU or empty
+}
+
+// Conditions reported by METARs and forecast by TAF groups. Nil / empty if not given.
+type Conditions struct {
+	Wind           *Wind
+	Visibility     *Visibility
+	Weather        []string // present weather groups, e.g. -TSRA or BR
+	Clouds         []CloudLayer
+	Ceiling        *int // feet AGL, lowest BKN, OVC or VV layer
+	CAVOK          bool
+	FlightCategory string // FLIGHT_CATEGORY_*, empty if neither visibility nor ceiling are known
+}
+
+// Removes the end of report marker and joins continuation lines.
+func tokenize(text string) []string {
+	text = strings.TrimSpace(text)
+	text = strings.TrimSuffix(text, "=")
+	return strings.Fields(text)
+}
+
+// Parses the condition groups starting at tokens[i] and returns the number of tokens used, 0 if tokens[i] isn't
+// a condition group.
+func (c *Conditions) parseToken(tokens []string, i int) int {
+	t := tokens[i]
+	if m := windRegexp.FindStringSubmatch(t); m != nil {
+		w := &Wind{}
+		if m[1] == "VRB" {
+			w.Variable = true
+		} else {
+			w.Direction, _ = strconv.Atoi(m[1])
+		}
+		w.Speed, _ = strconv.Atoi(m[2])
+		if len(m[3]) > 0 {
+			w.Gust, _ = strconv.Atoi(m[3])
+		}
+		switch m[4] {
+		case "MPS":
+			w.Speed = int(math.Round(float64(w.Speed) * KNOTS_PER_MPS))
+			w.Gust = int(math.Round(float64(w.Gust) * KNOTS_PER_MPS))
+		case "KMH":
+			w.Speed = int(math.Round(float64(w.Speed) * KNOTS_PER_KMH))
+			w.Gust = int(math.Round(float64(w.Gust) * KNOTS_PER_KMH))
+		}
+		c.Wind = w
+		return 1
+	}
+	if m := windVariableRegexp.FindStringSubmatch(t); m != nil && c.Wind != nil {
+		c.Wind.VariableFrom, _ = strconv.Atoi(m[1])
+		c.Wind.VariableTo, _ = strconv.Atoi(m[2])
+		return 1
+	}
+	if t == "CAVOK" {
+		c.CAVOK = true
+		c.Visibility = &Visibility{Miles: CAVOK_MILES, GreaterThan: true}
+		return 1
+	}
+	// "1 1/2SM"
+	if i+1 < len(tokens) && len(t) == 1 && t[0] >= '1' && t[0] <= '9' {
+		if m := visibilityRegexp.FindStringSubmatch(tokens[i+1]); m != nil && len(m[1]) == 0 && len(m[3]) > 0 {
+			whole, _ := strconv.Atoi(t)
+			c.Visibility = parseVisibilitySM(m)
+			c.Visibility.Miles += float64(whole)
+			return 2
+		}
+	}
+	if m := visibilityRegexp.FindStringSubmatch(t); m != nil {
+		c.Visibility = parseVisibilitySM(m)
+		return 1
+	}
+	if m := visibilityMRegexp.FindStringSubmatch(t); m != nil && c.Visibility == nil {
+		meters, _ := strconv.Atoi(m[1])
+		if meters == 9999 {
+			c.Visibility = &Visibility{Miles: VISIBILITY_9999, GreaterThan: true}
+		} else {
+			c.Visibility = &Visibility{Miles: float64(meters) / METERS_PER_SM}
+		}
+		return 1
+	}
+	if m := cloudRegexp.FindStringSubmatch(t); m != nil {
+		layer := CloudLayer{Cover: m[1], Type: m[3]}
+		if m[2] != "///" {
+			base, _ := strconv.Atoi(m[2])
+			layer.Base = base * 100
+		}
+		c.Clouds = append(c.Clouds, layer)
+		return 1
+	}
+	switch t {
+	case "SKC", "CLR", "NSC", "NCD":
+		c.Clouds = append(c.Clouds, CloudLayer{Cover: t})
+		return 1
+	case "NSW":
+		c.Weather = append(c.Weather, t)
+		return 1
+	}
+	if m := weatherRegexp.FindStringSubmatch(t); m != nil && (len(m[2]) > 0 || len(m[3]) > 0) {
+		c.Weather = append(c.Weather, t)
+		return 1
+	}
+	return 0
+}
+
+func parseVisibilitySM(m []string) *Visibility {
+	v := &Visibility{LessThan: m[1] == "M", GreaterThan: m[1] == "P"}
+	n, _ := strconv.Atoi(m[2])
+	if len(m[3]) > 0 {
+		d, _ := strconv.Atoi(m[3])
+		if d > 0 {
+			v.Miles = float64(n) / float64(d)
+		}
+	} else {
+		v.Miles = float64(n)
+	}
+	return v
+}
+
+// Sets the ceiling and the flight category from the parsed groups.
+func (c *Conditions) finish() {
+	for _, l := range c.Clouds {
+		if l.Cover == "BKN" || l.Cover == "OVC" || l.Cover == "VV" {
+			if c.Ceiling == nil || l.Base < *c.Ceiling {
+				base := l.Base
+				c.Ceiling = &base
+			}
+		}
+	}
+	c.FlightCategory = FlightCategory(c.Visibility, c.Ceiling, c.CAVOK)
+}
+
+// Flight category as used by the NWS: LIFR below 500 ft ceiling or 1 SM visibility, IFR below 1000 ft or 3 SM,
+// MVFR up to 3000 ft or 5 SM, VFR above. A missing ceiling means there is none; returns "" if visibility is unknown
+// and there is no ceiling either.
+func FlightCategory(visibility *Visibility, ceiling *int, cavok bool) string {
+	if cavok {
+		return FLIGHT_CATEGORY_VFR
+	}
+	if visibility == nil && ceiling == nil {
+		return ""
+	}
+	vis := math.Inf(1)
+	if visibility != nil {
+		vis = visibility.Miles
+	}
+	cig := math.Inf(1)
+	if ceiling != nil {
+		cig = float64(*ceiling)
+	}
+	switch {
+	case cig < 500 || vis < 1:
+		return FLIGHT_CATEGORY_LIFR
+	case cig < 1000 || vis < 3:
+		return FLIGHT_CATEGORY_IFR
+	case cig <= 3000 || vis <= 5:
+		return FLIGHT_CATEGORY_MVFR
+	}
+	return FLIGHT_CATEGORY_VFR
+}
+
+// Completes a day/hour/minute time with the month and year closest to ref. Hour 24 is the end of the day.
+func resolveDayTime(day, hour, minute int, ref time.Time) time.Time {
+	ref = ref.UTC()
+	best := time.Time{}
+	for m := ref.Month() - 1; m <= ref.Month()+1; m++ {
+		first := time.Date(ref.Year(), m, 1, 0, 0, 0, 0, time.UTC)
+		if day > first.AddDate(0, 1, -1).Day() {
+			continue // no such day in that month
+		}
+		t := first.AddDate(0, 0, day-1).Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute)
+		if best.IsZero() || math.Abs(t.Sub(ref).Hours()) < math.Abs(best.Sub(ref).Hours()) {
+			best = t
+		}
+	}
+	return best
+}
+
+// "281354Z"
+func parseDayTime(s string, ref time.Time) (time.Time, bool) {
+	m := dayTimeRegexp.FindStringSubmatch(s)
+	if m == nil {
+		return time.Time{}, false
+	}
+	day, _ := strconv.Atoi(m[1])
+	hour, _ := strconv.Atoi(m[2])
+	minute, _ := strconv.Atoi(m[3])
+	return resolveDayTime(day, hour, minute, ref), true
+}
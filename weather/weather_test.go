@@ -0,0 +1,389 @@
+package weather
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Text reports as received over FIS-B (product 413) in test-data/example.dump978, recorded on 2015-07-28 around 2215Z.
+var (
+	fisbReceived = time.Date(2015, 7, 28, 22, 15, 0, 0, time.UTC)
+
+	fisbMETARKOLY = "METAR KOLY 282215Z AUTO 01005KT 10SM SCT034 32/26 A2993 RMK AO2 \n LTG DSNT S AND SW=\n"
+	fisbMETARK2I0 = "METAR K2I0 282215Z AUTO 18010G15KT 10SM SCT036 BKN044 28/23 A2994 \n RMK AO2 T02820230=\n"
+	fisbMETARKSLH = "METAR KSLH 282214Z AUTO 05005KT 4SM HZ CLR 27/16 A2996 RMK AO2\n T02760161=\n"
+	fisbSPECIKIOW = "SPECI KIOW 282228Z AUTO 26010KT 2SM +TSRA BR SCT035 BKN065 BKN080 \n    26/24 A2992 RMK AO2 LTG DSNT W-N RAB20 TSB05 P0008 T02610239=\n"
+	fisbSPECIKDCA = "SPECI KDCA 282220Z 16015KT 10SM TS BKN050CB 31/22 A2996 RMK AO2 \n    TSB20 VCSH S OCNL LTGIC VC SE-S-SW TS SE-S-SW MOV S=\n"
+
+	fisbTAFKEKN = "TAF KEKN 281725Z 2818/2918 28006KT P6SM VCTS SCT040CB\n     FM282200 VRB03KT P6SM SCT045\n" +
+		"     FM290500 00000KT 5SM BR SCT045\n     FM290700 00000KT 1/4SM FG VV001\n     FM291200 00000KT 3SM BR BKN003\n" +
+		"     FM291300 00000KT P6SM SCT030\n     FM291600 VRB03KT P6SM VCTS SCT035CB=\n"
+	fisbTAFKFFO = "TAF KFFO 2819/3001 08006KT 9999 FEW030 BKN055 QNH2992INS\n     BECMG 2822/2823 VRB06KT 9999 SCT040 QNH2992INS\n" +
+		"     BECMG 2908/2909 VRB04KT 6000 BR FEW040 QNH2996INS\n     BECMG 2914/2915 35006KT 9999 NSW FEW040 SCT250 QNH2993INS\n" +
+		"     BECMG 2920/2921 22009KT 9000 -SHRA BKN035 OVC120 QNH2991INS\n     TEMPO 2922/3001 4800 -TSRA SCT015 BKN030CB TX33/2820Z\n" +
+		"     TN22/2911Z=\n"
+	fisbTAFKNYG = "TAF.AMD KNYG 2822/2921 VRB06KT 9999 VCTS BKN030CB BKN080 QNH2994INS\n     TEMPO 2822/2901 3200 -TSRA BKN025CB\n" +
+		"     FM290300 VRB04KT 9999 FEW150 QNH3000INS\n     FM290900 VRB04KT 9999 SKC QNH3003INS T23/2910Z T31/2919Z AMD\n     2210=\n"
+	fisbTAFKDPA = "TAF.AMD KDPA 282222Z 2822/2918 20010KT P6SM SCT040 SCT250\n     FM290000 20006KT P6SM SCT050 SCT100 BKN150\n" +
+		"     FM290500 18008KT P6SM VCSH BKN070 BKN100\n     FM290900 26009KT P6SM VCTS SCT020 OVC040CB PROB30 2910/2913 3SM\n" +
+		"      TSRA\n     FM291300 29011G16KT P6SM SCT040 BKN140=\n"
+
+	fisbPIREP = "PIREP RBS 282145Z TIP UA /OV RBS345015/TM 2145/FL220/TP C560/TA M08/IC MOD RIME 220-270/RM MOD RIME FL220-FL270, TEMP 2-M08\n"
+	fisbWINDS = "WINDS PSB 291800Z  FT      6000      9000   12000       18000   24000   30000    34000  39000                         \n" +
+		"        2307+17 2608+10 2809+06 3014-05 3125-16 343330 344240 344952\n"
+)
+
+func fisbTime(day, hour, minute int) time.Time {
+	return time.Date(2015, 7, day, hour, minute, 0, 0, time.UTC)
+}
+
+func float(v float64) *float64 {
+	return &v
+}
+
+func feet(v int) *int {
+	return &v
+}
+
+func equalFloat(a, b *float64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return math.Abs(*a-*b) < 0.001
+}
+
+func equalInt(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func TestParseMETAR(t *testing.T) {
+	tests := []struct {
+		text        string
+		typ         string
+		station     string
+		time        time.Time
+		wind        Wind
+		miles       float64
+		weather     string
+		clouds      int
+		ceiling     *int
+		category    string
+		temperature *float64
+		dewpoint    *float64
+		altimeter   *float64
+		remarks     string
+	}{
+		{fisbMETARKOLY, "METAR", "KOLY", fisbTime(28, 22, 15), Wind{Direction: 10, Speed: 5}, 10, "", 1, nil,
+			FLIGHT_CATEGORY_VFR, float(32), float(26), float(29.93), "AO2 LTG DSNT S AND SW"},
+		{fisbMETARK2I0, "METAR", "K2I0", fisbTime(28, 22, 15), Wind{Direction: 180, Speed: 10, Gust: 15}, 10, "", 2,
+			feet(4400), FLIGHT_CATEGORY_VFR, float(28.2), float(23), float(29.94), "AO2 T02820230"},
+		{fisbMETARKSLH, "METAR", "KSLH", fisbTime(28, 22, 14), Wind{Direction: 50, Speed: 5}, 4, "HZ", 1, nil,
+			FLIGHT_CATEGORY_MVFR, float(27.6), float(16.1), float(29.96), "AO2 T02760161"},
+		{fisbSPECIKIOW, "SPECI", "KIOW", fisbTime(28, 22, 28), Wind{Direction: 260, Speed: 10}, 2, "+TSRA BR", 3,
+			feet(6500), FLIGHT_CATEGORY_IFR, float(26.1), float(23.9), float(29.92),
+			"AO2 LTG DSNT W-N RAB20 TSB05 P0008 T02610239"},
+		{fisbSPECIKDCA, "SPECI", "KDCA", fisbTime(28, 22, 20), Wind{Direction: 160, Speed: 15}, 10, "TS", 1, feet(5000),
+			FLIGHT_CATEGORY_VFR, float(31), float(22), float(29.96), "AO2 TSB20 VCSH S OCNL LTGIC VC SE-S-SW TS SE-S-SW MOV S"},
+	}
+	for _, tt := range tests {
+		m, err := ParseMETAR(tt.text, fisbReceived)
+		if err != nil {
+			t.Errorf("%s: %v", tt.station, err)
+			continue
+		}
+		if m.Type != tt.typ || m.Station != tt.station || !m.Time.Equal(tt.time) || !m.Auto != (tt.station == "KDCA") {
+			t.Errorf("%s: %s %s %v auto %t", tt.station, m.Type, m.Station, m.Time, m.Auto)
+		}
+		if m.Wind == nil || *m.Wind != tt.wind {
+			t.Errorf("%s: wind %+v, want %+v", tt.station, m.Wind, tt.wind)
+		}
+		if m.Visibility == nil || m.Visibility.Miles != tt.miles {
+			t.Errorf("%s: visibility %+v, want %.0f SM", tt.station, m.Visibility, tt.miles)
+		}
+		if w := strings.Join(m.Weather, " "); w != tt.weather {
+			t.Errorf("%s: weather %q, want %q", tt.station, w, tt.weather)
+		}
+		if len(m.Clouds) != tt.clouds || !equalInt(m.Ceiling, tt.ceiling) || m.FlightCategory != tt.category {
+			t.Errorf("%s: clouds %+v ceiling %v category %s", tt.station, m.Clouds, m.Ceiling, m.FlightCategory)
+		}
+		if !equalFloat(m.Temperature, tt.temperature) || !equalFloat(m.Dewpoint, tt.dewpoint) ||
+			!equalFloat(m.Altimeter, tt.altimeter) {
+			t.Errorf("%s: temperature %v dewpoint %v altimeter %v", tt.station, m.Temperature, m.Dewpoint, m.Altimeter)
+		}
+		if m.Remarks != tt.remarks {
+			t.Errorf("%s: remarks %q, want %q", tt.station, m.Remarks, tt.remarks)
+		}
+	}
+}
+
+// Reports that are damaged, incomplete or not METARs at all
+func TestParseMETARMalformed(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		err  error
+	}{
+		{"empty", "", ErrNoStation},
+		{"end of report only", "=\n", ErrNoStation},
+		{"type only", "METAR", ErrNoStation},
+		{"lower case station", "METAR koly 282215Z AUTO 01005KT 10SM=", ErrNoStation},
+		{"no time", "METAR KOLY", ErrNoTime},
+		{"short time", "METAR KOLY 2822Z AUTO 01005KT 10SM=", ErrNoTime},
+		{"PIREP", fisbPIREP, ErrNoStation},
+		{"WINDS", fisbWINDS, ErrNoStation},
+		{"TAF", fisbTAFKEKN, ErrNoTime}, // TAF looks like a station identifier
+	}
+	for _, tt := range tests {
+		if m, err := ParseMETAR(tt.text, fisbReceived); err != tt.err || m != nil {
+			t.Errorf("%s: %v, want %v", tt.name, err, tt.err)
+		}
+	}
+
+	// The groups that can't be parsed are skipped
+	m, err := ParseMETAR("METAR KOLY 282215Z AUTO 01005XT 1O SM SCT03 1/0SM 32/ A29 RMK T0321=", fisbReceived)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Wind != nil || len(m.Clouds) != 0 || m.Altimeter != nil {
+		t.Errorf("wind %+v clouds %+v altimeter %v", m.Wind, m.Clouds, m.Altimeter)
+	}
+	if m.Visibility == nil || m.Visibility.Miles != 0 || m.FlightCategory != FLIGHT_CATEGORY_LIFR {
+		t.Errorf("1/0SM: visibility %+v category %s", m.Visibility, m.FlightCategory)
+	}
+	if !equalFloat(m.Temperature, float(32)) || m.Dewpoint != nil {
+		t.Errorf("temperature %v dewpoint %v", m.Temperature, m.Dewpoint)
+	}
+
+	// Nothing after the time
+	m, err = ParseMETAR("METAR KOLY 282215Z=", fisbReceived)
+	if err != nil || m.Station != "KOLY" || m.Wind != nil || m.Visibility != nil || m.FlightCategory != "" {
+		t.Errorf("time only: %+v %v", m, err)
+	}
+}
+
+func TestParseTAF(t *testing.T) {
+	taf, err := ParseTAF(fisbTAFKEKN, fisbReceived)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if taf.Station != "KEKN" || taf.Amended || !taf.Issued.Equal(fisbTime(28, 17, 25)) ||
+		!taf.ValidFrom.Equal(fisbTime(28, 18, 0)) || !taf.ValidTo.Equal(fisbTime(29, 18, 0)) {
+		t.Errorf("KEKN: %s amended %t issued %v valid %v - %v", taf.Station, taf.Amended, taf.Issued, taf.ValidFrom,
+			taf.ValidTo)
+	}
+	if len(taf.Groups) != 7 || taf.Groups[0].Type != TAF_GROUP_INITIAL || !taf.Groups[0].To.Equal(fisbTime(28, 22, 0)) {
+		t.Fatalf("KEKN: groups %+v", taf.Groups)
+	}
+	fog := taf.Groups[3]
+	if fog.Type != TAF_GROUP_FM || !fog.From.Equal(fisbTime(29, 7, 0)) || !fog.To.Equal(fisbTime(29, 12, 0)) ||
+		fog.Visibility.Miles != 0.25 || !equalInt(fog.Ceiling, feet(100)) || fog.FlightCategory != FLIGHT_CATEGORY_LIFR {
+		t.Errorf("KEKN FM290700: %+v", fog)
+	}
+	if g := taf.Prevailing(fisbTime(29, 8, 0)); g == nil || !g.From.Equal(fog.From) {
+		t.Errorf("KEKN at 0800Z: %+v", g)
+	}
+	if g := taf.Prevailing(fisbTime(28, 23, 0)); g == nil || g.FlightCategory != FLIGHT_CATEGORY_VFR || !g.Wind.Variable {
+		t.Errorf("KEKN at 2300Z: %+v", g)
+	}
+	if g := taf.Prevailing(fisbTime(29, 12, 30)); g == nil || !equalInt(g.Ceiling, feet(300)) ||
+		g.FlightCategory != FLIGHT_CATEGORY_LIFR {
+		t.Errorf("KEKN at 1230Z: %+v", g)
+	}
+
+	// Amended, without issue time, with a TEMPO group and the amendment remark
+	taf, err = ParseTAF(fisbTAFKNYG, fisbReceived)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !taf.Amended || !taf.Issued.IsZero() || !taf.ValidFrom.Equal(fisbTime(28, 22, 0)) ||
+		!taf.ValidTo.Equal(fisbTime(29, 21, 0)) || len(taf.Groups) != 4 {
+		t.Fatalf("KNYG: %+v", taf)
+	}
+	if g := taf.Groups[0]; g.FlightCategory != FLIGHT_CATEGORY_MVFR || !equalInt(g.Ceiling, feet(3000)) ||
+		strings.Join(g.Weather, " ") != "VCTS" {
+		t.Errorf("KNYG initial: %+v", g)
+	}
+	if g := taf.Groups[1]; g.Type != TAF_GROUP_TEMPO || !g.To.Equal(fisbTime(29, 1, 0)) ||
+		g.FlightCategory != FLIGHT_CATEGORY_IFR {
+		t.Errorf("KNYG TEMPO: %+v", g)
+	}
+	if want := "QNH2994INS QNH3000INS QNH3003INS T23/2910Z T31/2919Z AMD 2210"; taf.Remarks != want {
+		t.Errorf("KNYG remarks %q, want %q", taf.Remarks, want)
+	}
+
+	// PROB30 split over two lines
+	taf, err = ParseTAF(fisbTAFKDPA, fisbReceived)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(taf.Groups) != 6 || !taf.Issued.Equal(fisbTime(28, 22, 22)) {
+		t.Fatalf("KDPA: %+v", taf)
+	}
+	if g := taf.Groups[4]; g.Type != TAF_GROUP_PROB || g.Probability != 30 || !g.From.Equal(fisbTime(29, 10, 0)) ||
+		g.Visibility.Miles != 3 || strings.Join(g.Weather, " ") != "TSRA" {
+		t.Errorf("KDPA PROB30: %+v", g)
+	}
+	if g := taf.Groups[5]; g.Type != TAF_GROUP_FM || g.Wind.Gust != 16 || !equalInt(g.Ceiling, feet(14000)) {
+		t.Errorf("KDPA FM291300: %+v", g)
+	}
+}
+
+func TestTAFPrevailingBECMG(t *testing.T) {
+	taf, err := ParseTAF(fisbTAFKFFO, fisbReceived)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(taf.Groups) != 6 || !taf.ValidTo.Equal(fisbTime(30, 1, 0)) {
+		t.Fatalf("KFFO: %+v", taf)
+	}
+	tests := []struct {
+		at       time.Time
+		wind     Wind
+		ceiling  *int
+		category string
+	}{
+		{fisbTime(28, 19, 0), Wind{Direction: 80, Speed: 6}, feet(5500), FLIGHT_CATEGORY_VFR},
+		{fisbTime(28, 22, 30), Wind{Direction: 80, Speed: 6}, feet(5500), FLIGHT_CATEGORY_VFR}, // still becoming
+		{fisbTime(28, 23, 0), Wind{Variable: true, Speed: 6}, nil, FLIGHT_CATEGORY_VFR},
+		{fisbTime(29, 10, 0), Wind{Variable: true, Speed: 4}, nil, FLIGHT_CATEGORY_MVFR}, // 6000 m
+		{fisbTime(29, 21, 30), Wind{Direction: 220, Speed: 9}, feet(3500), FLIGHT_CATEGORY_VFR},
+	}
+	for _, tt := range tests {
+		g := taf.Prevailing(tt.at)
+		if g == nil {
+			t.Errorf("%v: nothing prevailing", tt.at)
+			continue
+		}
+		if *g.Wind != tt.wind || !equalInt(g.Ceiling, tt.ceiling) || g.FlightCategory != tt.category {
+			t.Errorf("%v: wind %+v ceiling %v category %s", tt.at, *g.Wind, g.Ceiling, g.FlightCategory)
+		}
+	}
+	if g := taf.Prevailing(fisbTime(28, 18, 59)); g != nil {
+		t.Errorf("before the validity period: %+v", g)
+	}
+	if g := taf.Prevailing(fisbTime(30, 1, 0)); g != nil {
+		t.Errorf("after the validity period: %+v", g)
+	}
+}
+
+func TestParseTAFMalformed(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		err  error
+	}{
+		{"empty", "", ErrNoStation},
+		{"type only", "TAF.AMD", ErrNoStation},
+		{"no time", "TAF KEKN", ErrNoTime},
+		{"issue time only", "TAF KEKN 281725Z=", ErrNoTime},
+		{"bad period", "TAF KEKN 281725Z 2818 28006KT P6SM=", ErrNoTime},
+		{"PIREP", fisbPIREP, ErrNoStation},
+		{"WINDS", fisbWINDS, ErrNoStation},
+	}
+	for _, tt := range tests {
+		if taf, err := ParseTAF(tt.text, fisbReceived); err != tt.err || taf != nil {
+			t.Errorf("%s: %v, want %v", tt.name, err, tt.err)
+		}
+	}
+
+	// Change groups without a period are skipped, the conditions go to the previous group
+	taf, err := ParseTAF("TAF KEKN 281725Z 2818/2918 28006KT P6SM TEMPO 1/4SM FG PROB30 FM2822 VV001 BECMG", fisbReceived)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(taf.Groups) != 1 || taf.Groups[0].FlightCategory != FLIGHT_CATEGORY_LIFR {
+		t.Errorf("groups %+v", taf.Groups)
+	}
+}
+
+func testNoPanic(t *testing.T, name, text string) {
+	defer func() {
+		if r := recover(); r != nil {
+			t.Errorf("%s: panic on %q: %v", name, text, r)
+		}
+	}()
+	if m, err := ParseMETAR(text, fisbReceived); (m == nil) == (err == nil) ||
+		(err != nil && !errors.Is(err, ErrNoStation) && !errors.Is(err, ErrNoTime)) {
+		t.Errorf("%s: METAR %v, %v from %q", name, m, err, text)
+	}
+	if taf, err := ParseTAF(text, fisbReceived); (taf == nil) == (err == nil) ||
+		(err != nil && !errors.Is(err, ErrNoStation) && !errors.Is(err, ErrNoTime)) {
+		t.Errorf("%s: TAF %v, %v from %q", name, taf, err, text)
+	} else if taf != nil {
+		taf.Prevailing(fisbReceived)
+		taf.Prevailing(taf.ValidFrom)
+	}
+}
+
+// Reports cut off anywhere or missing any group, as happens with incomplete FIS-B uplinks, return an error or a
+// partial result.
+func TestParseTruncated(t *testing.T) {
+	reports := []string{fisbMETARKOLY, fisbMETARK2I0, fisbMETARKSLH, fisbSPECIKIOW, fisbSPECIKDCA, fisbTAFKEKN, fisbTAFKFFO,
+		fisbTAFKNYG, fisbTAFKDPA, fisbPIREP, fisbWINDS}
+	for _, text := range reports {
+		name := strings.Fields(text)[1]
+		for n := 0; n <= len(text); n++ {
+			testNoPanic(t, name, text[:n])
+		}
+		tokens := strings.Fields(text)
+		for i := range tokens {
+			dropped := append(append([]string{}, tokens[:i]...), tokens[i+1:]...)
+			testNoPanic(t, name, strings.Join(dropped, " "))
+		}
+	}
+	for _, text := range []string{"\x00\xff=", "////// //// ///", "= = =", "METAR KOLY 282215Z 1 1/", "METAR KOLY 282215Z 1 1/0SM",
+		"METAR KOLY 992599Z", "TAF KEKN 2899/2999 FM999999 BECMG 0000/0000 PROB99 TEMPO", "TAF KEKN 3124/0124 PROB40 TEMPO",
+		"METAR KOLY 282215Z RMK", "TAF KEKN 2818/2918 RMK"} {
+		testNoPanic(t, "garbage", text)
+	}
+}
+
+func TestFlightCategory(t *testing.T) {
+	tests := []struct {
+		miles    float64 // negative for unknown
+		ceiling  *int
+		cavok    bool
+		category string
+	}{
+		{-1, nil, false, ""},
+		{-1, nil, true, FLIGHT_CATEGORY_VFR},
+		{10, nil, false, FLIGHT_CATEGORY_VFR},
+		{-1, feet(3100), false, FLIGHT_CATEGORY_VFR},
+		{-1, feet(3000), false, FLIGHT_CATEGORY_MVFR},
+		{5, feet(5000), false, FLIGHT_CATEGORY_MVFR},
+		{6, feet(999), false, FLIGHT_CATEGORY_IFR},
+		{2.5, nil, false, FLIGHT_CATEGORY_IFR},
+		{1, feet(500), false, FLIGHT_CATEGORY_IFR},
+		{0.75, feet(5000), false, FLIGHT_CATEGORY_LIFR},
+		{10, feet(400), false, FLIGHT_CATEGORY_LIFR},
+		{10, feet(0), false, FLIGHT_CATEGORY_LIFR},
+	}
+	for _, tt := range tests {
+		var vis *Visibility
+		if tt.miles >= 0 {
+			vis = &Visibility{Miles: tt.miles}
+		}
+		if c := FlightCategory(vis, tt.ceiling, tt.cavok); c != tt.category {
+			t.Errorf("%.2f SM ceiling %v CAVOK %t: %q, want %q", tt.miles, tt.ceiling, tt.cavok, c, tt.category)
+		}
+	}
+}
+
+// The day of month is resolved to the month closest to the reception time
+func TestParseDayTimeMonthChange(t *testing.T) {
+	received := time.Date(2015, 8, 1, 0, 5, 0, 0, time.UTC)
+	m, err := ParseMETAR("METAR KOLY 312355Z AUTO 01005KT 10SM SCT034 32/26 A2993=", received)
+	if err != nil || !m.Time.Equal(fisbTime(31, 23, 55)) {
+		t.Errorf("312355Z: %v %v", m, err)
+	}
+	taf, err := ParseTAF("TAF KEKN 312330Z 0100/0124 28006KT P6SM SCT040=", received)
+	if err != nil || !taf.Issued.Equal(fisbTime(31, 23, 30)) || !taf.ValidTo.Equal(time.Date(2015, 8, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("0100/0124: %v %v", taf, err)
+	}
+}
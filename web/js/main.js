@@ -21,16 +21,24 @@ var URL_SHUTDOWN            = URL_HOST_PROTOCOL + URL_HOST_BASE + "/shutdown";
 var URL_STATUS_GET          = URL_HOST_PROTOCOL + URL_HOST_BASE + "/getStatus";
 var URL_TOWERS_GET          = URL_HOST_PROTOCOL + URL_HOST_BASE + "/getTowers";
 var URL_UPDATE_UPLOAD       = URL_HOST_PROTOCOL + URL_HOST_BASE + "/updateUpload";
+var URL_AIRCRAFTDB_UPLOAD   = URL_HOST_PROTOCOL + URL_HOST_BASE + "/uploadAircraftDB";
 var URL_GET_SITUATION       = URL_HOST_PROTOCOL + URL_HOST_BASE + "/getSituation";
 var URL_GET_TILESETS        = URL_HOST_PROTOCOL + URL_HOST_BASE + "/tiles/tilesets";
 var URL_GET_TILE            = URL_HOST_PROTOCOL + URL_HOST_BASE + "/tiles";
 var URL_GET_STYLE           = URL_HOST_PROTOCOL + URL_HOST_BASE + "/mapdata/styles"
+var URL_GET_IGC_FILES       = URL_HOST_PROTOCOL + URL_HOST_BASE + "/getIGCFiles";
+var URL_GET_FLIGHTS         = URL_HOST_PROTOCOL + URL_HOST_BASE + "/getFlights";
+var URL_EXPORT_FLIGHT       = URL_HOST_PROTOCOL + URL_HOST_BASE + "/exportFlight";
+var URL_WEATHER_OVERLAYS    = URL_HOST_PROTOCOL + URL_HOST_BASE + "/weather/overlays";
+var URL_AIRSPACE_RESTRICTIONS = URL_HOST_PROTOCOL + URL_HOST_BASE + "/weather/restrictions";
+var URL_GET_METARS          = URL_HOST_PROTOCOL + URL_HOST_BASE + "/getMetars";
 
 
 var URL_DEVELOPER_WS        = "ws://" + URL_HOST_BASE + "/developer";
 var URL_GPS_WS              = "ws://" + URL_HOST_BASE + "/situation";
 var URL_STATUS_WS           = "ws://" + URL_HOST_BASE + "/status";
 var URL_TRAFFIC_WS          = "ws://" + URL_HOST_BASE + "/traffic";
+var URL_TRAFFIC_EVENTS_WS   = "ws://" + URL_HOST_BASE + "/trafficEvents";
 var URL_WEATHER_WS          = "ws://" + URL_HOST_BASE + "/weather";
 var URL_RADAR_WS            = "ws://" + URL_HOST_BASE + "/radar";
 
@@ -288,7 +296,24 @@ app.controller('MainCtrl', function ($scope, $http) {
 		return category[aircraft.Emitter_category]?category[aircraft.Emitter_category]:'---';
 	};
 
+	const getEmergencyDescription = (status) => {
+		// GDL90 / DO-260B emergency status, see emergency.go
+		const emergency = {
+			1: 'General emergency',
+			2: 'Lifeguard / medical emergency',
+			3: 'Minimum fuel',
+			4: 'No communication',
+			5: 'Unlawful interference',
+			6: 'Downed aircraft'
+		};
+		return emergency[status]?emergency[status]:'';
+	};
+
 	return {
+		getEmergencyDescription: (status) => {
+			return getEmergencyDescription(status);
+		},
+
 		getCategory: (craft) => {
 			if (craft.TargetType === TARGET_TYPE_AIS) {
 				return getVesselCategory(craft);
@@ -61,6 +61,11 @@ function DeveloperCtrl($rootScope, $scope, $state, $http, $interval) {
             $scope.UAT_SIGMET_total = status.UAT_SIGMET_total;
             $scope.UAT_PIREP_total = status.UAT_PIREP_total;
             $scope.UAT_NOTAM_total = status.UAT_NOTAM_total;
+            $scope.UAT_SUA_total = status.UAT_SUA_total;
+            $scope.UAT_LIGHTNING_total = status.UAT_LIGHTNING_total;
+            $scope.UAT_ICING_total = status.UAT_ICING_total;
+            $scope.UAT_TURBULENCE_total = status.UAT_TURBULENCE_total;
+            $scope.UAT_CLOUDTOPS_total = status.UAT_CLOUDTOPS_total;
             $scope.UAT_OTHER_total = status.UAT_OTHER_total;
             $scope.Logfile_Size = humanFileSize(status.Logfile_Size);
             $scope.AHRS_LogFiles_Size = humanFileSize(status.AHRS_LogFiles_Size);
@@ -8,4 +8,19 @@ function LogsCtrl($scope, $state, $http) {
 	// just a couple environment variables that may bve useful for dev/debugging but otherwise not significant
 	$scope.userAgent = navigator.userAgent;
     $scope.deviceViewport = 'screen = ' + window.screen.width + ' x ' + window.screen.height;
+
+	$scope.igcFiles = [];
+	$http.get(URL_GET_IGC_FILES).then(function (response) {
+		$scope.igcFiles = angular.fromJson(response.data);
+	});
+
+	$scope.flights = [];
+	$scope.exportTraffic = false;
+	$http.get(URL_GET_FLIGHTS).then(function (response) {
+		$scope.flights = angular.fromJson(response.data).reverse();
+	});
+
+	$scope.flightExportURL = function (flight, format) {
+		return URL_EXPORT_FLIGHT + '?id=' + flight.ID + '&format=' + format + ($scope.exportTraffic ? '&traffic=1' : '');
+	};
 }
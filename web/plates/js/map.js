@@ -27,6 +27,7 @@ function MapCtrl($rootScope, $scope, $state, $http, $interval, craftService) {
 	
 
 	// Dynamic MBTiles layers
+	$scope.tileRefresh = [];
 	$http.get(URL_GET_TILESETS).then(function(response) {
 		var tilesets = angular.fromJson(response.data);
 		for (let file in tilesets) {
@@ -77,6 +78,12 @@ function MapCtrl($rootScope, $scope, $state, $http, $interval, craftService) {
 					})						
 				});
 			}
+			// Rendered on demand (NEXRAD), reload periodically
+			if (meta.stratux_refresh) {
+				$scope.tileRefresh.push($interval(function() {
+					layer.getSource().refresh();
+				}, parseInt(meta.stratux_refresh) * 1000));
+			}
 			if (baselayer)
 				$scope.map.getLayers().insertAt(0, layer);
 			else
@@ -84,6 +91,9 @@ function MapCtrl($rootScope, $scope, $state, $http, $interval, craftService) {
 		}
 		$scope.map.addLayer(aircraftSymbolsLayer);
 		$scope.map.addLayer(aircraftTrailsLayer);
+		$scope.map.addLayer(weatherOverlaysLayer);
+		$scope.map.addLayer(restrictionsLayer);
+		$scope.map.addLayer(metarsLayer);
 
 		// Restore layer visibility
 		$scope.map.getLayers().forEach((layer) => {
@@ -119,6 +129,144 @@ function MapCtrl($rootScope, $scope, $state, $http, $interval, craftService) {
 		zIndex: 9
 	});
 
+	// FIS-B AIRMET/SIGMET/G-AIRMET/CWA/NOTAM/SUA overlays, see weatheroverlays.go
+	const WEATHER_OVERLAY_COLORS = {
+		8: [230, 126, 34],  // NOTAM
+		11: [41, 128, 185], // AIRMET
+		12: [192, 57, 43], // SIGMET
+		13: [142, 68, 173], // SUA
+		14: [22, 160, 133], // G-AIRMET
+		15: [211, 84, 0], // CWA
+		16: [127, 140, 141], // NOTAM-TRA
+		17: [231, 76, 60]  // NOTAM-TFR
+	};
+	$scope.weatherOverlays = new ol.source.Vector();
+	let weatherOverlaysLayer = new ol.layer.Vector({
+		title: 'FIS-B weather overlays',
+		type: 'overlay',
+		visible: false,
+		source: $scope.weatherOverlays,
+		zIndex: 5,
+		style: function(feature) {
+			const color = WEATHER_OVERLAY_COLORS[feature.get('product_id')] || [85, 85, 85];
+			return new ol.style.Style({
+				stroke: new ol.style.Stroke({ color: color, width: 2 }),
+				fill: new ol.style.Fill({ color: color.concat([0.15]) }),
+				image: new ol.style.Circle({
+					radius: 5,
+					stroke: new ol.style.Stroke({ color: color, width: 2 })
+				}),
+				text: new ol.style.Text({
+					text: feature.get('product') + ' ' + feature.get('location'),
+					font: '10px sans-serif',
+					overflow: true
+				})
+			});
+		}
+	});
+
+	function updateWeatherOverlays() {
+		if (!weatherOverlaysLayer.getVisible())
+			return;
+		$http.get(URL_WEATHER_OVERLAYS).then(function(response) {
+			const features = new ol.format.GeoJSON().readFeatures(response.data, {
+				featureProjection: 'EPSG:3857'
+			});
+			$scope.weatherOverlays.clear();
+			$scope.weatherOverlays.addFeatures(features);
+		});
+	}
+	weatherOverlaysLayer.on('change:visible', updateWeatherOverlays);
+	$scope.weatherOverlaysUpdate = $interval(updateWeatherOverlays, 60000);
+
+	// Active TFRs and other airspace restrictions, see airspace.go
+	$scope.restrictions = new ol.source.Vector();
+	let restrictionsLayer = new ol.layer.Vector({
+		title: 'TFRs / airspace restrictions',
+		type: 'overlay',
+		source: $scope.restrictions,
+		zIndex: 6,
+		style: function(feature) {
+			const incursion = feature.get('incursion');
+			let limits = feature.get('altitude_bottom') > 0 ? feature.get('altitude_bottom') + 'ft' : 'SFC';
+			limits += '-' + (feature.get('altitude_top') > 0 ? feature.get('altitude_top') + 'ft' : 'UNL');
+			return new ol.style.Style({
+				stroke: new ol.style.Stroke({ color: [200, 0, 0], width: 2, lineDash: [6, 4] }),
+				fill: new ol.style.Fill({ color: [200, 0, 0, incursion ? 0.4 : 0.1] }),
+				text: new ol.style.Text({
+					text: feature.get('id') + '\n' + limits,
+					font: 'bold 10px sans-serif',
+					fill: new ol.style.Fill({ color: [200, 0, 0] }),
+					overflow: true
+				})
+			});
+		}
+	});
+
+	function updateRestrictions() {
+		if (!restrictionsLayer.getVisible())
+			return;
+		$http.get(URL_AIRSPACE_RESTRICTIONS + '?active=1&area=1').then(function(response) {
+			const features = new ol.format.GeoJSON().readFeatures(response.data, {
+				featureProjection: 'EPSG:3857'
+			});
+			$scope.restrictions.clear();
+			$scope.restrictions.addFeatures(features);
+		});
+	}
+	restrictionsLayer.on('change:visible', updateRestrictions);
+	updateRestrictions();
+	$scope.restrictionsUpdate = $interval(updateRestrictions, 10000);
+
+	// Flight category of the stations with a METAR (or TAF), see metars.go
+	const FLIGHT_CATEGORY_COLORS = {
+		'VFR': [0, 160, 0],
+		'MVFR': [0, 80, 255],
+		'IFR': [230, 0, 0],
+		'LIFR': [200, 0, 200]
+	};
+	$scope.metars = new ol.source.Vector();
+	let metarsLayer = new ol.layer.Vector({
+		title: 'METAR flight categories',
+		type: 'overlay',
+		source: $scope.metars,
+		zIndex: 7,
+		style: function(feature) {
+			const color = FLIGHT_CATEGORY_COLORS[feature.get('category')] || [128, 128, 128];
+			return new ol.style.Style({
+				image: new ol.style.Circle({
+					radius: 6,
+					fill: new ol.style.Fill({ color: color }),
+					stroke: new ol.style.Stroke({ color: [255, 255, 255], width: 1 })
+				}),
+				text: new ol.style.Text({
+					text: feature.get('station'),
+					font: '10px sans-serif',
+					offsetY: -12
+				})
+			});
+		}
+	});
+
+	function updateMetars() {
+		if (!metarsLayer.getVisible())
+			return;
+		$http.get(URL_GET_METARS).then(function(response) {
+			$scope.metars.clear();
+			response.data.forEach((sw) => {
+				if (!sw.Position_valid)
+					return;
+				$scope.metars.addFeature(new ol.Feature({
+					geometry: new ol.geom.Point(ol.proj.fromLonLat([sw.Lng, sw.Lat])),
+					station: sw.Station,
+					category: sw.FlightCategory
+				}));
+			});
+		});
+	}
+	metarsLayer.on('change:visible', updateMetars);
+	$scope.metarsUpdate = $interval(updateMetars, 60000);
+
 	$scope.map = new ol.Map({
 		target: 'map_display',
 		layers: [
@@ -525,6 +673,10 @@ function MapCtrl($rootScope, $scope, $state, $http, $interval, craftService) {
 		}
 		// stop stale traffic cleanup
 		$interval.cancel($scope.update);
+		$interval.cancel($scope.weatherOverlaysUpdate);
+		$interval.cancel($scope.restrictionsUpdate);
+		$interval.cancel($scope.metarsUpdate);
+		$scope.tileRefresh.forEach((promise) => $interval.cancel(promise));
 	}
 
 
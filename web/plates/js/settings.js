@@ -260,7 +260,7 @@ function SettingsCtrl($rootScope, $scope, $state, $location, $window, $http) {
 
 	$scope.$parent.helppage = 'plates/settings-help.html';
 
-	var toggles = ['UAT_Enabled', 'ES_Enabled', 'OGN_Enabled', 'AIS_Enabled', 'APRS_Enabled', 'Ping_Enabled', 'OGNI2CTXEnabled', 'GPS_Enabled', 'IMU_Sensor_Enabled',
+	var toggles = ['UAT_Enabled', 'ES_Enabled', 'OGN_Enabled', 'AIS_Enabled', 'APRS_Enabled', 'GDL90In_Enabled', 'MAVLink_Enabled', 'CoT_Enabled', 'ASTERIX_Enabled', 'MQTT_Enabled', 'AISOut_Enabled', 'AISOutAircraft', 'IGC_Enabled', 'Ping_Enabled', 'OGNI2CTXEnabled', 'GPS_Enabled', 'IMU_Sensor_Enabled',
 		'BMP_Sensor_Enabled', 'DisplayTrafficSource', 'DEBUG', 'ReplayLog', 'TraceLog', 'AHRSLog', 'PersistentLogging', 'GDL90MSLAlt_Enabled', 'EstimateBearinglessDist', 'DarkMode'];
 
 	var settings = {};
@@ -268,6 +268,7 @@ function SettingsCtrl($rootScope, $scope, $state, $location, $window, $http) {
 		settings[toggles[i]] = undefined;
 	}
 	$scope.update_files = '';
+	$scope.aircraftdb_files = '';
 
 	$http.get(URL_STATUS_GET).then(function(response) {
 		var status = angular.fromJson(response.data);
@@ -295,6 +296,21 @@ function SettingsCtrl($rootScope, $scope, $state, $location, $window, $http) {
 		$scope.OGN_Enabled = settings.OGN_Enabled;
 		$scope.AIS_Enabled = settings.AIS_Enabled;
 		$scope.APRS_Enabled = settings.APRS_Enabled;
+		$scope.GDL90In_Enabled = settings.GDL90In_Enabled;
+		$scope.GDL90InPort = settings.GDL90InPort;
+		$scope.MAVLink_Enabled = settings.MAVLink_Enabled;
+		$scope.MAVLinkPort = settings.MAVLinkPort;
+		$scope.CoT_Enabled = settings.CoT_Enabled;
+		$scope.ASTERIX_Enabled = settings.ASTERIX_Enabled;
+		$scope.ASTERIXPort = settings.ASTERIXPort;
+		$scope.MQTT_Enabled = settings.MQTT_Enabled;
+		$scope.MQTTBroker = settings.MQTTBroker;
+		$scope.MQTTQoS = settings.MQTTQoS;
+		$scope.AISOut_Enabled = settings.AISOut_Enabled;
+		$scope.AISOutPort = settings.AISOutPort;
+		$scope.AISOutAircraft = settings.AISOutAircraft;
+		$scope.IGC_Enabled = settings.IGC_Enabled;
+		$scope.IGCInterval = settings.IGCInterval;
 		$scope.Ping_Enabled = settings.Ping_Enabled;
 		$scope.GPS_Enabled = settings.GPS_Enabled;
 		$scope.OGNI2CTXEnabled = settings.OGNI2CTXEnabled;
@@ -430,6 +446,16 @@ function SettingsCtrl($rootScope, $scope, $state, $location, $window, $http) {
 		}
 	};
 
+	$scope.updateMQTTQoS = function () {
+		if ($scope.MQTTQoS !== undefined && $scope.MQTTQoS !== null) {
+			settings["MQTTQoS"] = parseInt($scope.MQTTQoS);
+			var newsettings = {
+				"MQTTQoS": settings["MQTTQoS"]
+			};
+			setSettings(angular.toJson(newsettings));
+		}
+	};
+
 	$scope.updatePWMDutyMin = function() {
 		settings['PWMDutyMin'] = 0;
 		if ($scope.PWMDutyMin !== undefined && $scope.PWMDutyMin !== null) {
@@ -441,6 +467,16 @@ function SettingsCtrl($rootScope, $scope, $state, $location, $window, $http) {
 		}
 	}
 
+	$scope.updateIGCInterval = function() {
+		if ($scope.IGCInterval !== undefined && $scope.IGCInterval !== null && $scope.IGCInterval >= 1) {
+			settings['IGCInterval'] = parseInt($scope.IGCInterval);
+			var newsettings = {
+				'IGCInterval': settings['IGCInterval']
+			};
+			setSettings(angular.toJson(newsettings));
+		}
+	}
+
 	$scope.updateBaud = function () {
 		settings["Baud"] = 0;
 		if ($scope.Baud !== undefined && $scope.Baud !== null) {
@@ -583,6 +619,39 @@ function SettingsCtrl($rootScope, $scope, $state, $location, $window, $http) {
 		});
 	};
 
+	$scope.setAircraftDBFile = function (files) {
+		$scope.aircraftdb_files = files;
+		$scope.$apply();
+	};
+
+	$scope.uploadAircraftDB = function () {
+		var fd = new FormData();
+		var file = $scope.aircraftdb_files[0];
+		if (file === undefined || file === null) {
+			alert ("aircraft database file not selected");
+			return;
+		}
+
+		fd.append("aircraftdb_file", file);
+		$scope.uploading_aircraftdb = true;
+		$scope.$apply();
+
+		$http.post(URL_AIRCRAFTDB_UPLOAD, fd, {
+			withCredentials: true,
+			headers: {
+				'Content-Type': undefined
+			},
+			transformRequest: angular.identity
+		}).success(function (data) {
+			$scope.uploading_aircraftdb = false;
+			$scope.aircraftdb_files = '';
+			alert("Aircraft database installed with " + data.Records + " aircraft.");
+		}).error(function (data) {
+			$scope.uploading_aircraftdb = false;
+			alert("Aircraft database upload failed: " + data);
+		});
+	};
+
 	$scope.setOrientation = function(action) {
 		// console.log("sending " + action + " message.");
 		$http.post(URL_AHRS_ORIENT, action).
@@ -134,6 +134,9 @@ function StatusCtrl($rootScope, $scope, $state, $http, $interval, craftService)
 				case 1:
 					tempGpsProtocolString = "NMEA protocol";
 					break;
+				case 2:
+					tempGpsProtocolString = "GDL90 ownship";
+					break;
 				default:
 					tempGpsProtocolString = "Not communicating";
 			}
@@ -148,6 +151,11 @@ function StatusCtrl($rootScope, $scope, $state, $http, $interval, craftService)
 			$scope.UAT_SIGMET_total = status.UAT_SIGMET_total;
 			$scope.UAT_PIREP_total = status.UAT_PIREP_total;
 			$scope.UAT_NOTAM_total = status.UAT_NOTAM_total;
+			$scope.UAT_SUA_total = status.UAT_SUA_total;
+			$scope.UAT_LIGHTNING_total = status.UAT_LIGHTNING_total;
+			$scope.UAT_ICING_total = status.UAT_ICING_total;
+			$scope.UAT_TURBULENCE_total = status.UAT_TURBULENCE_total;
+			$scope.UAT_CLOUDTOPS_total = status.UAT_CLOUDTOPS_total;
 			$scope.UAT_OTHER_total = status.UAT_OTHER_total;
 			// Errors array.
 			if (status.Errors.length > 0) {
@@ -8,6 +8,7 @@ function TrafficCtrl($rootScope, $scope, $state, $http, $interval, craftService)
 	$scope.$parent.helppage = 'plates/traffic-help.html';
 	$scope.data_list = [];
 	$scope.data_list_invalid = [];
+	$scope.emergencies = [];
 
 	$scope.$parent.esStyleColor = craftService.getTrafficSourceColor(1);
 	$scope.$parent.uatStyleColor = craftService.getTrafficSourceColor(2);
@@ -56,8 +57,10 @@ function TrafficCtrl($rootScope, $scope, $state, $http, $interval, craftService)
 		new_traffic.icao_int = obj.Icao_addr;
 		new_traffic.isStratux = obj.IsStratux;
 		new_traffic.signal = obj.SignalLevel;
-		new_traffic.Last_source = obj.Last_source; // 1=ES, 2=UAT, 4=OGN, 8=AIS
+		new_traffic.Last_source = obj.Last_source; // 1=ES, 2=UAT, 4=OGN, 8=AIS, 16=GDL90
+		new_traffic.sources = obj.Sources; // tracks this target was fused from, if any
 		new_traffic.Emitter_category = obj.Emitter_category;
+		new_traffic.emergency = obj.Emergency; // 0 = none, see emergency.go
 		//console.log('Emitter Category:' + obj.Emitter_category);
 		
 		new_traffic.icao = obj.Icao_addr.toString(16).toUpperCase();
@@ -173,6 +176,16 @@ function TrafficCtrl($rootScope, $scope, $state, $http, $interval, craftService)
 				}
 			}
 			
+			if (message.Fused) {
+				// This track was fused into another target - only show that one
+				if (validIdx >= 0)
+					$scope.data_list.splice(validIdx, 1);
+				if (invalidIdx >= 0)
+					$scope.data_list_invalid.splice(invalidIdx, 1);
+				$scope.$apply();
+				return;
+			}
+
 			if ((validIdx < 0) && (message.Position_valid)) {
 				var new_traffic = {};
 				setAircraft(message, new_traffic);
@@ -201,6 +214,38 @@ function TrafficCtrl($rootScope, $scope, $state, $http, $interval, craftService)
 		};
 	}
 
+	// Emergency events (squawk 7500/7600/7700 or DO-260B emergency status) from the /trafficEvents websocket
+	function connectEvents($scope) {
+		if (($scope === undefined) || ($scope === null))
+			return;
+
+		if (($scope.eventSocket === undefined) || ($scope.eventSocket === null)) {
+			$scope.eventSocket = new WebSocket(URL_TRAFFIC_EVENTS_WS);
+		}
+
+		$scope.eventSocket.onclose = function (msg) {
+			if ($scope.eventSocket !== null)
+				setTimeout(function() { $scope.eventSocket = null; connectEvents($scope); }, 1000);
+		};
+
+		$scope.eventSocket.onmessage = function (msg) {
+			var ev = JSON.parse(msg.data);
+			if (ev.Type !== 'emergency' && ev.Type !== 'emergency_end')
+				return;
+			for (var i = $scope.emergencies.length; i > 0; i--) {
+				if (isSameAircraft($scope.emergencies[i - 1].Icao_addr, $scope.emergencies[i - 1].Addr_type, ev.Icao_addr, ev.Addr_type))
+					$scope.emergencies.splice(i - 1, 1);
+			}
+			if (ev.Type === 'emergency') {
+				ev.icao = ev.Icao_addr.toString(16).toUpperCase();
+				ev.time = utcTimeString(Date.parse(ev.Time));
+				ev.description = craftService.getEmergencyDescription(ev.Emergency);
+				$scope.emergencies.unshift(ev);
+			}
+			$scope.$apply();
+		};
+	}
+
 	var getClock = $interval(function () {
 		$http.get(URL_STATUS_GET).
 		then(function (response) {
@@ -256,6 +301,11 @@ function TrafficCtrl($rootScope, $scope, $state, $http, $interval, craftService)
 			$scope.socket.close();
 			$scope.socket = null;
 		}
+		if (($scope.eventSocket !== undefined) && ($scope.eventSocket !== null)) {
+			var eventSocket = $scope.eventSocket;
+			$scope.eventSocket = null;
+			eventSocket.close();
+		}
 		// stop stale traffic cleanup
 		$interval.cancel(clearStaleTraffic);
 		$interval.cancel(getClock);
@@ -263,4 +313,5 @@ function TrafficCtrl($rootScope, $scope, $state, $http, $interval, craftService)
 
 	// Traffic Controller tasks
 	connect($scope); // connect - opens a socket and listens for messages
+	connectEvents($scope);
 };
@@ -189,6 +189,8 @@ function WeatherCtrl($rootScope, $scope, $state, $http, $interval) {
 
 			$scope.raw_data = angular.toJson(msg.data, true);
 			var message = JSON.parse(msg.data);
+			if (message.Blocks)
+				return; // lightning, icing, turbulence and cloud tops grids are for map displays
 			// we need to use an array so AngularJS can perform sorting; it also means we need to loop to find an aircraft in the data_list set
 			var found = false;
 			if (inList(message.Location, $scope.watching)) {